@@ -2,55 +2,218 @@ package main
 
 import (
 	"context"
-	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/piyushpatra/rate-limiter/internal/api"
 	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/events"
 	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/piyushpatra/rate-limiter/internal/pb"
+	"github.com/piyushpatra/rate-limiter/internal/profiles"
 	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/snapshot"
+	"github.com/piyushpatra/rate-limiter/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	log.Println("Starting Rate Limiter Service...")
-
-	// Load configuration
+	// Load configuration first so even the startup lines use LOG_FORMAT
 	cfg := config.Load()
-	log.Printf("Config loaded: Redis=%s, Port=%s", cfg.RedisAddr, cfg.ServerPort)
+	logging.Setup(cfg)
+	logging.Println("Starting Rate Limiter Service...")
+
+	if err := cfg.Validate(); err != nil {
+		logging.Fatalf("Invalid configuration: %v", err)
+	}
+	logging.Printf("Config loaded: Redis=%s, Port=%s", cfg.RedisTarget(), cfg.ServerPort)
+
+	if cfg.DefaultAlgorithm != "" && !limiter.IsSupported(cfg.DefaultAlgorithm) {
+		logging.Fatalf("Invalid DEFAULT_ALGORITHM: %q", cfg.DefaultAlgorithm)
+	}
+
+	if cfg.AllowClientTimestamps {
+		if cfg.ClientTimestampsEnabled() {
+			logging.Printf("⚠️  Client-supplied timestamps (now_ms) enabled - %s environment, testing only", cfg.Environment)
+		} else {
+			logging.Println("⚠️  ALLOW_CLIENT_TIMESTAMPS ignored in production")
+		}
+	}
+
+	// Validate TLS material before doing anything else - fail fast on bad paths
+	tlsCfg, err := cfg.ServerTLSConfig()
+	if err != nil {
+		logging.Fatalf("Invalid TLS configuration: %v", err)
+	}
+	if _, err := cfg.RedisTLSConfig(); err != nil {
+		logging.Fatalf("Invalid Redis TLS configuration: %v", err)
+	}
+
+	// Tracing (opt-in) - installed before anything creates spans
+	if cfg.OTelEnabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+		if err != nil {
+			logging.Fatalf("Invalid tracing configuration: %v", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logging.Printf("Tracing shutdown error: %v", err)
+			}
+		}()
+		logging.Printf("Tracing enabled: exporting to %s", cfg.OTelEndpoint)
+	}
+
+	// Background jobs share one context so shutdown stops them together
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Every collector, registered with the default registry that /metrics serves
+	m := metrics.New(prometheus.DefaultRegisterer, cfg.CheckLatencyBuckets, cfg.RedisLatencyBuckets)
+
+	// Batch the per-check metrics instead of updating shared collectors on every request
+	if cfg.MetricsFlushInterval > 0 {
+		go m.RunBuffer(bgCtx, cfg.MetricsFlushInterval)
+	}
 
 	// Initialize Redis client
-	redis, err := redisclient.NewClient(cfg)
+	redis, err := redisclient.NewClient(cfg, m)
 	if err != nil {
-		log.Printf("⚠️  Warning: Failed to connect to Redis: %v", err)
-		log.Println("🔓 Running in FAIL-OPEN mode - all requests will be allowed")
-		log.Println("   (This demonstrates the fail-open strategy)")
-		log.Println("   To run with Redis: docker run -d -p 6379:6379 redis:7-alpine")
+		logging.Printf("⚠️  Warning: Failed to connect to Redis: %v", err)
+		logging.Println("🔓 Running in FAIL-OPEN mode - all requests will be allowed")
+		logging.Printf("   Retrying Redis every %v in the background", cfg.RedisReconnectInterval)
+		logging.Println("   To run with Redis: docker run -d -p 6379:6379 redis:7-alpine")
+
+		redis = redisclient.NewDisconnectedClient(cfg, m)
+		go redis.Reconnect(bgCtx, cfg.RedisReconnectInterval)
 	} else {
-		defer redis.Close()
-		log.Println("✅ Redis connected successfully")
+		logging.Println("✅ Redis connected successfully")
+	}
+
+	if cfg.RedisPoolStatsInterval > 0 {
+		go redis.RecordPoolStats(bgCtx, cfg.RedisPoolStatsInterval)
+	}
+
+	// Periodic analytics snapshots (opt-in, ticks fail and retry until Redis is up)
+	if cfg.SnapshotInterval > 0 {
+		collector, err := snapshot.NewCollector(redis, cfg)
+		if err != nil {
+			logging.Fatalf("Invalid snapshot configuration: %v", err)
+		}
+		go collector.Run(bgCtx)
+		logging.Printf("Snapshots enabled: every %v to %s sink", cfg.SnapshotInterval, cfg.SnapshotSink)
+	}
+
+	// Forward key expiry (limit reset) notifications (opt-in)
+	// Started even while Redis is down - it subscribes once Redis is back
+	if cfg.KeyspaceEventsEnabled {
+		go events.NewExpiryForwarder(redis, cfg).Run(bgCtx)
+		logging.Println("Keyspace expiry events enabled")
 	}
 
 	// Initialize rate limiter
-	rateLimiter := limiter.NewLimiter(redis)
+	rateLimiter := limiter.NewLimiter(redis, cfg, m)
+
+	// Server-side routing rules for checks that don't name an algorithm (opt-in)
+	router, err := limiter.LoadRouter(cfg.RoutingRulesFile)
+	if err != nil {
+		logging.Fatalf("Invalid routing rules file: %v", err)
+	}
+	if router != nil {
+		rateLimiter.SetRouter(router)
+		logging.Printf("Routing rules loaded from %s", cfg.RoutingRulesFile)
+	}
+
+	// Capacity factors are read from Redis on a timer so checks never wait on them
+	if adaptive := rateLimiter.Adaptive(); adaptive != nil {
+		go adaptive.Run(bgCtx, cfg.AdaptiveRefreshInterval)
+		logging.Printf("Adaptive limits enabled: factors refreshed every %v", cfg.AdaptiveRefreshInterval)
+	}
+
+	// The offset from Redis TIME is measured on a timer so checks never wait on it
+	if clock := rateLimiter.RedisClock(); clock != nil {
+		go clock.Run(bgCtx, cfg.ClockSyncInterval)
+		logging.Printf("Clock synced with Redis TIME every %v", cfg.ClockSyncInterval)
+	}
+
+	// Load scripts now rather than on the first request after a deploy
+	warmupCtx, cancelWarmup := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := rateLimiter.Warmup(warmupCtx); err != nil {
+		logging.Printf("Script warmup skipped: %v", err)
+	} else {
+		logging.Println("Lua scripts loaded into Redis")
+	}
+	cancelWarmup()
+
+	// Named limit profiles (opt-in)
+	limitProfiles, err := profiles.Load(cfg.ProfilesFile)
+	if err != nil {
+		logging.Fatalf("Invalid profiles file: %v", err)
+	}
+	if cfg.ProfilesFile != "" {
+		logging.Printf("Limit profiles loaded from %s (version %s)", cfg.ProfilesFile, limitProfiles.Version())
+		if cfg.ProfilesReloadInterval > 0 {
+			go limitProfiles.Watch(bgCtx, cfg.ProfilesFile, cfg.ProfilesReloadInterval)
+		}
+	}
 
 	// Initialize HTTP handlers
-	handler := api.NewHandler(rateLimiter, redis)
+	handler := api.NewHandler(rateLimiter, redis, cfg, m, limitProfiles)
 
 	// Set up router with middleware
 	mux := http.NewServeMux()
 	
 	// API endpoints
 	mux.HandleFunc("/check", handler.HandleCheck)
+	mux.HandleFunc("/check/batch", handler.HandleCheckBatch)
+	mux.HandleFunc("/check/multi", handler.HandleCheckMulti)
+	mux.Handle("/check/stream", handler.HandleCheckStream())
+	mux.HandleFunc("/peek", handler.HandlePeek)
+	mux.HandleFunc("/algorithms", handler.HandleAlgorithms)
+	mux.HandleFunc("/acquire", handler.HandleAcquire)
+	mux.HandleFunc("/release", handler.HandleRelease)
 	mux.HandleFunc("/health", handler.HandleHealth)
+	mux.HandleFunc("/livez", handler.HandleLivez)
+	mux.HandleFunc("/readyz", handler.HandleReadyz)
+	mux.HandleFunc("/auth", handler.HandleAuthRequest)
+	mux.HandleFunc("/fleet", handler.HandleFleet)
+	mux.HandleFunc("/fleet/local", handler.HandleFleetLocal)
+	mux.HandleFunc("/stats", handler.HandleStats)
 	mux.Handle("/metrics", handler.HandleMetrics())
+	mux.HandleFunc("/watch/metrics", handler.HandleWatchMetrics)
+
+	// Admin endpoints (opt-in)
+	if cfg.ScriptReloadEnabled {
+		mux.HandleFunc("/admin/scripts/reload", handler.HandleReloadScripts)
+	}
+	if cfg.ConfigEndpointEnabled {
+		mux.HandleFunc("/config", handler.HandleConfig)
+	}
+	if cfg.AdaptiveEnabled {
+		mux.HandleFunc("/adaptive/factor", handler.HandleAdaptiveFactor)
+	}
+	if cfg.AdminToken != "" {
+		mux.HandleFunc("/reset/bulk", handler.HandleBulkReset)
+	} else if cfg.ScriptReloadEnabled || cfg.ConfigEndpointEnabled || cfg.AdaptiveEnabled {
+		logging.Printf("⚠️  Warning: ADMIN_TOKEN is not set, so admin endpoints under ADMIN_PROTECTED_PATHS will refuse every request")
+	}
+
+	// Counts requests on both transports so shutdown can report what it's waiting for
+	inFlight := &api.InFlight{}
 
 	// Apply middleware chain
-	// Recovery -> CORS -> Logger -> Handler
-	wrappedMux := api.Recovery(api.CORS(api.Logger(cfg)(mux)))
+	// InFlight -> Recovery -> CORS -> RequestID -> Tracing -> Logger -> Auth -> Handler
+	wrappedMux := inFlight.Middleware(api.Recovery(api.CORS(cfg)(api.RequestID(api.Tracing(api.Logger(cfg)(api.AuthMiddleware(cfg)(mux)))))))
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -59,31 +222,111 @@ func main() {
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		TLSConfig:    tlsCfg,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server listening on port %s", cfg.ServerPort)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+		var err error
+		if tlsCfg != nil {
+			logging.Printf("Server listening on port %s (TLS, mTLS=%t)", cfg.ServerPort, tlsCfg.ClientCAs != nil)
+			// Certificates are already loaded into TLSConfig, so no paths needed here
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			logging.Printf("Server listening on port %s", cfg.ServerPort)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.Fatalf("Server error: %v", err)
 		}
 	}()
 
+	// gRPC server (opt-in) - same limiter and TLS settings, separate port
+	var grpcSrv *grpc.Server
+	if cfg.GRPCPort != "" {
+		opts := []grpc.ServerOption{grpc.UnaryInterceptor(inFlight.UnaryInterceptor)}
+		if tlsCfg != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		}
+		grpcSrv = grpc.NewServer(opts...)
+		pb.RegisterRateLimiterServer(grpcSrv, api.NewGRPCServer(handler))
+
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			logging.Fatalf("gRPC listen error: %v", err)
+		}
+		go func() {
+			logging.Printf("gRPC server listening on port %s", cfg.GRPCPort)
+			if err := grpcSrv.Serve(lis); err != nil {
+				logging.Fatalf("gRPC server error: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logging.Printf("Shutting down server (%d requests in flight, timeout %v)...", inFlight.Count(), cfg.ShutdownTimeout)
+
+	// A second signal skips the drain for operators who don't want to wait
+	go func() {
+		<-quit
+		logging.Println("Second signal received, exiting immediately")
+		os.Exit(1)
+	}()
 
-	// Give outstanding requests 5 seconds to complete
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// HTTP, gRPC and Redis all share one deadline
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+	// Stop accepting on both transports at once, then wait for in-flight checks to finish
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := srv.Shutdown(ctx); err != nil {
+			logging.Printf("Server forced to shutdown: %v", err)
+		}
+	}()
+
+	// Streams are hijacked connections, which Shutdown neither closes nor waits for
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.CloseStreams(ctx)
+	}()
+
+	if grpcSrv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// GracefulStop waits for in-flight RPCs; fall back to Stop once the deadline passes
+			stopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				logging.Println("gRPC server forced to shutdown")
+				grpcSrv.Stop()
+			}
+		}()
 	}
+	wg.Wait()
 
-	log.Println("Server stopped gracefully")
-}
+	if n := inFlight.Count(); n > 0 {
+		logging.Printf("%d requests still in flight at the shutdown deadline", n)
+	}
+
+	// Nothing is serving checks any more, so background jobs and Redis can go
+	stopBackground()
+	if err := redis.Close(); err != nil {
+		logging.Printf("Redis close error: %v", err)
+	}
 
+	logging.Println("Server stopped gracefully")
+}
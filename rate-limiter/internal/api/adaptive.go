@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+)
+
+// AdaptiveFactorRequest sets the capacity factor for a namespace, or globally when
+// namespace is empty. Leaving factor out removes a namespace's override
+type AdaptiveFactorRequest struct {
+	Namespace string   `json:"namespace,omitempty"`
+	Factor    *float64 `json:"factor"`
+}
+
+// AdaptiveFactorsResponse lists the factors in effect, "" being the global one
+type AdaptiveFactorsResponse struct {
+	Factors map[string]float64 `json:"factors"`
+}
+
+// HandleAdaptiveFactor reads (GET) or sets (POST) the factors capacities are scaled by
+// Only registered when ADAPTIVE_ENABLED is set
+func (h *Handler) HandleAdaptiveFactor(w http.ResponseWriter, r *http.Request) {
+	adaptive := h.limiter.Adaptive()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req AdaptiveFactorRequest
+		if err := decodeBody(w, r, h.cfg.MaxBodyBytes, &req); err != nil {
+			respondBodyError(w, err)
+			return
+		}
+		// An empty namespace is the global factor here, so REQUIRE_NAMESPACE doesn't apply
+		if strings.Contains(req.Namespace, ":") {
+			respondError(w, CodeInvalidNamespace, "namespace cannot contain ':'", http.StatusBadRequest)
+			return
+		}
+		if err := adaptive.SetFactor(r.Context(), req.Namespace, req.Factor); err != nil {
+			if errors.Is(err, limiter.ErrInvalidFactor) {
+				respondError(w, CodeInvalidRequest, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logging.Printf("adaptive factor update failed: %v", err)
+			respondError(w, CodeRedisUnavailable, "failed to store adaptive factor", http.StatusServiceUnavailable)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, AdaptiveFactorsResponse{Factors: adaptive.Factors()}, http.StatusOK)
+}
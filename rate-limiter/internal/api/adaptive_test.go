@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func newAdaptiveTestHandler(t *testing.T) *testHandler {
+	t.Helper()
+	return newTestHandler(t, func(cfg *config.Config) { cfg.AdaptiveEnabled = true })
+}
+
+func TestHandleAdaptiveFactorSetsAndLists(t *testing.T) {
+	th := newAdaptiveTestHandler(t)
+
+	w := post(th.HandleAdaptiveFactor, "/adaptive/factor", `{"factor":0.5}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	w = post(th.HandleAdaptiveFactor, "/adaptive/factor", `{"namespace":"billing","factor":0.25}`)
+	var resp AdaptiveFactorsResponse
+	decode(t, w, &resp)
+	if len(resp.Factors) != 2 || resp.Factors[""] != 0.5 || resp.Factors["billing"] != 0.25 {
+		t.Errorf("factors = %v, want global 0.5 and billing 0.25", resp.Factors)
+	}
+
+	// Checks are held to the scaled capacity straight away
+	var check CheckResponse
+	decode(t, post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":10,"refill_rate":1}`), &check)
+	if !check.Allowed || check.Remaining != 4 {
+		t.Errorf("check = %+v, want allowed with 4 of the halved 5 remaining", check)
+	}
+
+	// Leaving factor out drops billing's override
+	post(th.HandleAdaptiveFactor, "/adaptive/factor", `{"namespace":"billing"}`)
+	w = httptest.NewRecorder()
+	th.HandleAdaptiveFactor(w, httptest.NewRequest(http.MethodGet, "/adaptive/factor", nil))
+	resp = AdaptiveFactorsResponse{}
+	decode(t, w, &resp)
+	if len(resp.Factors) != 1 || resp.Factors[""] != 0.5 {
+		t.Errorf("factors after removing billing = %v, want only global 0.5", resp.Factors)
+	}
+}
+
+func TestHandleAdaptiveFactorRejectsBadRequests(t *testing.T) {
+	th := newAdaptiveTestHandler(t)
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"above one", `{"factor":1.5}`, CodeInvalidRequest},
+		{"negative", `{"factor":-1}`, CodeInvalidRequest},
+		{"removing the global factor", `{}`, CodeInvalidRequest},
+		{"namespace with a colon", `{"namespace":"a:b","factor":0.5}`, CodeInvalidNamespace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(th.HandleAdaptiveFactor, "/adaptive/factor", tt.body)
+			if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != tt.code {
+				t.Errorf("status %d code %q, want 400 %s", w.Code, code, tt.code)
+			}
+		})
+	}
+
+	w := post(th.HandleCheck, "/check", `{"key":"adaptive_factors","algorithm":"token_bucket","capacity":10,"refill_rate":1}`)
+	if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != CodeInvalidKey {
+		t.Errorf("check on the factors key: status %d code %q, want 400 %s", w.Code, code, CodeInvalidKey)
+	}
+}
+
+func TestHandleAdaptiveFactorReportsRedisOutage(t *testing.T) {
+	th := newAdaptiveTestHandler(t)
+	th.redis.Close()
+
+	w := post(th.HandleAdaptiveFactor, "/adaptive/factor", `{"factor":0.5}`)
+	if code := errorCodeOf(t, w); w.Code != http.StatusServiceUnavailable || code != CodeRedisUnavailable {
+		t.Errorf("status %d code %q, want 503 %s", w.Code, code, CodeRedisUnavailable)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+)
+
+// ReloadScriptsRequest optionally carries new script bodies keyed by algorithm
+// Algorithms left out are re-read from disk
+type ReloadScriptsRequest struct {
+	Scripts map[string]string `json:"scripts,omitempty"`
+}
+
+// HandleReloadScripts validates and atomically swaps the Lua scripts
+// Only registered when SCRIPT_RELOAD_ENABLED is set
+func (h *Handler) HandleReloadScripts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ReloadScriptsRequest
+	// Empty body means "reload everything from disk"
+	if err := decodeBody(w, r, h.cfg.MaxBatchBodyBytes, &req); err != nil && !errors.Is(err, io.EOF) {
+		respondBodyError(w, err)
+		return
+	}
+
+	if err := h.limiter.ReloadScripts(r.Context(), req.Scripts); err != nil {
+		logging.Printf("script reload rejected: %v", err)
+		respondError(w, CodeInvalidScript, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logging.Println("Lua scripts reloaded")
+	respondJSON(w, map[string]string{
+		"status": "reloaded",
+	}, http.StatusOK)
+}
+
+// BulkResetRequest names the namespace whose keys should all be deleted
+type BulkResetRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// BulkResetResponse reports how many keys a bulk reset removed
+type BulkResetResponse struct {
+	Namespace string `json:"namespace"`
+	Deleted   int64  `json:"deleted"`
+}
+
+// HandleBulkReset deletes every key in a namespace, e.g. when offboarding a tenant
+// Only registered when ADMIN_TOKEN is set, and requires it as a bearer token
+func (h *Handler) HandleBulkReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// AuthMiddleware covers this by default; checked again since ADMIN_PROTECTED_PATHS may leave it out
+	if !bearerTokenValid(r, h.cfg.AdminToken) {
+		respondError(w, CodeUnauthorized, "admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	var req BulkResetRequest
+	if err := decodeBody(w, r, h.cfg.MaxBodyBytes, &req); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	// An empty namespace would match every key under REDIS_KEY_PREFIX
+	if req.Namespace == "" {
+		respondError(w, CodeNamespaceRequired, "namespace is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateNamespace(req.Namespace, h.cfg); err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.limiter.ResetByPrefix(r.Context(), h.limiter.NamespacePrefix(req.Namespace))
+	if err != nil {
+		logging.Printf("bulk reset of namespace %q stopped after %d keys: %v", req.Namespace, deleted, err)
+		respondError(w, CodeRedisUnavailable, fmt.Sprintf("reset stopped after deleting %d keys, retry to finish", deleted), http.StatusServiceUnavailable)
+		return
+	}
+
+	logging.Printf("Bulk reset of namespace %q deleted %d keys", req.Namespace, deleted)
+	respondJSON(w, BulkResetResponse{Namespace: req.Namespace, Deleted: deleted}, http.StatusOK)
+}
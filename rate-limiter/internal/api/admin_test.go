@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleReloadScripts(t *testing.T) {
+	th := newTestHandler(t, nil)
+	check := `{"key":"user:1","algorithm":"token_bucket","capacity":10,"refill_rate":1}`
+
+	w := post(th.HandleReloadScripts, "/admin/scripts/reload", `{"scripts":{"token_bucket":"return 'nope'"}}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid script: status = %d, want 400", w.Code)
+	}
+	if code := errorCodeOf(t, w); code != CodeInvalidScript {
+		t.Errorf("code = %q, want %q", code, CodeInvalidScript)
+	}
+	var resp CheckResponse
+	decode(t, post(th.HandleCheck, "/check", check), &resp)
+	if !resp.Allowed {
+		t.Fatal("check after a rejected reload blocked, want the running script's allow")
+	}
+
+	if w := post(th.HandleReloadScripts, "/admin/scripts/reload", `{"scripts":{"token_bucket":"return {0, 0, 1000, 0}"}}`); w.Code != http.StatusOK {
+		t.Fatalf("valid script: status = %d, want 200: %s", w.Code, w.Body)
+	}
+	decode(t, post(th.HandleCheck, "/check", check), &resp)
+	if resp.Allowed {
+		t.Error("check after the reload allowed, want the new script's deny")
+	}
+
+	// An empty body reloads everything from disk, back to the built-in scripts here
+	if w := post(th.HandleReloadScripts, "/admin/scripts/reload", ""); w.Code != http.StatusOK {
+		t.Fatalf("empty body: status = %d, want 200: %s", w.Code, w.Body)
+	}
+	decode(t, post(th.HandleCheck, "/check", check), &resp)
+	if !resp.Allowed {
+		t.Error("check after reloading the built-ins blocked, want allowed")
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+// AlgorithmsResponse lists what /check accepts, for SDKs that discover it at runtime
+type AlgorithmsResponse struct {
+	Algorithms       []limiter.AlgorithmInfo `json:"algorithms"`
+	DefaultAlgorithm string                  `json:"default_algorithm,omitempty"`
+}
+
+// HandleAlgorithms returns the supported algorithms and their required/optional parameters
+func (h *Handler) HandleAlgorithms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, AlgorithmsResponse{
+		Algorithms:       limiter.Algorithms(),
+		DefaultAlgorithm: h.cfg.DefaultAlgorithm,
+	}, http.StatusOK)
+}
+
+// algorithmChoices renders the supported names for validation messages,
+// e.g. "'token_bucket', 'sliding_window' or 'gcra'"
+func algorithmChoices() string {
+	algorithms := limiter.Algorithms()
+	names := make([]string, len(algorithms))
+	for i, a := range algorithms {
+		names[i] = "'" + a.Name + "'"
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+// Headers nginx is expected to set on the auth subrequest via proxy_set_header
+// Only the key is special - it falls back to the forwarded client IP when absent
+const (
+	headerAuthKey           = "X-RateLimit-Key"
+	headerAuthAlgorithm     = "X-RateLimit-Algorithm"
+	headerAuthCapacity      = "X-RateLimit-Capacity"
+	headerAuthRefillRate    = "X-RateLimit-Refill-Rate"
+	headerAuthWindowSeconds = "X-RateLimit-Window-Seconds"
+	headerAuthWindowMs      = "X-RateLimit-Window-Ms"
+	headerAuthLeakRate      = "X-RateLimit-Leak-Rate"
+	headerAuthPeriod        = "X-RateLimit-Period"
+	headerAuthTier          = "X-RateLimit-Tier"
+	headerAuthCost          = "X-RateLimit-Cost"
+	headerAuthFailMode      = "X-RateLimit-Fail-Mode"
+	headerAuthProfile       = "X-RateLimit-Profile"
+	headerAuthNamespace     = "X-RateLimit-Namespace"
+)
+
+// HandleAuthRequest implements nginx's auth_request contract
+// 204 = allow, 429 = deny, no body either way. nginx only passes 2xx/401/403 through
+// auth_request, so map the 429 with `error_page 500 =429` on the protected location.
+//
+//	location = /_ratelimit {
+//	    internal;
+//	    proxy_pass http://rate-limiter/auth;
+//	    proxy_pass_request_body off;
+//	    proxy_set_header X-RateLimit-Key $http_x_api_key;
+//	    proxy_set_header X-RateLimit-Algorithm token_bucket;
+//	    proxy_set_header X-RateLimit-Capacity 10;
+//	    proxy_set_header X-RateLimit-Refill-Rate 1;
+//	}
+func (h *Handler) HandleAuthRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.checkRequestFromHeaders(r)
+	if err == nil {
+		err = h.prepareCheckRequest(req)
+	}
+	if err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.limiter.Check(r.Context(), req.toLimiter())
+	if err != nil {
+		// nginx only looks at the status, so the code and message stay out of the response
+		_, _, status := checkErrorStatus(r.Context(), err)
+		w.WriteHeader(status)
+		return
+	}
+
+	setRateLimitHeaders(w, req.Capacity, result.Remaining)
+	setResetHeader(w, result.ResetAt)
+
+	if !result.Allowed {
+		setRetryAfter(w, result.RetryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkRequestFromHeaders builds a CheckRequest from the headers nginx forwards
+func (h *Handler) checkRequestFromHeaders(r *http.Request) (*CheckRequest, error) {
+	req := &CheckRequest{
+		Key:       r.Header.Get(headerAuthKey),
+		Algorithm: r.Header.Get(headerAuthAlgorithm),
+		Tier:      r.Header.Get(headerAuthTier),
+		FailMode:  r.Header.Get(headerAuthFailMode),
+		Profile:   r.Header.Get(headerAuthProfile),
+		Namespace: r.Header.Get(headerAuthNamespace),
+		Period:    r.Header.Get(headerAuthPeriod),
+	}
+	req.Source = h.clientIP(r)
+	if req.Key == "" || req.Key == ipKey {
+		req.Key = req.Source
+	}
+
+	var err error
+	if v := r.Header.Get(headerAuthCapacity); v != "" {
+		if req.Capacity, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, &ValidationError{CodeInvalidHeader, headerAuthCapacity + " must be an integer"}
+		}
+	}
+	if v := r.Header.Get(headerAuthRefillRate); v != "" {
+		if req.RefillRate, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, &ValidationError{CodeInvalidHeader, headerAuthRefillRate + " must be a number"}
+		}
+	}
+	if v := r.Header.Get(headerAuthWindowSeconds); v != "" {
+		if req.WindowSeconds, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, &ValidationError{CodeInvalidHeader, headerAuthWindowSeconds + " must be an integer"}
+		}
+	}
+	if v := r.Header.Get(headerAuthWindowMs); v != "" {
+		if req.WindowMillis, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, &ValidationError{CodeInvalidHeader, headerAuthWindowMs + " must be an integer"}
+		}
+	}
+	if v := r.Header.Get(headerAuthLeakRate); v != "" {
+		if req.LeakRate, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, &ValidationError{CodeInvalidHeader, headerAuthLeakRate + " must be a number"}
+		}
+	}
+	if v := r.Header.Get(headerAuthCost); v != "" {
+		if req.Cost, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, &ValidationError{CodeInvalidHeader, headerAuthCost + " must be an integer"}
+		}
+	}
+
+	return req, nil
+}
+
+// peerIP is the address of the directly connected client, without the port
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// setRateLimitHeaders writes the conventional X-RateLimit-* headers
+func setRateLimitHeaders(w http.ResponseWriter, limit, remaining int64) {
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+}
+
+// setResetHeader writes X-RateLimit-Reset (unix seconds) when the limit reported one
+func setResetHeader(w http.ResponseWriter, resetAt time.Time) {
+	if unix := resetUnix(resetAt); unix > 0 {
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(unix, 10))
+	}
+}
+
+// resetUnix is resetAt in unix seconds, rounded up so a client never retries early
+// 0 means unknown (e.g. the decision came from FAIL_MODE)
+func resetUnix(resetAt time.Time) int64 {
+	if resetAt.IsZero() {
+		return 0
+	}
+	return (resetAt.UnixMilli() + 999) / 1000
+}
+
+// retryAfterMillis is d in milliseconds, rounded up like Retry-After so a client
+// that waits exactly this long isn't blocked again
+func retryAfterMillis(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// suggestedPollMillis is a dry run's SuggestedPoll in milliseconds, rounded up like
+// retryAfterMillis. nil when FAIL_MODE decided, since then nothing is known about the limit
+func suggestedPollMillis(result *limiter.CheckResponse) *int64 {
+	if result.Degraded {
+		return nil
+	}
+	ms := retryAfterMillis(result.SuggestedPoll)
+	return &ms
+}
+
+// setRetryAfter writes Retry-After in whole seconds, rounded up so clients never retry early
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// authRequest sends an nginx-style auth subrequest carrying headers, from remoteAddr
+func authRequest(th *testHandler, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/auth", nil)
+	r.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	th.HandleAuthRequest(w, r)
+	return w
+}
+
+func TestHandleAuthRequestMapsDecisionsToStatus(t *testing.T) {
+	th := newTestHandler(t, nil)
+	headers := map[string]string{
+		headerAuthKey:        "api-key-1",
+		headerAuthAlgorithm:  "token_bucket",
+		headerAuthCapacity:   "1",
+		headerAuthRefillRate: "0.5",
+	}
+
+	w := authRequest(th, "192.0.2.1:1234", headers)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("allowed status = %d, want 204", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("allowed response has a body: %q", w.Body)
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("allowed headers = %v, want limit 1 and remaining 0", w.Header())
+	}
+	if w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Error("allowed response has no X-RateLimit-Reset")
+	}
+
+	w = authRequest(th, "192.0.2.1:1234", headers)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("denied status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", w.Header().Get("X-RateLimit-Remaining"))
+	}
+}
+
+func TestHandleAuthRequestKeysOnClientIPWithoutKey(t *testing.T) {
+	th := newTestHandler(t, nil)
+	headers := map[string]string{
+		headerAuthAlgorithm:  "token_bucket",
+		headerAuthCapacity:   "1",
+		headerAuthRefillRate: "1",
+	}
+
+	if w := authRequest(th, "192.0.2.1:1234", headers); w.Code != http.StatusNoContent {
+		t.Fatalf("first client status = %d, want 204", w.Code)
+	}
+	if w := authRequest(th, "192.0.2.1:5678", headers); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same client, another port: status = %d, want 429", w.Code)
+	}
+	if w := authRequest(th, "192.0.2.2:1234", headers); w.Code != http.StatusNoContent {
+		t.Errorf("second client status = %d, want 204", w.Code)
+	}
+}
+
+func TestHandleAuthRequestRejectsBadHeaders(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	w := authRequest(th, "192.0.2.1:1234", map[string]string{
+		headerAuthKey:        "api-key-1",
+		headerAuthAlgorithm:  "token_bucket",
+		headerAuthCapacity:   "ten",
+		headerAuthRefillRate: "1",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if code := errorCodeOf(t, w); code != CodeInvalidHeader {
+		t.Errorf("code = %q, want %q", code, CodeInvalidHeader)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/auth", nil)
+	w = httptest.NewRecorder()
+	th.HandleAuthRequest(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", w.Code)
+	}
+}
+
+func TestHandleAuthRequestReportsKeyTypeConflict(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	post(th.HandleCheck, "/check", `{"key":"api-key-1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	w := authRequest(th, "192.0.2.1:1234", map[string]string{
+		headerAuthKey:           "api-key-1",
+		headerAuthAlgorithm:     "sliding_window",
+		headerAuthCapacity:      "5",
+		headerAuthWindowSeconds: "60",
+	})
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", w.Code)
+	}
+}
+
+func TestHandleAuthRequestMatchesCheckErrorStatuses(t *testing.T) {
+	th := newTestHandler(t, nil)
+	th.redis.Close()
+	headers := map[string]string{
+		headerAuthKey:        "api-key-1",
+		headerAuthAlgorithm:  "token_bucket",
+		headerAuthCapacity:   "5",
+		headerAuthRefillRate: "1",
+		headerAuthFailMode:   "error",
+	}
+
+	w := authRequest(th, "192.0.2.1:1234", headers)
+	check := post(th.HandleCheck, "/check", `{"key":"api-key-1","algorithm":"token_bucket","capacity":5,"refill_rate":1,"fail_mode":"error"}`)
+	if w.Code != http.StatusServiceUnavailable || w.Code != check.Code {
+		t.Errorf("status = %d, want 503 like /check's %d", w.Code, check.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", w.Body)
+	}
+}
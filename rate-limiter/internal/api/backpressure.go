@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// backpressureDelay computes how long to hold an allowed response for a client near its limit
+// Zero above the threshold, then grows linearly to maxDelay as remaining approaches 0.
+// Example: threshold=0.2, capacity=100 -> remaining 20 = no delay, 10 = maxDelay/2, 0 = maxDelay
+func backpressureDelay(remaining, capacity int64, threshold float64, maxDelay time.Duration) time.Duration {
+	if capacity <= 0 || threshold <= 0 || maxDelay <= 0 {
+		return 0
+	}
+
+	ratio := float64(remaining) / float64(capacity)
+	if ratio >= threshold {
+		return 0
+	}
+	if ratio < 0 {
+		ratio = 0
+	}
+
+	proximity := 1 - ratio/threshold
+	return time.Duration(proximity * float64(maxDelay))
+}
+
+// applyBackpressure sleeps for d but never past the request deadline or cancellation
+// A disconnected client shouldn't keep a handler goroutine parked
+func applyBackpressure(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if untilDeadline := time.Until(deadline); untilDeadline < d {
+			d = untilDeadline
+		}
+		if d <= 0 {
+			return
+		}
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
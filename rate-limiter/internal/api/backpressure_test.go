@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestBackpressureDelayScalesWithProximity(t *testing.T) {
+	const maxDelay = 100 * time.Millisecond
+	tests := []struct {
+		remaining int64
+		want      time.Duration
+	}{
+		{remaining: 100, want: 0},
+		{remaining: 20, want: 0}, // at the threshold
+		{remaining: 15, want: 25 * time.Millisecond},
+		{remaining: 10, want: 50 * time.Millisecond},
+		{remaining: 5, want: 75 * time.Millisecond},
+		{remaining: 0, want: maxDelay},
+		{remaining: -5, want: maxDelay}, // never past maxDelay
+	}
+	for _, tt := range tests {
+		if got := backpressureDelay(tt.remaining, 100, 0.2, maxDelay); got != tt.want {
+			t.Errorf("backpressureDelay(remaining %d) = %v, want %v", tt.remaining, got, tt.want)
+		}
+	}
+}
+
+func TestBackpressureDelayOffWithoutSettings(t *testing.T) {
+	if got := backpressureDelay(0, 0, 0.2, time.Second); got != 0 {
+		t.Errorf("zero capacity: %v, want 0", got)
+	}
+	if got := backpressureDelay(0, 100, 0, time.Second); got != 0 {
+		t.Errorf("zero threshold: %v, want 0", got)
+	}
+	if got := backpressureDelay(0, 100, 0.2, 0); got != 0 {
+		t.Errorf("zero max delay: %v, want 0", got)
+	}
+}
+
+func TestApplyBackpressureStopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	applyBackpressure(ctx, 5*time.Second)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("held for %v, want no longer than the 20ms deadline", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	applyBackpressure(cancelled, 5*time.Second)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("held a cancelled request for %v", elapsed)
+	}
+}
+
+func TestHandleCheckDelaysNearLimit(t *testing.T) {
+	const maxDelay = 200 * time.Millisecond
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.BackpressureEnabled = true
+		cfg.BackpressureThreshold = 0.5
+		cfg.BackpressureMaxDelay = maxDelay
+	})
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":2,"refill_rate":1}`
+
+	// Remaining 1 of 2 sits on the threshold, so no delay
+	start := time.Now()
+	post(th.HandleCheck, "/check", body)
+	if elapsed := time.Since(start); elapsed >= maxDelay/2 {
+		t.Errorf("check above the threshold took %v, want no delay", elapsed)
+	}
+
+	// Remaining 0 is as close as it gets
+	start = time.Now()
+	post(th.HandleCheck, "/check", body)
+	if elapsed := time.Since(start); elapsed < maxDelay {
+		t.Errorf("check at the limit took %v, want at least %v", elapsed, maxDelay)
+	}
+
+	// Denials are answered straight away
+	start = time.Now()
+	post(th.HandleCheck, "/check", body)
+	if elapsed := time.Since(start); elapsed >= maxDelay/2 {
+		t.Errorf("denied check took %v, want no delay", elapsed)
+	}
+}
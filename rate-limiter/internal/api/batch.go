@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+// maxBatchSize bounds how many checks one batch request can carry
+const maxBatchSize = 100
+
+// BatchCheckResponse is one entry of a batch result - Error and Code are set instead of the decision on failure
+type BatchCheckResponse struct {
+	*CheckResponse
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// HandleCheckBatch checks several limits in one request (e.g. per-user, per-IP, per-endpoint)
+// Responses are in request order; invalid or failed entries carry an error instead of a decision
+func (h *Handler) HandleCheckBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reqs []CheckRequest
+	if err := decodeBody(w, r, h.cfg.MaxBatchBodyBytes, &reqs); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	if len(reqs) == 0 {
+		respondError(w, CodeInvalidRequest, "batch must contain at least one check", http.StatusBadRequest)
+		return
+	}
+	if len(reqs) > maxBatchSize {
+		respondError(w, CodeInvalidRequest, fmt.Sprintf("batch cannot contain more than %d checks", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	respondJSON(w, h.checkBatch(r.Context(), reqs, h.clientIP(r)), http.StatusOK)
+}
+
+// checkBatch validates and runs a batch; source is the caller IP, filling in entries that
+// don't name a source and "$ip" keys
+// Shared by the HTTP and gRPC batch endpoints
+func (h *Handler) checkBatch(ctx context.Context, reqs []CheckRequest, source string) []BatchCheckResponse {
+	resps := make([]BatchCheckResponse, len(reqs))
+
+	// Only valid entries go to the limiter; index maps them back to their position
+	valid := make([]limiter.CheckRequest, 0, len(reqs))
+	index := make([]int, 0, len(reqs))
+	for i := range reqs {
+		applyClientIP(&reqs[i], source)
+		if err := h.prepareCheckRequest(&reqs[i]); err != nil {
+			resps[i].Error = err.Error()
+			resps[i].Code = errorCode(err)
+			continue
+		}
+		valid = append(valid, reqs[i].toLimiter())
+		index = append(index, i)
+	}
+
+	for j, result := range h.limiter.CheckBatch(ctx, valid) {
+		i := index[j]
+		if result.Err != nil {
+			resps[i].Code, resps[i].Error, _ = checkErrorStatus(ctx, result.Err)
+			continue
+		}
+
+		resps[i].CheckResponse = newCheckResponse(&reqs[i], result.Response)
+	}
+
+	return resps
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// unknownFieldPrefix starts the error encoding/json returns for a field v doesn't have
+const unknownFieldPrefix = "json: unknown field "
+
+// decodeBody decodes r's JSON body into v, reading at most limit bytes of it
+// Without the cap a client could stream an arbitrarily large body into the decoder
+func decodeBody(w http.ResponseWriter, r *http.Request, limit int64, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	return decodeStrict(r.Body, v)
+}
+
+// decodeStrict decodes JSON from rd into v, rejecting fields v doesn't have
+// A typo like refil_rate would otherwise be dropped and the limit silently fall back to defaults
+func decodeStrict(rd io.Reader, v interface{}) error {
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// respondBodyError answers a body decodeBody rejected - 413 if it was over the limit
+func respondBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(w, CodeBodyTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	respondError(w, CodeInvalidBody, bodyErrorMessage(err), http.StatusBadRequest)
+}
+
+// bodyErrorMessage names the offending field for unknown-field errors, the one case
+// where the client can't tell what's wrong from "invalid request body"
+func bodyErrorMessage(err error) string {
+	if field, ok := strings.CutPrefix(err.Error(), unknownFieldPrefix); ok {
+		return "unknown field " + field
+	}
+	return "invalid request body"
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// padded is body with whitespace before its closing bracket bringing it to size bytes
+// The padding is inside the JSON value, so the decoder has to read all of it
+func padded(body string, size int) string {
+	end := len(body) - 1
+	return body[:end] + strings.Repeat(" ", size-len(body)) + body[end:]
+}
+
+func TestHandleCheckCapsBodySize(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MaxBodyBytes = 256
+		cfg.MaxBatchBodyBytes = 1024
+	})
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`
+
+	if w := post(th.HandleCheck, "/check", padded(body, 256)); w.Code != http.StatusOK {
+		t.Errorf("body at MAX_BODY_BYTES: status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	w := post(th.HandleCheck, "/check", padded(body, 257))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("body over MAX_BODY_BYTES: status = %d, want 413", w.Code)
+	}
+	if code := errorCodeOf(t, w); code != CodeBodyTooLarge {
+		t.Errorf("code = %q, want %q", code, CodeBodyTooLarge)
+	}
+}
+
+func TestHandleCheckBatchHasItsOwnBodyCap(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.MaxBodyBytes = 256
+		cfg.MaxBatchBodyBytes = 1024
+	})
+	batch := `[{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}]`
+
+	// Over the single-check cap is fine for a batch
+	if w := post(th.HandleCheckBatch, "/check/batch", padded(batch, 512)); w.Code != http.StatusOK {
+		t.Errorf("batch over MAX_BODY_BYTES: status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	w := post(th.HandleCheckBatch, "/check/batch", padded(batch, 1025))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("batch over MAX_BATCH_BODY_BYTES: status = %d, want 413", w.Code)
+	}
+	if code := errorCodeOf(t, w); code != CodeBodyTooLarge {
+		t.Errorf("code = %q, want %q", code, CodeBodyTooLarge)
+	}
+}
+
+func TestHandlersRejectUnknownFields(t *testing.T) {
+	const token = "admin-secret"
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.AdminToken = token
+	})
+	reset := func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+		th.HandleBulkReset(w, r)
+	}
+
+	tests := []struct {
+		name   string
+		handle http.HandlerFunc
+		good   string
+		typo   string
+	}{
+		{
+			name:   "check",
+			handle: th.HandleCheck,
+			good:   `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`,
+			typo:   `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refil_rate":1}`,
+		},
+		{
+			name:   "batch",
+			handle: th.HandleCheckBatch,
+			good:   `[{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}]`,
+			typo:   `[{"key":"user:1","algorithm":"token_bucket","capacity":5,"refil_rate":1}]`,
+		},
+		{
+			name:   "bulk reset",
+			handle: reset,
+			good:   `{"namespace":"billing"}`,
+			typo:   `{"namespace":"billing","refil_rate":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := post(tt.handle, "/", tt.good); w.Code != http.StatusOK {
+				t.Fatalf("known fields: status = %d, want 200: %s", w.Code, w.Body)
+			}
+
+			w := post(tt.handle, "/", tt.typo)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("unknown field: status = %d, want 400: %s", w.Code, w.Body)
+			}
+			var body map[string]string
+			decode(t, w, &body)
+			if body["code"] != CodeInvalidBody || body["error"] != `unknown field "refil_rate"` {
+				t.Errorf("error = %v, want %s naming refil_rate", body, CodeInvalidBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// checkMode is how this /check reports a block: ?mode= when given (on GET or POST),
+// otherwise CHECK_MODE
+func (h *Handler) checkMode(r *http.Request) (string, error) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		return h.cfg.CheckMode, nil
+	case config.CheckModeJSON, config.CheckModeGateway:
+		return mode, nil
+	}
+	return "", &ValidationError{CodeInvalidQuery, fmt.Sprintf("mode must be %q or %q", config.CheckModeJSON, config.CheckModeGateway)}
+}
+
+// checkRequestFromQuery fills req from GET /check query parameters (CHECK_GET_ENABLED)
+// The parameters carry the same names as the JSON fields, and the result goes through the
+// same prepareCheckRequest as a POST, so both forms are validated identically
+func checkRequestFromQuery(q url.Values, req *CheckRequest) error {
+	req.Key = q.Get("key")
+	req.Profile = q.Get("profile")
+	req.Namespace = q.Get("namespace")
+	req.Algorithm = q.Get("algorithm")
+	req.Period = q.Get("period")
+	req.Tier = q.Get("tier")
+	req.FailMode = q.Get("fail_mode")
+
+	if err := queryInt(q, "capacity", &req.Capacity); err != nil {
+		return err
+	}
+	if err := queryFloat(q, "refill_rate", &req.RefillRate); err != nil {
+		return err
+	}
+	if err := queryInt(q, "window_seconds", &req.WindowSeconds); err != nil {
+		return err
+	}
+	if err := queryInt(q, "window_ms", &req.WindowMillis); err != nil {
+		return err
+	}
+	if err := queryFloat(q, "leak_rate", &req.LeakRate); err != nil {
+		return err
+	}
+	return queryInt(q, "cost", &req.Cost)
+}
+
+// queryInt parses an optional integer parameter into dst, leaving it alone when absent
+func queryInt(q url.Values, name string, dst *int64) error {
+	v := q.Get(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return &ValidationError{CodeInvalidQuery, name + " must be an integer"}
+	}
+	*dst = n
+	return nil
+}
+
+// queryFloat parses an optional number parameter into dst, leaving it alone when absent
+func queryFloat(q url.Values, name string, dst *float64) error {
+	v := q.Get(name)
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return &ValidationError{CodeInvalidQuery, name + " must be a number"}
+	}
+	*dst = f
+	return nil
+}
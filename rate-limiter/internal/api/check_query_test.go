@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// get sends a GET for path (query included) to handle and returns the recorded response
+func get(handle http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handle(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestCheckGetAndPostAgreeWithoutAlgorithm(t *testing.T) {
+	tests := []struct {
+		name             string
+		defaultAlgorithm string
+		wantStatus       int
+		wantCode         string
+	}{
+		{name: "no default", wantStatus: http.StatusBadRequest, wantCode: CodeMissingAlgorithm},
+		{name: "default token_bucket", defaultAlgorithm: "token_bucket", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t, func(cfg *config.Config) {
+				cfg.CheckGetEnabled = true
+				cfg.DefaultAlgorithm = tt.defaultAlgorithm
+			})
+
+			responses := map[string]*httptest.ResponseRecorder{
+				"POST": post(th.HandleCheck, "/check", `{"key":"user:1","capacity":2,"refill_rate":1}`),
+				"GET":  get(th.HandleCheck, "/check?key=user:2&capacity=2&refill_rate=1"),
+			}
+			for method, w := range responses {
+				if w.Code != tt.wantStatus {
+					t.Fatalf("%s status = %d, want %d: %s", method, w.Code, tt.wantStatus, w.Body)
+				}
+				if tt.wantCode != "" {
+					if code := errorCodeOf(t, w); code != tt.wantCode {
+						t.Errorf("%s code = %q, want %q", method, code, tt.wantCode)
+					}
+					continue
+				}
+				var resp CheckResponse
+				decode(t, w, &resp)
+				if !resp.Allowed || resp.Remaining != 1 {
+					t.Errorf("%s response = %+v, want allowed with 1 remaining", method, resp)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckGetRejectsMalformedParameters(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.CheckGetEnabled = true
+	})
+
+	w := get(th.HandleCheck, "/check?key=user:1&algorithm=token_bucket&capacity=two&refill_rate=1")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if code := errorCodeOf(t, w); code != CodeInvalidQuery {
+		t.Errorf("code = %q, want %q", code, CodeInvalidQuery)
+	}
+}
+
+func TestCheckGetDisabledByDefault(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	if w := get(th.HandleCheck, "/check?key=user:1&algorithm=token_bucket&capacity=2&refill_rate=1"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405 with CHECK_GET_ENABLED off", w.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ipKey is the key value that stands for the caller's own IP address
+// Lets a client limit per end user without knowing the user's address itself
+const ipKey = "$ip"
+
+// clientIP resolves the real client address behind TRUSTED_PROXIES
+// Forwarding headers are only believed when the connection comes from a trusted proxy;
+// anyone else could write whatever they like into them
+func (h *Handler) clientIP(r *http.Request) string {
+	peer := peerIP(r)
+	if !h.trustedProxy(peer) {
+		return peer
+	}
+
+	// Each proxy appends the address it got the request from, so walk right to left and
+	// take the first hop that isn't one of ours - anything further left is client-supplied
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Garbage in the chain - stop rather than trust anything left of it
+			break
+		}
+		if !h.trustedProxy(hop) {
+			return hop
+		}
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return peer
+}
+
+// trustedProxy reports whether ip falls inside TRUSTED_PROXIES
+func (h *Handler) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range h.trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyClientIP fills in the caller's address: as the source when none was given, and
+// as the key when the key is literally "$ip"
+func applyClientIP(req *CheckRequest, ip string) {
+	if req.Source == "" {
+		req.Source = ip
+	}
+	if req.Key == ipKey {
+		req.Key = ip
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+	})
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:1234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed X-Forwarded-For from an untrusted peer",
+			remoteAddr: "203.0.113.7:1234",
+			forwarded:  []string{"1.2.3.4"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed X-Real-IP from an untrusted peer",
+			remoteAddr: "203.0.113.7:1234",
+			realIP:     "1.2.3.4",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "one trusted proxy",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"198.51.100.9"},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"198.51.100.9, 192.168.1.1, 10.0.0.5"},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "client prepends a spoofed hop",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"1.2.3.4, 198.51.100.9"},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "chain split over several headers",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"198.51.100.9", "10.0.0.5"},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "garbage hop stops the walk",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"198.51.100.9, not-an-ip, 10.0.0.5"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "X-Real-IP from a trusted proxy",
+			remoteAddr: "10.0.0.2:1234",
+			realIP:     "198.51.100.9",
+			want:       "198.51.100.9",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/check", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := th.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCheckSubstitutesIPKey(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	})
+	check := func(remoteAddr, forwarded string) {
+		r := httptest.NewRequest(http.MethodPost, "/check", strings.NewReader(
+			`{"key":"$ip","algorithm":"token_bucket","capacity":5,"refill_rate":1}`))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		th.HandleCheck(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+	}
+
+	check("10.0.0.2:1234", "198.51.100.9")
+	check("203.0.113.7:1234", "198.51.100.9") // spoofed, so keyed on the peer
+	for _, key := range []string{"198.51.100.9", "203.0.113.7"} {
+		if !th.redis.Exists(key) {
+			t.Errorf("keys = %v, want a bucket for %s", th.redis.Keys(), key)
+		}
+	}
+	if th.redis.Exists("$ip") {
+		t.Error("the literal $ip key reached Redis")
+	}
+}
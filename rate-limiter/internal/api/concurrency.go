@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// AcquireRequest asks for one of `limit` concurrent slots on a key
+type AcquireRequest struct {
+	Key       string `json:"key"`
+	Namespace string `json:"namespace,omitempty"`
+	Limit     int64  `json:"limit"`
+
+	// LeaseSeconds bounds how long the slot is held if it's never released,
+	// defaults to CONCURRENCY_LEASE_TTL
+	LeaseSeconds int64 `json:"lease_seconds,omitempty"`
+}
+
+// AcquireResponse carries the lease token to pass to /release when done
+type AcquireResponse struct {
+	Acquired  bool   `json:"acquired"`
+	Token     string `json:"token,omitempty"`
+	Remaining int64  `json:"remaining"`
+	Degraded  bool   `json:"degraded,omitempty"`
+}
+
+// ReleaseRequest gives back a lease from /acquire
+type ReleaseRequest struct {
+	Key       string `json:"key"`
+	Namespace string `json:"namespace,omitempty"`
+	Token     string `json:"token"`
+}
+
+// ReleaseResponse reports whether the lease was still held
+// false means it had already expired (or was released before) - the slot is free either way
+type ReleaseResponse struct {
+	Released bool `json:"released"`
+}
+
+// HandleAcquire takes a concurrency lease (e.g. max 5 concurrent jobs per user)
+// Like /check, a full key is a normal 200 with acquired=false and a Retry-After
+func (h *Handler) HandleAcquire(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AcquireRequest
+	if err := decodeBody(w, r, h.cfg.MaxBodyBytes, &req); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	if err := validateAcquireRequest(&req, h.cfg); err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ttl := h.cfg.ConcurrencyLeaseTTL
+	if req.LeaseSeconds > 0 {
+		ttl = time.Duration(req.LeaseSeconds) * time.Second
+	}
+
+	lease, err := h.limiter.Acquire(r.Context(), req.Namespace, req.Key, req.Limit, ttl)
+	if err != nil {
+		code, msg, status := checkErrorStatus(r.Context(), err)
+		respondError(w, code, msg, status)
+		return
+	}
+
+	setRateLimitHeaders(w, req.Limit, lease.Remaining)
+	if !lease.Acquired {
+		setRetryAfter(w, lease.RetryAfter)
+	}
+
+	respondJSON(w, AcquireResponse{
+		Acquired:  lease.Acquired,
+		Token:     lease.Token,
+		Remaining: lease.Remaining,
+		Degraded:  lease.Degraded,
+	}, http.StatusOK)
+}
+
+// HandleRelease returns a lease so the slot frees up before its expiry
+func (h *Handler) HandleRelease(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ReleaseRequest
+	if err := decodeBody(w, r, h.cfg.MaxBodyBytes, &req); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	if req.Key == "" || req.Token == "" {
+		respondError(w, CodeInvalidRequest, "key and token are required", http.StatusBadRequest)
+		return
+	}
+	if err := validateNamespace(req.Namespace, h.cfg); err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	released, err := h.limiter.Release(r.Context(), req.Namespace, req.Key, req.Token)
+	var failOpenErr *redisclient.FailOpenError
+	if errors.As(err, &failOpenErr) {
+		// Nothing lost - the lease expires on its own once Redis is back
+		respondError(w, CodeRedisUnavailable, "redis unavailable, the lease will expire on its own", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		code, msg, status := checkErrorStatus(r.Context(), err)
+		respondError(w, code, msg, status)
+		return
+	}
+
+	respondJSON(w, ReleaseResponse{Released: released}, http.StatusOK)
+}
+
+// validateAcquireRequest mirrors validateCheckRequest for concurrency leases
+func validateAcquireRequest(req *AcquireRequest, cfg *config.Config) error {
+	if req.Key == "" {
+		return &ValidationError{CodeMissingKey, "key is required"}
+	}
+
+	if err := validateNamespace(req.Namespace, cfg); err != nil {
+		return err
+	}
+
+	if req.Limit <= 0 {
+		return &ValidationError{CodeLimitRequired, "limit must be positive"}
+	}
+
+	if req.Limit > limiter.MaxSafeInteger {
+		return &ValidationError{CodeLimitTooLarge, "limit is too large"}
+	}
+
+	if cfg.MaxCapacity > 0 && req.Limit > cfg.MaxCapacity {
+		return &ValidationError{CodeLimitTooLarge, fmt.Sprintf("limit cannot exceed %d", cfg.MaxCapacity)}
+	}
+
+	if req.LeaseSeconds < 0 {
+		return &ValidationError{CodeInvalidLease, "lease_seconds cannot be negative"}
+	}
+	return nil
+}
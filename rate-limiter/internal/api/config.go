@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// redacted stands in for secrets that are set, so /config still shows they're configured
+const redacted = "[redacted]"
+
+// ConfigResponse is the effective configuration as this instance loaded it
+// Only settings that help explain limit behaviour are listed, and secrets are redacted
+type ConfigResponse struct {
+	ServerPort        string `json:"server_port"`
+	GRPCPort          string `json:"grpc_port,omitempty"`
+	RedisAddr         string `json:"redis_addr"`
+	RedisUsername     string `json:"redis_username,omitempty"`
+	RedisPassword     string `json:"redis_password,omitempty"`
+	RedisDB           int    `json:"redis_db"`
+	RedisTLSEnabled   bool   `json:"redis_tls_enabled"`
+	RedisPoolSize     int    `json:"redis_pool_size"`
+	RedisMinIdleConns int    `json:"redis_min_idle_conns"`
+	RedisTimeout      string `json:"redis_timeout"`
+	RedisRetryBackoff string `json:"redis_retry_backoff"`
+	ClockSource       string `json:"clock_source"`
+	CheckMode         string `json:"check_mode"`
+	FailMode          string `json:"fail_mode"`
+	DefaultAlgorithm  string `json:"default_algorithm,omitempty"`
+	RoutingRulesFile  string `json:"routing_rules_file,omitempty"`
+	Environment       string `json:"environment"`
+
+	MaxCapacity      int64   `json:"max_capacity"`
+	MaxWindowSeconds int64   `json:"max_window_seconds"`
+	MaxRefillRate    float64 `json:"max_refill_rate"`
+	MaxKeyLength     int     `json:"max_key_length"`
+	KeyLowercase     bool    `json:"key_lowercase"`
+
+	AdminToken string `json:"admin_token,omitempty"`
+
+	ProfilesFile    string   `json:"profiles_file,omitempty"`
+	ProfilesVersion string   `json:"profiles_version,omitempty"`
+	Profiles        []string `json:"profiles"`
+}
+
+// HandleConfig reports the effective configuration for debugging mismatched limits
+// Only registered when CONFIG_ENDPOINT_ENABLED is set
+func (h *Handler) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := configResponse(h.cfg)
+	// Profiles can be reloaded at runtime, so read them from the live store
+	resp.ProfilesVersion = h.profiles.Version()
+	resp.Profiles = h.profiles.Names()
+
+	respondJSON(w, resp, http.StatusOK)
+}
+
+func configResponse(cfg *config.Config) ConfigResponse {
+	resp := ConfigResponse{
+		ServerPort:        cfg.ServerPort,
+		GRPCPort:          cfg.GRPCPort,
+		RedisAddr:         cfg.RedisTarget(),
+		RedisUsername:     cfg.RedisUsername,
+		RedisDB:           cfg.RedisDB,
+		RedisTLSEnabled:   cfg.RedisTLSEnabled,
+		RedisPoolSize:     cfg.RedisPoolSize,
+		RedisMinIdleConns: cfg.RedisMinIdleConns,
+		RedisTimeout:      cfg.RedisTimeout.String(),
+		RedisRetryBackoff: cfg.RedisRetryBackoff.String(),
+		ClockSource:       cfg.ClockSource,
+		CheckMode:         cfg.CheckMode,
+		FailMode:          cfg.FailMode,
+		DefaultAlgorithm:  cfg.DefaultAlgorithm,
+		RoutingRulesFile:  cfg.RoutingRulesFile,
+		Environment:       cfg.Environment,
+		ProfilesFile:      cfg.ProfilesFile,
+
+		MaxCapacity:      cfg.MaxCapacity,
+		MaxWindowSeconds: cfg.MaxWindowSeconds,
+		MaxRefillRate:    cfg.MaxRefillRate,
+		MaxKeyLength:     cfg.MaxKeyLength,
+		KeyLowercase:     cfg.KeyLowercase,
+	}
+	if cfg.RedisPassword != "" {
+		resp.RedisPassword = redacted
+	}
+	if cfg.AdminToken != "" {
+		resp.AdminToken = redacted
+	}
+	return resp
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestDryRunReturnsSuggestedPoll(t *testing.T) {
+	th := newTestHandler(t, nil)
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":4}`
+
+	var resp CheckResponse
+	decode(t, post(th.HandleCheck, "/check", body), &resp)
+	if resp.SuggestedPollMs != nil {
+		t.Errorf("enforced check has suggested_poll_ms %d, want none", *resp.SuggestedPollMs)
+	}
+
+	// The bucket is empty, so the next token is 250ms away
+	dryRun := `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":4,"dry_run":true}`
+	decode(t, post(th.HandleCheck, "/check", dryRun), &resp)
+	if !resp.Allowed {
+		t.Error("dry run blocked, want allowed")
+	}
+	if resp.SuggestedPollMs == nil || *resp.SuggestedPollMs != 250 {
+		t.Errorf("suggested_poll_ms = %v, want 250", resp.SuggestedPollMs)
+	}
+}
+
+func TestDryRunSuggestedPollIsZeroWhenAllowed(t *testing.T) {
+	th := newTestHandler(t, nil)
+	dryRun := `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":4,"dry_run":true}`
+
+	// Present as 0, not left out, so pollers can tell "go now" from "unknown"
+	w := post(th.HandleCheck, "/check", dryRun)
+	var raw map[string]interface{}
+	decode(t, w, &raw)
+	if got, ok := raw["suggested_poll_ms"]; !ok || got != float64(0) {
+		t.Errorf("suggested_poll_ms = %v (present %v), want 0: %s", got, ok, w.Body)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import "errors"
+
+// Error codes returned in the "code" field of error responses
+// Messages may be reworded over time; codes are stable, so clients should branch on these
+const (
+	CodeInvalidBody    = "INVALID_BODY"    // body isn't valid JSON for the endpoint
+	CodeBodyTooLarge   = "BODY_TOO_LARGE"  // body over MAX_BODY_BYTES (MAX_BATCH_BODY_BYTES for batches)
+	CodeInvalidRequest = "INVALID_REQUEST" // well-formed but not acceptable (e.g. an empty batch)
+
+	CodeMissingKey        = "MISSING_KEY"
+	CodeInvalidKey        = "INVALID_KEY" // blank, too long or has control characters (see KeyNormalizer)
+	CodeMissingAlgorithm  = "MISSING_ALGORITHM"
+	CodeInvalidAlgorithm  = "INVALID_ALGORITHM"
+	CodeCapacityRequired  = "CAPACITY_REQUIRED"
+	CodeRateRequired      = "RATE_REQUIRED"   // refill_rate or leak_rate missing for the algorithm
+	CodeWindowRequired    = "WINDOW_REQUIRED" // window_ms/window_seconds missing for a sliding window
+	CodePeriodRequired    = "PERIOD_REQUIRED" // period missing or unknown for a quota
+	CodeLimitRequired     = "LIMIT_REQUIRED"  // /acquire limit missing
+	CodeLimitTooLarge     = "LIMIT_TOO_LARGE" // over a MAX_* setting or the safe numeric range
+	CodeInvalidCost       = "INVALID_COST"
+	CodeInvalidFailMode   = "INVALID_FAIL_MODE"
+	CodeInvalidRequestID  = "INVALID_REQUEST_ID"
+	CodeInvalidPenalty    = "INVALID_PENALTY" // penalty_base_seconds/penalty_max_seconds out of order
+	CodeInvalidLease      = "INVALID_LEASE"
+	CodeInvalidExperiment = "INVALID_EXPERIMENT"
+	CodeInvalidHeader     = "INVALID_HEADER" // an X-RateLimit-* header on /auth didn't parse
+	CodeInvalidQuery      = "INVALID_QUERY"  // a GET /check query parameter didn't parse
+	CodeUnknownProfile    = "UNKNOWN_PROFILE"
+	CodeNamespaceRequired = "NAMESPACE_REQUIRED"
+	CodeInvalidNamespace  = "INVALID_NAMESPACE"
+	CodeInvalidScript     = "INVALID_SCRIPT" // /admin/scripts/reload rejected the scripts
+
+	CodeUnauthorized        = "UNAUTHORIZED"      // missing or wrong ADMIN_TOKEN on an admin endpoint
+	CodeKeyTypeConflict     = "KEY_TYPE_CONFLICT" // key already holds another algorithm's state
+	CodeSourceQuotaExceeded = "SOURCE_QUOTA_EXCEEDED"
+	CodeScriptError         = "SCRIPT_ERROR"
+	CodeRedisUnavailable    = "REDIS_UNAVAILABLE"
+	CodeRedisResharding     = "REDIS_RESHARDING" // cluster slot still moving after every redirect - retry
+	CodeCrossSlot           = "CROSS_SLOT"       // a multi-limit check's keys are on different cluster slots
+	CodeInternal            = "INTERNAL"
+)
+
+// errorCode picks the code for a request that failed before reaching the limiter
+// Validation failures carry their own; anything else is a generic bad request
+func errorCode(err error) string {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) && validationErr.Code != "" {
+		return validationErr.Code
+	}
+	return CodeInvalidRequest
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/redis/lua"
+)
+
+func TestHandleCheckMapsScriptErrorTo400(t *testing.T) {
+	// A LUA_DIR token bucket that rejects one key the way scripts reject bad input
+	body, err := lua.Scripts.ReadFile("token_bucket.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	guard := "if KEYS[1] == 'user:rejected' then return redis.error_reply('invalid arguments: key is rejected') end\n"
+	if err := os.WriteFile(filepath.Join(dir, "token_bucket.lua"), append([]byte(guard), body...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.LuaDir = dir
+	})
+
+	w := post(th.HandleCheck, "/check", `{"key":"user:rejected","algorithm":"token_bucket","capacity":1,"refill_rate":1}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+	}
+	var resp map[string]string
+	decode(t, w, &resp)
+	if resp["code"] != CodeScriptError {
+		t.Errorf("code = %q, want %q", resp["code"], CodeScriptError)
+	}
+	if want := "invalid arguments: key is rejected"; resp["error"] != want {
+		t.Errorf("error = %q, want the script's message %q", resp["error"], want)
+	}
+
+	// Other keys still run the script as usual
+	if w := post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`); w.Code != http.StatusOK {
+		t.Errorf("status for another key = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestHandleCheckFailsOpenWhenRedisIsDown(t *testing.T) {
+	th := newTestHandler(t, nil)
+	th.redis.Close()
+
+	w := post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp CheckResponse
+	decode(t, w, &resp)
+	if !resp.Allowed || !resp.Degraded {
+		t.Errorf("response = %+v, want a degraded allow", resp)
+	}
+}
+
+func TestHandleCheckFailModeFromRequest(t *testing.T) {
+	th := newTestHandler(t, nil)
+	th.redis.Close()
+
+	w := post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1,"fail_mode":"closed"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp CheckResponse
+	decode(t, w, &resp)
+	if resp.Allowed || !resp.Degraded {
+		t.Errorf("response = %+v, want a degraded deny", resp)
+	}
+
+	w = post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1,"fail_mode":"sometimes"}`)
+	if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != CodeInvalidFailMode {
+		t.Errorf("unknown fail_mode: status %d code %q, want 400 %q", w.Code, code, CodeInvalidFailMode)
+	}
+}
+
+func TestHandleCheckRefusesKeysOverSourceQuota(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.SourceKeyLimit = 1
+	})
+
+	// Without a source in the body, the client IP is the source
+	post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	w := post(th.HandleCheck, "/check", `{"key":"user:2","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429: %s", w.Code, w.Body)
+	}
+	if code := errorCodeOf(t, w); code != CodeSourceQuotaExceeded {
+		t.Errorf("code = %q, want %q", code, CodeSourceQuotaExceeded)
+	}
+
+	if w := post(th.HandleCheck, "/check", `{"key":"user:2","source":"tenant-b","algorithm":"token_bucket","capacity":5,"refill_rate":1}`); w.Code != http.StatusOK {
+		t.Errorf("another source: status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestHandleCheckValidatesNamespace(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.RequireNamespace = true
+	})
+
+	tests := []struct {
+		body     string
+		wantCode string
+	}{
+		{body: `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`, wantCode: CodeNamespaceRequired},
+		{body: `{"key":"user:1","namespace":"a:b","algorithm":"token_bucket","capacity":1,"refill_rate":1}`, wantCode: CodeInvalidNamespace},
+	}
+	for _, tt := range tests {
+		w := post(th.HandleCheck, "/check", tt.body)
+		if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != tt.wantCode {
+			t.Errorf("%s: status %d code %q, want 400 %q", tt.body, w.Code, code, tt.wantCode)
+		}
+	}
+
+	if w := post(th.HandleCheck, "/check", `{"key":"user:1","namespace":"billing","algorithm":"token_bucket","capacity":1,"refill_rate":1}`); w.Code != http.StatusOK {
+		t.Errorf("status with a namespace = %d, want 200: %s", w.Code, w.Body)
+	}
+	if !th.redis.Exists("billing:user:1") {
+		t.Errorf("keys = %v, want billing:user:1", th.redis.Keys())
+	}
+}
+
+func TestHandleCheckDeduplicatesRequestID(t *testing.T) {
+	th := newTestHandler(t, nil)
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1,"request_id":"retry-me"}`
+
+	var first, replay CheckResponse
+	decode(t, post(th.HandleCheck, "/check", body), &first)
+	decode(t, post(th.HandleCheck, "/check", body), &replay)
+	if first.Remaining != 4 || replay.Remaining != 4 {
+		t.Errorf("remaining = %d then %d, want 4 both times", first.Remaining, replay.Remaining)
+	}
+
+	for _, body := range []string{
+		`{"key":"user:1","algorithm":"sliding_window","capacity":5,"window_seconds":60,"request_id":"retry-me"}`,
+		`{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1,"request_id":"` + strings.Repeat("x", maxRequestIDLength+1) + `"}`,
+	} {
+		w := post(th.HandleCheck, "/check", body)
+		if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != CodeInvalidRequestID {
+			t.Errorf("status %d code %q, want 400 %s", w.Code, code, CodeInvalidRequestID)
+		}
+	}
+}
+
+func TestHandleCheckAnswers503UnderErrorFailMode(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.FailMode = config.FailModeError
+	})
+	th.redis.Close()
+
+	w := post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", w.Code, w.Body)
+	}
+	if code := errorCodeOf(t, w); code != CodeRedisUnavailable {
+		t.Errorf("code = %q, want %q", code, CodeRedisUnavailable)
+	}
+}
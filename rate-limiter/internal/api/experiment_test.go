@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+func TestApplyExperiment(t *testing.T) {
+	control := CheckRequest{Key: "user:1", Capacity: 10, RefillRate: 1, WindowMillis: 60000, LeakRate: 2}
+	tests := []struct {
+		name       string
+		experiment *PolicyExperiment
+		want       CheckRequest // limits after applyExperiment
+		policy     string
+		code       string // error code, empty when it should apply
+	}{
+		{
+			name: "no experiment",
+			want: control,
+		},
+		{
+			name:       "weight 0 keeps control",
+			experiment: &PolicyExperiment{Weight: 0, Capacity: 99},
+			want:       control,
+			policy:     limiter.PolicyControl,
+		},
+		{
+			name:       "weight 1 swaps every limit",
+			experiment: &PolicyExperiment{Weight: 1, Capacity: 20, RefillRate: 4, WindowMillis: 500, LeakRate: 8},
+			want:       CheckRequest{Capacity: 20, RefillRate: 4, WindowMillis: 500, LeakRate: 8},
+			policy:     limiter.PolicyExperiment,
+		},
+		{
+			name:       "unset fields inherit control",
+			experiment: &PolicyExperiment{Weight: 1, Capacity: 20},
+			want:       CheckRequest{Capacity: 20, RefillRate: 1, WindowMillis: 60000, LeakRate: 2},
+			policy:     limiter.PolicyExperiment,
+		},
+		{
+			name:       "window_seconds converts",
+			experiment: &PolicyExperiment{Weight: 1, WindowSeconds: 30},
+			want:       CheckRequest{Capacity: 10, RefillRate: 1, WindowMillis: 30000, LeakRate: 2},
+			policy:     limiter.PolicyExperiment,
+		},
+		{
+			name:       "weight above 1",
+			experiment: &PolicyExperiment{Weight: 1.5},
+			code:       CodeInvalidExperiment,
+		},
+		{
+			name:       "negative weight",
+			experiment: &PolicyExperiment{Weight: -0.1},
+			code:       CodeInvalidExperiment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := control
+			req.Experiment = tt.experiment
+			err := applyExperiment(&req)
+			if tt.code != "" {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Code != tt.code {
+					t.Fatalf("err = %v, want a %s ValidationError", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyExperiment: %v", err)
+			}
+			if req.policy != tt.policy {
+				t.Errorf("policy = %q, want %q", req.policy, tt.policy)
+			}
+			if req.Capacity != tt.want.Capacity || req.RefillRate != tt.want.RefillRate ||
+				req.WindowMillis != tt.want.WindowMillis || req.LeakRate != tt.want.LeakRate {
+				t.Errorf("limits = capacity %d refill %v window %d leak %v, want %+v",
+					req.Capacity, req.RefillRate, req.WindowMillis, req.LeakRate, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyExperimentRoutesKeysConsistently(t *testing.T) {
+	for _, key := range []string{"user:1", "user:2", "user:3", "tenant:acme"} {
+		want := limiter.SelectPolicy(key, 0.5)
+		for i := 0; i < 3; i++ {
+			req := CheckRequest{Key: key, Capacity: 10, Experiment: &PolicyExperiment{Weight: 0.5, Capacity: 20}}
+			if err := applyExperiment(&req); err != nil {
+				t.Fatal(err)
+			}
+			wantCapacity := int64(10)
+			if want == limiter.PolicyExperiment {
+				wantCapacity = 20
+			}
+			if req.policy != want || req.Capacity != wantCapacity {
+				t.Errorf("%s: policy %q capacity %d, want %q capacity %d", key, req.policy, req.Capacity, want, wantCapacity)
+			}
+		}
+	}
+}
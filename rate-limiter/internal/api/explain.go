@@ -0,0 +1,82 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+// explainDecision renders a plain-English reason for a check result, for support tooling
+// Only built when the request asks for it (explain=true) so the hot path doesn't pay for it
+func explainDecision(req *CheckRequest, result *limiter.CheckResponse) string {
+	verdict := "allowed"
+	if !result.Allowed {
+		verdict = "denied"
+	}
+
+	if result.Degraded {
+		return fmt.Sprintf("%s without checking the limit: Redis was unavailable (fail mode decided)", verdict)
+	}
+
+	if !result.Allowed && !result.PenaltyUntil.IsZero() {
+		return fmt.Sprintf("%s: key is serving a repeat-offender penalty until %s",
+			verdict, result.PenaltyUntil.UTC().Format(time.RFC3339))
+	}
+
+	switch req.Algorithm {
+	case limiter.AlgorithmTokenBucket:
+		if result.Allowed {
+			return fmt.Sprintf("%s: %d of %d tokens remain, refilling at %s/s",
+				verdict, result.Remaining, req.Capacity, formatRate(req.RefillRate))
+		}
+		return fmt.Sprintf("%s: bucket of %d tokens is empty, refilling at %s/s",
+			verdict, req.Capacity, formatRate(req.RefillRate))
+
+	case limiter.AlgorithmSlidingWindow, limiter.AlgorithmSlidingWindowCounter:
+		if result.Allowed {
+			return fmt.Sprintf("%s: %d of %d requests remain in the last %s",
+				verdict, result.Remaining, req.Capacity, formatWindow(req.WindowMillis))
+		}
+		return fmt.Sprintf("%s: all %d requests in the last %s are used",
+			verdict, req.Capacity, formatWindow(req.WindowMillis))
+
+	case limiter.AlgorithmGCRA:
+		if result.Allowed {
+			return fmt.Sprintf("%s: %d more of a %d burst allowed, at %s/s sustained",
+				verdict, result.Remaining, req.Capacity, formatRate(req.RefillRate))
+		}
+		return fmt.Sprintf("%s: burst of %d is used up, at %s/s sustained",
+			verdict, req.Capacity, formatRate(req.RefillRate))
+
+	case limiter.AlgorithmLeakyBucket:
+		if result.Allowed {
+			return fmt.Sprintf("%s: %d of %d queue slots free, draining at %s/s",
+				verdict, result.Remaining, req.Capacity, formatRate(req.LeakRate))
+		}
+		return fmt.Sprintf("%s: queue of %d is full, draining at %s/s",
+			verdict, req.Capacity, formatRate(req.LeakRate))
+
+	case limiter.AlgorithmQuota:
+		if result.Allowed {
+			return fmt.Sprintf("%s: %d of %d left in the %s quota", verdict, result.Remaining, req.Capacity, req.Period)
+		}
+		return fmt.Sprintf("%s: %s quota of %d is used up until it resets", verdict, req.Period, req.Capacity)
+	}
+
+	return fmt.Sprintf("%s: %d of %d remaining", verdict, result.Remaining, req.Capacity)
+}
+
+// formatWindow prints whole seconds as "60s" and anything finer as "500ms"
+func formatWindow(ms int64) string {
+	if ms%1000 == 0 {
+		return fmt.Sprintf("%ds", ms/1000)
+	}
+	return fmt.Sprintf("%dms", ms)
+}
+
+// formatRate prints 10 as "10" and 0.5 as "0.5" rather than "10.000000"
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+func TestExplainDecisionDescribesAlgorithmState(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    CheckRequest
+		result limiter.CheckResponse
+		want   string
+	}{
+		{
+			name:   "token bucket allowed",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmTokenBucket, Capacity: 100, RefillRate: 10},
+			result: limiter.CheckResponse{Allowed: true, Remaining: 42},
+			want:   "allowed: 42 of 100 tokens remain, refilling at 10/s",
+		},
+		{
+			name:   "token bucket denied",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmTokenBucket, Capacity: 100, RefillRate: 0.5},
+			result: limiter.CheckResponse{},
+			want:   "denied: bucket of 100 tokens is empty, refilling at 0.5/s",
+		},
+		{
+			name:   "sliding window allowed",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmSlidingWindow, Capacity: 10, WindowMillis: 60000},
+			result: limiter.CheckResponse{Allowed: true, Remaining: 3},
+			want:   "allowed: 3 of 10 requests remain in the last 60s",
+		},
+		{
+			name:   "sliding window counter denied",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmSlidingWindowCounter, Capacity: 10, WindowMillis: 1500},
+			result: limiter.CheckResponse{},
+			want:   "denied: all 10 requests in the last 1500ms are used",
+		},
+		{
+			name:   "leaky bucket denied",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmLeakyBucket, Capacity: 5, LeakRate: 2},
+			result: limiter.CheckResponse{},
+			want:   "denied: queue of 5 is full, draining at 2/s",
+		},
+		{
+			name:   "quota allowed",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmQuota, Capacity: 1000, Period: "daily"},
+			result: limiter.CheckResponse{Allowed: true, Remaining: 999},
+			want:   "allowed: 999 of 1000 left in the daily quota",
+		},
+		{
+			name:   "penalty",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmTokenBucket, Capacity: 100, RefillRate: 10},
+			result: limiter.CheckResponse{PenaltyUntil: time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC)},
+			want:   "denied: key is serving a repeat-offender penalty until 2026-01-01T00:05:00Z",
+		},
+		{
+			name:   "fail mode",
+			req:    CheckRequest{Algorithm: limiter.AlgorithmTokenBucket, Capacity: 100, RefillRate: 10},
+			result: limiter.CheckResponse{Allowed: true, Degraded: true},
+			want:   "allowed without checking the limit: Redis was unavailable (fail mode decided)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := explainDecision(&tt.req, &tt.result); got != tt.want {
+				t.Errorf("explainDecision = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCheckExplainsOnlyWhenAsked(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	var resp CheckResponse
+	decode(t, post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":2,"refill_rate":1}`), &resp)
+	if resp.Explanation != "" {
+		t.Errorf("explanation = %q without explain, want none", resp.Explanation)
+	}
+
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":2,"refill_rate":1,"explain":true}`
+	decode(t, post(th.HandleCheck, "/check", body), &resp)
+	if want := "allowed: 0 of 2 tokens remain, refilling at 1/s"; resp.Explanation != want {
+		t.Errorf("allowed explanation = %q, want %q", resp.Explanation, want)
+	}
+
+	decode(t, post(th.HandleCheck, "/check", body), &resp)
+	if want := "denied: bucket of 2 tokens is empty, refilling at 1/s"; resp.Explanation != want {
+		t.Errorf("denied explanation = %q, want %q", resp.Explanation, want)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+)
+
+// fleetPeerTimeout bounds how long the aggregate view waits on a slow peer
+const fleetPeerTimeout = 1 * time.Second
+
+// FleetReport is the fleet-wide view combining this instance and its peers
+type FleetReport struct {
+	Instances   int            `json:"instances"`
+	Totals      metrics.Totals `json:"totals"`
+	Unreachable []string       `json:"unreachable,omitempty"`
+}
+
+// HandleFleetLocal reports this instance's counters for peers to pull
+func (h *Handler) HandleFleetLocal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	totals, err := h.metrics.ReadTotals()
+	if err != nil {
+		respondError(w, CodeInternal, "failed to read metrics", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, totals, http.StatusOK)
+}
+
+// HandleFleet pulls /fleet/local from every configured peer and sums them with our own
+// Pull-based and best effort - a down peer is listed as unreachable, not an error
+func (h *Handler) HandleFleet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	local, err := h.metrics.ReadTotals()
+	if err != nil {
+		respondError(w, CodeInternal, "failed to read metrics", http.StatusInternalServerError)
+		return
+	}
+
+	report := FleetReport{Instances: 1, Totals: local}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, peer := range h.cfg.FleetPeers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			totals, err := fetchPeerTotals(r.Context(), peer)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Unreachable = append(report.Unreachable, peer)
+				return
+			}
+			report.Instances++
+			report.Totals.Allowed += totals.Allowed
+			report.Totals.Blocked += totals.Blocked
+			report.Totals.RedisErrors += totals.RedisErrors
+		}(peer)
+	}
+	wg.Wait()
+
+	respondJSON(w, report, http.StatusOK)
+}
+
+// fetchPeerTotals pulls one peer's local counters, peer is a base URL like http://10.0.0.2:8080
+func fetchPeerTotals(ctx context.Context, peer string) (metrics.Totals, error) {
+	ctx, cancel := context.WithTimeout(ctx, fleetPeerTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+"/fleet/local", nil)
+	if err != nil {
+		return metrics.Totals{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return metrics.Totals{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return metrics.Totals{}, fmt.Errorf("peer returned %d", resp.StatusCode)
+	}
+
+	var totals metrics.Totals
+	err = json.NewDecoder(resp.Body).Decode(&totals)
+	return totals, err
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+)
+
+// serveFleetLocal exposes th's /fleet/local on a test server and returns its base URL
+func serveFleetLocal(t *testing.T, th *testHandler) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fleet/local", th.HandleFleetLocal)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestHandleFleetSumsPeers(t *testing.T) {
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`
+
+	peer := newTestHandler(t, nil)
+	for i := 0; i < 3; i++ {
+		post(peer.HandleCheck, "/check", body) // 1 allowed, 2 blocked
+	}
+	peerURL := serveFleetLocal(t, peer)
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.FleetPeers = []string{peerURL, down.URL}
+	})
+	for i := 0; i < 2; i++ {
+		post(th.HandleCheck, "/check", body) // 1 allowed, 1 blocked
+	}
+
+	w := get(th.HandleFleet, "/fleet")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var report FleetReport
+	decode(t, w, &report)
+	if report.Instances != 2 {
+		t.Errorf("instances = %d, want 2", report.Instances)
+	}
+	if want := (metrics.Totals{Allowed: 2, Blocked: 3}); report.Totals != want {
+		t.Errorf("totals = %+v, want %+v", report.Totals, want)
+	}
+	if len(report.Unreachable) != 1 || report.Unreachable[0] != down.URL {
+		t.Errorf("unreachable = %v, want [%s]", report.Unreachable, down.URL)
+	}
+}
+
+func TestHandleFleetLocalReportsOwnCounters(t *testing.T) {
+	th := newTestHandler(t, nil)
+	post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`)
+
+	var totals metrics.Totals
+	decode(t, get(th.HandleFleetLocal, "/fleet/local"), &totals)
+	if want := (metrics.Totals{Allowed: 1}); totals != want {
+		t.Errorf("totals = %+v, want %+v", totals, want)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"github.com/piyushpatra/rate-limiter/internal/pb"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer serves the rate limiter over gRPC for callers that want to skip JSON/HTTP
+// It wraps the HTTP Handler so both transports share defaults, validation and error mapping
+type GRPCServer struct {
+	pb.UnimplementedRateLimiterServer
+	h *Handler
+}
+
+func NewGRPCServer(h *Handler) *GRPCServer {
+	return &GRPCServer{h: h}
+}
+
+// Check is the gRPC equivalent of POST /check
+// A blocked request is a normal response (allowed=false), not an error
+func (s *GRPCServer) Check(ctx context.Context, in *pb.CheckRequest) (*pb.CheckResponse, error) {
+	req := checkRequestFromProto(in)
+	// No forwarding headers over gRPC, so "$ip" is always the peer
+	applyClientIP(&req, grpcPeerIP(ctx))
+
+	if err := s.h.prepareCheckRequest(&req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result, err := s.h.limiter.Check(ctx, req.toLimiter())
+	if err != nil {
+		return nil, grpcCheckError(ctx, err)
+	}
+
+	// Dry runs aren't enforced, so they shouldn't slow the caller down either
+	if s.h.cfg.BackpressureEnabled && result.Allowed && !req.DryRun {
+		applyBackpressure(ctx, backpressureDelay(result.Remaining, req.Capacity,
+			s.h.cfg.BackpressureThreshold, s.h.cfg.BackpressureMaxDelay))
+	}
+
+	return checkResponseToProto(newCheckResponse(&req, result)), nil
+}
+
+// CheckBatch is the gRPC equivalent of POST /check/batch
+func (s *GRPCServer) CheckBatch(ctx context.Context, in *pb.CheckBatchRequest) (*pb.CheckBatchResponse, error) {
+	if len(in.Checks) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "batch must contain at least one check")
+	}
+	if len(in.Checks) > maxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("batch cannot contain more than %d checks", maxBatchSize))
+	}
+
+	reqs := make([]CheckRequest, len(in.Checks))
+	for i, check := range in.Checks {
+		reqs[i] = checkRequestFromProto(check)
+	}
+
+	out := &pb.CheckBatchResponse{Results: make([]*pb.CheckBatchResult, len(reqs))}
+	for i, resp := range s.h.checkBatch(ctx, reqs, grpcPeerIP(ctx)) {
+		result := &pb.CheckBatchResult{Error: resp.Error}
+		if resp.CheckResponse != nil {
+			result.Response = checkResponseToProto(resp.CheckResponse)
+		}
+		out.Results[i] = result
+	}
+	return out, nil
+}
+
+// Health is the gRPC equivalent of GET /readyz - not-ready states return Unavailable
+func (s *GRPCServer) Health(ctx context.Context, _ *pb.HealthRequest) (*pb.HealthResponse, error) {
+	state, _ := s.h.healthStatus(ctx)
+	if !healthReady(state) {
+		return nil, status.Error(codes.Unavailable, state)
+	}
+	return &pb.HealthResponse{Status: state}, nil
+}
+
+// checkRequestFromProto converts the gRPC message into the shared API request type
+func checkRequestFromProto(in *pb.CheckRequest) CheckRequest {
+	req := CheckRequest{
+		Key:           in.Key,
+		Algorithm:     in.Algorithm,
+		Capacity:      in.Capacity,
+		RefillRate:    in.RefillRate,
+		WindowSeconds: in.WindowSeconds,
+		LeakRate:      in.LeakRate,
+		Cost:          in.Cost,
+		FailMode:      in.FailMode,
+		Source:        in.Source,
+		Tier:          in.Tier,
+		NowMillis:     in.NowMs,
+		Explain:       in.Explain,
+		Namespace:     in.Namespace,
+		WindowMillis:  in.WindowMs,
+		Period:        in.Period,
+		DryRun:        in.DryRun,
+		RequestID:     in.RequestId,
+		Profile:       in.Profile,
+		Precise:       in.Precise,
+
+		PenaltyBaseSeconds: in.PenaltyBaseSeconds,
+		PenaltyMaxSeconds:  in.PenaltyMaxSeconds,
+	}
+	if exp := in.Experiment; exp != nil {
+		req.Experiment = &PolicyExperiment{
+			Weight:        exp.Weight,
+			Capacity:      exp.Capacity,
+			RefillRate:    exp.RefillRate,
+			WindowMillis:  exp.WindowMs,
+			WindowSeconds: exp.WindowSeconds,
+			LeakRate:      exp.LeakRate,
+		}
+	}
+	return req
+}
+
+// checkResponseToProto converts the shared API response into the gRPC message
+func checkResponseToProto(resp *CheckResponse) *pb.CheckResponse {
+	return &pb.CheckResponse{
+		Allowed:         resp.Allowed,
+		Remaining:       resp.Remaining,
+		RetryAfterMs:    resp.RetryAfterMs,
+		Policy:          resp.Policy,
+		Explanation:     resp.Explanation,
+		ResetAt:         resp.ResetAt,
+		Degraded:        resp.Degraded,
+		PenaltyUntil:    resp.PenaltyUntil,
+		SuggestedPollMs: resp.SuggestedPollMs,
+		RemainingFloat:  resp.RemainingFloat,
+	}
+}
+
+// grpcCheckError maps a limiter error to a gRPC status, mirroring checkErrorStatus
+func grpcCheckError(ctx context.Context, err error) error {
+	_, msg, _ := checkErrorStatus(ctx, err)
+
+	var scriptErr *redisclient.ScriptError
+	switch {
+	case errors.Is(err, redisclient.ErrWrongType):
+		return status.Error(codes.FailedPrecondition, msg)
+	case errors.Is(err, limiter.ErrSourceKeyQuota):
+		return status.Error(codes.ResourceExhausted, msg)
+	case errors.Is(err, limiter.ErrRedisUnavailable), errors.Is(err, redisclient.ErrClusterRedirect):
+		return status.Error(codes.Unavailable, msg)
+	case errors.As(err, &scriptErr), errors.Is(err, limiter.ErrInvalidKey), errors.Is(err, redisclient.ErrCrossSlot):
+		return status.Error(codes.InvalidArgument, msg)
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+// grpcPeerIP is the caller's IP, the gRPC counterpart of peerIP
+func grpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves newTestHandler's Handler over an in-memory gRPC connection
+func newGRPCClient(t *testing.T, setup func(cfg *config.Config)) (*testHandler, pb.RateLimiterClient) {
+	t.Helper()
+	th := newTestHandler(t, setup)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterRateLimiterServer(srv, NewGRPCServer(th.Handler))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return th, pb.NewRateLimiterClient(conn)
+}
+
+func TestGRPCCheckMatchesJSONResponse(t *testing.T) {
+	th, client := newGRPCClient(t, nil)
+	ctx := context.Background()
+
+	req := &pb.CheckRequest{Key: "user:1", Algorithm: "token_bucket", Capacity: 1, RefillRate: 1, Precise: true}
+	resp, err := client.Check(ctx, req)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	var want CheckResponse
+	decode(t, post(th.HandleCheck, "/check", `{"key":"user:2","algorithm":"token_bucket","capacity":1,"refill_rate":1,"precise":true}`), &want)
+	if !resp.Allowed || resp.Remaining != want.Remaining || resp.ResetAt != want.ResetAt || resp.ResetAt == 0 ||
+		resp.RemainingFloat == nil || *resp.RemainingFloat != *want.RemainingFloat {
+		t.Errorf("gRPC response %+v doesn't match JSON %+v", resp, want)
+	}
+
+	resp, err = client.Check(ctx, req)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.Allowed || resp.RetryAfterMs != 1000 {
+		t.Errorf("second check = %+v, want blocked with retry_after_ms 1000", resp)
+	}
+}
+
+func TestGRPCCheckPassesJSONOnlyFields(t *testing.T) {
+	th, client := newGRPCClient(t, nil)
+	ctx := context.Background()
+
+	// namespace and window_ms reach the limiter
+	window := &pb.CheckRequest{Key: "user:1", Namespace: "billing", Algorithm: "sliding_window", Capacity: 1, WindowMs: 500}
+	if _, err := client.Check(ctx, window); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !th.redis.Exists("billing:user:1") {
+		t.Errorf("keys = %v, want billing:user:1", th.redis.Keys())
+	}
+	th.clock.Advance(500 * time.Millisecond)
+	if resp, err := client.Check(ctx, window); err != nil || !resp.Allowed {
+		t.Errorf("check after the 500ms window = %+v, %v, want allowed", resp, err)
+	}
+
+	// A dry run doesn't consume and suggests when to poll
+	dryRun := &pb.CheckRequest{Key: "user:2", Algorithm: "token_bucket", Capacity: 1, RefillRate: 4, DryRun: true}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Check(ctx, dryRun)
+		if err != nil || !resp.Allowed || resp.SuggestedPollMs == nil || *resp.SuggestedPollMs != 0 {
+			t.Fatalf("dry run %d = %+v, %v, want allowed with suggested_poll_ms 0", i+1, resp, err)
+		}
+	}
+
+	// A retried request_id replays the first decision without consuming again
+	idempotent := &pb.CheckRequest{Key: "user:3", Algorithm: "token_bucket", Capacity: 2, RefillRate: 1, RequestId: "req-1"}
+	for i := 0; i < 2; i++ {
+		if resp, err := client.Check(ctx, idempotent); err != nil || resp.Remaining != 1 {
+			t.Errorf("attempt %d = %+v, %v, want remaining 1", i+1, resp, err)
+		}
+	}
+
+	// A block with penalties on reports when the penalty ends
+	penalized := &pb.CheckRequest{Key: "user:4", Algorithm: "token_bucket", Capacity: 1, RefillRate: 1, PenaltyBaseSeconds: 10, PenaltyMaxSeconds: 60}
+	client.Check(ctx, penalized)
+	resp, err := client.Check(ctx, penalized)
+	if err != nil || resp.Allowed || resp.PenaltyUntil != resetUnix(time.UnixMilli(th.clock.NowMillis()).Add(10*time.Second)) {
+		t.Errorf("blocked check = %+v, %v, want a penalty ending in 10s", resp, err)
+	}
+}
+
+func TestGRPCCheckReportsDegraded(t *testing.T) {
+	th, client := newGRPCClient(t, func(cfg *config.Config) { cfg.FailMode = config.FailModeOpen })
+	th.redis.Close()
+
+	resp, err := client.Check(context.Background(), &pb.CheckRequest{Key: "user:1", Algorithm: "token_bucket", Capacity: 1, RefillRate: 1})
+	if err != nil || !resp.Allowed || !resp.Degraded {
+		t.Errorf("check with Redis down = %+v, %v, want allowed and degraded", resp, err)
+	}
+}
+
+func TestGRPCDryRunSkipsBackpressure(t *testing.T) {
+	_, client := newGRPCClient(t, func(cfg *config.Config) {
+		cfg.BackpressureEnabled = true
+		cfg.BackpressureThreshold = 1
+		cfg.BackpressureMaxDelay = 500 * time.Millisecond
+	})
+	req := &pb.CheckRequest{Key: "user:1", Algorithm: "token_bucket", Capacity: 1, RefillRate: 0.001}
+
+	req.DryRun = true
+	start := time.Now()
+	if _, err := client.Check(context.Background(), req); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 250*time.Millisecond {
+		t.Errorf("dry run held for %v, want no backpressure", elapsed)
+	}
+
+	req.DryRun = false
+	start = time.Now()
+	if _, err := client.Check(context.Background(), req); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("enforced check held for %v, want the full 500ms with nothing left", elapsed)
+	}
+}
+
+func TestGRPCCheckBatch(t *testing.T) {
+	_, client := newGRPCClient(t, nil)
+
+	resp, err := client.CheckBatch(context.Background(), &pb.CheckBatchRequest{Checks: []*pb.CheckRequest{
+		{Key: "user:1", Algorithm: "token_bucket", Capacity: 2, RefillRate: 1, DryRun: true},
+		{Key: "user:2", Algorithm: "token_bucket"},
+	}})
+	if err != nil {
+		t.Fatalf("CheckBatch: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(resp.Results))
+	}
+	if r := resp.Results[0].Response; r == nil || !r.Allowed || r.Remaining != 1 || r.ResetAt == 0 || r.SuggestedPollMs == nil {
+		t.Errorf("first result = %+v, want an allowed dry run with reset_at and suggested_poll_ms", resp.Results[0])
+	}
+	if r := resp.Results[1]; r.Response != nil || r.Error == "" {
+		t.Errorf("second result = %+v, want an error", r)
+	}
+}
+
+func TestGRPCCheckErrors(t *testing.T) {
+	th, client := newGRPCClient(t, nil)
+	ctx := context.Background()
+
+	_, err := client.Check(ctx, &pb.CheckRequest{Algorithm: "token_bucket", Capacity: 1, RefillRate: 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing key: %v, want InvalidArgument", err)
+	}
+
+	post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`)
+	_, err = client.Check(ctx, &pb.CheckRequest{Key: "user:1", Algorithm: "sliding_window", Capacity: 1, WindowSeconds: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("key used by another algorithm: %v, want FailedPrecondition", err)
+	}
+}
@@ -1,11 +1,21 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/piyushpatra/rate-limiter/internal/config"
 	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/piyushpatra/rate-limiter/internal/profiles"
 	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -13,128 +23,706 @@ import (
 type Handler struct {
 	limiter *limiter.Limiter
 	redis   *redisclient.Client
+	cfg     *config.Config
+	metrics *metrics.Metrics
+
+	// profiles are the named limits from PROFILES_FILE
+	profiles *profiles.Store
+
+	// trustedProxies are the TRUSTED_PROXIES ranges whose forwarding headers are believed
+	trustedProxies []*net.IPNet
+
+	// readyAt is when the warmup delay ends and health starts reflecting real state
+	readyAt time.Time
+
+	// streamsClosed is closed by CloseStreams to end every /check/stream connection,
+	// and streams counts the open ones so shutdown can wait for them
+	streamsClosed    chan struct{}
+	closeStreamsOnce sync.Once
+	streams          sync.WaitGroup
 }
 
-func NewHandler(limiter *limiter.Limiter, redis *redisclient.Client) *Handler {
+func NewHandler(limiter *limiter.Limiter, redis *redisclient.Client, cfg *config.Config, m *metrics.Metrics, profiles *profiles.Store) *Handler {
+	// Already checked by cfg.Validate
+	trusted, _ := cfg.TrustedProxyNets()
 	return &Handler{
-		limiter: limiter,
-		redis:   redis,
+		limiter:        limiter,
+		redis:          redis,
+		cfg:            cfg,
+		metrics:        m,
+		profiles:       profiles,
+		trustedProxies: trusted,
+		readyAt:        time.Now().Add(cfg.WarmupDelay),
+
+		streamsClosed: make(chan struct{}),
 	}
 }
 
 // CheckRequest represents the incoming rate limit check request
 type CheckRequest struct {
 	Key           string  `json:"key"`
+	Profile       string  `json:"profile,omitempty"` // named limits from PROFILES_FILE, explicit fields override
+
+	// Namespace keeps teams' keys apart - stored as REDIS_KEY_PREFIX:namespace:key
+	Namespace string `json:"namespace,omitempty"`
+
 	Algorithm     string  `json:"algorithm"`
 	Capacity      int64   `json:"capacity"`
-	RefillRate    float64 `json:"refill_rate,omitempty"`    // for token_bucket
-	WindowSeconds int64   `json:"window_seconds,omitempty"` // for sliding_window
+	RefillRate    float64 `json:"refill_rate,omitempty"`    // for token_bucket and gcra
+	WindowMillis  int64   `json:"window_ms,omitempty"`      // for sliding_window and sliding_window_counter
+	WindowSeconds int64   `json:"window_seconds,omitempty"` // convenience for window_ms, which wins if both are set
+	LeakRate      float64 `json:"leak_rate,omitempty"`      // for leaky_bucket
+	Period        string  `json:"period,omitempty"`         // for quota: daily or monthly
+
+	// Cost is how many units this request consumes (e.g. a bulk call costs 10), defaults to 1
+	Cost int64 `json:"cost,omitempty"`
+
+	// FailMode overrides FAIL_MODE for this check, e.g. "closed" for billing-sensitive limits
+	FailMode string `json:"fail_mode,omitempty"`
+
+	// Source identifies the caller for the distinct-key quota, defaults to the client IP
+	Source string `json:"source,omitempty"`
+
+	// Tier labels the allowed/blocked metrics - must be listed in METRIC_TIERS to count separately
+	Tier string `json:"tier,omitempty"`
+
+	// NowMillis drives the scripts' clock instead of the server's (testing mode only)
+	// Ignored unless ALLOW_CLIENT_TIMESTAMPS is set outside production
+	NowMillis int64 `json:"now_ms,omitempty"`
+
+	// DryRun evaluates the limit without consuming from it and always allows
+	// The would-be decision shows up in the metrics under dry_run="true"
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// RequestID makes a retried check idempotent - the same id within DEDUP_TTL returns
+	// the first decision without consuming again. token_bucket only
+	RequestID string `json:"request_id,omitempty"`
+
+	// PenaltyBaseSeconds turns on repeat-offender penalties: a block shuts the key for this
+	// long, doubling with each consecutive block up to PenaltyMaxSeconds
+	PenaltyBaseSeconds int64 `json:"penalty_base_seconds,omitempty"`
+	PenaltyMaxSeconds  int64 `json:"penalty_max_seconds,omitempty"`
+
+	// Precise adds remaining_float, the token bucket's unfloored token count
+	Precise bool `json:"precise,omitempty"`
+
+	// Explain asks for a human-readable explanation of the decision (support/debugging)
+	Explain bool `json:"explain,omitempty"`
+
+	// Experiment optionally routes a fraction of keys to alternate limits (A/B testing)
+	Experiment *PolicyExperiment `json:"experiment,omitempty"`
+
+	// policy records which side of the experiment was applied by prepareCheckRequest
+	policy string
+}
+
+// PolicyExperiment describes the alternate limits applied to `weight` of keys
+// Zero-valued limit fields inherit the control value
+type PolicyExperiment struct {
+	Weight        float64 `json:"weight"`
+	Capacity      int64   `json:"capacity,omitempty"`
+	RefillRate    float64 `json:"refill_rate,omitempty"`
+	WindowMillis  int64   `json:"window_ms,omitempty"`
+	WindowSeconds int64   `json:"window_seconds,omitempty"`
+	LeakRate      float64 `json:"leak_rate,omitempty"`
+}
+
+// toLimiter converts the API request into the limiter's request type
+func (req *CheckRequest) toLimiter() limiter.CheckRequest {
+	return limiter.CheckRequest{
+		Key:           req.Key,
+		Namespace:     req.Namespace,
+		Algorithm:     req.Algorithm,
+		Capacity:      req.Capacity,
+		RefillRate:    req.RefillRate,
+		WindowMillis:  req.WindowMillis,
+		LeakRate:      req.LeakRate,
+		Period:        req.Period,
+		Source:        req.Source,
+		Tier:          req.Tier,
+		Cost:          req.Cost,
+		FailMode:      req.FailMode,
+		NowMillis:     req.NowMillis,
+		DryRun:        req.DryRun,
+		RequestID:     req.RequestID,
+		PenaltyBase:   time.Duration(req.PenaltyBaseSeconds) * time.Second,
+		PenaltyMax:    time.Duration(req.PenaltyMaxSeconds) * time.Second,
+		Precise:       req.Precise,
+	}
 }
 
 // CheckResponse represents the rate limit check result
 type CheckResponse struct {
-	Allowed   bool  `json:"allowed"`
-	Remaining int64 `json:"remaining"`
+	Allowed   bool   `json:"allowed"`
+	Remaining int64  `json:"remaining"`
+	Policy    string `json:"policy,omitempty"` // set only when an experiment was supplied
+
+	// RemainingFloat is remaining before flooring - set only when precise=true
+	RemainingFloat *float64 `json:"remaining_float,omitempty"`
+
+	// ResetAt is when remaining next goes up, in unix seconds (omitted when unknown)
+	ResetAt int64 `json:"reset_at,omitempty"`
+
+	// RetryAfterMs is how long a blocked caller should wait before retrying - the
+	// millisecond version of the Retry-After header (omitted when allowed)
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+
+	// SuggestedPollMs is how long a dry run's caller should wait before checking again,
+	// 0 when the check would have been allowed - set only for dry runs Redis decided
+	SuggestedPollMs *int64 `json:"suggested_poll_ms,omitempty"`
+
+	// Degraded means Redis was unavailable and the decision came from FAIL_MODE, not the limit
+	Degraded bool `json:"degraded,omitempty"`
+
+	// PenaltyUntil is when the key's repeat-offender penalty ends, in unix seconds (omitted when none)
+	PenaltyUntil int64 `json:"penalty_until,omitempty"`
+
+	Explanation string `json:"explanation,omitempty"` // set only when explain=true
 }
 
 // HandleCheck processes rate limit check requests
 // This is the hot path - keep allocations minimal
 func (h *Handler) HandleCheck(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	start := time.Now()
+	var timing checkTiming
+
+	mode, err := h.checkMode(r)
+	if err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	var req CheckRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, "invalid request body", http.StatusBadRequest)
+	switch {
+	case r.Method == http.MethodPost:
+		if err := decodeBody(w, r, h.cfg.MaxBodyBytes, &req); err != nil {
+			respondBodyError(w, err)
+			return
+		}
+	case r.Method == http.MethodGet && h.cfg.CheckGetEnabled:
+		if err := checkRequestFromQuery(r.URL.Query(), &req); err != nil {
+			respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Every GET consumes from the limit, so nothing along the way may answer it from cache
+		w.Header().Set("Cache-Control", "no-store")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	timing.decode = time.Since(start)
+	applyClientIP(&req, h.clientIP(r))
 
-	// Validate request
-	if err := validateCheckRequest(&req); err != nil {
-		respondError(w, err.Error(), http.StatusBadRequest)
+	// Apply defaults and validate request
+	validateStart := time.Now()
+	if err := h.prepareCheckRequest(&req); err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
 		return
 	}
+	timing.validate = time.Since(validateStart)
 
 	// Execute rate limit check
-	result, err := h.limiter.Check(r.Context(), limiter.CheckRequest{
-		Key:           req.Key,
-		Algorithm:     req.Algorithm,
-		Capacity:      req.Capacity,
-		RefillRate:    req.RefillRate,
-		WindowSeconds: req.WindowSeconds,
-	})
+	checkStart := time.Now()
+	result, err := h.limiter.Check(r.Context(), req.toLimiter())
+	timing.check = time.Since(checkStart)
+
+	if h.cfg.DebugLogging {
+		if result != nil {
+			timing.redis = result.Timing.Redis
+		}
+		setTimingHeader(w, timing)
+	}
 
 	if err != nil {
-		log.Printf("rate limit check error: %v", err)
-		respondError(w, "internal server error", http.StatusInternalServerError)
+		code, msg, status := checkErrorStatus(r.Context(), err)
+		respondError(w, code, msg, status)
 		return
 	}
 
-	respondJSON(w, CheckResponse{
+	// Dry runs aren't enforced, so they shouldn't slow the caller down either
+	if h.cfg.BackpressureEnabled && result.Allowed && !req.DryRun {
+		applyBackpressure(r.Context(), backpressureDelay(result.Remaining, req.Capacity,
+			h.cfg.BackpressureThreshold, h.cfg.BackpressureMaxDelay))
+	}
+
+	setRateLimitHeaders(w, req.Capacity, result.Remaining)
+	setResetHeader(w, result.ResetAt)
+	if !result.Allowed {
+		setRetryAfter(w, result.RetryAfter)
+	}
+
+	resp := newCheckResponse(&req, result)
+
+	// Gateways only look at the status, so a block has to be a 429 for them to enforce it
+	status := http.StatusOK
+	if mode == config.CheckModeGateway && !result.Allowed {
+		status = http.StatusTooManyRequests
+	}
+	respondJSON(w, resp, status)
+}
+
+// newCheckResponse is the response body for req's decision, shared by /check, /check/batch, /check/stream and gRPC
+func newCheckResponse(req *CheckRequest, result *limiter.CheckResponse) *CheckResponse {
+	resp := &CheckResponse{
 		Allowed:   result.Allowed,
 		Remaining: result.Remaining,
-	}, http.StatusOK)
+		ResetAt:   resetUnix(result.ResetAt),
+		Policy:    req.policy,
+		Degraded:  result.Degraded,
+
+		RetryAfterMs: retryAfterMillis(result.RetryAfter),
+		PenaltyUntil: resetUnix(result.PenaltyUntil),
+	}
+	if req.Precise {
+		resp.RemainingFloat = &result.RemainingFloat
+	}
+	if req.DryRun {
+		resp.SuggestedPollMs = suggestedPollMillis(result)
+	}
+	if req.Explain {
+		resp.Explanation = explainDecision(req, result)
+	}
+	return resp
 }
 
-// HandleHealth checks service health
-// Returns 200 if healthy, 503 if Redis is down
+// checkErrorStatus maps a limiter error to the code, message and status returned to the client
+// Unexpected errors are logged with the request id so a 500 can be traced back
+func checkErrorStatus(ctx context.Context, err error) (string, string, int) {
+	if errors.Is(err, redisclient.ErrWrongType) {
+		return CodeKeyTypeConflict, "key is already in use by a different algorithm", http.StatusConflict
+	}
+
+	if errors.Is(err, redisclient.ErrCrossSlot) {
+		return CodeCrossSlot, "keys hash to different redis cluster slots - give keys checked together a shared {hash tag}", http.StatusBadRequest
+	}
+
+	if errors.Is(err, redisclient.ErrClusterRedirect) {
+		return CodeRedisResharding, "redis cluster is resharding this key, retry shortly", http.StatusServiceUnavailable
+	}
+
+	if errors.Is(err, limiter.ErrSourceKeyQuota) {
+		return CodeSourceQuotaExceeded, err.Error(), http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, limiter.ErrInvalidKey) {
+		return CodeInvalidKey, err.Error(), http.StatusBadRequest
+	}
+
+	// FAIL_MODE=error leaves the decision to the caller
+	if errors.Is(err, limiter.ErrRedisUnavailable) {
+		return CodeRedisUnavailable, "rate limit state unavailable", http.StatusServiceUnavailable
+	}
+
+	var scriptErr *redisclient.ScriptError
+	if errors.As(err, &scriptErr) {
+		return CodeScriptError, scriptErr.Message, http.StatusBadRequest
+	}
+
+	logging.Printf("rate limit check error (request_id=%s): %v", RequestIDFromContext(ctx), err)
+	return CodeInternal, "internal server error", http.StatusInternalServerError
+}
+
+// HandleLivez reports that the process is up and serving HTTP
+// Always 200 - Redis trouble is a readiness concern, restarting the pod wouldn't fix it
+func (h *Handler) HandleLivez(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	respondJSON(w, map[string]string{"status": "alive"}, http.StatusOK)
+}
+
+// HandleHealth is the original health endpoint, kept as an alias of /readyz
 func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	h.HandleReadyz(w, r)
+}
+
+// HandleReadyz reports whether this instance should receive traffic
+// Returns 503 while inside the warmup delay, when Redis is down and
+// READINESS_REQUIRES_REDIS (by default: FAIL_MODE=closed) says that matters, or when an
+// algorithm's script fails against a throwaway key
+func (h *Handler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	resp := map[string]interface{}{}
+	// Lets operators confirm a profiles file edit has been picked up
+	if version := h.profiles.Version(); version != "" {
+		resp["profiles_version"] = version
+	}
+
+	status, scriptErrs := h.healthStatus(r.Context())
+	resp["status"] = status
+	switch status {
+	case healthUnhealthy:
+		resp["error"] = "redis connection failed"
+		respondJSON(w, resp, http.StatusServiceUnavailable)
+	case healthDegraded:
+		// Still ready: checks are answered by FAIL_MODE until Redis is back
+		resp["error"] = "redis connection failed"
+		resp["fail_mode"] = h.cfg.FailMode
+		respondJSON(w, resp, http.StatusOK)
+	case healthScriptsFailing:
+		// Redis is up but checks would fail - say which algorithms, and why
+		failed := make(map[string]string, len(scriptErrs))
+		for name, err := range scriptErrs {
+			failed[name] = err.Error()
+		}
+		resp["error"] = "lua scripts failing"
+		resp["scripts"] = failed
+		respondJSON(w, resp, http.StatusServiceUnavailable)
+	case healthWarmingUp:
+		respondJSON(w, resp, http.StatusServiceUnavailable)
+	default:
+		respondJSON(w, resp, http.StatusOK)
+	}
+}
+
+// Health states shared by the HTTP and gRPC health endpoints
+const (
+	healthHealthy        = "healthy"
+	healthDegraded       = "degraded"
+	healthWarmingUp      = "warming_up"
+	healthUnhealthy      = "unhealthy"
+	healthScriptsFailing = "scripts_failing" // Redis is up but an algorithm's script won't run
+)
+
+// healthReady reports whether a health state should keep the instance in rotation
+func healthReady(state string) bool {
+	return state == healthHealthy || state == healthDegraded
+}
+
+// healthStatus reports whether this instance should receive traffic, along with the
+// algorithms whose scripts failed when the state is healthScriptsFailing
+func (h *Handler) healthStatus(ctx context.Context) (string, map[string]error) {
+	// Hold off traffic until dependencies (e.g. replica sync) have had time to settle
+	if time.Now().Before(h.readyAt) {
+		return healthWarmingUp, nil
+	}
+
 	// Check Redis connectivity
-	if err := h.redis.Ping(r.Context()); err != nil {
-		respondJSON(w, map[string]string{
-			"status": "unhealthy",
-			"error":  "redis connection failed",
-		}, http.StatusServiceUnavailable)
-		return
+	if err := h.redis.Ping(ctx); err != nil {
+		if h.cfg.RedisRequiredForReadiness() {
+			return healthUnhealthy, nil
+		}
+		return healthDegraded, nil
 	}
 
-	respondJSON(w, map[string]string{
-		"status": "healthy",
-	}, http.StatusOK)
+	// A reachable Redis is no use if the scripts can't run there (bad LUA_DIR, EVAL denied)
+	if failed := h.limiter.CheckScripts(ctx); failed != nil {
+		return healthScriptsFailing, failed
+	}
+
+	return healthHealthy, nil
 }
 
 // HandleMetrics exposes Prometheus metrics
+// Buffered observations are flushed first so a scrape sees every check up to now
 func (h *Handler) HandleMetrics() http.Handler {
-	return promhttp.Handler()
+	next := promhttp.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.metrics.Flush()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// prepareCheckRequest applies server-side defaults then validates
+// Every check entry point goes through here so defaulting can't diverge between them
+func (h *Handler) prepareCheckRequest(req *CheckRequest) error {
+	if err := h.applyProfile(req); err != nil {
+		return err
+	}
+	h.applyRoute(req)
+	applyCheckDefaults(req, h.cfg)
+	if err := applyExperiment(req); err != nil {
+		return err
+	}
+	if err := validateNamespace(req.Namespace, h.cfg); err != nil {
+		return err
+	}
+	if err := validateCheckRequest(req); err != nil {
+		return err
+	}
+	return validateLimitCaps(req, h.cfg)
+}
+
+// applyProfile fills any limit fields the request left empty from its named profile
+// It runs before the other defaults so a profile's algorithm beats DEFAULT_ALGORITHM
+func (h *Handler) applyProfile(req *CheckRequest) error {
+	if req.Profile == "" {
+		return nil
+	}
+	p, ok := h.profiles.Get(req.Profile)
+	if !ok {
+		return &ValidationError{CodeUnknownProfile, "unknown profile: " + req.Profile}
+	}
+
+	if req.Algorithm == "" {
+		req.Algorithm = p.Algorithm
+	}
+	if req.Capacity == 0 {
+		req.Capacity = p.Capacity
+	}
+	if req.RefillRate == 0 {
+		req.RefillRate = p.RefillRate
+	}
+	if req.WindowSeconds == 0 && req.WindowMillis == 0 {
+		req.WindowSeconds = p.WindowSeconds
+	}
+	if req.LeakRate == 0 {
+		req.LeakRate = p.LeakRate
+	}
+	if req.Period == "" {
+		req.Period = p.Period
+	}
+	return nil
+}
+
+// applyRoute fills an algorithm-less request from the first matching ROUTING_RULES_FILE rule
+// It runs after the profile and before DEFAULT_ALGORITHM, so explicit fields still win
+func (h *Handler) applyRoute(req *CheckRequest) {
+	if req.Algorithm != "" {
+		return
+	}
+	rule, ok := h.limiter.Route(req.Key, req.Tier)
+	if !ok {
+		return
+	}
+
+	req.Algorithm = rule.Algorithm
+	if req.Capacity == 0 {
+		req.Capacity = rule.Capacity
+	}
+	if req.RefillRate == 0 {
+		req.RefillRate = rule.RefillRate
+	}
+	if req.WindowSeconds == 0 && req.WindowMillis == 0 {
+		req.WindowMillis = rule.WindowMillis
+	}
+	if req.LeakRate == 0 {
+		req.LeakRate = rule.LeakRate
+	}
+	if req.Period == "" {
+		req.Period = rule.Period
+	}
+}
+
+// applyExperiment swaps in the experiment limits for keys routed to it
+// Routing hashes the key, so a given key sees consistent limits across requests
+func applyExperiment(req *CheckRequest) error {
+	exp := req.Experiment
+	if exp == nil {
+		return nil
+	}
+	if exp.Weight < 0 || exp.Weight > 1 {
+		return &ValidationError{CodeInvalidExperiment, "experiment.weight must be between 0 and 1"}
+	}
+
+	req.policy = limiter.SelectPolicy(req.Key, exp.Weight)
+	if req.policy != limiter.PolicyExperiment {
+		return nil
+	}
+
+	if exp.Capacity != 0 {
+		req.Capacity = exp.Capacity
+	}
+	if exp.RefillRate != 0 {
+		req.RefillRate = exp.RefillRate
+	}
+	// Defaults have already turned window_seconds into window_ms
+	if exp.WindowMillis != 0 {
+		req.WindowMillis = exp.WindowMillis
+	} else if exp.WindowSeconds != 0 {
+		req.WindowMillis = windowMillis(exp.WindowSeconds)
+	}
+	if exp.LeakRate != 0 {
+		req.LeakRate = exp.LeakRate
+	}
+	return nil
+}
+
+// applyCheckDefaults fills in fields the client may omit
+func applyCheckDefaults(req *CheckRequest, cfg *config.Config) {
+	if req.Algorithm == "" {
+		req.Algorithm = cfg.DefaultAlgorithm
+	}
+
+	if req.Cost == 0 {
+		req.Cost = 1
+	}
+
+	// Sliding windows run in milliseconds; window_seconds is kept for existing callers
+	if req.WindowMillis == 0 && req.WindowSeconds != 0 {
+		req.WindowMillis = windowMillis(req.WindowSeconds)
+	}
+
+	// Strictly refuse client clocks unless explicitly enabled in a non-production env
+	if !cfg.ClientTimestampsEnabled() {
+		req.NowMillis = 0
+	}
+}
+
+// validateLimitCaps enforces the operator's MAX_* settings (0 = no cap)
+// Runs after validateCheckRequest, so only parameters the algorithm uses are set
+// and huge windows can't create keys that effectively never expire
+func validateLimitCaps(req *CheckRequest, cfg *config.Config) error {
+	if cfg.MaxCapacity > 0 && req.Capacity > cfg.MaxCapacity {
+		return &ValidationError{CodeLimitTooLarge, fmt.Sprintf("capacity cannot exceed %d", cfg.MaxCapacity)}
+	}
+
+	if cfg.MaxWindowSeconds > 0 && req.WindowMillis > cfg.MaxWindowSeconds*1000 {
+		return &ValidationError{CodeLimitTooLarge, fmt.Sprintf("window cannot exceed %d seconds", cfg.MaxWindowSeconds)}
+	}
+
+	// Leak rate is the same kind of quantity as a refill rate, so one cap covers both
+	if cfg.MaxRefillRate > 0 && req.RefillRate > cfg.MaxRefillRate {
+		return &ValidationError{CodeLimitTooLarge, fmt.Sprintf("refill_rate cannot exceed %s", formatRate(cfg.MaxRefillRate))}
+	}
+	if cfg.MaxRefillRate > 0 && req.LeakRate > cfg.MaxRefillRate {
+		return &ValidationError{CodeLimitTooLarge, fmt.Sprintf("leak_rate cannot exceed %s", formatRate(cfg.MaxRefillRate))}
+	}
+
+	return nil
+}
+
+// validateNamespace enforces REQUIRE_NAMESPACE and keeps namespaces from
+// containing the separator, so "a:b" + "c" can't collide with "a" + "b:c"
+func validateNamespace(namespace string, cfg *config.Config) error {
+	if namespace == "" && cfg.RequireNamespace {
+		return &ValidationError{CodeNamespaceRequired, "namespace is required"}
+	}
+	if strings.Contains(namespace, ":") {
+		return &ValidationError{CodeInvalidNamespace, "namespace cannot contain ':'"}
+	}
+	return nil
+}
+
+// windowMillis converts window_seconds, saturating instead of overflowing
+// so an absurd window is rejected as too large rather than wrapping around
+func windowMillis(seconds int64) int64 {
+	if seconds > limiter.MaxSafeInteger/1000 {
+		return limiter.MaxSafeInteger + 1
+	}
+	return seconds * 1000
 }
 
 // validateCheckRequest ensures request parameters are valid
 func validateCheckRequest(req *CheckRequest) error {
 	if req.Key == "" {
-		return &ValidationError{"key is required"}
+		return &ValidationError{CodeMissingKey, "key is required"}
+	}
+
+	if req.Algorithm == "" {
+		return &ValidationError{CodeMissingAlgorithm, "algorithm is required"}
 	}
 
 	if req.Capacity <= 0 {
-		return &ValidationError{"capacity must be positive"}
+		return &ValidationError{CodeCapacityRequired, "capacity must be positive"}
+	}
+
+	if req.Capacity > limiter.MaxSafeInteger {
+		return &ValidationError{CodeLimitTooLarge, "capacity is too large"}
+	}
+
+	if req.Cost <= 0 {
+		return &ValidationError{CodeInvalidCost, "cost must be positive"}
+	}
+
+	if req.Cost > req.Capacity {
+		return &ValidationError{CodeInvalidCost, "cost cannot exceed capacity"}
+	}
+
+	if req.FailMode != "" && !config.IsFailMode(req.FailMode) {
+		return &ValidationError{CodeInvalidFailMode, "fail_mode must be 'open', 'closed', 'local' or 'error'"}
+	}
+
+	if req.RequestID != "" && req.Algorithm != limiter.AlgorithmTokenBucket {
+		return &ValidationError{CodeInvalidRequestID, "request_id is only supported for token_bucket"}
+	}
+
+	if len(req.RequestID) > maxRequestIDLength {
+		return &ValidationError{CodeInvalidRequestID, "request_id is too long"}
+	}
+
+	if err := validatePenalty(req); err != nil {
+		return err
 	}
 
 	switch req.Algorithm {
 	case limiter.AlgorithmTokenBucket:
 		if req.RefillRate <= 0 {
-			return &ValidationError{"refill_rate must be positive for token_bucket"}
+			return &ValidationError{CodeRateRequired, "refill_rate must be positive for token_bucket"}
+		}
+		if req.RefillRate > limiter.MaxSafeInteger {
+			return &ValidationError{CodeLimitTooLarge, "refill_rate is too large"}
+		}
+	
+	case limiter.AlgorithmSlidingWindow, limiter.AlgorithmSlidingWindowCounter:
+		if req.WindowMillis <= 0 {
+			return &ValidationError{CodeWindowRequired, "window_ms or window_seconds must be positive for " + req.Algorithm}
+		}
+		if req.WindowMillis > limiter.MaxSafeInteger {
+			return &ValidationError{CodeLimitTooLarge, "window is too large"}
+		}
+	
+	case limiter.AlgorithmGCRA:
+		if req.RefillRate <= 0 {
+			return &ValidationError{CodeRateRequired, "refill_rate must be positive for gcra"}
+		}
+		if req.RefillRate > limiter.MaxSafeInteger {
+			return &ValidationError{CodeLimitTooLarge, "refill_rate is too large"}
 		}
 	
-	case limiter.AlgorithmSlidingWindow:
-		if req.WindowSeconds <= 0 {
-			return &ValidationError{"window_seconds must be positive for sliding_window"}
+	case limiter.AlgorithmLeakyBucket:
+		if req.LeakRate <= 0 {
+			return &ValidationError{CodeRateRequired, "leak_rate must be positive for leaky_bucket"}
+		}
+		if req.LeakRate > limiter.MaxSafeInteger {
+			return &ValidationError{CodeLimitTooLarge, "leak_rate is too large"}
+		}
+
+	case limiter.AlgorithmQuota:
+		if !limiter.IsPeriod(req.Period) {
+			return &ValidationError{CodePeriodRequired, "period must be 'daily' or 'monthly' for quota"}
 		}
 	
 	default:
-		return &ValidationError{"algorithm must be 'token_bucket' or 'sliding_window'"}
+		return &ValidationError{CodeInvalidAlgorithm, "algorithm must be " + algorithmChoices()}
 	}
 
 	return nil
 }
 
+// maxPenaltySeconds caps penalty_max_seconds - anything longer is a ban, not a penalty
+const maxPenaltySeconds = 365 * 24 * 60 * 60
+
+// validatePenalty checks the repeat-offender penalty parameters (both zero = no penalty)
+func validatePenalty(req *CheckRequest) error {
+	if req.PenaltyBaseSeconds == 0 && req.PenaltyMaxSeconds == 0 {
+		return nil
+	}
+	if req.PenaltyBaseSeconds <= 0 {
+		return &ValidationError{CodeInvalidPenalty, "penalty_base_seconds must be positive when penalty_max_seconds is set"}
+	}
+	if req.PenaltyMaxSeconds < req.PenaltyBaseSeconds {
+		return &ValidationError{CodeInvalidPenalty, "penalty_max_seconds must be at least penalty_base_seconds"}
+	}
+	if req.PenaltyMaxSeconds > maxPenaltySeconds {
+		return &ValidationError{CodeLimitTooLarge, fmt.Sprintf("penalty_max_seconds cannot exceed %d", maxPenaltySeconds)}
+	}
+	// A retried request id must get its first decision back, not another strike
+	if req.RequestID != "" {
+		return &ValidationError{CodeInvalidPenalty, "request_id cannot be combined with penalties"}
+	}
+	return nil
+}
+
 // ValidationError represents a request validation error
+// Code is one of the Code* constants, so clients don't have to match on Message
 type ValidationError struct {
+	Code    string
 	Message string
 }
 
@@ -148,14 +736,15 @@ func respondJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.WriteHeader(status)
 	
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("failed to encode response: %v", err)
+		logging.Printf("failed to encode response: %v", err)
 	}
 }
 
 // respondError writes an error response
-func respondError(w http.ResponseWriter, message string, status int) {
+func respondError(w http.ResponseWriter, code, message string, status int) {
 	respondJSON(w, map[string]string{
 		"error": message,
+		"code":  code,
 	}, status)
 }
 
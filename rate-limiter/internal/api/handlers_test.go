@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/piyushpatra/rate-limiter/internal/profiles"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testHandler is a Handler on a miniredis server with a clock the test steps by hand
+type testHandler struct {
+	*Handler
+	redis *miniredis.Miniredis
+	clock *utils.ManualClock
+}
+
+// newTestHandler starts miniredis and builds a Handler from the default config,
+// after setup (which may be nil) has adjusted it
+func newTestHandler(t *testing.T, setup func(cfg *config.Config)) *testHandler {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisAddr = mr.Addr()
+	cfg.RedisKeyPrefix = ""
+	// The 2ms default is for production; a busy test machine would see checks fail open
+	cfg.RedisTimeout = time.Second
+	if setup != nil {
+		setup(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+
+	m := metrics.New(prometheus.NewRegistry(), nil, nil)
+	client, err := redisclient.NewClient(cfg, m)
+	if err != nil {
+		t.Fatalf("redis client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	clock := utils.NewManualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, err := profiles.Load(cfg.ProfilesFile)
+	if err != nil {
+		t.Fatalf("profiles: %v", err)
+	}
+	l := limiter.NewLimiterWithClock(client, cfg, m, clock)
+	return &testHandler{Handler: NewHandler(l, client, cfg, m, store), redis: mr, clock: clock}
+}
+
+// post sends body to handle and returns the recorded response
+func post(handle http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handle(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+// decode unmarshals the recorded JSON body into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+// errorCodeOf is the "code" field of a respondError body
+func errorCodeOf(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body map[string]string
+	decode(t, w, &body)
+	return body["code"]
+}
+
+func TestHandleCheckAllowsThenBlocks(t *testing.T) {
+	th := newTestHandler(t, nil)
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`
+
+	w := post(th.HandleCheck, "/check", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp CheckResponse
+	decode(t, w, &resp)
+	if !resp.Allowed {
+		t.Fatal("first check blocked, want allowed")
+	}
+
+	decode(t, post(th.HandleCheck, "/check", body), &resp)
+	if resp.Allowed || resp.RetryAfterMs != 1000 {
+		t.Errorf("second check = %+v, want blocked with retry_after_ms 1000", resp)
+	}
+}
+
+func TestHandleCheckRejectsMissingKey(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	w := post(th.HandleCheck, "/check", `{"algorithm":"token_bucket","capacity":1,"refill_rate":1}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if code := errorCodeOf(t, w); code != CodeMissingKey {
+		t.Errorf("code = %q, want %q", code, CodeMissingKey)
+	}
+}
+
+func TestHandleCheckReportsKeyTypeConflict(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	w := post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"sliding_window","capacity":5,"window_seconds":60}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body)
+	}
+	if code := errorCodeOf(t, w); code != CodeKeyTypeConflict {
+		t.Errorf("code = %q, want %q", code, CodeKeyTypeConflict)
+	}
+}
+
+func TestHandleCheckSlidingWindowRetryAfter(t *testing.T) {
+	th := newTestHandler(t, nil)
+	body := `{"key":"user:1","algorithm":"sliding_window","capacity":1,"window_seconds":10}`
+
+	post(th.HandleCheck, "/check", body)
+	th.clock.Advance(2500 * time.Millisecond)
+
+	w := post(th.HandleCheck, "/check", body)
+	var resp CheckResponse
+	decode(t, w, &resp)
+	if resp.Allowed || resp.RetryAfterMs != 7500 {
+		t.Errorf("response = %+v, want blocked with retry_after_ms 7500 (10s window - 2.5s age)", resp)
+	}
+	if got := w.Header().Get("Retry-After"); got != "8" {
+		t.Errorf("Retry-After = %q, want 8 (7.5s rounded up)", got)
+	}
+}
+
+// clientTimestampBody is a check at the client-supplied time nowMs
+func clientTimestampBody(limit string, nowMs int64) string {
+	return fmt.Sprintf(`{"key":"user:1",%s,"now_ms":%d}`, limit, nowMs)
+}
+
+func TestHandleCheckHonoursClientTimestamps(t *testing.T) {
+	// Far from the server clock, which never moves in this test
+	start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	tests := []struct {
+		name  string
+		limit string
+		steps []int64 // ms after start of each check, alternating allowed and blocked
+	}{
+		{"token bucket refills", `"algorithm":"token_bucket","capacity":1,"refill_rate":1`, []int64{0, 999, 1000, 1500}},
+		{"sliding window evicts", `"algorithm":"sliding_window","capacity":1,"window_ms":1000`, []int64{0, 500, 1000, 1999}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t, func(cfg *config.Config) {
+				cfg.Environment = "staging"
+				cfg.AllowClientTimestamps = true
+			})
+			for i, at := range tt.steps {
+				var resp CheckResponse
+				decode(t, post(th.HandleCheck, "/check", clientTimestampBody(tt.limit, start+at)), &resp)
+				if want := i%2 == 0; resp.Allowed != want {
+					t.Errorf("check at +%dms: allowed = %v, want %v", at, resp.Allowed, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCheckIgnoresClientTimestampsInProduction(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.Environment = "production"
+		cfg.AllowClientTimestamps = true
+	})
+	limit := `"algorithm":"token_bucket","capacity":1,"refill_rate":1`
+	start := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	post(th.HandleCheck, "/check", clientTimestampBody(limit, start))
+	// A minute later by the client's clock, but the server's hasn't moved
+	var resp CheckResponse
+	decode(t, post(th.HandleCheck, "/check", clientTimestampBody(limit, start+60000)), &resp)
+	if resp.Allowed {
+		t.Error("now_ms refilled the bucket in production")
+	}
+	if got := th.redis.HGet("user:1", "last_refill"); got != fmt.Sprint(th.clock.NowMillis()) {
+		t.Errorf("last_refill = %s, want the server clock %d", got, th.clock.NowMillis())
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+// InFlight counts requests currently being served over HTTP and gRPC
+// Shutdown logs the count so a slow drain can be told apart from an idle one
+type InFlight struct {
+	n atomic.Int64
+}
+
+// Count returns the number of requests still being handled
+func (f *InFlight) Count() int64 {
+	return f.n.Load()
+}
+
+// Middleware counts HTTP requests for as long as the handler runs
+func (f *InFlight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.n.Add(1)
+		defer f.n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor counts gRPC calls the same way
+func (f *InFlight) UnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	f.n.Add(1)
+	defer f.n.Add(-1)
+	return handler(ctx, req)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestValidateLimitCapsBoundaries(t *testing.T) {
+	cfg := &config.Config{MaxCapacity: 100, MaxWindowSeconds: 3600, MaxRefillRate: 10}
+	tests := []struct {
+		name    string
+		req     CheckRequest
+		wantErr bool
+	}{
+		{name: "capacity at the cap", req: CheckRequest{Capacity: 100}},
+		{name: "capacity over the cap", req: CheckRequest{Capacity: 101}, wantErr: true},
+		{name: "window at the cap", req: CheckRequest{Capacity: 1, WindowMillis: 3600 * 1000}},
+		{name: "window 1ms over the cap", req: CheckRequest{Capacity: 1, WindowMillis: 3600*1000 + 1}, wantErr: true},
+		{name: "refill rate at the cap", req: CheckRequest{Capacity: 1, RefillRate: 10}},
+		{name: "refill rate over the cap", req: CheckRequest{Capacity: 1, RefillRate: 10.5}, wantErr: true},
+		{name: "leak rate over the cap", req: CheckRequest{Capacity: 1, LeakRate: 11}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLimitCaps(&tt.req, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateLimitCaps = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ve, ok := err.(*ValidationError); err != nil && (!ok || ve.Code != CodeLimitTooLarge) {
+				t.Errorf("error = %#v, want a %s ValidationError", err, CodeLimitTooLarge)
+			}
+		})
+	}
+
+	// 0 turns a cap off
+	huge := CheckRequest{Capacity: 1 << 40, WindowMillis: 1 << 40, RefillRate: 1e9}
+	if err := validateLimitCaps(&huge, &config.Config{}); err != nil {
+		t.Errorf("with every cap off: %v", err)
+	}
+}
+
+func TestHandleCheckRejectsLimitsOverDefaults(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	// The never-expiring window from the original report
+	w := post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"sliding_window","capacity":10,"window_seconds":999999999}`)
+	if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != CodeLimitTooLarge {
+		t.Errorf("huge window: status %d code %q, want 400 %s", w.Code, code, CodeLimitTooLarge)
+	}
+	if len(th.redis.Keys()) != 0 {
+		t.Errorf("rejected check wrote keys: %v", th.redis.Keys())
+	}
+
+	// Generous limits callers already use stay well inside the defaults
+	for _, body := range []string{
+		`{"key":"user:2","algorithm":"sliding_window","capacity":100000,"window_seconds":86400}`,
+		`{"key":"user:3","algorithm":"token_bucket","capacity":1000000,"refill_rate":10000}`,
+	} {
+		if w := post(th.HandleCheck, "/check", body); w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200: %s", body, w.Code, w.Body)
+		}
+	}
+}
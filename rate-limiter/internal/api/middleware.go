@@ -1,11 +1,21 @@
 package api
 
 import (
-	"log"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // Logger middleware logs HTTP requests
@@ -22,25 +32,125 @@ func Logger(cfg *config.Config) func(http.Handler) http.Handler {
 			
 			// Only log if debug mode is on or if there's an error
 			if cfg.DebugLogging || lrw.statusCode >= 400 {
-				log.Printf("[%s] %s %s - %d (%v)",
-					r.Method,
-					r.URL.Path,
-					r.RemoteAddr,
-					lrw.statusCode,
-					time.Since(start),
-				)
+				logging.LogRequest(logging.Request{
+					Method:     r.Method,
+					Path:       r.URL.Path,
+					RemoteAddr: r.RemoteAddr,
+					Status:     lrw.statusCode,
+					Duration:   time.Since(start),
+					RequestID:  RequestIDFromContext(r.Context()),
+				})
 			}
 		})
 	}
 }
 
+// requestIDHeader carries the correlation id in both directions
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps caller-supplied ids so they can't bloat every log line
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID middleware tags each request with a correlation id
+// Reuses the caller's X-Request-ID when present, otherwise generates a UUID,
+// and echoes it back so the caller can match its logs to ours
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext returns the id set by RequestID, or "" outside an HTTP request
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random (version 4) UUID
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Tracing middleware picks up the caller's trace context (W3C traceparent)
+// so the limiter's spans join the caller's trace instead of starting a new one
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AuthMiddleware requires "Authorization: Bearer <ADMIN_TOKEN>" on ADMIN_PROTECTED_PATHS
+// Everything else (/check, /health, ...) passes straight through. With no ADMIN_TOKEN
+// set, protected paths are refused outright rather than left open
+func AuthMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	exact := make(map[string]bool, len(cfg.AdminProtectedPaths))
+	var prefixes []string
+	for _, path := range cfg.AdminProtectedPaths {
+		if strings.HasSuffix(path, "/") {
+			prefixes = append(prefixes, path)
+		} else {
+			exact[path] = true
+		}
+	}
+	protected := func(path string) bool {
+		if exact[path] {
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if protected(r.URL.Path) && !bearerTokenValid(r, cfg.AdminToken) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				respondError(w, CodeUnauthorized, "admin token required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerTokenValid checks for "Authorization: Bearer <token>"
+// Compared in constant time so the token can't be guessed byte by byte from response timings
+func bearerTokenValid(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
 // Recovery middleware recovers from panics and returns 500
 // Prevents the entire server from crashing due to a single bad request
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("panic recovered: %v", err)
+				logging.Printf("panic recovered: %v", err)
 				http.Error(w, "internal server error", http.StatusInternalServerError)
 			}
 		}()
@@ -50,21 +160,43 @@ func Recovery(next http.Handler) http.Handler {
 }
 
 // CORS middleware adds CORS headers
-// Allowing all origins here - in production you'd want to restrict this
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		
-		// Handle preflight
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+// Origins come from CORS_ALLOWED_ORIGINS - "*" allows any origin, otherwise only
+// listed origins are echoed back and everything else gets no Allow-Origin header
+func CORS(cfg *config.Config) func(http.Handler) http.Handler {
+	allowAll := false
+	origins := make(map[string]bool, len(cfg.CORSAllowedOrigins))
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			allowAll = true
 		}
-		
-		next.ServeHTTP(w, r)
-	})
+		origins[origin] = true
+	}
+	methods := strings.Join(cfg.CORSAllowedMethods, ", ")
+	headers := strings.Join(cfg.CORSAllowedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allowAll {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				// The response depends on Origin, so caches must key on it
+				w.Header().Add("Vary", "Origin")
+				if origin := r.Header.Get("Origin"); origins[origin] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+
+			// Handle preflight
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // loggingResponseWriter wraps http.ResponseWriter to capture status code
@@ -78,3 +210,13 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack lets WebSocket upgrades (/check/stream) through the logger
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	lrw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+// maxMultiLimits bounds how many limits one multi check can combine
+const maxMultiLimits = 10
+
+// MultiCheckResponse is the combined decision for POST /check/multi
+type MultiCheckResponse struct {
+	Allowed   bool            `json:"allowed"`
+	Remaining int64           `json:"remaining"`          // lowest remaining across the limits
+	ResetAt   int64           `json:"reset_at,omitempty"` // when that lowest remaining goes up
+	Degraded  bool            `json:"degraded,omitempty"`
+	Limits    []CheckResponse `json:"limits"` // each limit's own decision, in request order
+
+	// RetryAfterMs is the longest wait any blocking limit asks for (omitted when allowed)
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// HandleCheckMulti enforces several limits on one request (e.g. 10/sec AND 100/min)
+// The request is allowed only if every limit allows it, and nothing is consumed otherwise
+func (h *Handler) HandleCheckMulti(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var reqs []CheckRequest
+	if err := decodeBody(w, r, h.cfg.MaxBatchBodyBytes, &reqs); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	if len(reqs) == 0 {
+		respondError(w, CodeInvalidRequest, "multi check must contain at least one limit", http.StatusBadRequest)
+		return
+	}
+	if len(reqs) > maxMultiLimits {
+		respondError(w, CodeInvalidRequest, fmt.Sprintf("multi check cannot contain more than %d limits", maxMultiLimits), http.StatusBadRequest)
+		return
+	}
+
+	// Unlike a batch, one bad limit fails the whole request - partial AND makes no sense
+	limits := make([]limiter.CheckRequest, len(reqs))
+	seen := make(map[[2]string]bool, len(reqs))
+	ip := h.clientIP(r)
+	for i := range reqs {
+		applyClientIP(&reqs[i], ip)
+		if err := h.prepareCheckRequest(&reqs[i]); err != nil {
+			respondError(w, errorCode(err), fmt.Sprintf("limit %d: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		if reqs[i].DryRun {
+			respondError(w, CodeInvalidRequest, fmt.Sprintf("limit %d: dry_run is not supported for multi checks", i), http.StatusBadRequest)
+			return
+		}
+		if reqs[i].PenaltyBaseSeconds > 0 {
+			respondError(w, CodeInvalidPenalty, fmt.Sprintf("limit %d: penalties are not supported for multi checks", i), http.StatusBadRequest)
+			return
+		}
+		if reqs[i].RequestID != "" {
+			respondError(w, CodeInvalidRequestID, fmt.Sprintf("limit %d: request_id is not supported for multi checks", i), http.StatusBadRequest)
+			return
+		}
+		id := [2]string{reqs[i].Namespace, reqs[i].Key}
+		if seen[id] {
+			respondError(w, CodeInvalidRequest, fmt.Sprintf("limit %d: key %q is used by another limit", i, reqs[i].Key), http.StatusBadRequest)
+			return
+		}
+		seen[id] = true
+		limits[i] = reqs[i].toLimiter()
+	}
+
+	result, err := h.limiter.CheckAll(r.Context(), limits)
+	if err != nil {
+		code, msg, status := checkErrorStatus(r.Context(), err)
+		respondError(w, code, msg, status)
+		return
+	}
+
+	setResetHeader(w, result.ResetAt)
+	if !result.Allowed {
+		setRetryAfter(w, result.RetryAfter)
+	}
+
+	resp := MultiCheckResponse{
+		Allowed:   result.Allowed,
+		Remaining: result.Remaining,
+		ResetAt:   resetUnix(result.ResetAt),
+		Degraded:  result.Degraded,
+		Limits:    make([]CheckResponse, len(result.Results)),
+
+		RetryAfterMs: retryAfterMillis(result.RetryAfter),
+	}
+	for i := range result.Results {
+		resp.Limits[i] = CheckResponse{
+			Allowed:   result.Results[i].Allowed,
+			Remaining: result.Results[i].Remaining,
+			ResetAt:   resetUnix(result.Results[i].ResetAt),
+			Policy:    reqs[i].policy,
+			Degraded:  result.Results[i].Degraded,
+
+			RetryAfterMs: retryAfterMillis(result.Results[i].RetryAfter),
+		}
+		if reqs[i].Explain {
+			resp.Limits[i].Explanation = explainDecision(&reqs[i], &result.Results[i])
+		}
+	}
+
+	respondJSON(w, resp, http.StatusOK)
+}
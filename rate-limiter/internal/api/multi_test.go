@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleCheckMultiRejectsWithoutConsuming(t *testing.T) {
+	th := newTestHandler(t, nil)
+	perSecond := `{"key":"{user:1}:sec","algorithm":"token_bucket","capacity":1,"refill_rate":1}`
+	perMinute := `{"key":"{user:1}:min","algorithm":"sliding_window","capacity":3,"window_seconds":60}`
+	body := `[` + perSecond + `,` + perMinute + `]`
+
+	w := post(th.HandleCheckMulti, "/check/multi", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp MultiCheckResponse
+	decode(t, w, &resp)
+	if !resp.Allowed || resp.Remaining != 0 || len(resp.Limits) != 2 {
+		t.Fatalf("first multi check = %+v, want allowed with 0 remaining and 2 limits", resp)
+	}
+	if resp.Limits[1].Remaining != 2 {
+		t.Errorf("per-minute remaining = %d, want 2", resp.Limits[1].Remaining)
+	}
+
+	w = post(th.HandleCheckMulti, "/check/multi", body)
+	resp = MultiCheckResponse{}
+	decode(t, w, &resp)
+	if resp.Allowed || resp.RetryAfterMs != 1000 || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("second multi check = %+v (Retry-After %q), want blocked for 1s", resp, w.Header().Get("Retry-After"))
+	}
+	if resp.Limits[0].Allowed || resp.Limits[0].RetryAfterMs != 1000 {
+		t.Errorf("per-second limit = %+v, want blocked with retry_after_ms 1000", resp.Limits[0])
+	}
+	// The per-minute limit would have allowed, and keeps the request it didn't consume
+	if !resp.Limits[1].Allowed || resp.Limits[1].Remaining != 2 || resp.Limits[1].RetryAfterMs != 0 {
+		t.Errorf("per-minute limit = %+v, want allowed with 2 remaining", resp.Limits[1])
+	}
+
+	w = post(th.HandleCheck, "/check", perMinute)
+	var single CheckResponse
+	decode(t, w, &single)
+	if !single.Allowed || single.Remaining != 1 {
+		t.Errorf("per-minute check after the rejection = %+v, want allowed with 1 remaining", single)
+	}
+}
+
+func TestHandleCheckMultiRejectsUnsupportedOptions(t *testing.T) {
+	th := newTestHandler(t, nil)
+	tests := []struct {
+		name  string
+		limit string
+		want  string
+	}{
+		{"dry run", `{"key":"b","algorithm":"token_bucket","capacity":1,"refill_rate":1,"dry_run":true}`, CodeInvalidRequest},
+		{"penalty", `{"key":"b","algorithm":"token_bucket","capacity":1,"refill_rate":1,"penalty_base_seconds":1}`, CodeInvalidPenalty},
+		{"request id", `{"key":"b","algorithm":"token_bucket","capacity":1,"refill_rate":1,"request_id":"req-1"}`, CodeInvalidRequestID},
+		{"repeated key", `{"key":"a","algorithm":"token_bucket","capacity":1,"refill_rate":1}`, CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(th.HandleCheckMulti, "/check/multi", `[{"key":"a","algorithm":"token_bucket","capacity":1,"refill_rate":1},`+tt.limit+`]`)
+			if code := errorCodeOf(t, w); w.Code != http.StatusBadRequest || code != tt.want {
+				t.Errorf("status %d code %q, want 400 %s", w.Code, code, tt.want)
+			}
+		})
+	}
+	if keys := th.redis.Keys(); len(keys) != 0 {
+		t.Errorf("rejected multi checks wrote %v", keys)
+	}
+}
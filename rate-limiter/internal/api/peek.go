@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// PeekResponse is the current state of a limit, as shown on dashboards
+type PeekResponse struct {
+	Remaining    int64  `json:"remaining"`
+	ResetAfterMs int64  `json:"reset_after_ms"` // until the limit is fully replenished
+	Policy       string `json:"policy,omitempty"`
+
+	// Decisions is how many checks the key has allowed and denied - set only when
+	// DECISION_COUNTS_TTL is on
+	Decisions *DecisionCounts `json:"decisions,omitempty"`
+}
+
+// DecisionCounts is a key's allow/deny counts
+type DecisionCounts struct {
+	Allowed int64 `json:"allowed"`
+	Denied  int64 `json:"denied"`
+}
+
+// HandlePeek reports a key's remaining quota without consuming any of it
+// Takes the same body as /check so dashboards can reuse the limit definitions
+func (h *Handler) HandlePeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CheckRequest
+	if err := decodeBody(w, r, h.cfg.MaxBodyBytes, &req); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	if req.Key == ipKey {
+		req.Key = h.clientIP(r)
+	}
+
+	if err := h.prepareCheckRequest(&req); err != nil {
+		respondError(w, errorCode(err), err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.limiter.Peek(r.Context(), req.toLimiter())
+
+	// Nothing to fail open to - the state just isn't readable right now
+	var failOpenErr *redisclient.FailOpenError
+	if errors.As(err, &failOpenErr) {
+		respondError(w, CodeRedisUnavailable, "rate limit state unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		code, msg, status := checkErrorStatus(r.Context(), err)
+		respondError(w, code, msg, status)
+		return
+	}
+
+	resp := PeekResponse{
+		Remaining:    result.Remaining,
+		ResetAfterMs: result.ResetAfter.Milliseconds(),
+		Policy:       req.policy,
+	}
+	if result.Decisions != nil {
+		resp.Decisions = &DecisionCounts{Allowed: result.Decisions.Allowed, Denied: result.Decisions.Denied}
+	}
+	respondJSON(w, resp, http.StatusOK)
+}
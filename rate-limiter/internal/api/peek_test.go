@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestPeekReturnsDecisionCounts(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.DecisionCountsTTL = time.Hour
+	})
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`
+
+	post(th.HandleCheck, "/check", body)
+	post(th.HandleCheck, "/check", body)
+
+	w := post(th.HandlePeek, "/peek", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp PeekResponse
+	decode(t, w, &resp)
+	if resp.Decisions == nil || *resp.Decisions != (DecisionCounts{Allowed: 1, Denied: 1}) {
+		t.Errorf("decisions = %+v, want 1 allowed and 1 denied", resp.Decisions)
+	}
+}
+
+func TestPeekLeavesOutDecisionCountsWhenOff(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	var raw map[string]interface{}
+	decode(t, post(th.HandlePeek, "/peek", `{"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1}`), &raw)
+	if _, ok := raw["decisions"]; ok {
+		t.Errorf("decisions present with DECISION_COUNTS_TTL off: %v", raw)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestReadyzWaitsOutWarmupDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.WarmupDelay = delay
+	})
+
+	w := get(th.HandleReadyz, "/readyz")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status during warmup = %d, want 503", w.Code)
+	}
+	var resp map[string]interface{}
+	decode(t, w, &resp)
+	if resp["status"] != healthWarmingUp {
+		t.Errorf("status field = %v, want %q", resp["status"], healthWarmingUp)
+	}
+
+	// Liveness doesn't wait - the process is fine, it just shouldn't get traffic yet
+	if w := get(th.HandleLivez, "/livez"); w.Code != http.StatusOK {
+		t.Errorf("livez during warmup = %d, want 200", w.Code)
+	}
+
+	time.Sleep(delay)
+	if w := get(th.HandleReadyz, "/readyz"); w.Code != http.StatusOK {
+		t.Errorf("status after warmup = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestReadyzReadyAtOnceWithoutWarmup(t *testing.T) {
+	th := newTestHandler(t, nil)
+
+	if w := get(th.HandleReadyz, "/readyz"); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+)
+
+// StatsResponse is a human-readable summary of this instance's decisions since it started
+type StatsResponse struct {
+	Allowed      float64 `json:"allowed"`
+	Blocked      float64 `json:"blocked"`
+	BlockRate    float64 `json:"block_rate"` // blocked / (allowed + blocked), 0 before any check
+	RedisErrors  float64 `json:"redis_errors"`
+	CircuitState string  `json:"circuit_state"` // closed, open or half_open
+
+	// FillLevel is the smoothed fraction of capacity in use per algorithm, as in the
+	// bucket_fill_ratio gauge; 0 until an algorithm has been sampled
+	FillLevel map[string]float64 `json:"fill_level"`
+}
+
+// HandleStats summarizes the decision counters for a quick look without scraping /metrics
+// Read from the same registry as /metrics, so the numbers always agree with it
+func (h *Handler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	totals, err := h.metrics.ReadTotals()
+	if err != nil {
+		respondError(w, CodeInternal, "failed to read metrics", http.StatusInternalServerError)
+		return
+	}
+
+	resp := StatsResponse{
+		Allowed:      totals.Allowed,
+		Blocked:      totals.Blocked,
+		RedisErrors:  totals.RedisErrors,
+		CircuitState: h.redis.CircuitState(),
+		FillLevel:    make(map[string]float64),
+	}
+	for _, a := range limiter.Algorithms() {
+		resp.FillLevel[a.Name] = h.limiter.FillLevel(a.Name)
+	}
+	if decided := totals.Allowed + totals.Blocked; decided > 0 {
+		resp.BlockRate = totals.Blocked / decided
+	}
+	respondJSON(w, resp, http.StatusOK)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStatsReportsFillLevel(t *testing.T) {
+	th := newTestHandler(t, nil)
+	body := `{"key":"user:1","algorithm":"token_bucket","capacity":32,"refill_rate":1}`
+
+	// Checks are sampled 1 in 16; the 16th leaves 16 of 32 tokens
+	for i := 0; i < 16; i++ {
+		post(th.HandleCheck, "/check", body)
+	}
+
+	w := httptest.NewRecorder()
+	th.HandleStats(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var resp StatsResponse
+	decode(t, w, &resp)
+	if got := resp.FillLevel["token_bucket"]; math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("fill_level.token_bucket = %v, want 0.5", got)
+	}
+	if got, ok := resp.FillLevel["sliding_window"]; !ok || got != 0 {
+		t.Errorf("fill_level.sliding_window = %v (present %v), want 0", got, ok)
+	}
+	if resp.Allowed != 16 {
+		t.Errorf("allowed = %v, want 16", resp.Allowed)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"golang.org/x/net/websocket"
+)
+
+// StreamCheckRequest is one inbound /check/stream frame: a check plus the caller's sequence number
+type StreamCheckRequest struct {
+	Seq uint64 `json:"seq"`
+	CheckRequest
+}
+
+// StreamCheckResponse answers the frame with the same seq - Error and Code are set instead
+// of the decision on failure. Responses can arrive out of order, so match them on seq
+type StreamCheckResponse struct {
+	Seq uint64 `json:"seq"`
+	*CheckResponse
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// HandleCheckStream serves GET /check/stream, a WebSocket where every text frame is a
+// check and gets one response frame back. Saves the per-request HTTP overhead for clients
+// doing thousands of checks a second over one connection
+func (h *Handler) HandleCheckStream() http.Handler {
+	return websocket.Server{Handler: h.serveCheckStream, Handshake: h.streamHandshake}
+}
+
+// streamHandshake applies CORS_ALLOWED_ORIGINS to browser connections
+// Non-browser clients send no Origin and are always let in, as with plain HTTP
+func (h *Handler) streamHandshake(_ *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range h.cfg.CORSAllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+// serveCheckStream runs one stream until the client disconnects or the server shuts down
+// Up to STREAM_MAX_IN_FLIGHT checks run concurrently; past that the next frame isn't read
+// until one finishes, so a client sending faster than we answer is slowed by TCP itself
+func (h *Handler) serveCheckStream(ws *websocket.Conn) {
+	h.streams.Add(1)
+	defer h.streams.Done()
+
+	// Hijacked connections keep the server's read/write timeouts - a stream outlives them
+	ws.SetDeadline(time.Time{})
+	// A frame is one check, so it gets the same cap as a /check body
+	ws.MaxPayloadBytes = int(h.cfg.MaxBodyBytes)
+
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+
+	// Closing the connection is what unblocks the read loop below
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.streamsClosed:
+		}
+		ws.Close()
+	}()
+
+	source := h.clientIP(ws.Request())
+	slots := make(chan struct{}, h.cfg.StreamMaxInFlight)
+	// On the way out, cancel checks still running and wait for them before returning
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	for {
+		var frame []byte
+		err := websocket.Message.Receive(ws, &frame)
+		if errors.Is(err, websocket.ErrFrameTooLarge) {
+			h.sendStream(ws, StreamCheckResponse{Error: "frame too large", Code: CodeInvalidBody})
+			continue
+		}
+		if err != nil {
+			// Disconnect, shutdown or a broken frame - either way the stream is done
+			return
+		}
+
+		var req StreamCheckRequest
+		if err := decodeStrict(bytes.NewReader(frame), &req); err != nil {
+			h.sendStream(ws, StreamCheckResponse{Seq: frameSeq(frame), Error: bodyErrorMessage(err), Code: CodeInvalidBody})
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			h.sendStream(ws, h.streamCheck(ctx, &req, source))
+		}()
+	}
+}
+
+// frameSeq reads just the seq of a frame that failed to decode, so the error can still be
+// matched to its request. Unknown fields and bad values elsewhere don't stop it; a frame
+// that isn't JSON at all gets seq 0
+func frameSeq(frame []byte) uint64 {
+	var probe struct {
+		Seq uint64 `json:"seq"`
+	}
+	_ = json.Unmarshal(frame, &probe)
+	return probe.Seq
+}
+
+// streamCheck runs one frame's check the same way POST /check does
+func (h *Handler) streamCheck(ctx context.Context, req *StreamCheckRequest, source string) StreamCheckResponse {
+	resp := StreamCheckResponse{Seq: req.Seq}
+	applyClientIP(&req.CheckRequest, source)
+	if err := h.prepareCheckRequest(&req.CheckRequest); err != nil {
+		resp.Code, resp.Error = errorCode(err), err.Error()
+		return resp
+	}
+
+	result, err := h.limiter.Check(ctx, req.toLimiter())
+	if err != nil {
+		resp.Code, resp.Error, _ = checkErrorStatus(ctx, err)
+		return resp
+	}
+
+	resp.CheckResponse = newCheckResponse(&req.CheckRequest, result)
+	return resp
+}
+
+// sendStream writes one response frame - websocket.Conn serializes concurrent writers
+// A failed write means the client is gone, which the read loop notices on its own
+func (h *Handler) sendStream(ws *websocket.Conn, resp StreamCheckResponse) {
+	if err := websocket.JSON.Send(ws, resp); err != nil && h.cfg.DebugLogging {
+		logging.Printf("check stream write failed: %v", err)
+	}
+}
+
+// CloseStreams ends every open /check/stream connection and waits, until ctx is done, for
+// their in-flight checks to finish. http.Server.Shutdown doesn't track hijacked connections
+func (h *Handler) CloseStreams(ctx context.Context) {
+	h.closeStreamsOnce.Do(func() { close(h.streamsClosed) })
+
+	done := make(chan struct{})
+	go func() {
+		h.streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"golang.org/x/net/websocket"
+)
+
+const streamOrigin = "http://localhost"
+
+// newStreamHandler is newTestHandler serving /check/stream over a real listener
+func newStreamHandler(t *testing.T, setup func(cfg *config.Config)) (*testHandler, string) {
+	t.Helper()
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.CORSAllowedOrigins = []string{streamOrigin}
+		if setup != nil {
+			setup(cfg)
+		}
+	})
+	srv := httptest.NewServer(th.HandleCheckStream())
+	t.Cleanup(srv.Close)
+	return th, "ws" + strings.TrimPrefix(srv.URL, "http") + "/check/stream"
+}
+
+// dialStream opens a stream that the test closes on cleanup
+func dialStream(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial(url, "", streamOrigin)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// sendFrame writes one text frame
+func sendFrame(t *testing.T, ws *websocket.Conn, frame string) {
+	t.Helper()
+	if err := websocket.Message.Send(ws, frame); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+// receiveFrame reads one response frame, failing the test if none arrives within a second
+func receiveFrame(t *testing.T, ws *websocket.Conn) StreamCheckResponse {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var resp StreamCheckResponse
+	if err := websocket.JSON.Receive(ws, &resp); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	return resp
+}
+
+// blockDecisions holds every check in OnDecision until the returned release is closed,
+// announcing each one on started
+func blockDecisions(th *testHandler) (started <-chan struct{}, release chan struct{}) {
+	s := make(chan struct{}, 100)
+	release = make(chan struct{})
+	th.limiter.OnDecision = func(context.Context, limiter.CheckRequest, limiter.CheckResponse, error) {
+		s <- struct{}{}
+		<-release
+	}
+	return s, release
+}
+
+func TestCheckStreamMatchesResponsesOnSeq(t *testing.T) {
+	_, url := newStreamHandler(t, nil)
+	ws := dialStream(t, url)
+
+	for seq := 1; seq <= 5; seq++ {
+		// Capacity seq, so each reply's remaining says which request it answers
+		sendFrame(t, ws, fmt.Sprintf(`{"seq":%d,"key":"user:%d","algorithm":"token_bucket","capacity":%d,"refill_rate":1}`, seq, seq, seq))
+	}
+	seen := make(map[uint64]bool)
+	for i := 0; i < 5; i++ {
+		resp := receiveFrame(t, ws)
+		if resp.CheckResponse == nil || !resp.Allowed || resp.Remaining != int64(resp.Seq)-1 {
+			t.Errorf("reply %+v doesn't answer request seq %d", resp, resp.Seq)
+		}
+		seen[resp.Seq] = true
+	}
+	if len(seen) != 5 {
+		t.Errorf("replies covered seqs %v, want 1 to 5", seen)
+	}
+}
+
+func TestCheckStreamEchoesSeqOfBadFrames(t *testing.T) {
+	_, url := newStreamHandler(t, nil)
+	ws := dialStream(t, url)
+
+	tests := []struct {
+		frame string
+		seq   uint64
+		code  string
+	}{
+		{`{"seq":7,"key":"user:1","algorithm":"token_bucket","capacity":1,"refill_rate":1,"bogus":true}`, 7, CodeInvalidBody},
+		{`{"seq":8,"key":"user:1","capacity":"ten"}`, 8, CodeInvalidBody},
+		{`{"seq":9,"algorithm":"token_bucket","capacity":1,"refill_rate":1}`, 9, CodeMissingKey},
+		{`{"seq":10,`, 0, CodeInvalidBody},
+	}
+	for _, tt := range tests {
+		sendFrame(t, ws, tt.frame)
+		resp := receiveFrame(t, ws)
+		if resp.Seq != tt.seq || resp.Code != tt.code || resp.CheckResponse != nil {
+			t.Errorf("reply to %s = %+v, want seq %d code %s", tt.frame, resp, tt.seq, tt.code)
+		}
+	}
+}
+
+func TestCheckStreamBoundsChecksInFlight(t *testing.T) {
+	th, url := newStreamHandler(t, func(cfg *config.Config) { cfg.StreamMaxInFlight = 2 })
+	started, release := blockDecisions(th)
+	var released atomic.Bool
+	t.Cleanup(func() {
+		if !released.Load() {
+			close(release)
+		}
+	})
+	ws := dialStream(t, url)
+
+	for seq := 1; seq <= 5; seq++ {
+		sendFrame(t, ws, fmt.Sprintf(`{"seq":%d,"key":"user:%d","algorithm":"token_bucket","capacity":5,"refill_rate":1}`, seq, seq))
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d checks started, want 2", i)
+		}
+	}
+	select {
+	case <-started:
+		t.Fatal("a third check started with STREAM_MAX_IN_FLIGHT=2")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	released.Store(true)
+	for i := 0; i < 5; i++ {
+		if resp := receiveFrame(t, ws); resp.CheckResponse == nil || !resp.Allowed {
+			t.Errorf("reply %+v, want allowed", resp)
+		}
+	}
+}
+
+func TestCloseStreamsWaitsForChecksInFlight(t *testing.T) {
+	th, url := newStreamHandler(t, nil)
+	started, release := blockDecisions(th)
+	ws := dialStream(t, url)
+
+	sendFrame(t, ws, `{"seq":1,"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("check never started")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		th.CloseStreams(context.Background())
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("CloseStreams returned with a check still running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("CloseStreams didn't return once the check finished")
+	}
+
+	// The connection is gone, and new ones are turned away
+	for _, conn := range []*websocket.Conn{ws, dialStream(t, url)} {
+		websocket.Message.Send(conn, `{"seq":2,"key":"user:2","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var frame []byte
+		err := websocket.Message.Receive(conn, &frame)
+		var netErr net.Error
+		if err == nil || (errors.As(err, &netErr) && netErr.Timeout()) {
+			t.Errorf("stream still open after CloseStreams (read: %q, %v)", frame, err)
+		}
+	}
+}
+
+func TestCloseStreamsGivesUpAtDeadline(t *testing.T) {
+	th, url := newStreamHandler(t, nil)
+	started, release := blockDecisions(th)
+	t.Cleanup(func() { close(release) })
+	ws := dialStream(t, url)
+
+	sendFrame(t, ws, `{"seq":1,"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	th.CloseStreams(ctx)
+	if waited := time.Since(begin); waited > time.Second {
+		t.Errorf("CloseStreams waited %v past its 50ms deadline", waited)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// HandleWatchMetrics writes the remaining of each watched key (WATCH_KEYS) in the
+// Prometheus text format, ready to drop into a node_exporter textfile directory
+// Only keys checked on this instance are listed, so there are never more series than
+// MAX_WATCH_KEYS. No timestamps, as the textfile collector rejects them
+func (h *Handler) HandleWatchMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// A registry of its own, so the output is just these series and never the full /metrics
+	remaining := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "watched_key_remaining",
+		Help: "Remaining after the latest check on each watched key on this instance, labelled by key hash",
+	}, []string{"key"})
+	for _, wr := range h.limiter.WatchedRemaining() {
+		remaining.WithLabelValues(wr.Label).Set(float64(wr.Remaining))
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(remaining)
+
+	families, err := reg.Gather()
+	if err != nil {
+		respondError(w, CodeInternal, "failed to gather watched keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", string(expfmt.FmtText))
+	w.WriteHeader(http.StatusOK)
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return
+		}
+	}
+}
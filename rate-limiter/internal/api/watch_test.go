@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/tracing"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestHandleWatchMetricsWritesExposition(t *testing.T) {
+	th := newTestHandler(t, func(cfg *config.Config) {
+		cfg.WatchKeys = []string{"user:1", "user:2"}
+	})
+	for i := 0; i < 2; i++ {
+		post(th.HandleCheck, "/check", `{"key":"user:1","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+	}
+	post(th.HandleCheck, "/check", `{"key":"user:3","algorithm":"token_bucket","capacity":5,"refill_rate":1}`)
+
+	w := httptest.NewRecorder()
+	th.HandleWatchMetrics(w, httptest.NewRequest(http.MethodGet, "/watch/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(w.Body)
+	if err != nil {
+		t.Fatalf("not valid exposition format: %v", err)
+	}
+	mf, ok := families["watched_key_remaining"]
+	if !ok || len(families) != 1 {
+		t.Fatalf("families = %v, want only watched_key_remaining", families)
+	}
+
+	// user:2 is watched but never checked, and user:3 isn't watched
+	metrics := mf.GetMetric()
+	if len(metrics) != 1 {
+		t.Fatalf("%d series, want 1", len(metrics))
+	}
+	m := metrics[0]
+	if label := m.GetLabel()[0].GetValue(); label != tracing.HashKey("user:1") {
+		t.Errorf("key label = %q, want the hash of user:1", label)
+	}
+	if got := m.GetGauge().GetValue(); got != 3 {
+		t.Errorf("remaining = %v, want 3", got)
+	}
+	if m.TimestampMs != nil {
+		t.Error("sample has a timestamp, which the textfile collector rejects")
+	}
+}
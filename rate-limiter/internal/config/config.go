@@ -1,16 +1,73 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// Fail modes - what a check does when Redis can't be reached
+const (
+	FailModeOpen   = "open"   // allow everything
+	FailModeClosed = "closed" // deny everything
+	FailModeLocal  = "local"  // enforce a fraction of the limit in memory
+	FailModeError  = "error"  // fail the check, so the caller decides (503 over HTTP)
+)
+
+// IsFailMode reports whether mode is one of the FailMode* values
+func IsFailMode(mode string) bool {
+	switch mode {
+	case FailModeOpen, FailModeClosed, FailModeLocal, FailModeError:
+		return true
+	}
+	return false
+}
+
+// Log formats
+const (
+	LogFormatText = "text" // human-readable lines from the standard log package
+	LogFormatJSON = "json" // one JSON object per line, for Loki/ELK
+)
+
+// Check modes - how /check reports a block
+const (
+	CheckModeJSON    = "json"    // 200 with "allowed": false, for programmatic clients
+	CheckModeGateway = "gateway" // 429, for proxies that only look at the status
+)
+
+// Clock sources - where the limiter's "now" comes from
+const (
+	ClockSourceLocal = "local" // this machine's clock
+	ClockSourceRedis = "redis" // this machine's clock, corrected by its offset from Redis TIME
+)
+
 type Config struct {
 	ServerPort   string
+	// gRPC listens on its own port when set - empty disables it
+	GRPCPort     string
 	RedisAddr    string
+	// Cluster seed nodes - when set, a cluster client is used instead of RedisAddr
+	RedisClusterAddrs []string
+	// How many MOVED/ASK redirects a command follows while slots are being resharded
+	RedisClusterMaxRedirects int
+	// Sentinel nodes and the master name they monitor - when set, the master is
+	// discovered through Sentinel instead of RedisAddr
+	RedisSentinelAddrs []string
+	RedisMasterName    string
+	RedisUsername string // Redis 6 ACL user - empty means the default user
 	RedisPassword string
 	RedisDB      int
+
+	// TLS to Redis (managed offerings usually require it)
+	// RedisTLSCAFile adds a private CA; otherwise the system roots are used
+	RedisTLSEnabled            bool
+	RedisTLSInsecureSkipVerify bool
+	RedisTLSCAFile             string
 	
 	// Connection pool settings - tuned these based on load testing
 	RedisPoolSize     int
@@ -18,23 +75,540 @@ type Config struct {
 	
 	// Timeout for Redis ops - keeping it tight for fail-open behavior
 	RedisTimeout time.Duration
+
+	// Backoff before retrying a script call that hit a network error, jittered and kept
+	// within RedisTimeout - 0 disables the retry
+	RedisRetryBackoff time.Duration
+
+	// ClockSource is local or redis; with redis the offset from Redis TIME is
+	// re-measured every ClockSyncInterval
+	ClockSource       string
+	ClockSyncInterval time.Duration
+
+	// How often to retry when Redis is unreachable at startup
+	RedisReconnectInterval time.Duration
+
+	// How often pool stats are sampled into the redis_pool_* metrics - 0 disables
+	RedisPoolStatsInterval time.Duration
+
+	// Circuit breaker: trip after Threshold consecutive fail-open errors within Window,
+	// then skip Redis for Cooldown before probing again. Threshold 0 disables it.
+	CircuitBreakerThreshold int
+	CircuitBreakerWindow    time.Duration
+	CircuitBreakerCooldown  time.Duration
+
+	// Script calls allowed in flight at once; beyond that checks are shed straight to
+	// FAIL_MODE instead of queueing for a connection. 0 disables the limit
+	MaxInflightChecks int
 	
+	// Default lease length for /acquire when the request doesn't set lease_seconds
+	ConcurrencyLeaseTTL time.Duration
+
+	// RedisKeyPrefix is put in front of every key, e.g. to share a Redis with other apps
+	RedisKeyPrefix string
+	// RequireNamespace rejects checks without a namespace, for deployments shared by teams
+	RequireNamespace bool
+	// HashKeys stores callers' keys as SHA-256 digests, so raw ids and emails stay out of Redis
+	HashKeys bool
+	// Key normalization: keys are always trimmed and refused if they hold control characters
+	// KeyLowercase also folds case; MaxKeyLength (bytes) rejects longer keys - 0 disables it
+	KeyLowercase bool
+	MaxKeyLength int
+
+	// Upper bounds on client-supplied limits, so one request can't create huge or
+	// effectively permanent keys - 0 disables a cap. MaxRefillRate also caps leak_rate
+	MaxCapacity      int64
+	MaxWindowSeconds int64
+	MaxRefillRate    float64
+
+	// Largest request body read, in bytes - batch, multi and script reload bodies get their own
+	MaxBodyBytes      int64
+	MaxBatchBodyBytes int64
+
+	// How long token bucket checks remember a request_id so retries don't consume twice
+	DedupTTL time.Duration
+
+	// How often buffered per-check metrics reach the Prometheus collectors - 0 writes through
+	MetricsFlushInterval time.Duration
+
+	// Histogram bucket bounds in milliseconds for check_latency_ms and redis_latency_ms
+	CheckLatencyBuckets []float64
+	RedisLatencyBuckets []float64
+
+	// Checks one /check/stream connection may have running at once before reads pause
+	StreamMaxInFlight int
+
+	// Scale capacities by the factor set through POST /adaptive/factor, refreshed from Redis
+	AdaptiveEnabled         bool
+	AdaptiveRefreshInterval time.Duration
+
+	// How long a blocked key is refused in memory before asking Redis again - 0 disables
+	DenyCacheTTL time.Duration
+
+	// Per-key allow/deny counts shown by /peek, kept this long after a key's last check
+	// 0 disables them, saving the extra write on every check
+	DecisionCountsTTL time.Duration
+
+	// Sampled log of blocked checks for abuse detection: at most BlockLogRate lines a second
+	// after a burst of BlockLogBurst - 0 rate disables
+	BlockLogRate  float64
+	BlockLogBurst int
+
+	// Shared deadline for draining HTTP/gRPC and closing Redis on SIGINT/SIGTERM
+	ShutdownTimeout time.Duration
+
+	// Up to this much (percent) is randomly added to key TTLs so keys created together
+	// don't all expire in the same second
+	TTLJitterPercent float64
+
+	// Algorithm used when a check omits one - empty means the client must always send it
+	DefaultAlgorithm string
+
+	// IANA time zone whose midnight starts each quota period (e.g. America/New_York)
+	QuotaTimezone string
+
+	// RoutingRulesFile is a JSON file of rules choosing the algorithm and limits for checks
+	// that omit the algorithm, consulted before DefaultAlgorithm - empty disables
+	RoutingRulesFile string
+
+	// Base URLs of peer instances aggregated by /fleet (e.g. http://rl-2:8080)
+	FleetPeers []string
+
+	// Caps distinct keys one source (API key/IP) may create per window - 0 disables
+	SourceKeyLimit  int64
+	SourceKeyWindow time.Duration
+
+	// CIDRs of proxies whose X-Forwarded-For/X-Real-IP are believed when resolving the
+	// caller's IP - empty means only the connection's own address is used
+	TrustedProxies []string
+
+	// Exposes POST /admin/scripts/reload for swapping Lua scripts at runtime
+	ScriptReloadEnabled bool
+
+	// LuaDir overrides the built-in Lua scripts with files of the same name - empty uses the built-ins
+	LuaDir string
+
+	// Exposes GET /config with the effective (non-secret) settings
+	ConfigEndpointEnabled bool
+
+	// Accepts GET /check with query parameters, for clients that can't POST JSON
+	CheckGetEnabled bool
+
+	// CheckMode is json or gateway - a request's ?mode= overrides it
+	CheckMode string
+
+	// AdminToken is the bearer token for destructive admin endpoints - empty leaves them unregistered
+	AdminToken string
+	// Paths that require AdminToken - an entry ending in "/" covers everything under it
+	AdminProtectedPaths []string
+	// SCAN page size (and delete batch) for POST /reset/bulk
+	ResetScanCount int64
+
+	// Environment name - "production" always refuses client-supplied timestamps
+	Environment string
+
+	// Lets requests supply now_ms to drive the scripts' clock (staging/test suites only)
+	AllowClientTimestamps bool
+
+	// Tier names allowed as a metrics label - anything else is counted as "other"
+	MetricTiers []string
+
+	// Keys, as stored in Redis (e.g. "rl:billing:user:123"), that get per-key metrics
+	// labelled by a hash of the key. Each one is a new label value, so there can be at
+	// most MaxWatchKeys of them
+	WatchKeys    []string
+	MaxWatchKeys int
+
+	// What checks do when Redis is unavailable: open, closed or local
+	// local enforces LocalFallbackFraction of each limit in memory on this instance
+	FailMode              string
+	LocalFallbackFraction float64
+
+	// Health reports not-ready for this long after startup
+	WarmupDelay time.Duration
+
+	// Whether /readyz fails while Redis is down: true, false, or auto (only under FAIL_MODE=closed
+	// or error, since the other modes keep serving decisions without Redis)
+	ReadinessRequiresRedis string
+
 	// When true, logs every request (useful for debugging but adds overhead)
 	DebugLogging bool
+
+	// CORS - "*" in CORSAllowedOrigins allows any origin
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+
+	// Log output format: text or json
+	LogFormat string
+
+	// ProfilesFile is a JSON file of named limit profiles checks can refer to - empty disables
+	ProfilesFile string
+	// ProfilesReloadInterval is how often the file is polled for changes - 0 disables reloading
+	ProfilesReloadInterval time.Duration
+
+	// OpenTelemetry tracing - spans are exported over OTLP/gRPC when enabled
+	OTelEnabled     bool
+	OTelEndpoint    string
+	OTelInsecure    bool
+	OTelSampleRatio float64
+
+	// TLS for the HTTP server - leave empty to serve plain HTTP (e.g. behind a proxy)
+	// Setting TLSClientCAFile additionally requires clients to present a cert (mTLS)
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	// Backpressure: delay allowed responses once remaining/capacity drops below the threshold
+	// Nudges clients to slow down before they start getting denied
+	BackpressureEnabled   bool
+	BackpressureThreshold float64
+	BackpressureMaxDelay  time.Duration
+
+	// Periodic analytics snapshots of aggregate state - 0 interval disables
+	SnapshotInterval  time.Duration
+	SnapshotTopN      int
+	SnapshotSink      string // log, file or redis
+	SnapshotFile      string
+	SnapshotRedisKey  string
+	SnapshotScanCount int64
+
+	// Forward Redis key expiry (limit reset) events - needs notify-keyspace-events=Ex
+	// Events go to the webhook if set, otherwise to the log
+	KeyspaceEventsEnabled bool
+	KeyspaceEventsWebhook string
 }
 
 // Load pulls config from environment variables with sensible defaults
 func Load() *Config {
-	return &Config{
+	cfg := &Config{
 		ServerPort:        getEnv("PORT", "8080"),
-		RedisAddr:         getEnv("REDIS_ADDR", "localhost:6379"),
+		GRPCPort:          getEnv("GRPC_PORT", ""),
+		RedisAddr:         getEnv("REDIS_ADDR", ""),
+		RedisClusterAddrs: getEnvAsList("REDIS_CLUSTER_ADDRS"),
+
+		RedisClusterMaxRedirects: getEnvAsInt("REDIS_CLUSTER_MAX_REDIRECTS", 8),
+		RedisUsername:     getEnv("REDIS_USERNAME", ""),
 		RedisPassword:     getEnv("REDIS_PASSWORD", ""),
 		RedisDB:           getEnvAsInt("REDIS_DB", 0),
+
+		RedisSentinelAddrs: getEnvAsList("REDIS_SENTINEL_ADDRS"),
+		RedisMasterName:    getEnv("REDIS_MASTER_NAME", ""),
+
 		RedisPoolSize:     getEnvAsInt("REDIS_POOL_SIZE", 100),
 		RedisMinIdleConns: getEnvAsInt("REDIS_MIN_IDLE_CONNS", 10),
 		RedisTimeout:      getEnvAsDuration("REDIS_TIMEOUT", 2*time.Millisecond),
+		RedisRetryBackoff: getEnvAsDuration("REDIS_RETRY_BACKOFF", 0),
+		ClockSource:       getEnv("CLOCK_SOURCE", ClockSourceRedis),
+		ClockSyncInterval: getEnvAsDuration("CLOCK_SYNC_INTERVAL", 30*time.Second),
+		DefaultAlgorithm:  getEnv("DEFAULT_ALGORITHM", ""),
+		RoutingRulesFile:  getEnv("ROUTING_RULES_FILE", ""),
+		QuotaTimezone:     getEnv("QUOTA_TIMEZONE", "UTC"),
+		WarmupDelay:       getEnvAsDuration("WARMUP_DELAY", 0),
 		DebugLogging:      getEnvAsBool("DEBUG_LOGGING", false),
+		LogFormat:         getEnv("LOG_FORMAT", LogFormatText),
+
+		ProfilesFile:           getEnv("PROFILES_FILE", ""),
+		ProfilesReloadInterval: getEnvAsDuration("PROFILES_RELOAD_INTERVAL", 10*time.Second),
+
+		ConcurrencyLeaseTTL: getEnvAsDuration("CONCURRENCY_LEASE_TTL", time.Minute),
+		DedupTTL:            getEnvAsDuration("DEDUP_TTL", 10*time.Second),
+		DenyCacheTTL:        getEnvAsDuration("DENY_CACHE_TTL", 0),
+		DecisionCountsTTL:   getEnvAsDuration("DECISION_COUNTS_TTL", 0),
+
+		BlockLogRate:  getEnvAsFloat("BLOCK_LOG_RATE", 0),
+		BlockLogBurst: getEnvAsInt("BLOCK_LOG_BURST", 10),
+
+		MetricsFlushInterval: getEnvAsDuration("METRICS_FLUSH_INTERVAL", time.Second),
+		CheckLatencyBuckets:  getEnvAsFloatList("CHECK_LATENCY_BUCKETS", []float64{0.5, 1, 2, 3, 5, 10, 25, 50}),
+		RedisLatencyBuckets:  getEnvAsFloatList("REDIS_LATENCY_BUCKETS", []float64{0.1, 0.5, 1, 2, 5, 10, 25, 50, 100}),
+		StreamMaxInFlight:    getEnvAsInt("STREAM_MAX_IN_FLIGHT", 256),
+
+		AdaptiveEnabled:         getEnvAsBool("ADAPTIVE_ENABLED", false),
+		AdaptiveRefreshInterval: getEnvAsDuration("ADAPTIVE_REFRESH_INTERVAL", time.Second),
+		ShutdownTimeout:     getEnvAsDuration("SHUTDOWN_TIMEOUT", 5*time.Second),
+		TTLJitterPercent:    getEnvAsFloat("TTL_JITTER_PERCENT", 10),
+
+		MaxCapacity:      int64(getEnvAsInt("MAX_CAPACITY", 1000000000)),
+		MaxWindowSeconds: int64(getEnvAsInt("MAX_WINDOW_SECONDS", 30*24*60*60)),
+		MaxRefillRate:    getEnvAsFloat("MAX_REFILL_RATE", 1000000),
+
+		MaxBodyBytes:      int64(getEnvAsInt("MAX_BODY_BYTES", 64<<10)),
+		MaxBatchBodyBytes: int64(getEnvAsInt("MAX_BATCH_BODY_BYTES", 1<<20)),
+
+		RedisKeyPrefix:   getEnv("REDIS_KEY_PREFIX", ""),
+		RequireNamespace: getEnvAsBool("REQUIRE_NAMESPACE", false),
+		HashKeys:         getEnvAsBool("HASH_KEYS", false),
+		KeyLowercase:     getEnvAsBool("KEY_LOWERCASE", false),
+		MaxKeyLength:     getEnvAsInt("MAX_KEY_LENGTH", 1024),
+
+		CORSAllowedOrigins: getEnvAsListOr("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		CORSAllowedMethods: getEnvAsListOr("CORS_ALLOWED_METHODS", []string{"GET", "POST", "OPTIONS"}),
+		CORSAllowedHeaders: getEnvAsListOr("CORS_ALLOWED_HEADERS", []string{"Content-Type"}),
+
+		OTelEnabled:     getEnvAsBool("OTEL_ENABLED", false),
+		OTelEndpoint:    getEnv("OTEL_ENDPOINT", "localhost:4317"),
+		OTelInsecure:    getEnvAsBool("OTEL_INSECURE", true),
+		OTelSampleRatio: getEnvAsFloat("OTEL_SAMPLE_RATIO", 1.0),
+		TLSCertFile:       getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:        getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:   getEnv("TLS_CLIENT_CA_FILE", ""),
+
+		BackpressureEnabled:   getEnvAsBool("BACKPRESSURE_ENABLED", false),
+		BackpressureThreshold: getEnvAsFloat("BACKPRESSURE_THRESHOLD", 0.1),
+		BackpressureMaxDelay:  getEnvAsDuration("BACKPRESSURE_MAX_DELAY", 50*time.Millisecond),
+
+		SnapshotInterval:  getEnvAsDuration("SNAPSHOT_INTERVAL", 0),
+		SnapshotTopN:      getEnvAsInt("SNAPSHOT_TOP_N", 10),
+		SnapshotSink:      getEnv("SNAPSHOT_SINK", "log"),
+		SnapshotFile:      getEnv("SNAPSHOT_FILE", ""),
+		SnapshotRedisKey:  getEnv("SNAPSHOT_REDIS_KEY", "snapshot:latest"),
+		SnapshotScanCount: int64(getEnvAsInt("SNAPSHOT_SCAN_COUNT", 500)),
+
+		KeyspaceEventsEnabled: getEnvAsBool("KEYSPACE_EVENTS_ENABLED", false),
+		KeyspaceEventsWebhook: getEnv("KEYSPACE_EVENTS_WEBHOOK", ""),
+
+		RedisTLSEnabled:            getEnvAsBool("REDIS_TLS_ENABLED", false),
+		RedisTLSInsecureSkipVerify: getEnvAsBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+		RedisTLSCAFile:             getEnv("REDIS_TLS_CA_FILE", ""),
+
+		RedisReconnectInterval: getEnvAsDuration("REDIS_RECONNECT_INTERVAL", 5*time.Second),
+		RedisPoolStatsInterval: getEnvAsDuration("REDIS_POOL_STATS_INTERVAL", 10*time.Second),
+
+		CircuitBreakerThreshold: getEnvAsInt("CIRCUIT_BREAKER_THRESHOLD", 5),
+		CircuitBreakerWindow:    getEnvAsDuration("CIRCUIT_BREAKER_WINDOW", 10*time.Second),
+		CircuitBreakerCooldown:  getEnvAsDuration("CIRCUIT_BREAKER_COOLDOWN", 5*time.Second),
+		MaxInflightChecks:       getEnvAsInt("MAX_INFLIGHT_CHECKS", 0),
+
+		FleetPeers: getEnvAsList("FLEET_PEERS"),
+
+		ScriptReloadEnabled:   getEnvAsBool("SCRIPT_RELOAD_ENABLED", false),
+		ConfigEndpointEnabled: getEnvAsBool("CONFIG_ENDPOINT_ENABLED", false),
+		CheckGetEnabled:       getEnvAsBool("CHECK_GET_ENABLED", false),
+		CheckMode:             getEnv("CHECK_MODE", CheckModeJSON),
+		LuaDir:                getEnv("LUA_DIR", ""),
+
+		AdminToken:          getEnv("ADMIN_TOKEN", ""),
+		AdminProtectedPaths: getEnvAsListOr("ADMIN_PROTECTED_PATHS", []string{"/admin/", "/config", "/reset/", "/adaptive/"}),
+		ResetScanCount:      int64(getEnvAsInt("RESET_SCAN_COUNT", 500)),
+
+		SourceKeyLimit:  int64(getEnvAsInt("SOURCE_KEY_LIMIT", 0)),
+		SourceKeyWindow: getEnvAsDuration("SOURCE_KEY_WINDOW", time.Hour),
+
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
+
+		Environment:           getEnv("ENVIRONMENT", "production"),
+		AllowClientTimestamps: getEnvAsBool("ALLOW_CLIENT_TIMESTAMPS", false),
+
+		MetricTiers: getEnvAsList("METRIC_TIERS"),
+
+		WatchKeys:    getEnvAsList("WATCH_KEYS"),
+		MaxWatchKeys: getEnvAsInt("MAX_WATCH_KEYS", 20),
+
+		FailMode:              getEnv("FAIL_MODE", FailModeOpen),
+		LocalFallbackFraction: getEnvAsFloat("LOCAL_FALLBACK_FRACTION", 0.1),
+
+		ReadinessRequiresRedis: getEnv("READINESS_REQUIRES_REDIS", "auto"),
+	}
+
+	// Only default the single-node address when cluster or Sentinel mode isn't configured,
+	// so Validate can tell an explicit REDIS_ADDR apart from the default
+	if cfg.RedisAddr == "" && len(cfg.RedisClusterAddrs) == 0 && len(cfg.RedisSentinelAddrs) == 0 {
+		cfg.RedisAddr = "localhost:6379"
+	}
+
+	return cfg
+}
+
+// Validate catches conflicting settings that can't be resolved with a default
+func (c *Config) Validate() error {
+	if c.RedisAddr != "" && len(c.RedisClusterAddrs) > 0 {
+		return errors.New("REDIS_ADDR and REDIS_CLUSTER_ADDRS are mutually exclusive")
+	}
+	if len(c.RedisSentinelAddrs) > 0 {
+		if len(c.RedisClusterAddrs) > 0 {
+			return errors.New("REDIS_SENTINEL_ADDRS and REDIS_CLUSTER_ADDRS are mutually exclusive")
+		}
+		if c.RedisAddr != "" {
+			return errors.New("REDIS_ADDR and REDIS_SENTINEL_ADDRS are mutually exclusive")
+		}
+		if c.RedisMasterName == "" {
+			return errors.New("REDIS_MASTER_NAME is required with REDIS_SENTINEL_ADDRS")
+		}
+	} else if c.RedisMasterName != "" {
+		return errors.New("REDIS_MASTER_NAME needs REDIS_SENTINEL_ADDRS")
+	}
+	if !IsFailMode(c.FailMode) {
+		return fmt.Errorf("FAIL_MODE must be %q, %q, %q or %q", FailModeOpen, FailModeClosed, FailModeLocal, FailModeError)
+	}
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		return fmt.Errorf("LOG_FORMAT must be %q or %q", LogFormatText, LogFormatJSON)
+	}
+	if c.CheckMode != CheckModeJSON && c.CheckMode != CheckModeGateway {
+		return fmt.Errorf("CHECK_MODE must be %q or %q", CheckModeJSON, CheckModeGateway)
+	}
+	if c.ClockSource != ClockSourceLocal && c.ClockSource != ClockSourceRedis {
+		return fmt.Errorf("CLOCK_SOURCE must be %q or %q", ClockSourceLocal, ClockSourceRedis)
+	}
+	if c.ClockSource == ClockSourceRedis && c.ClockSyncInterval <= 0 {
+		return errors.New("CLOCK_SYNC_INTERVAL must be positive when CLOCK_SOURCE is redis")
+	}
+	if c.OTelSampleRatio < 0 || c.OTelSampleRatio > 1 {
+		return errors.New("OTEL_SAMPLE_RATIO must be in [0, 1]")
+	}
+	if c.DedupTTL < 0 {
+		return errors.New("DEDUP_TTL cannot be negative")
+	}
+	for _, path := range c.AdminProtectedPaths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("ADMIN_PROTECTED_PATHS entry %q must start with /", path)
+		}
+	}
+	if _, err := c.TrustedProxyNets(); err != nil {
+		return err
+	}
+	if _, err := c.QuotaLocation(); err != nil {
+		return err
+	}
+	if c.AdaptiveEnabled && c.AdaptiveRefreshInterval <= 0 {
+		return errors.New("ADAPTIVE_REFRESH_INTERVAL must be positive when ADAPTIVE_ENABLED is set")
+	}
+	if c.StreamMaxInFlight <= 0 {
+		return errors.New("STREAM_MAX_IN_FLIGHT must be positive")
+	}
+	if !validBuckets(c.CheckLatencyBuckets) {
+		return errors.New("CHECK_LATENCY_BUCKETS must be comma-separated numbers in increasing order")
+	}
+	if !validBuckets(c.RedisLatencyBuckets) {
+		return errors.New("REDIS_LATENCY_BUCKETS must be comma-separated numbers in increasing order")
+	}
+	if c.MetricsFlushInterval < 0 {
+		return errors.New("METRICS_FLUSH_INTERVAL cannot be negative")
+	}
+	if c.DenyCacheTTL < 0 {
+		return errors.New("DENY_CACHE_TTL cannot be negative")
+	}
+	if c.DecisionCountsTTL < 0 {
+		return errors.New("DECISION_COUNTS_TTL cannot be negative")
+	}
+	if c.BlockLogRate < 0 {
+		return errors.New("BLOCK_LOG_RATE cannot be negative")
+	}
+	if c.BlockLogRate > 0 && c.BlockLogBurst < 1 {
+		return errors.New("BLOCK_LOG_BURST must be at least 1 when BLOCK_LOG_RATE is set")
+	}
+	if c.RedisClusterMaxRedirects < 1 {
+		return errors.New("REDIS_CLUSTER_MAX_REDIRECTS must be at least 1")
+	}
+	if c.MaxInflightChecks < 0 {
+		return errors.New("MAX_INFLIGHT_CHECKS cannot be negative")
+	}
+	if c.RedisRetryBackoff < 0 {
+		return errors.New("REDIS_RETRY_BACKOFF cannot be negative")
+	}
+	if c.RedisReconnectInterval <= 0 {
+		return errors.New("REDIS_RECONNECT_INTERVAL must be positive")
+	}
+	if c.MaxKeyLength < 0 {
+		return errors.New("MAX_KEY_LENGTH cannot be negative")
+	}
+	if c.ResetScanCount <= 0 {
+		return errors.New("RESET_SCAN_COUNT must be positive")
+	}
+	if c.BackpressureEnabled && (c.BackpressureThreshold <= 0 || c.BackpressureThreshold > 1) {
+		return errors.New("BACKPRESSURE_THRESHOLD must be in (0, 1] when BACKPRESSURE_ENABLED is set")
+	}
+	if c.BackpressureMaxDelay < 0 {
+		return errors.New("BACKPRESSURE_MAX_DELAY cannot be negative")
+	}
+	if c.TTLJitterPercent < 0 || c.TTLJitterPercent > 100 {
+		return errors.New("TTL_JITTER_PERCENT must be in [0, 100]")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("SHUTDOWN_TIMEOUT must be positive")
 	}
+	switch c.ReadinessRequiresRedis {
+	case "auto", "true", "false":
+	default:
+		return fmt.Errorf("READINESS_REQUIRES_REDIS must be auto, true or false, got %q", c.ReadinessRequiresRedis)
+	}
+	if c.MaxCapacity < 0 || c.MaxWindowSeconds < 0 || c.MaxRefillRate < 0 {
+		return errors.New("MAX_CAPACITY, MAX_WINDOW_SECONDS and MAX_REFILL_RATE cannot be negative")
+	}
+	if c.MaxBodyBytes <= 0 || c.MaxBatchBodyBytes <= 0 {
+		return errors.New("MAX_BODY_BYTES and MAX_BATCH_BODY_BYTES must be positive")
+	}
+	if c.LocalFallbackFraction <= 0 || c.LocalFallbackFraction > 1 {
+		return errors.New("LOCAL_FALLBACK_FRACTION must be in (0, 1]")
+	}
+	if len(c.WatchKeys) > c.MaxWatchKeys {
+		return fmt.Errorf("WATCH_KEYS lists %d keys, more than MAX_WATCH_KEYS (%d)", len(c.WatchKeys), c.MaxWatchKeys)
+	}
+	return nil
+}
+
+// RedisTarget describes where we're connecting, for logs
+func (c *Config) RedisTarget() string {
+	if len(c.RedisClusterAddrs) > 0 {
+		return "cluster[" + strings.Join(c.RedisClusterAddrs, ",") + "]"
+	}
+	if len(c.RedisSentinelAddrs) > 0 {
+		return "sentinel[" + c.RedisMasterName + "@" + strings.Join(c.RedisSentinelAddrs, ",") + "]"
+	}
+	return c.RedisAddr
+}
+
+// TrustedProxyNets parses TRUSTED_PROXIES - a bare address is taken as a single-host range
+func (c *Config) TrustedProxyNets() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, entry := range c.TrustedProxies {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP or CIDR", entry)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES entry %q is not an IP or CIDR", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// QuotaLocation loads QUOTA_TIMEZONE
+func (c *Config) QuotaLocation() (*time.Location, error) {
+	loc, err := time.LoadLocation(c.QuotaTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("QUOTA_TIMEZONE %q: %w", c.QuotaTimezone, err)
+	}
+	return loc, nil
+}
+
+// ClientTimestampsEnabled reports whether now_ms from requests should be honoured
+// Hard-disabled in production regardless of ALLOW_CLIENT_TIMESTAMPS
+func (c *Config) ClientTimestampsEnabled() bool {
+	return c.AllowClientTimestamps && c.Environment != "production"
+}
+
+// RedisRequiredForReadiness reports whether Redis being down should take the instance out of rotation
+// auto follows FAIL_MODE: fail-open and local fallback still serve, fail-closed denies everything
+// and the error mode answers nothing
+func (c *Config) RedisRequiredForReadiness() bool {
+	switch c.ReadinessRequiresRedis {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return c.FailMode == FailModeClosed || c.FailMode == FailModeError
 }
 
 func getEnv(key, defaultVal string) string {
@@ -44,6 +618,56 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+// getEnvAsList splits a comma-separated value, dropping empty entries
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvAsListOr is getEnvAsList with a default for unset (or all-empty) values
+func getEnvAsListOr(key string, defaultVal []string) []string {
+	if list := getEnvAsList(key); len(list) > 0 {
+		return list
+	}
+	return defaultVal
+}
+
+// getEnvAsFloatList parses a comma-separated list of numbers
+// Returns nil if any entry doesn't parse, so Validate can reject it instead of guessing
+func getEnvAsFloatList(key string, defaultVal []float64) []float64 {
+	items := getEnvAsList(key)
+	if len(items) == 0 {
+		return defaultVal
+	}
+	list := make([]float64, 0, len(items))
+	for _, item := range items {
+		val, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil
+		}
+		list = append(list, val)
+	}
+	return list
+}
+
+// validBuckets reports whether bounds can be histogram buckets: non-empty, finite, strictly increasing
+func validBuckets(bounds []float64) bool {
+	if len(bounds) == 0 {
+		return false
+	}
+	for i, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) || (i > 0 && b <= bounds[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
 func getEnvAsInt(key string, defaultVal int) int {
 	valStr := os.Getenv(key)
 	if val, err := strconv.Atoi(valStr); err == nil {
@@ -52,6 +676,14 @@ func getEnvAsInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	valStr := os.Getenv(key)
+	if val, err := strconv.ParseFloat(valStr, 64); err == nil {
+		return val
+	}
+	return defaultVal
+}
+
 func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
 	valStr := os.Getenv(key)
 	if val, err := time.ParseDuration(valStr); err == nil {
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateCapsWatchKeys(t *testing.T) {
+	cfg := Load()
+	cfg.WatchKeys = []string{"a", "b", "c"}
+
+	cfg.MaxWatchKeys = 3
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with WATCH_KEYS at the cap: %v", err)
+	}
+
+	cfg.MaxWatchKeys = 2
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate accepted more WATCH_KEYS than MAX_WATCH_KEYS")
+	}
+}
+
+func TestValidateRejectsNegativeLimitCaps(t *testing.T) {
+	for _, set := range []func(*Config){
+		func(c *Config) { c.MaxCapacity = -1 },
+		func(c *Config) { c.MaxWindowSeconds = -1 },
+		func(c *Config) { c.MaxRefillRate = -1 },
+	} {
+		cfg := Load()
+		set(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate accepted a negative cap: %+v", cfg)
+		}
+	}
+
+	if cfg := Load(); cfg.MaxCapacity <= 0 || cfg.MaxWindowSeconds <= 0 || cfg.MaxRefillRate <= 0 {
+		t.Errorf("default caps = %d, %d, %v, want every cap on", cfg.MaxCapacity, cfg.MaxWindowSeconds, cfg.MaxRefillRate)
+	}
+}
+
+func TestValidateChecksBackpressure(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		threshold float64
+		maxDelay  time.Duration
+		ok        bool
+	}{
+		{"defaults", true, 0.1, 50 * time.Millisecond, true},
+		{"threshold of one", true, 1, 50 * time.Millisecond, true},
+		{"zero max delay", true, 0.1, 0, true},
+		{"zero threshold", true, 0, 50 * time.Millisecond, false},
+		{"negative threshold", true, -0.5, 50 * time.Millisecond, false},
+		{"threshold above one", true, 1.5, 50 * time.Millisecond, false},
+		{"negative max delay", true, 0.1, -time.Millisecond, false},
+		{"threshold ignored when disabled", false, 2, 50 * time.Millisecond, true},
+		{"negative max delay when disabled", false, 0.1, -time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Load()
+			cfg.BackpressureEnabled = tt.enabled
+			cfg.BackpressureThreshold = tt.threshold
+			cfg.BackpressureMaxDelay = tt.maxDelay
+			if err := cfg.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestClientTimestampsEnabled(t *testing.T) {
+	tests := []struct {
+		environment string
+		allow       bool
+		want        bool
+	}{
+		{"production", true, false},
+		{"production", false, false},
+		{"staging", true, true},
+		{"staging", false, false},
+		{"", true, true},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Environment: tt.environment, AllowClientTimestamps: tt.allow}
+		if got := cfg.ClientTimestampsEnabled(); got != tt.want {
+			t.Errorf("ENVIRONMENT=%q ALLOW_CLIENT_TIMESTAMPS=%v: enabled = %v, want %v", tt.environment, tt.allow, got, tt.want)
+		}
+	}
+	if Load().ClientTimestampsEnabled() {
+		t.Error("client timestamps enabled by default")
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSEnabled reports whether the HTTP server should terminate TLS itself
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSKeyFile != ""
+}
+
+// ServerTLSConfig builds the HTTP server TLS config from the cert/key/CA paths
+// Returns nil when TLS isn't configured. Loading everything up front means a bad
+// cert path fails at startup instead of on the first handshake.
+func (c *Config) ServerTLSConfig() (*tls.Config, error) {
+	if !c.TLSEnabled() {
+		if c.TLSClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+		return nil, errors.New("both TLS_CERT_FILE and TLS_KEY_FILE must be set to enable TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	// mTLS: only clients presenting a cert signed by this CA get through
+	if c.TLSClientCAFile != "" {
+		pool, err := loadCertPool(c.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+// RedisTLSConfig builds the client TLS config for Redis connections
+// Returns nil when REDIS_TLS_ENABLED is off. main calls it at startup so an
+// unreadable CA file fails fast rather than on every reconnect attempt.
+func (c *Config) RedisTLSConfig() (*tls.Config, error) {
+	if !c.RedisTLSEnabled {
+		if c.RedisTLSCAFile != "" || c.RedisTLSInsecureSkipVerify {
+			return nil, errors.New("REDIS_TLS_CA_FILE and REDIS_TLS_INSECURE_SKIP_VERIFY require REDIS_TLS_ENABLED")
+		}
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Only for self-signed test setups - disables server identity checks entirely
+		InsecureSkipVerify: c.RedisTLSInsecureSkipVerify,
+	}
+
+	if c.RedisTLSCAFile != "" {
+		pool, err := loadCertPool(c.RedisTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return tlsCfg, nil
+}
+
+// loadCertPool reads a PEM bundle into a cert pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, parsed and PEM encoded
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	kpem []byte
+}
+
+// issue makes a certificate for tmpl, signed by parent (self-signed when nil)
+func issue(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		kpem: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}),
+	}
+}
+
+// writeFile writes data under dir and returns its path
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestServerTLSConfigRequiresClientCertWithCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+	server := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "rate-limiter"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	cfg := &Config{
+		TLSCertFile:     writeFile(t, dir, "server.pem", server.pem),
+		TLSKeyFile:      writeFile(t, dir, "server-key.pem", server.kpem),
+		TLSClientCAFile: writeFile(t, dir, "ca.pem", ca.pem),
+	}
+	tlsCfg, err := cfg.ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = tlsCfg
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // the rejected handshakes
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) error {
+		c := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		resp, err := c.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(); err == nil {
+		t.Error("request without a client certificate succeeded, want it rejected")
+	}
+
+	stranger := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "stranger"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil)
+	if err := get(tls.Certificate{Certificate: [][]byte{stranger.cert.Raw}, PrivateKey: stranger.key}); err == nil {
+		t.Error("request with a certificate from another CA succeeded, want it rejected")
+	}
+
+	if err := get(tls.Certificate{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}); err != nil {
+		t.Errorf("request with a valid client certificate: %v", err)
+	}
+}
+
+func TestServerTLSConfigFailsFastOnBadFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := (&Config{TLSCertFile: filepath.Join(dir, "missing.pem")}).ServerTLSConfig(); err == nil {
+		t.Error("accepted TLS_CERT_FILE without TLS_KEY_FILE")
+	}
+	if _, err := (&Config{TLSClientCAFile: filepath.Join(dir, "ca.pem")}).ServerTLSConfig(); err == nil {
+		t.Error("accepted TLS_CLIENT_CA_FILE without a server certificate")
+	}
+
+	cfg := &Config{
+		TLSCertFile: writeFile(t, dir, "cert.pem", []byte("not a certificate")),
+		TLSKeyFile:  writeFile(t, dir, "key.pem", []byte("not a key")),
+	}
+	if _, err := cfg.ServerTLSConfig(); err == nil {
+		t.Error("accepted unparseable certificate files")
+	}
+
+	if tlsCfg, err := (&Config{}).ServerTLSConfig(); tlsCfg != nil || err != nil {
+		t.Errorf("ServerTLSConfig without TLS = %v, %v, want nil, nil", tlsCfg, err)
+	}
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/redis/go-redis/v9"
+)
+
+// ExpiryEvent is what gets forwarded when a rate limit key expires
+// An expired key means its state has fully reset - the next check starts from a full limit
+type ExpiryEvent struct {
+	Key       string    `json:"key"`
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// companionKeyPattern matches the keys scripts keep beside a limit (see the limiter's
+// companionKey): a hash tag, then the sliding window counter, a request_id replay,
+// a penalty, decision counts or concurrency leases
+var companionKeyPattern = regexp.MustCompile(`^[^{]*\{[^}]+\}.*:(counter|decisions|penalty|leases|req:.+)$`)
+
+// quotaPeriodPattern matches a quota's per-period counter (key:2026-01-02 or key:2026-01)
+var quotaPeriodPattern = regexp.MustCompile(`:\d{4}-\d{2}(-\d{2})?$`)
+
+// internalKeyNames are bookkeeping keys the service keeps under its prefix
+var internalKeyNames = []string{"source_keys:", "__script_validation__"}
+
+// ExpiryForwarder relays Redis keyspace expiry events to a webhook or the log
+// Every instance subscribes, so with N replicas a webhook sees each event N times
+type ExpiryForwarder struct {
+	redis      *redisclient.Client
+	keyPrefix  string
+	webhookURL string
+	httpClient *http.Client
+
+	// retryInterval is how often to try subscribing while Redis is down (REDIS_RECONNECT_INTERVAL)
+	retryInterval time.Duration
+}
+
+func NewExpiryForwarder(redis *redisclient.Client, cfg *config.Config) *ExpiryForwarder {
+	return &ExpiryForwarder{
+		redis:      redis,
+		keyPrefix:  cfg.RedisKeyPrefix,
+		webhookURL: cfg.KeyspaceEventsWebhook,
+		httpClient: &http.Client{Timeout: 2 * time.Second},
+
+		retryInterval: cfg.RedisReconnectInterval,
+	}
+}
+
+// CheckServerConfig warns if Redis isn't publishing expiry events
+// We don't set it ourselves - managed Redis often forbids CONFIG SET
+func (f *ExpiryForwarder) CheckServerConfig(ctx context.Context) {
+	vals, err := f.redis.ConfigGet(ctx, "notify-keyspace-events")
+	if err != nil {
+		logging.Printf("⚠️  Could not read notify-keyspace-events (%v) - expiry events may not arrive", err)
+		return
+	}
+
+	flags := vals["notify-keyspace-events"]
+	hasClass := strings.Contains(flags, "E")
+	hasExpired := strings.Contains(flags, "x") || strings.Contains(flags, "A")
+	if !hasClass || !hasExpired {
+		logging.Printf("⚠️  notify-keyspace-events is %q - set it to include \"Ex\" to receive expiry events", flags)
+	}
+}
+
+// Run forwards expiry events until ctx is cancelled
+// Redis may still be down at startup, so it keeps trying to subscribe until it's up
+func (f *ExpiryForwarder) Run(ctx context.Context) {
+	pubsub := f.subscribe(ctx)
+	if pubsub == nil {
+		return
+	}
+	defer pubsub.Close()
+	f.CheckServerConfig(ctx)
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !f.isLimitKey(msg.Payload) {
+				continue
+			}
+			f.forward(ctx, ExpiryEvent{Key: msg.Payload, Event: "expired", Timestamp: time.Now().UTC()})
+		}
+	}
+}
+
+// isLimitKey reports whether an expired key is a limit's own state - under
+// REDIS_KEY_PREFIX, and not a companion, a quota period counter or bookkeeping
+// Other apps sharing the Redis, and keys that expire alongside a limit, are just noise
+func (f *ExpiryForwarder) isLimitKey(key string) bool {
+	if f.keyPrefix != "" {
+		if !strings.HasPrefix(key, f.keyPrefix+":") {
+			return false
+		}
+		key = strings.TrimPrefix(key, f.keyPrefix+":")
+	}
+	if companionKeyPattern.MatchString(key) || quotaPeriodPattern.MatchString(key) {
+		return false
+	}
+	for _, name := range internalKeyNames {
+		if strings.HasPrefix(key, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// subscribe retries every retryInterval until the subscription is made
+// Returns nil if ctx is cancelled first
+func (f *ExpiryForwarder) subscribe(ctx context.Context) *redis.PubSub {
+	ticker := time.NewTicker(f.retryInterval)
+	defer ticker.Stop()
+
+	logged := false
+	for {
+		pubsub, err := f.redis.SubscribeExpired(ctx)
+		if err == nil {
+			return pubsub
+		}
+		if !logged {
+			logging.Printf("expiry event subscription failed, retrying every %v: %v", f.retryInterval, err)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *ExpiryForwarder) forward(ctx context.Context, event ExpiryEvent) {
+	if f.webhookURL == "" {
+		logging.Printf("rate limit key expired: %s", event.Key)
+		return
+	}
+
+	if err := f.post(ctx, event); err != nil {
+		logging.Printf("expiry webhook failed for %s: %v", event.Key, err)
+	}
+}
+
+func (f *ExpiryForwarder) post(ctx context.Context, event ExpiryEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
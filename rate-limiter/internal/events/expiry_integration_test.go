@@ -0,0 +1,66 @@
+//go:build integration
+
+package events
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// Needs a real Redis (REDIS_ADDR, default localhost:6379) that allows CONFIG SET:
+// miniredis doesn't publish keyspace events
+//
+//	go test -tags integration ./internal/events
+func TestRunForwardsExpiryToWebhook(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	defer rdb.Close()
+	if err := rdb.ConfigSet(ctx, "notify-keyspace-events", "Ex").Err(); err != nil {
+		t.Skipf("no Redis at %s that allows CONFIG SET: %v", addr, err)
+	}
+
+	cfg := config.Load()
+	cfg.RedisAddr = addr
+	client, err := redisclient.NewClient(cfg, metrics.New(prometheus.NewRegistry(), nil, nil))
+	if err != nil {
+		t.Fatalf("redis client: %v", err)
+	}
+	defer client.Close()
+
+	url, events := webhook(t, http.StatusNoContent)
+	cfg.KeyspaceEventsWebhook = url
+	go NewExpiryForwarder(client, cfg).Run(ctx)
+
+	// The subscription is made in the background, so keep expiring keys until one is seen
+	key := "rl:integration:expiry:" + time.Now().Format("150405.000000")
+	counter := "{" + key + "}:counter"
+	for {
+		rdb.Set(ctx, counter, 1, 50*time.Millisecond)
+		rdb.Set(ctx, key, 1, 50*time.Millisecond)
+		select {
+		case event := <-events:
+			if event.Key != key || event.Event != "expired" {
+				t.Fatalf("webhook got %+v, want an expiry of %s (and never its counter)", event, key)
+			}
+			return
+		case <-time.After(500 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no expiry event forwarded")
+		}
+	}
+}
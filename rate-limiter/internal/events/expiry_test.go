@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhook is a test endpoint that hands every event it receives to the returned channel
+func webhook(t *testing.T, status int) (string, <-chan ExpiryEvent) {
+	t.Helper()
+	events := make(chan ExpiryEvent, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event ExpiryEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		events <- event
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, events
+}
+
+func TestPostSendsEventToWebhook(t *testing.T) {
+	url, events := webhook(t, http.StatusNoContent)
+	f := &ExpiryForwarder{webhookURL: url, httpClient: &http.Client{Timeout: time.Second}}
+
+	sent := ExpiryEvent{Key: "rl:user:1", Event: "expired", Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := f.post(context.Background(), sent); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	if got := <-events; got != sent {
+		t.Errorf("webhook got %+v, want %+v", got, sent)
+	}
+}
+
+func TestPostReportsWebhookFailure(t *testing.T) {
+	url, _ := webhook(t, http.StatusInternalServerError)
+	f := &ExpiryForwarder{webhookURL: url, httpClient: &http.Client{Timeout: time.Second}}
+
+	if err := f.post(context.Background(), ExpiryEvent{Key: "rl:user:1", Event: "expired"}); err == nil {
+		t.Error("post succeeded on a 500 from the webhook")
+	}
+}
+
+func TestIsLimitKey(t *testing.T) {
+	tests := []struct {
+		prefix string
+		key    string
+		want   bool
+	}{
+		{"rl", "rl:user:1", true},
+		{"rl", "rl:billing:user:1", true},
+		{"rl", "rl:{user:1}:sec", true},
+		{"rl", "rl:user:counter", true}, // a caller's key, not a companion
+		{"rl", "other-app:session:9", false},
+		{"rl", "rlx:user:1", false},
+		{"rl", "{rl:user:1}:counter", false},
+		{"rl", "rl:{user:1}:sec:counter", false},
+		{"rl", "{rl:user:1}:req:3f2a9c", false},
+		{"rl", "{rl:user:1}:penalty", false},
+		{"rl", "{rl:user:1}:decisions", false},
+		{"rl", "{rl:user:1}:leases", false},
+		{"rl", "rl:user:1:2026-01-02", false},
+		{"rl", "rl:user:1:2026-01", false},
+		{"rl", "rl:source_keys:api-key-1", false},
+		{"rl", "rl:__script_validation__:gcra", false},
+		{"", "user:1", true},
+		{"", "{user:1}:counter", false},
+		{"", "user:1:2026-01-02", false},
+	}
+	for _, tt := range tests {
+		f := &ExpiryForwarder{keyPrefix: tt.prefix}
+		if got := f.isLimitKey(tt.key); got != tt.want {
+			t.Errorf("isLimitKey(%q) with prefix %q = %v, want %v", tt.key, tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// adaptiveFactorsKey names the factors hash under REDIS_KEY_PREFIX. With adaptive limits
+// on, callerKey refuses it as a key, so no un-namespaced limit can land on the hash
+const adaptiveFactorsKey = "adaptive_factors"
+
+// ErrInvalidFactor means SetFactor was given a factor it can't store
+var ErrInvalidFactor = errors.New("invalid adaptive factor")
+
+// AdaptiveLimiter scales capacities by a factor (0..1) the operator sets from a backend
+// health signal, so limits shrink while the protected backend is struggling
+// Factors live in one Redis hash so every instance applies the same ones: field "" is the
+// global factor, any other field overrides it for that namespace. Each instance keeps a
+// copy refreshed by Run, so checks never wait on the hash
+type AdaptiveLimiter struct {
+	redis *redisclient.Client
+
+	// key is the Redis hash holding the factors
+	key string
+
+	// factors is the last copy read from (or written to) Redis, namespace -> factor
+	factors atomic.Pointer[map[string]float64]
+}
+
+func NewAdaptiveLimiter(redis *redisclient.Client, keyPrefix string) *AdaptiveLimiter {
+	a := &AdaptiveLimiter{redis: redis, key: joinKey(keyPrefix, adaptiveFactorsKey)}
+	a.factors.Store(&map[string]float64{})
+	return a
+}
+
+// Factor is the multiplier applied to namespace's capacities - 1 when none is set
+func (a *AdaptiveLimiter) Factor(namespace string) float64 {
+	factors := *a.factors.Load()
+	if f, ok := factors[namespace]; ok {
+		return f
+	}
+	if f, ok := factors[""]; ok {
+		return f
+	}
+	return 1
+}
+
+// Factors returns the factors currently applied, "" being the global one
+func (a *AdaptiveLimiter) Factors() map[string]float64 {
+	factors := *a.factors.Load()
+	out := make(map[string]float64, len(factors))
+	for ns, f := range factors {
+		out[ns] = f
+	}
+	return out
+}
+
+// SetFactor stores namespace's factor in Redis ("" sets the global one)
+// A nil factor removes a namespace's override so it follows the global factor again
+func (a *AdaptiveLimiter) SetFactor(ctx context.Context, namespace string, factor *float64) error {
+	if factor == nil {
+		if namespace == "" {
+			return fmt.Errorf("%w: the global factor can't be removed, set it to 1 instead", ErrInvalidFactor)
+		}
+		if err := a.redis.HDel(ctx, a.key, namespace); err != nil {
+			return fmt.Errorf("adaptive factor update failed: %w", err)
+		}
+	} else {
+		if math.IsNaN(*factor) || *factor < 0 || *factor > 1 {
+			return fmt.Errorf("%w: must be between 0 and 1, got %v", ErrInvalidFactor, *factor)
+		}
+		if err := a.redis.HSet(ctx, a.key, namespace, *factor); err != nil {
+			return fmt.Errorf("adaptive factor update failed: %w", err)
+		}
+	}
+	// Apply it here straight away rather than on the next refresh
+	return a.Refresh(ctx)
+}
+
+// Refresh reloads the factors from Redis
+// Unparseable values are skipped, so one bad HSET from outside can't disable the rest
+func (a *AdaptiveLimiter) Refresh(ctx context.Context) error {
+	raw, err := a.redis.HGetAll(ctx, a.key)
+	if err != nil {
+		return err
+	}
+	factors := make(map[string]float64, len(raw))
+	for ns, value := range raw {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 || f > 1 {
+			continue
+		}
+		factors[ns] = f
+	}
+	a.factors.Store(&factors)
+	return nil
+}
+
+// Run refreshes the factors every interval until ctx is done
+// While Redis is unreachable the last factors read stay in effect
+func (a *AdaptiveLimiter) Run(ctx context.Context, interval time.Duration) {
+	if err := a.Refresh(ctx); err != nil {
+		logging.Printf("adaptive factors not loaded yet: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Only log an outage once rather than on every tick until it's over
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.Refresh(ctx)
+			if err != nil && !failing {
+				logging.Printf("adaptive factor refresh failed, keeping the last factors: %v", err)
+			}
+			failing = err != nil
+		}
+	}
+}
+
+// apply scales req's capacity by its namespace's factor
+// Capacity never drops below one request's cost, since the scripts reject a cost over
+// capacity - so a factor of 0 leaves room for a single request rather than none
+func (a *AdaptiveLimiter) apply(req CheckRequest) CheckRequest {
+	factor := a.Factor(req.Namespace)
+	// Invalid capacities are left for the algorithm to reject
+	if factor >= 1 || req.Capacity <= 0 {
+		return req
+	}
+	scaled := int64(float64(req.Capacity) * factor)
+	floor := max(1, min(req.Cost, req.Capacity))
+	req.Capacity = max(scaled, floor)
+	return req
+}
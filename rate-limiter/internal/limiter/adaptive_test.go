@@ -0,0 +1,147 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func newAdaptiveTestLimiter(t *testing.T) *testLimiter {
+	t.Helper()
+	return newTestLimiter(t, func(cfg *config.Config) {
+		cfg.AdaptiveEnabled = true
+		cfg.RedisKeyPrefix = "rl"
+	})
+}
+
+// setFactor stores namespace's factor and fails the test on an error
+func setFactor(t *testing.T, a *AdaptiveLimiter, namespace string, factor float64) {
+	t.Helper()
+	if err := a.SetFactor(context.Background(), namespace, &factor); err != nil {
+		t.Fatalf("SetFactor(%q, %v): %v", namespace, factor, err)
+	}
+}
+
+func TestAdaptiveFactorScalesCapacity(t *testing.T) {
+	tl := newAdaptiveTestLimiter(t)
+	setFactor(t, tl.Adaptive(), "", 0.5)
+	setFactor(t, tl.Adaptive(), "billing", 1)
+
+	req := tokenBucketRequest("user:1", 4, 0.001)
+	for i := 0; i < 2; i++ {
+		if !tl.check(t, req).Allowed {
+			t.Fatalf("check %d denied under the halved capacity of 2", i+1)
+		}
+	}
+	if tl.check(t, req).Allowed {
+		t.Error("third check allowed past the halved capacity of 2")
+	}
+
+	// billing's override of 1 keeps its full capacity
+	req.Namespace = "billing"
+	if resp := tl.check(t, req); !resp.Allowed || resp.Remaining != 3 {
+		t.Errorf("billing check = %+v, want allowed with 3 of 4 remaining", resp)
+	}
+}
+
+func TestAdaptiveSetFactorValidates(t *testing.T) {
+	tl := newAdaptiveTestLimiter(t)
+	a := tl.Adaptive()
+
+	for _, factor := range []float64{-0.1, 1.5, math.NaN()} {
+		f := factor
+		if err := a.SetFactor(context.Background(), "", &f); !errors.Is(err, ErrInvalidFactor) {
+			t.Errorf("SetFactor(%v) = %v, want ErrInvalidFactor", factor, err)
+		}
+	}
+	if err := a.SetFactor(context.Background(), "", nil); !errors.Is(err, ErrInvalidFactor) {
+		t.Errorf("removing the global factor = %v, want ErrInvalidFactor", err)
+	}
+
+	setFactor(t, a, "", 0.5)
+	setFactor(t, a, "billing", 0.2)
+	if err := a.SetFactor(context.Background(), "billing", nil); err != nil {
+		t.Fatalf("removing billing's override: %v", err)
+	}
+	if got := a.Factor("billing"); got != 0.5 {
+		t.Errorf("billing factor after removing its override = %v, want the global 0.5", got)
+	}
+	if got, err := tl.redis.HKeys("rl:adaptive_factors"); err != nil || len(got) != 1 || got[0] != "" {
+		t.Errorf("stored factors = %v, %v, want only the global one", got, err)
+	}
+}
+
+func TestAdaptiveRefreshLoadsOtherInstancesFactors(t *testing.T) {
+	tl := newAdaptiveTestLimiter(t)
+	other := NewAdaptiveLimiter(tl.Limiter.redis, "rl")
+
+	setFactor(t, other, "", 0.25)
+	setFactor(t, other, "billing", 0.75)
+	if got := tl.Adaptive().Factor(""); got != 1 {
+		t.Fatalf("factor before a refresh = %v, want 1", got)
+	}
+
+	// Values written from outside that aren't factors are skipped, not fatal
+	tl.redis.HSet("rl:adaptive_factors", "search", "fast", "reports", "3")
+	if err := tl.Adaptive().Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	want := map[string]float64{"": 0.25, "billing": 0.75}
+	got := tl.Adaptive().Factors()
+	if len(got) != len(want) || got[""] != want[""] || got["billing"] != want["billing"] {
+		t.Errorf("Factors = %v, want %v", got, want)
+	}
+	if f := tl.Adaptive().Factor("search"); f != 0.25 {
+		t.Errorf("search factor = %v, want the global 0.25", f)
+	}
+}
+
+func TestAdaptiveApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		factor   float64
+		capacity int64
+		cost     int64
+		want     int64
+	}{
+		{"full", 1, 10, 1, 10},
+		{"halved", 0.5, 10, 1, 5},
+		{"rounded down", 0.55, 10, 1, 5},
+		{"zero leaves one request", 0, 10, 0, 1},
+		{"zero leaves room for the cost", 0, 10, 3, 3},
+		{"cost over capacity keeps capacity", 0, 10, 20, 10},
+		{"invalid capacity untouched", 0.5, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdaptiveLimiter(nil, "")
+			a.factors.Store(&map[string]float64{"": tt.factor})
+			got := a.apply(CheckRequest{Capacity: tt.capacity, Cost: tt.cost})
+			if got.Capacity != tt.want {
+				t.Errorf("capacity = %d, want %d", got.Capacity, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveFactorsKeyIsReserved(t *testing.T) {
+	tl := newAdaptiveTestLimiter(t)
+	setFactor(t, tl.Adaptive(), "", 0.5)
+
+	_, err := tl.Check(context.Background(), tokenBucketRequest("adaptive_factors", 4, 1))
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("check on the factors key = %v, want ErrInvalidKey", err)
+	}
+	if got := tl.redis.Type("rl:adaptive_factors"); got != "hash" {
+		t.Errorf("factors key type = %q, want hash", got)
+	}
+
+	// Without adaptive limits the name is an ordinary key
+	plain := newTestLimiter(t, func(cfg *config.Config) { cfg.RedisKeyPrefix = "rl" })
+	if !plain.check(t, tokenBucketRequest("adaptive_factors", 4, 1)).Allowed {
+		t.Error("check on adaptive_factors denied with adaptive limits off")
+	}
+}
@@ -0,0 +1,158 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// BatchResult is the outcome of one entry in CheckBatch - either Response or Err is set
+type BatchResult struct {
+	Response *CheckResponse
+	Err      error
+}
+
+// finishFunc converts a script reply into a CheckResponse for one algorithm
+type finishFunc func(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error)
+
+// CheckBatch runs several checks with a single pipelined Redis round trip
+// Results are returned in request order; one entry failing doesn't fail the rest
+func (l *Limiter) CheckBatch(ctx context.Context, reqs []CheckRequest) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+
+	// index maps each pipelined call back to the request it came from
+	// Calls keep request order so repeated keys see each other's effects
+	calls := make([]redisclient.ScriptCall, 0, len(reqs))
+	finishers := make([]finishFunc, 0, len(reqs))
+	prepared := make([]CheckRequest, 0, len(reqs))
+	contexts := make([]context.Context, 0, len(reqs))
+	index := make([]int, 0, len(reqs))
+
+	for i, req := range reqs {
+		key, err := l.callerKey(req.Key)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		req.Key = key
+		req = l.normalize(req)
+
+		entryCtx := ctx
+		if req.NowMillis > 0 {
+			entryCtx = utils.WithNowMillis(ctx, req.NowMillis)
+		}
+
+		// As in Check, a key that was just blocked is refused without going to Redis
+		if l.denyCache != nil {
+			if cached := l.denyCache.Lookup(entryCtx, req); cached != nil {
+				l.logBlocked(req, cached)
+				l.watch(req, cached)
+				results[i].Response = cached
+				continue
+			}
+		}
+
+		call, finish, err := l.prepare(entryCtx, req)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		// Admission touches a second key, so it runs ahead of the pipeline
+		if resp, err := l.admitSource(entryCtx, req); resp != nil || err != nil {
+			results[i].Response, results[i].Err = resp, err
+			continue
+		}
+
+		calls = append(calls, call)
+		finishers = append(finishers, finish)
+		prepared = append(prepared, req)
+		contexts = append(contexts, entryCtx)
+		index = append(index, i)
+	}
+
+	if len(calls) == 0 {
+		l.decidedBatch(ctx, reqs, results)
+		return results
+	}
+
+	redisStart := time.Now()
+	replies := l.redis.EvalLuaBatch(ctx, calls)
+	redisLatency := float64(time.Since(redisStart).Microseconds()) / 1000.0
+	l.metrics.RedisLatency.Observe(redisLatency)
+
+	for j, reply := range replies {
+		i := index[j]
+		results[i].Response, results[i].Err = finishers[j](contexts[j], reply.Value, reply.Err, prepared[j])
+		if results[i].Err == nil {
+			if l.denyCache != nil {
+				l.denyCache.Record(contexts[j], prepared[j], results[i].Response)
+			}
+			l.logBlocked(prepared[j], results[i].Response)
+			l.watch(prepared[j], results[i].Response)
+		}
+	}
+
+	l.decidedBatch(ctx, reqs, results)
+	return results
+}
+
+// decidedBatch runs OnDecision for each entry of a batch, in request order
+func (l *Limiter) decidedBatch(ctx context.Context, reqs []CheckRequest, results []BatchResult) {
+	if l.OnDecision == nil {
+		return
+	}
+	for i, result := range results {
+		l.decided(ctx, reqs[i], result.Response, result.Err)
+	}
+}
+
+// prepare builds the script call for a request along with how to interpret its reply
+func (l *Limiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, finishFunc, error) {
+	call, finish, err := l.prepareAlgorithm(ctx, req)
+	if err != nil {
+		return call, finish, err
+	}
+	if req.PenaltyBase > 0 {
+		if call, err = l.withPenalty(ctx, call, req); err != nil {
+			return call, finish, err
+		}
+	}
+	if l.decisionCountsTTL > 0 {
+		call = l.withDecisionCounts(call, req)
+	}
+	return call, finish, nil
+}
+
+// prepareAlgorithm builds the script call for the request's algorithm alone
+func (l *Limiter) prepareAlgorithm(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, finishFunc, error) {
+	switch req.Algorithm {
+	case AlgorithmTokenBucket:
+		call, err := l.tokenBucket.prepare(ctx, req)
+		return call, l.tokenBucket.finish, err
+
+	case AlgorithmSlidingWindow:
+		call, err := l.slidingWindow.prepare(ctx, req)
+		return call, l.slidingWindow.finish, err
+
+	case AlgorithmLeakyBucket:
+		call, err := l.leakyBucket.prepare(ctx, req)
+		return call, l.leakyBucket.finish, err
+
+	case AlgorithmGCRA:
+		call, err := l.gcra.prepare(ctx, req)
+		return call, l.gcra.finish, err
+
+	case AlgorithmSlidingWindowCounter:
+		call, err := l.slidingWindowCounter.prepare(ctx, req)
+		return call, l.slidingWindowCounter.finish, err
+
+	case AlgorithmQuota:
+		call, err := l.quota.prepare(ctx, req)
+		return call, l.quota.finish, err
+	}
+
+	return redisclient.ScriptCall{}, nil, unsupportedAlgorithm(req.Algorithm)
+}
@@ -0,0 +1,58 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// benchmarkBatch is 20 checks cycling through every algorithm, on distinct keys
+// with room for far more requests than a benchmark makes
+func benchmarkBatch() []CheckRequest {
+	reqs := make([]CheckRequest, 20)
+	for i := range reqs {
+		algorithm := multiAlgorithms[i%len(multiAlgorithms)]
+		reqs[i] = algorithmRequest(algorithm, fmt.Sprintf("bench:%d", i), 1<<40)
+	}
+	return reqs
+}
+
+func newBenchmarkLimiter(b *testing.B) *testLimiter {
+	b.Helper()
+	tl := newTestLimiter(b, nil)
+	tl.redis.SetTime(testStart)
+	return tl
+}
+
+// BenchmarkCheckBatchSequential makes the 20 checks one Check (one round trip) at a time
+func BenchmarkCheckBatchSequential(b *testing.B) {
+	tl := newBenchmarkLimiter(b)
+	reqs := benchmarkBatch()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, req := range reqs {
+			if _, err := tl.Check(ctx, req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkCheckBatchPipelined makes the same 20 checks with one CheckBatch, which
+// sends them all in a single pipelined round trip
+func BenchmarkCheckBatchPipelined(b *testing.B) {
+	tl := newBenchmarkLimiter(b)
+	reqs := benchmarkBatch()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range tl.CheckBatch(ctx, reqs) {
+			if r.Err != nil {
+				b.Fatal(r.Err)
+			}
+		}
+	}
+}
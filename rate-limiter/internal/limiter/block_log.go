@@ -0,0 +1,67 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"github.com/piyushpatra/rate-limiter/internal/tracing"
+)
+
+// BlockLogger writes a sampled log line for blocked checks, for abuse detection without
+// per-key metrics. Lines are rationed by a token bucket, so a flood of blocks (usually
+// from one key) costs at most burst lines and then rate lines a second
+type BlockLogger struct {
+	mu         sync.Mutex
+	rate       float64 // lines per second
+	burst      float64
+	tokens     float64
+	last       time.Time
+	suppressed int64 // blocks not logged since the last line
+}
+
+func NewBlockLogger(rate float64, burst int) *BlockLogger {
+	return &BlockLogger{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Log records a block of req, which has already been through normalize
+// The key is hashed the same way as the trace attribute so the two can be matched up
+func (b *BlockLogger) Log(req CheckRequest) {
+	suppressed, ok := b.take()
+	if !ok {
+		return
+	}
+	logging.LogBlocked(logging.Blocked{
+		KeyHash:    tracing.HashKey(req.Key),
+		Algorithm:  req.Algorithm,
+		Tier:       req.Tier,
+		Suppressed: suppressed,
+	})
+}
+
+// take spends a token, returning how many blocks were skipped since the last line
+func (b *BlockLogger) take() (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		b.suppressed++
+		return 0, false
+	}
+	b.tokens--
+	suppressed := b.suppressed
+	b.suppressed = 0
+	return suppressed, true
+}
+
+// logBlocked samples an enforced block - dry runs and FAIL_MODE denies say nothing about the key
+func (l *Limiter) logBlocked(req CheckRequest, resp *CheckResponse) {
+	if l.blockLog == nil || resp.Allowed || resp.Degraded || req.DryRun {
+		return
+	}
+	l.blockLog.Log(req)
+}
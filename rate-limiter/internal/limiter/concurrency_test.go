@@ -0,0 +1,102 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// acquire takes a lease and fails the test on an error
+func (tl *testLimiter) acquire(t *testing.T, key string, limit int64, ttl time.Duration) *Lease {
+	t.Helper()
+	lease, err := tl.Acquire(context.Background(), "", key, limit, ttl)
+	if err != nil {
+		t.Fatalf("Acquire(%q): %v", key, err)
+	}
+	return lease
+}
+
+func TestAcquireRejectsAtLimitUntilRelease(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		lease := tl.acquire(t, "exports", 3, time.Minute)
+		if !lease.Acquired {
+			t.Fatalf("acquire %d rejected under the limit", i+1)
+		}
+		if want := int64(2 - i); lease.Remaining != want {
+			t.Errorf("acquire %d: remaining = %d, want %d", i+1, lease.Remaining, want)
+		}
+		tokens = append(tokens, lease.Token)
+	}
+
+	tl.advance(10 * time.Second)
+	full := tl.acquire(t, "exports", 3, time.Minute)
+	if full.Acquired || full.Token != "" {
+		t.Fatalf("acquire past the limit = %+v, want rejected without a token", full)
+	}
+	if full.RetryAfter != 50*time.Second {
+		t.Errorf("retry after = %v, want 50s until the oldest lease expires", full.RetryAfter)
+	}
+
+	released, err := tl.Release(context.Background(), "", "exports", tokens[1])
+	if err != nil || !released {
+		t.Fatalf("Release = %v, %v, want true", released, err)
+	}
+	if !tl.acquire(t, "exports", 3, time.Minute).Acquired {
+		t.Error("acquire after a release was rejected")
+	}
+
+	// The token was already given back, so a second release frees nothing
+	released, err = tl.Release(context.Background(), "", "exports", tokens[1])
+	if err != nil || released {
+		t.Errorf("second Release = %v, %v, want false", released, err)
+	}
+}
+
+func TestAcquireFreesExpiredLeases(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+
+	tl.acquire(t, "exports", 1, 30*time.Second)
+	if tl.acquire(t, "exports", 1, 30*time.Second).Acquired {
+		t.Fatal("second lease acquired with the first still held")
+	}
+
+	// The holder never releases; its slot frees once the lease runs out
+	tl.advance(30*time.Second + time.Millisecond)
+	if !tl.acquire(t, "exports", 1, 30*time.Second).Acquired {
+		t.Error("acquire after the lease expired was rejected")
+	}
+}
+
+func TestLeasesAndChecksOnTheSameKeyStayApart(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) { cfg.RedisKeyPrefix = "rl" })
+
+	for i := 0; i < 2; i++ {
+		tl.acquire(t, "user:1", 5, time.Minute)
+	}
+
+	// Leases must not count as sliding window requests
+	req := slidingWindowRequest("user:1", 2, time.Minute)
+	for i := 0; i < 2; i++ {
+		if !tl.check(t, req).Allowed {
+			t.Fatalf("check %d denied - leases counted against the window", i+1)
+		}
+	}
+	if tl.check(t, req).Allowed {
+		t.Fatal("third check allowed past a capacity of 2")
+	}
+
+	// Nor may the window's expiry sweep drop leases that are still held
+	lease := tl.acquire(t, "user:1", 5, time.Minute)
+	if !lease.Acquired || lease.Remaining != 2 {
+		t.Errorf("acquire = %+v, want acquired with 2 remaining", lease)
+	}
+
+	if got := tl.redis.Type("{rl:user:1}:leases"); got != "zset" {
+		t.Errorf("lease key type = %q, want zset under {rl:user:1}:leases", got)
+	}
+}
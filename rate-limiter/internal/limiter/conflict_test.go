@@ -0,0 +1,32 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+func TestCheckReportsKeyTypeConflict(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+
+	// A token bucket is a hash, a sliding window a sorted set
+	tl.check(t, tokenBucketRequest("user:1", 5, 1))
+	_, err := tl.Check(context.Background(), slidingWindowRequest("user:1", 5, time.Minute))
+	if !errors.Is(err, redisclient.ErrWrongType) {
+		t.Fatalf("sliding window on a token bucket key: err = %v, want ErrWrongType", err)
+	}
+
+	tl.check(t, slidingWindowRequest("user:2", 5, time.Minute))
+	_, err = tl.Check(context.Background(), tokenBucketRequest("user:2", 5, 1))
+	if !errors.Is(err, redisclient.ErrWrongType) {
+		t.Fatalf("token bucket on a sliding window key: err = %v, want ErrWrongType", err)
+	}
+
+	// The conflict is refused, not failed open, and the original state is untouched
+	if resp := tl.check(t, tokenBucketRequest("user:1", 5, 1)); !resp.Allowed || resp.Remaining != 3 {
+		t.Errorf("token bucket after the conflict = %+v, want allowed with 3 remaining", resp)
+	}
+}
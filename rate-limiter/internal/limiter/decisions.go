@@ -0,0 +1,58 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// DecisionCounts is how many checks a key has allowed and denied (DECISION_COUNTS_TTL)
+// Counted in Redis, so they cover every instance. A quota's counts are per period,
+// like its counter
+type DecisionCounts struct {
+	Allowed int64
+	Denied  int64
+}
+
+// withDecisionCounts wraps a check's script call so the decision is counted on the key
+// Like withPenalty, the check becomes a function of the appended script. It gets its own
+// keys and arguments as parameters, so a penalty-wrapped check still finds its trailing ones
+func (l *Limiter) withDecisionCounts(call redisclient.ScriptCall, req CheckRequest) redisclient.ScriptCall {
+	script, ok := l.scripts.counted.Load(call.Script)
+	if !ok {
+		script, _ = l.scripts.counted.LoadOrStore(call.Script,
+			"local function decision_check(KEYS, ARGV)\n"+call.Script+"\nend\n"+l.scripts.decisions)
+	}
+
+	keys := make([]string, 0, len(call.Keys)+1)
+	keys = append(keys, call.Keys...)
+	keys = append(keys, companionKey(call.Keys[0], "decisions"))
+
+	args := make([]interface{}, 0, len(call.Args)+2)
+	args = append(args, call.Args...)
+	args = append(args, l.decisionCountsTTL.Milliseconds(), dryRunArg(req.DryRun))
+
+	return redisclient.ScriptCall{Script: script.(string), Keys: keys, Args: args}
+}
+
+// decisionCounts reads the counts kept for key (the key the check script ran against)
+// nil when DECISION_COUNTS_TTL is off
+func (l *Limiter) decisionCounts(ctx context.Context, key string) (*DecisionCounts, error) {
+	if l.decisionCountsTTL <= 0 {
+		return nil, nil
+	}
+
+	// Unreadable the same way a failed peek script is, so callers handle both alike
+	fields, err := l.redis.HGetAll(ctx, companionKey(key, "decisions"))
+	if err != nil {
+		return nil, fmt.Errorf("reading decision counts failed: %w", &redisclient.FailOpenError{Cause: err})
+	}
+
+	// Missing fields (a key never checked, or counts that expired) are zero
+	var counts DecisionCounts
+	counts.Allowed, _ = strconv.ParseInt(fields["allowed"], 10, 64)
+	counts.Denied, _ = strconv.ParseInt(fields["denied"], 10, 64)
+	return &counts, nil
+}
@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func withDecisionCounts(cfg *config.Config) {
+	cfg.DecisionCountsTTL = time.Hour
+}
+
+// peekDecisions peeks req and returns its decision counts
+func peekDecisions(t *testing.T, tl *testLimiter, req CheckRequest) DecisionCounts {
+	t.Helper()
+	resp, err := tl.Peek(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if resp.Decisions == nil {
+		t.Fatal("Peek returned no decision counts")
+	}
+	return *resp.Decisions
+}
+
+func TestDecisionCountsIncrementOnAllowAndDeny(t *testing.T) {
+	tl := newTestLimiter(t, withDecisionCounts)
+	req := tokenBucketRequest("user:1", 2, 1)
+
+	if got := peekDecisions(t, tl, req); got != (DecisionCounts{}) {
+		t.Fatalf("counts before any check = %+v, want zero", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		tl.check(t, req)
+	}
+	if got, want := peekDecisions(t, tl, req), (DecisionCounts{Allowed: 2, Denied: 1}); got != want {
+		t.Errorf("counts = %+v, want %+v", got, want)
+	}
+
+	// Dry runs decide nothing, so they aren't counted
+	req.DryRun = true
+	tl.check(t, req)
+	if got := peekDecisions(t, tl, req); got.Allowed+got.Denied != 3 {
+		t.Errorf("counts after a dry run = %+v, want still 3 in total", got)
+	}
+}
+
+func TestDecisionCountsWrapPenaltiesAndCompanionKeys(t *testing.T) {
+	tl := newTestLimiter(t, withDecisionCounts)
+
+	// The penalty reads its arguments from the end, after which decisions.lua appends its own
+	penalized := tokenBucketRequest("user:1", 1, 1)
+	penalized.PenaltyBase, penalized.PenaltyMax = time.Second, time.Minute
+	tl.check(t, penalized)
+	if resp := tl.check(t, penalized); resp.Allowed || resp.PenaltyUntil.IsZero() {
+		t.Fatalf("second penalized check = %+v, want blocked with a penalty", resp)
+	}
+	if got, want := peekDecisions(t, tl, penalized), (DecisionCounts{Allowed: 1, Denied: 1}); got != want {
+		t.Errorf("penalized counts = %+v, want %+v", got, want)
+	}
+
+	// The sliding window's counter is KEYS[2], ahead of the counts' key
+	window := slidingWindowRequest("user:2", 1, time.Minute)
+	tl.check(t, window)
+	tl.check(t, window)
+	if got, want := peekDecisions(t, tl, window), (DecisionCounts{Allowed: 1, Denied: 1}); got != want {
+		t.Errorf("sliding window counts = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecisionCountsCoverBatches(t *testing.T) {
+	tl := newTestLimiter(t, withDecisionCounts)
+	req := tokenBucketRequest("user:1", 1, 1)
+
+	for _, result := range tl.CheckBatch(context.Background(), []CheckRequest{req, req}) {
+		if result.Err != nil {
+			t.Fatalf("CheckBatch: %v", result.Err)
+		}
+	}
+	if got, want := peekDecisions(t, tl, req), (DecisionCounts{Allowed: 1, Denied: 1}); got != want {
+		t.Errorf("counts = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecisionCountsOffByDefault(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := tokenBucketRequest("user:1", 1, 1)
+
+	tl.check(t, req)
+	resp, err := tl.Peek(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if resp.Decisions != nil {
+		t.Errorf("Decisions = %+v, want nil with DECISION_COUNTS_TTL off", resp.Decisions)
+	}
+	if tl.redis.Exists("{user:1}:decisions") {
+		t.Error("counts written with DECISION_COUNTS_TTL off")
+	}
+}
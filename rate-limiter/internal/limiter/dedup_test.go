@@ -0,0 +1,83 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestRequestIDReplayConsumesOnce(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.DedupTTL = 5 * time.Second
+	})
+	req := tokenBucketRequest("user:1", 5, 1)
+	req.RequestID = "req-1"
+
+	first := tl.check(t, req)
+	if !first.Allowed || first.Remaining != 4 {
+		t.Fatalf("first check = %+v, want allowed with 4 remaining", first)
+	}
+	replay := tl.check(t, req)
+	replay.Timing = first.Timing // measured per call
+	if *replay != *first {
+		t.Errorf("replay = %+v, want the first decision %+v", replay, first)
+	}
+	if tokens := tl.redis.HGet("user:1", "tokens"); tokens != "4" {
+		t.Errorf("tokens after the replay = %s, want 4 - only one consumed", tokens)
+	}
+
+	// Another id is another request
+	other := req
+	other.RequestID = "req-2"
+	if resp := tl.check(t, other); resp.Remaining != 3 {
+		t.Errorf("check with a new id = %+v, want 3 remaining", resp)
+	}
+
+	// The id is stored beside the bucket and forgotten after DEDUP_TTL
+	dedupKey := "{user:1}:req:req-1"
+	if ttl := tl.redis.TTL(dedupKey); ttl <= 0 || ttl > 5*time.Second {
+		t.Errorf("dedup key TTL = %v, want up to DEDUP_TTL", ttl)
+	}
+	tl.redis.FastForward(5 * time.Second)
+	if tl.redis.Exists(dedupKey) {
+		t.Fatal("dedup key outlived DEDUP_TTL")
+	}
+	if resp := tl.check(t, req); resp.Remaining != 2 {
+		t.Errorf("check with the id after DEDUP_TTL = %+v, want it counted again (2 remaining)", resp)
+	}
+}
+
+func TestRequestIDReplaysADenial(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := tokenBucketRequest("user:1", 1, 1)
+	tl.check(t, req)
+
+	req.RequestID = "req-1"
+	denied := tl.check(t, req)
+	if denied.Allowed {
+		t.Fatal("check on an empty bucket allowed")
+	}
+
+	// The bucket refills, but a retry of the denied request still gets its denial
+	tl.advance(time.Second)
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Errorf("replayed denial = %+v, want the original deny", resp)
+	}
+}
+
+func TestRequestIDIgnoredWithDedupOff(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.DedupTTL = 0
+	})
+	req := tokenBucketRequest("user:1", 5, 1)
+	req.RequestID = "req-1"
+
+	tl.check(t, req)
+	if resp := tl.check(t, req); resp.Remaining != 3 {
+		t.Errorf("second check = %+v, want both counted with DEDUP_TTL=0", resp)
+	}
+	if len(tl.redis.Keys()) != 1 {
+		t.Errorf("keys = %v, want no dedup key", tl.redis.Keys())
+	}
+}
@@ -0,0 +1,145 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// denyCacheSweepMin is the smallest shard size that triggers a sweep of expired entries
+const denyCacheSweepMin = 1024
+
+// DenyCache remembers keys that were just blocked so repeats are refused in memory
+// Under a hot-key flood almost every request for an exhausted key is a deny anyway;
+// answering them here for a few milliseconds keeps that load off Redis. The price is
+// that a key may stay blocked up to ttl after it could have let a request through
+type DenyCache struct {
+	ttlMs   int64
+	shards  [localShards]denyShard
+	metrics *metrics.Metrics
+	clock   utils.Clock
+}
+
+type denyShard struct {
+	mu      sync.Mutex
+	entries map[string]denyEntry
+
+	// nextSweep is the entry count at which expired entries are next dropped
+	nextSweep int
+}
+
+type denyEntry struct {
+	params  denyParams
+	untilMs int64 // the entry answers checks before this time
+	retryMs int64 // when Redis said the key could next be allowed
+	resetAt time.Time
+
+	penaltyUntil time.Time
+}
+
+// denyParams are the parts of a request that decide whether it fits the limit
+// A check with different parameters is a different question, so it goes to Redis
+type denyParams struct {
+	algorithm    string
+	capacity     int64
+	refillRate   float64
+	windowMillis int64
+	leakRate     float64
+	period       string
+	cost         int64
+	penaltyBase  time.Duration
+	penaltyMax   time.Duration
+}
+
+func NewDenyCache(ttl time.Duration, m *metrics.Metrics, clock utils.Clock) *DenyCache {
+	dc := &DenyCache{ttlMs: ttl.Milliseconds(), metrics: m, clock: clock}
+	for i := range dc.shards {
+		dc.shards[i].entries = make(map[string]denyEntry)
+		dc.shards[i].nextSweep = denyCacheSweepMin
+	}
+	return dc
+}
+
+// cacheable reports whether req's answer may be served from (or stored in) the cache
+// Dry runs never block, request ids must see their own first decision, and a
+// client-supplied clock means "now" isn't ours to compare against
+func (dc *DenyCache) cacheable(req CheckRequest) bool {
+	return !req.DryRun && req.RequestID == "" && req.NowMillis == 0
+}
+
+// Lookup returns a blocked response if req's key was denied with the same parameters
+// within the cache ttl, or nil if the check has to go to Redis
+func (dc *DenyCache) Lookup(ctx context.Context, req CheckRequest) *CheckResponse {
+	if !dc.cacheable(req) {
+		return nil
+	}
+	now := utils.NowMillisCtx(ctx, dc.clock)
+
+	shard := &dc.shards[localShardFor(req.Key)]
+	shard.mu.Lock()
+	e, ok := shard.entries[req.Key]
+	shard.mu.Unlock()
+
+	if !ok || now >= e.untilMs || e.params != paramsOf(req) {
+		return nil
+	}
+
+	dc.metrics.IncCounter(dc.metrics.DenyCacheHits, req.Algorithm)
+	dc.metrics.IncCounter(dc.metrics.RequestsBlocked, req.Algorithm, req.Tier, dryRunLabel(false))
+	return &CheckResponse{Allowed: false, RetryAfter: time.Duration(e.retryMs-now) * time.Millisecond, ResetAt: e.resetAt, PenaltyUntil: e.penaltyUntil}
+}
+
+// Record caches resp if it blocked req
+// Entries never outlive the retry-after, so a key isn't held shut once Redis would allow it
+func (dc *DenyCache) Record(ctx context.Context, req CheckRequest, resp *CheckResponse) {
+	// Degraded denies come from FAIL_MODE, not the key's state
+	if resp.Allowed || resp.Degraded || resp.RetryAfter <= 0 || !dc.cacheable(req) {
+		return
+	}
+	now := utils.NowMillisCtx(ctx, dc.clock)
+	retryMs := now + resp.RetryAfter.Milliseconds()
+
+	shard := &dc.shards[localShardFor(req.Key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.sweep(now)
+	shard.entries[req.Key] = denyEntry{
+		params:  paramsOf(req),
+		untilMs: min(now+dc.ttlMs, retryMs),
+		retryMs: retryMs,
+		resetAt: resp.ResetAt,
+
+		penaltyUntil: resp.PenaltyUntil,
+	}
+}
+
+// sweep drops expired entries once the shard has doubled since the last sweep
+func (s *denyShard) sweep(now int64) {
+	if len(s.entries) < s.nextSweep {
+		return
+	}
+	for key, e := range s.entries {
+		if now >= e.untilMs {
+			delete(s.entries, key)
+		}
+	}
+	s.nextSweep = max(denyCacheSweepMin, 2*len(s.entries))
+}
+
+func paramsOf(req CheckRequest) denyParams {
+	return denyParams{
+		algorithm:    req.Algorithm,
+		capacity:     req.Capacity,
+		refillRate:   req.RefillRate,
+		windowMillis: req.WindowMillis,
+		leakRate:     req.LeakRate,
+		period:       req.Period,
+		cost:         req.Cost,
+		penaltyBase:  req.PenaltyBase,
+		penaltyMax:   req.PenaltyMax,
+	}
+}
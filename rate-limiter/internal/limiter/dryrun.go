@@ -0,0 +1,26 @@
+package limiter
+
+// Dry runs evaluate a limit without consuming from it, so a new limit can be
+// observed in the metrics (dry_run="true") before it's enforced
+
+// dryRunArg is the scripts' ARGV[5] - "1" skips every write
+func dryRunArg(dryRun bool) string {
+	if dryRun {
+		return "1"
+	}
+	return "0"
+}
+
+// dryRunLabel is the dry_run metrics label value
+func dryRunLabel(dryRun bool) string {
+	if dryRun {
+		return "true"
+	}
+	return "false"
+}
+
+// dryRunResponse reports the would-be decision's remaining but never blocks
+// The would-be wait becomes SuggestedPoll, for callers polling until there's capacity
+func dryRunResponse(resp *CheckResponse) *CheckResponse {
+	return &CheckResponse{Allowed: true, Remaining: resp.Remaining, RemainingFloat: resp.RemainingFloat, ResetAt: resp.ResetAt, SuggestedPoll: resp.RetryAfter}
+}
@@ -0,0 +1,50 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDryRunSuggestsPollUntilNextToken(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := tokenBucketRequest("user:1", 1, 2) // a token every 500ms
+
+	if resp := tl.check(t, req); !resp.Allowed {
+		t.Fatal("first check blocked, want allowed")
+	}
+
+	req.DryRun = true
+	resp := tl.check(t, req)
+	if !resp.Allowed {
+		t.Error("dry run blocked, want allowed")
+	}
+	if resp.SuggestedPoll != 500*time.Millisecond {
+		t.Errorf("SuggestedPoll = %v, want 500ms", resp.SuggestedPoll)
+	}
+
+	// Polling again part way through only waits out the rest
+	tl.advance(200 * time.Millisecond)
+	if resp := tl.check(t, req); resp.SuggestedPoll != 300*time.Millisecond {
+		t.Errorf("SuggestedPoll after 200ms = %v, want 300ms", resp.SuggestedPoll)
+	}
+
+	// A dry run that would be allowed needn't wait at all
+	tl.advance(300 * time.Millisecond)
+	if resp := tl.check(t, req); resp.SuggestedPoll != 0 {
+		t.Errorf("SuggestedPoll once refilled = %v, want 0", resp.SuggestedPoll)
+	}
+}
+
+func TestDryRunSuggestedPollFollowsSlidingWindow(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowRequest("user:1", 1, 10*time.Second)
+
+	tl.check(t, req)
+	tl.advance(4 * time.Second)
+
+	req.DryRun = true
+	// The only entry leaves the window 6s from now
+	if resp := tl.check(t, req); resp.SuggestedPoll != 6*time.Second {
+		t.Errorf("SuggestedPoll = %v, want 6s", resp.SuggestedPoll)
+	}
+}
@@ -0,0 +1,76 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// ErrRedisUnavailable is returned in place of a decision under the error fail mode
+var ErrRedisUnavailable = errors.New("redis unavailable")
+
+// FailurePolicy decides checks that couldn't reach Redis (FailOpenError)
+// Shared by all algorithms so a FAIL_MODE applies the same way everywhere
+type FailurePolicy struct {
+	mode    string
+	local   *LocalLimiter
+	metrics *metrics.Metrics
+}
+
+// NewFailurePolicy builds the policy for FAIL_MODE; fraction scales limits in local mode
+// The local limiter always exists since requests can ask for local mode themselves
+func NewFailurePolicy(mode string, fraction float64, m *metrics.Metrics, clock utils.Clock) *FailurePolicy {
+	return &FailurePolicy{mode: mode, local: NewLocalLimiter(fraction, clock), metrics: m}
+}
+
+// Decide returns the decision to use in place of the one Redis couldn't make (cause)
+// A request's own FailMode overrides the configured default. The response is always
+// marked Degraded, and allows are counted in requests_fail_open_total. Under the error
+// mode there is no decision - ErrRedisUnavailable (wrapping cause) is returned instead
+func (p *FailurePolicy) Decide(ctx context.Context, req CheckRequest, cause error) (*CheckResponse, error) {
+	if p.modeFor(req) == config.FailModeError && !req.DryRun {
+		return nil, fmt.Errorf("%w: %w", ErrRedisUnavailable, cause)
+	}
+
+	resp := p.decide(ctx, req)
+	resp.Degraded = true
+	if resp.Allowed && !req.DryRun {
+		p.metrics.IncCounter(p.metrics.RequestsFailOpen, req.Algorithm)
+	}
+	return resp, nil
+}
+
+// Mode is the configured FAIL_MODE, for decisions that can't go through Decide
+func (p *FailurePolicy) Mode() string {
+	return p.mode
+}
+
+// modeFor is the fail mode for req - its own FailMode, else the configured default
+func (p *FailurePolicy) modeFor(req CheckRequest) string {
+	if req.FailMode != "" {
+		return req.FailMode
+	}
+	return p.mode
+}
+
+func (p *FailurePolicy) decide(ctx context.Context, req CheckRequest) *CheckResponse {
+	// Dry runs never block, and mustn't drain the local fallback buckets either
+	if req.DryRun {
+		return &CheckResponse{Allowed: true}
+	}
+
+	switch p.modeFor(req) {
+	case config.FailModeClosed:
+		return &CheckResponse{Allowed: false}
+	case config.FailModeLocal:
+		return p.local.Check(ctx, req)
+	}
+
+	// Fail open: allow request when Redis is unavailable
+	// This prevents rate limiter from becoming a single point of failure
+	return &CheckResponse{Allowed: true}
+}
@@ -0,0 +1,116 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+func TestFailModeDecidesWhenRedisIsDown(t *testing.T) {
+	requests := map[string]CheckRequest{
+		"token_bucket":   tokenBucketRequest("user:1", 5, 1),
+		"sliding_window": slidingWindowRequest("user:2", 5, time.Minute),
+	}
+	tests := []struct {
+		name        string
+		defaultMode string
+		override    string
+		wantAllowed bool
+	}{
+		{name: "default open", defaultMode: config.FailModeOpen, wantAllowed: true},
+		{name: "default closed", defaultMode: config.FailModeClosed, wantAllowed: false},
+		{name: "request closes an open default", defaultMode: config.FailModeOpen, override: config.FailModeClosed, wantAllowed: false},
+		{name: "request opens a closed default", defaultMode: config.FailModeClosed, override: config.FailModeOpen, wantAllowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := newTestLimiter(t, func(cfg *config.Config) {
+				cfg.FailMode = tt.defaultMode
+				cfg.CircuitBreakerThreshold = 0 // each check should reach the client
+			})
+			tl.redis.Close()
+
+			for algorithm, req := range requests {
+				req.FailMode = tt.override
+				resp := tl.check(t, req)
+				if resp.Allowed != tt.wantAllowed || !resp.Degraded {
+					t.Errorf("%s: response = %+v, want allowed=%v and degraded", algorithm, resp, tt.wantAllowed)
+				}
+			}
+		})
+	}
+}
+
+func TestFailModeIgnoredForRequestErrors(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.FailMode = config.FailModeClosed
+	})
+
+	// A key of the wrong type is a bad request, not an outage - it mustn't become a deny
+	tl.redis.Set("user:1", "not a bucket")
+	resp, err := tl.Check(context.Background(), tokenBucketRequest("user:1", 5, 1))
+	if !errors.Is(err, redisclient.ErrWrongType) {
+		t.Errorf("Check = %+v, %v, want ErrWrongType rather than a fail-closed decision", resp, err)
+	}
+}
+
+func TestErrorFailModeSurfacesTheFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultMode string
+		override    string
+		wantErr     bool
+	}{
+		{name: "default error", defaultMode: config.FailModeError, wantErr: true},
+		{name: "request asks for error", defaultMode: config.FailModeOpen, override: config.FailModeError, wantErr: true},
+		{name: "request opens an error default", defaultMode: config.FailModeError, override: config.FailModeOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := newTestLimiter(t, func(cfg *config.Config) {
+				cfg.FailMode = tt.defaultMode
+				cfg.CircuitBreakerThreshold = 0
+			})
+			tl.redis.Close()
+
+			for _, req := range []CheckRequest{
+				tokenBucketRequest("user:1", 5, 1),
+				slidingWindowRequest("user:2", 5, time.Minute),
+			} {
+				req.FailMode = tt.override
+				resp, err := tl.Check(context.Background(), req)
+				if !tt.wantErr {
+					if err != nil || !resp.Allowed {
+						t.Errorf("%s: Check = %+v, %v, want a degraded allow", req.Algorithm, resp, err)
+					}
+					continue
+				}
+				var failOpen *redisclient.FailOpenError
+				if !errors.Is(err, ErrRedisUnavailable) || !errors.As(err, &failOpen) {
+					t.Errorf("%s: err = %v, want ErrRedisUnavailable wrapping the Redis failure", req.Algorithm, err)
+				}
+				if resp != nil {
+					t.Errorf("%s: response = %+v alongside the error, want none", req.Algorithm, resp)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorFailModeStillAnswersDryRuns(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.FailMode = config.FailModeError
+		cfg.CircuitBreakerThreshold = 0
+	})
+	tl.redis.Close()
+
+	req := tokenBucketRequest("user:1", 5, 1)
+	req.DryRun = true
+	if resp := tl.check(t, req); !resp.Allowed || !resp.Degraded {
+		t.Errorf("dry run = %+v, want a degraded allow - dry runs never fail", resp)
+	}
+}
@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+)
+
+// fillSampleEvery controls how often check results feed the fill level
+// Sampling 1 in 16 keeps the mutex off the hot path for most requests
+const fillSampleEvery = 16
+
+// fillSmoothing is the EWMA weight given to each new sample
+// Low enough that a single burst doesn't swing the autoscaling signal
+const fillSmoothing = 0.05
+
+// fillTracker keeps a smoothed average of how full buckets are per algorithm
+// Only labeled by algorithm, so cardinality stays fixed regardless of key count
+// One per Limiter, shared by its algorithms
+type fillTracker struct {
+	counter atomic.Uint64
+	metrics *metrics.Metrics
+
+	mu     sync.Mutex
+	levels map[string]float64
+}
+
+func newFillTracker(m *metrics.Metrics) *fillTracker {
+	return &fillTracker{metrics: m, levels: make(map[string]float64)}
+}
+
+// record observes a check result in the remaining_ratio histogram and samples it
+// into the aggregate fill level
+// Fill is the fraction of capacity in use: 0 = idle bucket, 1 = exhausted
+func (f *fillTracker) record(algorithm string, remaining, capacity int64) {
+	if capacity <= 0 {
+		return
+	}
+
+	ratio := float64(remaining) / float64(capacity)
+	if ratio < 0 {
+		ratio = 0
+	} else if ratio > 1 {
+		ratio = 1
+	}
+	f.metrics.ObserveVec(f.metrics.RemainingRatio, ratio, algorithm)
+
+	if f.counter.Add(1)%fillSampleEvery != 0 {
+		return
+	}
+	fill := 1 - ratio
+
+	f.mu.Lock()
+	level, seen := f.levels[algorithm]
+	if seen {
+		level += fillSmoothing * (fill - level)
+	} else {
+		level = fill
+	}
+	f.levels[algorithm] = level
+	f.mu.Unlock()
+
+	f.metrics.FillLevel.WithLabelValues(algorithm).Set(level)
+}
+
+// FillLevel returns the smoothed fill ratio (0..1) for an algorithm
+// Returns 0 until at least one check has been sampled
+func (l *Limiter) FillLevel(algorithm string) float64 {
+	l.fills.mu.Lock()
+	defer l.fills.mu.Unlock()
+	return l.fills.levels[algorithm]
+}
@@ -0,0 +1,42 @@
+package limiter
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFillLevelSamplesFractionInUse(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := tokenBucketRequest("user:1", 100, 1)
+
+	for i := 1; i < fillSampleEvery; i++ {
+		tl.check(t, req)
+	}
+	if got := tl.FillLevel(AlgorithmTokenBucket); got != 0 {
+		t.Fatalf("FillLevel before the first sample = %v, want 0", got)
+	}
+
+	// The first sample is taken as is: 16 of 100 tokens used
+	tl.check(t, req)
+	if got := tl.FillLevel(AlgorithmTokenBucket); math.Abs(got-0.16) > 1e-9 {
+		t.Errorf("FillLevel = %v, want 0.16", got)
+	}
+	if got := testutil.ToFloat64(tl.metrics.FillLevel.WithLabelValues(AlgorithmTokenBucket)); math.Abs(got-0.16) > 1e-9 {
+		t.Errorf("bucket_fill_ratio = %v, want 0.16", got)
+	}
+
+	// Each later one only moves the average by fillSmoothing
+	for i := 0; i < fillSampleEvery; i++ {
+		tl.check(t, req)
+	}
+	want := 0.16 + fillSmoothing*(0.32-0.16)
+	if got := tl.FillLevel(AlgorithmTokenBucket); math.Abs(got-want) > 1e-9 {
+		t.Errorf("FillLevel after a second sample = %v, want %v", got, want)
+	}
+
+	if got := tl.FillLevel(AlgorithmSlidingWindow); got != 0 {
+		t.Errorf("FillLevel of an unused algorithm = %v, want 0", got)
+	}
+}
@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// GCRALimiter implements the generic cell rate algorithm
+// Same limits as token bucket (burst of capacity, refilling at rate/s), but the only
+// state is one timestamp per key - cheaper to store and exact in its smoothing
+type GCRALimiter struct {
+	redis   *redisclient.Client
+	failure *FailurePolicy
+	metrics *metrics.Metrics
+	fills   *fillTracker
+	clock   utils.Clock
+	scripts *scriptSet
+	jitter  ttlJitter
+}
+
+func NewGCRALimiter(redis *redisclient.Client, failure *FailurePolicy, m *metrics.Metrics, fills *fillTracker, scripts *scriptSet, jitter ttlJitter, clock utils.Clock) *GCRALimiter {
+	return &GCRALimiter{redis: redis, failure: failure, metrics: m, fills: fills, scripts: scripts, jitter: jitter, clock: clock}
+}
+
+// Check determines if a request should be allowed under GCRA
+// capacity: burst size (requests that may arrive back to back)
+// rate: requests emitted per second (average rate limit)
+// remaining is the number of requests that would be allowed before the next rejection
+// cost: units this request consumes (req.Cost)
+func (g *GCRALimiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	start := time.Now()
+	defer func() {
+		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
+		g.metrics.ObserveVec(g.metrics.CheckLatency, latencyMs, "gcra")
+	}()
+
+	call, err := g.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute Lua script atomically
+	redisStart := time.Now()
+	result, err := g.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	g.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := g.finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
+
+// prepare validates the parameters and builds the script call for a check
+func (g *GCRALimiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, error) {
+	capacity, rate, cost := req.Capacity, req.RefillRate, req.Cost
+
+	if capacity <= 0 || rate <= 0 {
+		return redisclient.ScriptCall{}, errors.New("capacity and rate must be positive")
+	}
+
+	if cost <= 0 || cost > capacity {
+		return redisclient.ScriptCall{}, errors.New("cost must be positive and no more than capacity")
+	}
+
+	if capacity > MaxSafeInteger || rate > MaxSafeInteger || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return redisclient.ScriptCall{}, errors.New("capacity and rate exceed the safe numeric range")
+	}
+
+	now := scriptNow(ctx, g.clock)
+
+	return redisclient.ScriptCall{
+		Script: *g.scripts.gcra.Load(),
+		Keys:   []string{req.Key},
+		Args:   []interface{}{capacity, rate, now, cost, dryRunArg(req.DryRun), g.jitter.arg()},
+	}, nil
+}
+
+// finish turns the script reply (or error) into a CheckResponse and records metrics
+// req.Tier must already be normalized (see Limiter.tierLabel) to keep cardinality bounded
+func (g *GCRALimiter) finish(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error) {
+	if err != nil {
+		var failOpenErr *redisclient.FailOpenError
+		if errors.As(err, &failOpenErr) {
+			g.metrics.RedisErrors.Inc()
+			// Redis couldn't decide - FAIL_MODE picks open, closed or a local limit
+			return g.failure.Decide(ctx, req, err)
+		}
+		return nil, fmt.Errorf("gcra check failed: %w", err)
+	}
+
+	resp, err := parseCheckResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Allowed {
+		g.metrics.IncCounter(g.metrics.RequestsAllowed, "gcra", req.Tier, dryRunLabel(req.DryRun))
+	} else {
+		g.metrics.IncCounter(g.metrics.RequestsBlocked, "gcra", req.Tier, dryRunLabel(req.DryRun))
+	}
+	if req.DryRun {
+		return dryRunResponse(resp), nil
+	}
+	g.fills.record("gcra", resp.Remaining, req.Capacity)
+
+	return resp, nil
+}
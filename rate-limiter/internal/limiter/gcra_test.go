@@ -0,0 +1,75 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func gcraRequest(key string, capacity int64, rate float64) CheckRequest {
+	return CheckRequest{Key: key, Algorithm: AlgorithmGCRA, Capacity: capacity, RefillRate: rate}
+}
+
+func TestGCRABurstThenSpacing(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := gcraRequest("user:1", 5, 10) // a cell every 100ms
+
+	// The whole burst is available at once, remaining counting the cells left
+	for want := int64(4); want >= 0; want-- {
+		resp := tl.check(t, req)
+		if !resp.Allowed || resp.Remaining != want {
+			t.Fatalf("burst check = %+v, want allowed with remaining %d", resp, want)
+		}
+	}
+	resp := tl.check(t, req)
+	if resp.Allowed || resp.Remaining != 0 {
+		t.Fatalf("check past the burst = %+v, want blocked with remaining 0", resp)
+	}
+	if resp.RetryAfter != 100*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want one emission interval, 100ms", resp.RetryAfter)
+	}
+
+	// Then one cell per interval, no sooner
+	tl.advance(99 * time.Millisecond)
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Error("check 1ms early allowed")
+	}
+	tl.advance(time.Millisecond)
+	if resp := tl.check(t, req); !resp.Allowed || resp.Remaining != 0 {
+		t.Errorf("check on the interval = %+v, want allowed with remaining 0", resp)
+	}
+
+	// The only state is the theoretical arrival time
+	if typ := tl.redis.Type("user:1"); typ != "string" {
+		t.Errorf("key type = %q, want a single string value", typ)
+	}
+}
+
+func TestGCRAMatchesTokenBucketThroughput(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	const capacity, rate = 5, 10.0
+	gcra := gcraRequest("gcra", capacity, rate)
+	bucket := tokenBucketRequest("bucket", capacity, rate)
+
+	// 10s of a request every 10ms - ten times what either allows
+	var allowedGCRA, allowedBucket int
+	for i := 0; i < 1000; i++ {
+		if tl.check(t, gcra).Allowed {
+			allowedGCRA++
+		}
+		if tl.check(t, bucket).Allowed {
+			allowedBucket++
+		}
+		tl.advance(10 * time.Millisecond)
+	}
+
+	// The burst, then rate/s for the rest
+	want := capacity + int(rate*10) - 1
+	for name, got := range map[string]int{"gcra": allowedGCRA, "token_bucket": allowedBucket} {
+		if got < want-1 || got > want+1 {
+			t.Errorf("%s allowed %d, want about %d", name, got, want)
+		}
+	}
+	if diff := allowedGCRA - allowedBucket; diff < -1 || diff > 1 {
+		t.Errorf("gcra allowed %d, token bucket %d - want the same throughput for the same limits", allowedGCRA, allowedBucket)
+	}
+}
@@ -0,0 +1,41 @@
+package limiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// hashedKeyBytes is how much of the SHA-256 digest a hashed key keeps - 128 bits
+// is far past any realistic collision risk at 32 hex characters
+const hashedKeyBytes = 16
+
+// hashKey replaces a caller's key with a digest, so raw ids and emails never reach Redis
+// A Redis Cluster hash tag ({...}) is hashed on its own and kept as a tag, so keys that
+// shared a slot before hashing still do
+func hashKey(key string) string {
+	digest := digestHex(key)
+	if tag, ok := hashTag(key); ok {
+		return "{" + digestHex(tag) + "}" + digest
+	}
+	return digest
+}
+
+func digestHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:hashedKeyBytes])
+}
+
+// hashTag returns the part of key Redis Cluster hashes to pick a slot: the text between
+// the first '{' and the next '}', if that isn't empty
+func hashTag(key string) (string, bool) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return "", false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return "", false
+	}
+	return key[start+1 : start+1+end], true
+}
@@ -0,0 +1,80 @@
+package limiter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestHashKeysKeepsRawKeysOutOfRedis(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.HashKeys = true
+		cfg.RedisKeyPrefix = "rl"
+	})
+	const email = "alice@example.com"
+
+	window := slidingWindowRequest(email, 5, time.Minute)
+	window.Namespace = "billing"
+	tl.check(t, window)
+
+	want := "rl:billing:" + hashKey(email)
+	if !tl.redis.Exists(want) || !tl.redis.Exists("{"+want+"}:counter") {
+		t.Errorf("keys = %v, want %s and its counter", tl.redis.Keys(), want)
+	}
+	for _, key := range tl.redis.Keys() {
+		if strings.Contains(key, email) {
+			t.Errorf("raw key stored in Redis: %s", key)
+		}
+	}
+}
+
+func TestHashedKeysRoundTripThroughPeekAndReset(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.HashKeys = true
+	})
+	ctx := context.Background()
+	req := tokenBucketRequest("customer-42", 5, 1)
+	req.Namespace = "billing"
+
+	tl.check(t, req)
+	tl.check(t, req)
+
+	// Peek hashes the same way, so it sees the two checks
+	peek, err := tl.Peek(ctx, req)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if peek.Remaining != 3 {
+		t.Errorf("peeked remaining = %d, want 3", peek.Remaining)
+	}
+
+	// The namespace stays readable, so a bulk reset still finds hashed keys
+	deleted, err := tl.ResetByPrefix(ctx, tl.NamespacePrefix("billing"))
+	if err != nil {
+		t.Fatalf("ResetByPrefix: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d keys, want 1", deleted)
+	}
+	if resp := tl.check(t, req); resp.Remaining != 4 {
+		t.Errorf("check after reset = %+v, want a full bucket (4 remaining)", resp)
+	}
+}
+
+func TestHashKeyKeepsHashTags(t *testing.T) {
+	a, b := hashKey("{tenant-1}:user:1"), hashKey("{tenant-1}:user:2")
+	tagA, okA := hashTag(a)
+	tagB, okB := hashTag(b)
+	if !okA || !okB || tagA != tagB {
+		t.Errorf("hashed keys %s and %s, want the same hash tag", a, b)
+	}
+	if a == b {
+		t.Error("different keys hashed to the same value")
+	}
+	if got := hashKey("user:1"); len(got) != 2*hashedKeyBytes || got != hashKey("user:1") {
+		t.Errorf("hashKey(user:1) = %q, want a stable %d-char digest", got, 2*hashedKeyBytes)
+	}
+}
@@ -0,0 +1,17 @@
+package limiter
+
+import "math/rand"
+
+// ttlJitter is TTL_JITTER_PERCENT as a fraction, given to each limiter that sets expiries
+type ttlJitter float64
+
+// arg picks how much longer than the computed TTL this key's expiry should be,
+// as a fraction in [0, j). It's chosen here rather than with math.random in
+// the script, because Redis seeds the script PRNG identically on every call.
+// Jitter only ever lengthens the TTL, so state is never dropped before it's stale.
+func (j ttlJitter) arg() float64 {
+	if j <= 0 {
+		return 0
+	}
+	return rand.Float64() * float64(j)
+}
@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestTTLJitterArgStaysInRange(t *testing.T) {
+	if got := ttlJitter(0).arg(); got != 0 {
+		t.Errorf("arg with jitter off = %v, want 0", got)
+	}
+	for i := 0; i < 1000; i++ {
+		if got := ttlJitter(0.1).arg(); got < 0 || got >= 0.1 {
+			t.Fatalf("arg = %v, want in [0, 0.1)", got)
+		}
+	}
+}
+
+func TestKeyTTLsFallInJitteredRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func(key string) CheckRequest
+		keys    func(key string) []string
+		base    time.Duration // the TTL without jitter
+	}{
+		{
+			name:    "token_bucket",
+			request: func(key string) CheckRequest { return tokenBucketRequest(key, 100, 1) },
+			keys:    func(key string) []string { return []string{key} },
+			base:    200 * time.Second, // twice the time to fill from empty
+		},
+		{
+			name:    "sliding_window",
+			request: func(key string) CheckRequest { return slidingWindowRequest(key, 100, time.Minute) },
+			keys:    func(key string) []string { return []string{key, "{" + key + "}:counter"} },
+			base:    70 * time.Second, // the window plus 10s
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := newTestLimiter(t, func(cfg *config.Config) {
+				cfg.TTLJitterPercent = 10
+			})
+
+			// Keys created at the same instant should expire at different ones
+			distinct := map[time.Duration]bool{}
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("user:%d", i)
+				tl.check(t, tt.request(key))
+				for _, k := range tt.keys(key) {
+					ttl := tl.redis.TTL(k)
+					if ttl < tt.base || ttl > tt.base+tt.base/10 {
+						t.Fatalf("TTL of %s = %v, want in [%v, %v]", k, ttl, tt.base, tt.base+tt.base/10)
+					}
+					distinct[ttl] = true
+				}
+			}
+			if len(distinct) < 10 {
+				t.Errorf("%d distinct TTLs across 50 keys, want them spread out", len(distinct))
+			}
+		})
+	}
+}
+
+func TestKeyTTLsExactWithoutJitter(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.TTLJitterPercent = 0
+	})
+
+	tl.check(t, tokenBucketRequest("user:1", 100, 1))
+	tl.check(t, slidingWindowRequest("user:2", 100, time.Minute))
+	if ttl := tl.redis.TTL("user:1"); ttl != 200*time.Second {
+		t.Errorf("token bucket TTL = %v, want 200s", ttl)
+	}
+	if ttl := tl.redis.TTL("user:2"); ttl != 70*time.Second {
+		t.Errorf("sliding window TTL = %v, want 70s", ttl)
+	}
+}
@@ -0,0 +1,64 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidKey is wrapped by every key the KeyNormalizer rejects
+var ErrInvalidKey = errors.New("invalid key")
+
+// KeyNormalizer rewrites a caller's key into the form it's stored under, or rejects it
+// Tenants disagree on key rules (case, length), so embedders can swap in their own with
+// SetKeyNormalizer. Errors should wrap ErrInvalidKey so the API reports them as such
+type KeyNormalizer interface {
+	NormalizeKey(key string) (string, error)
+}
+
+// DefaultKeyNormalizer trims surrounding whitespace, optionally lowercases, and rejects
+// control characters and keys longer than MaxLength bytes (KEY_LOWERCASE, MAX_KEY_LENGTH)
+type DefaultKeyNormalizer struct {
+	Lowercase bool
+	MaxLength int // 0 means no limit
+}
+
+func (n DefaultKeyNormalizer) NormalizeKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("%w: key is blank", ErrInvalidKey)
+	}
+	if n.MaxLength > 0 && len(key) > n.MaxLength {
+		return "", fmt.Errorf("%w: key is longer than %d bytes", ErrInvalidKey, n.MaxLength)
+	}
+	if strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%w: key contains control characters", ErrInvalidKey)
+	}
+	if n.Lowercase {
+		key = strings.ToLower(key)
+	}
+	return key, nil
+}
+
+// SetKeyNormalizer replaces the KEY_LOWERCASE/MAX_KEY_LENGTH normalizer - call it before
+// the limiter starts serving
+func (l *Limiter) SetKeyNormalizer(n KeyNormalizer) {
+	l.keyNormalizer = n
+}
+
+// callerKey checks and normalizes a caller's key. Checks, peeks and leases all go
+// through it before redisKey, so they agree on which Redis key a caller's key means
+func (l *Limiter) callerKey(key string) (string, error) {
+	if key == "" {
+		return "", errors.New("key cannot be empty")
+	}
+	key, err := l.keyNormalizer.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if l.adaptive != nil && key == adaptiveFactorsKey {
+		return "", fmt.Errorf("%w: %q is reserved for the adaptive factors", ErrInvalidKey, key)
+	}
+	return key, nil
+}
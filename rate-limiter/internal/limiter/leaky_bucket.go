@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// LeakyBucketLimiter implements the leaky bucket algorithm (as a meter)
+// Requests fill a queue that drains at a constant rate - gives a strictly smoothed
+// output rate, unlike token bucket which lets a full bucket burst through at once
+type LeakyBucketLimiter struct {
+	redis   *redisclient.Client
+	failure *FailurePolicy
+	metrics *metrics.Metrics
+	fills   *fillTracker
+	clock   utils.Clock
+	scripts *scriptSet
+	jitter  ttlJitter
+}
+
+func NewLeakyBucketLimiter(redis *redisclient.Client, failure *FailurePolicy, m *metrics.Metrics, fills *fillTracker, scripts *scriptSet, jitter ttlJitter, clock utils.Clock) *LeakyBucketLimiter {
+	return &LeakyBucketLimiter{redis: redis, failure: failure, metrics: m, fills: fills, scripts: scripts, jitter: jitter, clock: clock}
+}
+
+// Check determines if a request should be allowed under leaky bucket
+// capacity: max queue depth before requests are rejected
+// leakRate: units drained from the queue per second (the smoothed output rate)
+// remaining is the number of free queue slots
+// cost: units this request consumes (req.Cost)
+func (lb *LeakyBucketLimiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	start := time.Now()
+	defer func() {
+		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
+		lb.metrics.ObserveVec(lb.metrics.CheckLatency, latencyMs, "leaky_bucket")
+	}()
+
+	call, err := lb.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute Lua script atomically
+	redisStart := time.Now()
+	result, err := lb.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	lb.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := lb.finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
+
+// prepare validates the parameters and builds the script call for a check
+func (lb *LeakyBucketLimiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, error) {
+	capacity, leakRate, cost := req.Capacity, req.LeakRate, req.Cost
+
+	if capacity <= 0 || leakRate <= 0 {
+		return redisclient.ScriptCall{}, errors.New("capacity and leakRate must be positive")
+	}
+
+	if cost <= 0 || cost > capacity {
+		return redisclient.ScriptCall{}, errors.New("cost must be positive and no more than capacity")
+	}
+
+	if capacity > MaxSafeInteger || leakRate > MaxSafeInteger || math.IsNaN(leakRate) || math.IsInf(leakRate, 0) {
+		return redisclient.ScriptCall{}, errors.New("capacity and leakRate exceed the safe numeric range")
+	}
+
+	now := scriptNow(ctx, lb.clock)
+
+	return redisclient.ScriptCall{
+		Script: *lb.scripts.leakyBucket.Load(),
+		Keys:   []string{req.Key},
+		Args:   []interface{}{capacity, leakRate, now, cost, dryRunArg(req.DryRun), lb.jitter.arg()},
+	}, nil
+}
+
+// finish turns the script reply (or error) into a CheckResponse and records metrics
+// req.Tier must already be normalized (see Limiter.tierLabel) to keep cardinality bounded
+func (lb *LeakyBucketLimiter) finish(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error) {
+	if err != nil {
+		var failOpenErr *redisclient.FailOpenError
+		if errors.As(err, &failOpenErr) {
+			lb.metrics.RedisErrors.Inc()
+			// Redis couldn't decide - FAIL_MODE picks open, closed or a local limit
+			return lb.failure.Decide(ctx, req, err)
+		}
+		return nil, fmt.Errorf("leaky bucket check failed: %w", err)
+	}
+
+	resp, err := parseCheckResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Allowed {
+		lb.metrics.IncCounter(lb.metrics.RequestsAllowed, "leaky_bucket", req.Tier, dryRunLabel(req.DryRun))
+	} else {
+		lb.metrics.IncCounter(lb.metrics.RequestsBlocked, "leaky_bucket", req.Tier, dryRunLabel(req.DryRun))
+	}
+	if req.DryRun {
+		return dryRunResponse(resp), nil
+	}
+	lb.fills.record("leaky_bucket", resp.Remaining, req.Capacity)
+
+	return resp, nil
+}
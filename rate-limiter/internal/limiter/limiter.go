@@ -2,30 +2,218 @@ package limiter
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"time"
 
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
 	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/tracing"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// tracer creates the per-check spans - a no-op until tracing.Setup installs a provider
+var tracer = otel.Tracer("github.com/piyushpatra/rate-limiter/internal/limiter")
+
 // Algorithm types supported by the rate limiter
 const (
 	AlgorithmTokenBucket   = "token_bucket"
 	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmLeakyBucket   = "leaky_bucket"
+	AlgorithmGCRA          = "gcra"
+
+	AlgorithmSlidingWindowCounter = "sliding_window_counter"
+	AlgorithmQuota                = "quota"
 )
 
+// MaxSafeInteger is the largest integer a float64 (and so a Lua number) represents exactly
+// Limits above this lose precision on the way into the scripts
+const MaxSafeInteger = 1 << 53
+
+// AlgorithmInfo describes what a check with this algorithm needs
+// Parameter names are the request's JSON field names
+type AlgorithmInfo struct {
+	Name     string   `json:"name"`
+	Required []string `json:"required"`
+	Optional []string `json:"optional"`
+}
+
+// algorithms is the list of everything Check can route to, in display order
+// Adding an algorithm here is what makes IsSupported (and so Check) accept it
+var algorithms = []AlgorithmInfo{
+	{Name: AlgorithmTokenBucket, Required: []string{"capacity", "refill_rate"}, Optional: []string{"cost", "dry_run", "request_id"}},
+	{Name: AlgorithmSlidingWindow, Required: []string{"capacity", "window_ms"}, Optional: []string{"window_seconds", "cost", "dry_run"}},
+	{Name: AlgorithmSlidingWindowCounter, Required: []string{"capacity", "window_ms"}, Optional: []string{"window_seconds", "cost", "dry_run"}},
+	{Name: AlgorithmLeakyBucket, Required: []string{"capacity", "leak_rate"}, Optional: []string{"cost", "dry_run"}},
+	{Name: AlgorithmGCRA, Required: []string{"capacity", "refill_rate"}, Optional: []string{"cost", "dry_run"}},
+	{Name: AlgorithmQuota, Required: []string{"capacity", "period"}, Optional: []string{"cost", "dry_run"}},
+}
+
+// Algorithms returns the supported algorithms and their parameters
+func Algorithms() []AlgorithmInfo {
+	return append([]AlgorithmInfo(nil), algorithms...)
+}
+
+// IsSupported reports whether an algorithm name can be passed to Check
+func IsSupported(algorithm string) bool {
+	for _, a := range algorithms {
+		if a.Name == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
 // Limiter provides a unified interface for different rate limiting algorithms
 type Limiter struct {
+	redis         *redisclient.Client
 	tokenBucket   *TokenBucketLimiter
 	slidingWindow *SlidingWindowLimiter
+	leakyBucket   *LeakyBucketLimiter
+	gcra          *GCRALimiter
+
+	slidingWindowCounter *SlidingWindowCounterLimiter
+	quota                *QuotaLimiter
+
+	concurrency *ConcurrencyLimiter
+
+	// failure decides checks that span several algorithms (CheckAll)
+	failure *FailurePolicy
+
+	// sourceQuota is nil when SOURCE_KEY_LIMIT is disabled
+	sourceQuota *SourceQuotaLimiter
+
+	// tiers is the METRIC_TIERS allow-list for the tier metrics label
+	tiers map[string]bool
+
+	// keyPrefix is REDIS_KEY_PREFIX, put in front of every key this limiter touches
+	keyPrefix string
+
+	// hashKeys is HASH_KEYS - callers' keys are stored as digests (see hashKey)
+	hashKeys bool
+
+	// resetScanCount is the SCAN page size for ResetByPrefix (RESET_SCAN_COUNT)
+	resetScanCount int64
+
+	// denyCache is nil when DENY_CACHE_TTL is disabled
+	denyCache *DenyCache
+
+	// adaptive is nil unless ADAPTIVE_ENABLED is set
+	adaptive *AdaptiveLimiter
+
+	// blockLog is nil when BLOCK_LOG_RATE is disabled
+	blockLog *BlockLogger
+
+	// watched is nil when WATCH_KEYS is empty
+	watched *watchList
+
+	// decisionCountsTTL is DECISION_COUNTS_TTL - 0 when checks don't keep counts
+	decisionCountsTTL time.Duration
+
+	// router is nil unless ROUTING_RULES_FILE is set (see SetRouter)
+	router *Router
+
+	// keyNormalizer cleans up callers' keys before anything else sees them (see callerKey)
+	keyNormalizer KeyNormalizer
+
+	// clock is "now" for every algorithm - a *RedisClock when CLOCK_SOURCE is redis
+	clock utils.Clock
+
+	// scripts is the Lua this limiter runs, shared with its algorithms
+	scripts *scriptSet
+
+	// jitter is TTL_JITTER_PERCENT, for the scripts called from here (CheckAll)
+	jitter ttlJitter
+
+	// scriptChecks caches which scripts CheckScripts has already seen run
+	scriptChecks scriptChecks
+
+	// OnDecision, when set, is called after every Check and every CheckBatch entry with the
+	// caller's request and the outcome (resp is the zero value when err is set), for side
+	// effects like auditing. It runs on the request path, so it must be fast and must not
+	// block - hand slow work off to a goroutine or queue. A panic in it is recovered and
+	// logged. Set it before the limiter starts serving; it isn't synchronized
+	OnDecision func(ctx context.Context, req CheckRequest, resp CheckResponse, err error)
+
+	metrics *metrics.Metrics
+	fills   *fillTracker
+}
+
+// NewLimiter creates a new rate limiter with all algorithms
+// Time comes from CLOCK_SOURCE: the local clock, or one kept in line with Redis
+func NewLimiter(redis *redisclient.Client, cfg *config.Config, m *metrics.Metrics) *Limiter {
+	var clock utils.Clock = utils.SystemClock{}
+	if cfg.ClockSource == config.ClockSourceRedis {
+		clock = NewRedisClock(redis)
+	}
+	return NewLimiterWithClock(redis, cfg, m, clock)
 }
 
-// NewLimiter creates a new rate limiter with both algorithms
-func NewLimiter(redis *redisclient.Client) *Limiter {
-	return &Limiter{
-		tokenBucket:   NewTokenBucketLimiter(redis),
-		slidingWindow: NewSlidingWindowLimiter(redis),
+// NewLimiterWithClock is NewLimiter with the clock supplied, e.g. a utils.ManualClock so
+// tests can step time forward instead of sleeping through refills
+func NewLimiterWithClock(redis *redisclient.Client, cfg *config.Config, m *metrics.Metrics, clock utils.Clock) *Limiter {
+	scripts := newScriptSet(cfg.LuaDir)
+	jitter := ttlJitter(cfg.TTLJitterPercent / 100)
+
+	// Already checked by cfg.Validate
+	quotaLoc, _ := cfg.QuotaLocation()
+
+	failure := NewFailurePolicy(cfg.FailMode, cfg.LocalFallbackFraction, m, clock)
+	fills := newFillTracker(m)
+	l := &Limiter{
+		redis:         redis,
+		tokenBucket:   NewTokenBucketLimiter(redis, failure, m, fills, cfg.DedupTTL, scripts, jitter, clock),
+		slidingWindow: NewSlidingWindowLimiter(redis, failure, m, fills, scripts, jitter, clock),
+		leakyBucket:   NewLeakyBucketLimiter(redis, failure, m, fills, scripts, jitter, clock),
+		gcra:          NewGCRALimiter(redis, failure, m, fills, scripts, jitter, clock),
+
+		slidingWindowCounter: NewSlidingWindowCounterLimiter(redis, failure, m, fills, scripts, jitter, clock),
+		quota:                NewQuotaLimiter(redis, failure, m, fills, quotaLoc, scripts, clock),
+		concurrency:          NewConcurrencyLimiter(redis, failure, m, scripts, clock),
+
+		failure:   failure,
+		tiers:     make(map[string]bool, len(cfg.MetricTiers)),
+		keyPrefix: cfg.RedisKeyPrefix,
+		hashKeys:  cfg.HashKeys,
+		metrics:   m,
+		fills:     fills,
+
+		resetScanCount: cfg.ResetScanCount,
+		keyNormalizer:  DefaultKeyNormalizer{Lowercase: cfg.KeyLowercase, MaxLength: cfg.MaxKeyLength},
+		clock:          clock,
+		scripts:        scripts,
+		jitter:         jitter,
+
+		decisionCountsTTL: cfg.DecisionCountsTTL,
+	}
+
+	for _, tier := range cfg.MetricTiers {
+		l.tiers[tier] = true
+	}
+
+	if cfg.SourceKeyLimit > 0 {
+		l.sourceQuota = NewSourceQuotaLimiter(redis, cfg.SourceKeyLimit, int64(cfg.SourceKeyWindow.Seconds()), cfg.RedisKeyPrefix, scripts)
+	}
+
+	if cfg.DenyCacheTTL > 0 {
+		l.denyCache = NewDenyCache(cfg.DenyCacheTTL, m, clock)
+	}
+
+	if cfg.AdaptiveEnabled {
+		l.adaptive = NewAdaptiveLimiter(redis, cfg.RedisKeyPrefix)
+	}
+
+	if cfg.BlockLogRate > 0 {
+		l.blockLog = NewBlockLogger(cfg.BlockLogRate, cfg.BlockLogBurst)
 	}
+
+	l.watched = newWatchList(cfg.WatchKeys)
+
+	return l
 }
 
 // CheckRequest evaluates a rate limit check based on the specified algorithm
@@ -33,45 +221,316 @@ type CheckRequest struct {
 	Key           string
 	Algorithm     string
 	Capacity      int64
-	RefillRate    float64 // only for token bucket
-	WindowSeconds int64   // only for sliding window
+	RefillRate    float64 // token bucket refill rate, GCRA emission rate
+	WindowMillis  int64   // only for sliding window (both variants)
+	LeakRate      float64 // only for leaky bucket
+	Period        string  // only for quota: daily or monthly
+
+	// Namespace separates teams sharing a deployment - the Redis key becomes
+	// REDIS_KEY_PREFIX:namespace:key (empty parts are left out)
+	Namespace string
+
+	// Source identifies the caller (API key/IP) for the distinct-key quota
+	Source string
+
+	// Tier groups checks in the allowed/blocked metrics (e.g. "free", "pro")
+	Tier string
+
+	// Cost is how many units this request consumes - 0 means 1
+	Cost int64
+
+	// FailMode overrides FAIL_MODE for this check (open, closed or local) - empty uses the default
+	FailMode string
+
+	// NowMillis overrides the server clock when non-zero (testing mode only)
+	NowMillis int64
+
+	// DryRun computes the decision and records metrics but always allows
+	// and leaves the limit's state untouched
+	DryRun bool
+
+	// RequestID makes retries idempotent: a repeat within DEDUP_TTL gets the first
+	// decision back without consuming again (token bucket only)
+	RequestID string
+
+	// PenaltyBase turns on repeat-offender penalties when positive: a block shuts the key
+	// for PenaltyBase, doubling with each consecutive block up to PenaltyMax. Strikes are
+	// forgotten once the key stays out of trouble for PenaltyMax after its last penalty
+	PenaltyBase time.Duration
+	PenaltyMax  time.Duration
+
+	// Precise asks the token bucket for its unfloored token count (see RemainingFloat)
+	Precise bool
 }
 
 type CheckResponse struct {
 	Allowed   bool
 	Remaining int64
+
+	// RemainingFloat is Remaining before flooring, for a Precise token bucket check
+	// Other checks just repeat Remaining
+	RemainingFloat float64
+
+	// RetryAfter is how long until the next request could be allowed (0 when allowed)
+	RetryAfter time.Duration
+
+	// SuggestedPoll is how long a dry run's caller should wait before polling again -
+	// the RetryAfter the check would have had, 0 when it would have been allowed
+	SuggestedPoll time.Duration
+
+	// ResetAt is when Remaining next goes up by one - the next whole token for token
+	// bucket, the oldest entry leaving the window for sliding window. Zero when unknown
+	// (e.g. a FAIL_MODE decision)
+	ResetAt time.Time
+
+	// Degraded means Redis couldn't be reached and FAIL_MODE made the decision
+	Degraded bool
+
+	// PenaltyUntil is when the key's active repeat-offender penalty ends - zero when
+	// there is none (or the check had no penalty)
+	PenaltyUntil time.Time
+
+	// Timing says where the check's time went, for the X-Timing debug header
+	Timing CheckTiming
+}
+
+// CheckTiming breaks down how long a check took
+type CheckTiming struct {
+	// Redis is the script call, retries included - zero when Redis wasn't called
+	Redis time.Duration
+}
+
+// withRedisTime records how long resp's script call took (resp may be nil)
+func withRedisTime(resp *CheckResponse, d time.Duration) *CheckResponse {
+	if resp != nil {
+		resp.Timing.Redis = d
+	}
+	return resp
 }
 
 // Check routes the request to the appropriate algorithm
 // This is the main entry point for rate limiting decisions
 func (l *Limiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
-	if req.Key == "" {
-		return nil, errors.New("key cannot be empty")
+	resp, err := l.check(ctx, req)
+	if l.OnDecision != nil {
+		l.decided(ctx, req, resp, err)
 	}
+	return resp, err
+}
 
-	var allowed bool
-	var remaining int64
+func (l *Limiter) check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	var resp *CheckResponse
 	var err error
 
+	if req.Key, err = l.callerKey(req.Key); err != nil {
+		return nil, err
+	}
+
+	req = l.normalize(req)
+	if req.NowMillis > 0 {
+		ctx = utils.WithNowMillis(ctx, req.NowMillis)
+	}
+
+	// A key that was just blocked is refused without going to Redis (or the source quota)
+	if l.denyCache != nil {
+		if cached := l.denyCache.Lookup(ctx, req); cached != nil {
+			l.logBlocked(req, cached)
+			l.watch(req, cached)
+			return cached, nil
+		}
+	}
+
+	// Span names must stay bounded, so reject unknown algorithms before naming one
+	if !IsSupported(req.Algorithm) {
+		return nil, unsupportedAlgorithm(req.Algorithm)
+	}
+
+	// Only a valid check may count against its source's distinct keys
+	if l.sourceQuota != nil && req.Source != "" {
+		if _, _, err := l.prepareAlgorithm(ctx, req); err != nil {
+			return nil, err
+		}
+		if resp, err := l.admitSource(ctx, req); resp != nil || err != nil {
+			return resp, err
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "ratelimit."+req.Algorithm)
+	defer span.End()
+	// Hashing the key isn't free - only pay for it when the span is sampled
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("ratelimit.key_hash", tracing.HashKey(req.Key)),
+			attribute.Int64("ratelimit.capacity", req.Capacity),
+		)
+	}
+
+	// Wrapped scripts go through the shared prepare/finish path
+	if req.PenaltyBase > 0 || l.decisionCountsTTL > 0 {
+		resp, err = l.checkPrepared(ctx, req)
+	} else {
+		resp, err = l.checkAlgorithm(ctx, req)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if l.denyCache != nil {
+		l.denyCache.Record(ctx, req, resp)
+	}
+	l.logBlocked(req, resp)
+	l.watch(req, resp)
+
+	span.SetAttributes(attribute.Bool("ratelimit.allowed", resp.Allowed))
+	return resp, nil
+}
+
+// checkAlgorithm runs the check on its algorithm's limiter
+func (l *Limiter) checkAlgorithm(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
 	switch req.Algorithm {
 	case AlgorithmTokenBucket:
-		allowed, remaining, err = l.tokenBucket.Check(ctx, req.Key, req.Capacity, req.RefillRate)
+		return l.tokenBucket.Check(ctx, req)
 	
 	case AlgorithmSlidingWindow:
-		allowed, remaining, err = l.slidingWindow.Check(ctx, req.Key, req.Capacity, req.WindowSeconds)
+		return l.slidingWindow.Check(ctx, req)
 	
-	default:
-		return nil, fmt.Errorf("unsupported algorithm: %s (supported: %s, %s)", 
-			req.Algorithm, AlgorithmTokenBucket, AlgorithmSlidingWindow)
+	case AlgorithmLeakyBucket:
+		return l.leakyBucket.Check(ctx, req)
+	
+	case AlgorithmGCRA:
+		return l.gcra.Check(ctx, req)
+
+	case AlgorithmSlidingWindowCounter:
+		return l.slidingWindowCounter.Check(ctx, req)
+
+	case AlgorithmQuota:
+		return l.quota.Check(ctx, req)
 	}
+	return nil, unsupportedAlgorithm(req.Algorithm)
+}
+
+// decided runs OnDecision, keeping a panicking hook from failing the check
+func (l *Limiter) decided(ctx context.Context, req CheckRequest, resp *CheckResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.Printf("OnDecision hook panicked: %v", r)
+		}
+	}()
 
+	var decision CheckResponse
+	if resp != nil {
+		decision = *resp
+	}
+	l.OnDecision(ctx, req, decision, err)
+}
+
+// Adaptive returns the capacity factors, or nil when ADAPTIVE_ENABLED is off
+func (l *Limiter) Adaptive() *AdaptiveLimiter {
+	return l.adaptive
+}
+
+// RedisClock returns the Redis-synced clock, or nil unless CLOCK_SOURCE is redis
+func (l *Limiter) RedisClock() *RedisClock {
+	rc, _ := l.clock.(*RedisClock)
+	return rc
+}
+
+// Acquire takes a concurrency lease on key - see ConcurrencyLimiter
+func (l *Limiter) Acquire(ctx context.Context, namespace, key string, limit int64, ttl time.Duration) (*Lease, error) {
+	key, err := l.callerKey(key)
 	if err != nil {
 		return nil, err
 	}
+	return l.concurrency.Acquire(ctx, l.leaseKey(namespace, key), limit, ttl)
+}
+
+// Release returns a concurrency lease taken with Acquire
+func (l *Limiter) Release(ctx context.Context, namespace, key, token string) (bool, error) {
+	key, err := l.callerKey(key)
+	if err != nil {
+		return false, err
+	}
+	return l.concurrency.Release(ctx, l.leaseKey(namespace, key), token)
+}
+
+// leaseKey is where key's concurrency leases are kept - a companion of the key's Redis
+// name rather than the name itself, which checks on the same key use. Leases and
+// sliding window entries are both sorted sets, so sharing it would mix the two
+func (l *Limiter) leaseKey(namespace, key string) string {
+	return companionKey(l.redisKey(namespace, key), "leases")
+}
+
+// normalize fills in request defaults the algorithms rely on
+// It also namespaces the key, so callers must pass the key through callerKey first
+func (l *Limiter) normalize(req CheckRequest) CheckRequest {
+	// Rules match on the caller's key and tier, so route before either is rewritten
+	if req.Algorithm == "" {
+		req = l.route(req)
+	}
+	if req.Cost == 0 {
+		req.Cost = 1
+	}
+	if l.adaptive != nil {
+		req = l.adaptive.apply(req)
+	}
+	req.Tier = l.tierLabel(req.Tier)
+	req.Key = l.redisKey(req.Namespace, req.Key)
+	return req
+}
 
-	return &CheckResponse{
-		Allowed:   allowed,
-		Remaining: remaining,
-	}, nil
+// redisKey is the key as stored in Redis: prefix:namespace:key
+// Every operation goes through here so companion keys (e.g. sliding window's
+// {key}:counter, see companionKey) and peeks see the same names the checks wrote. With HASH_KEYS only
+// the caller's key is hashed - the namespace stays readable so bulk resets still find it
+func (l *Limiter) redisKey(namespace, key string) string {
+	if l.hashKeys {
+		key = hashKey(key)
+	}
+	return joinKey(l.keyPrefix, namespace, key)
+}
+
+// joinKey joins the non-empty parts with ':'
+func joinKey(parts ...string) string {
+	key := ""
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if key != "" {
+			key += ":"
+		}
+		key += part
+	}
+	return key
 }
 
+// companionKey names the extra key a script keeps beside key (e.g. a sliding window's
+// ":counter"). The name carries key's hash tag - or wraps key in one - so in cluster mode
+// both land in the same slot and the script can touch them together
+func companionKey(key, suffix string) string {
+	if _, tagged := hashTag(key); tagged {
+		return key + ":" + suffix
+	}
+	return "{" + key + "}:" + suffix
+}
+
+// tierLabel maps a request's tier onto a bounded set of metric label values
+// Tiers come from callers, so anything off the allow-list collapses into "other"
+func (l *Limiter) tierLabel(tier string) string {
+	if tier == "" {
+		return "none"
+	}
+	if l.tiers[tier] {
+		return tier
+	}
+	return "other"
+}
+
+func unsupportedAlgorithm(algorithm string) error {
+	return fmt.Errorf("unsupported algorithm: %s (supported: %s, %s, %s, %s, %s, %s)", 
+		algorithm, AlgorithmTokenBucket, AlgorithmSlidingWindow, AlgorithmLeakyBucket, AlgorithmGCRA,
+		AlgorithmSlidingWindowCounter, AlgorithmQuota)
+}
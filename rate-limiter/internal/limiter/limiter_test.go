@@ -0,0 +1,101 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testStart is where every test clock begins - a fixed time keeps retry and reset
+// values exact
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testLimiter is a Limiter on a miniredis server with a clock the test steps by hand
+type testLimiter struct {
+	*Limiter
+	redis   *miniredis.Miniredis
+	clock   *utils.ManualClock
+	metrics *metrics.Metrics
+}
+
+// newTestLimiter starts miniredis and builds a Limiter from the default config,
+// after setup (which may be nil) has adjusted it
+func newTestLimiter(t testing.TB, setup func(cfg *config.Config)) *testLimiter {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisAddr = mr.Addr()
+	cfg.RedisKeyPrefix = ""
+	// The 2ms default is for production; a busy test machine would see checks fail open
+	cfg.RedisTimeout = time.Second
+	if setup != nil {
+		setup(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+
+	m := metrics.New(prometheus.NewRegistry(), nil, nil)
+	client, err := redisclient.NewClient(cfg, m)
+	if err != nil {
+		t.Fatalf("redis client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	clock := utils.NewManualClock(testStart)
+	return &testLimiter{Limiter: NewLimiterWithClock(client, cfg, m, clock), redis: mr, clock: clock, metrics: m}
+}
+
+// check runs req and fails the test on an error
+func (tl *testLimiter) check(t *testing.T, req CheckRequest) *CheckResponse {
+	t.Helper()
+	resp, err := tl.Check(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Check(%+v): %v", req, err)
+	}
+	return resp
+}
+
+// advance moves the test clock forward by d
+func (tl *testLimiter) advance(d time.Duration) {
+	tl.clock.Set(time.UnixMilli(tl.clock.NowMillis()).Add(d))
+}
+
+func tokenBucketRequest(key string, capacity int64, refillRate float64) CheckRequest {
+	return CheckRequest{Key: key, Algorithm: AlgorithmTokenBucket, Capacity: capacity, RefillRate: refillRate}
+}
+
+func slidingWindowRequest(key string, capacity int64, window time.Duration) CheckRequest {
+	return CheckRequest{Key: key, Algorithm: AlgorithmSlidingWindow, Capacity: capacity, WindowMillis: window.Milliseconds()}
+}
+
+func TestTokenBucketBlocksWhenEmptyAndRefills(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := tokenBucketRequest("user:1", 2, 1)
+
+	for i := 0; i < 2; i++ {
+		if resp := tl.check(t, req); !resp.Allowed {
+			t.Fatalf("check %d blocked, want allowed", i+1)
+		}
+	}
+	resp := tl.check(t, req)
+	if resp.Allowed {
+		t.Fatal("third check allowed, want blocked")
+	}
+	if resp.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", resp.RetryAfter)
+	}
+
+	tl.advance(time.Second)
+	if resp := tl.check(t, req); !resp.Allowed {
+		t.Fatal("check after refill blocked, want allowed")
+	}
+}
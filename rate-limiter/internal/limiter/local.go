@@ -0,0 +1,133 @@
+package limiter
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// localShards spreads keys over independent locks so fallback checks don't serialize
+const localShards = 32
+
+// localSweepMin is the smallest shard size that triggers a sweep of idle buckets
+const localSweepMin = 1024
+
+// LocalLimiter is an in-memory token bucket per key, used while Redis is unreachable
+// It only sees this instance's traffic, so every limit is scaled down by fraction -
+// with N instances, a fraction around 1/N keeps the fleet near the global limit
+type LocalLimiter struct {
+	fraction float64
+	shards   [localShards]localShard
+	clock    utils.Clock
+}
+
+type localShard struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+
+	// nextSweep is the bucket count at which idle buckets are next dropped
+	nextSweep int
+}
+
+type localBucket struct {
+	tokens   float64
+	lastMs   int64
+	capacity float64
+	rate     float64
+}
+
+func NewLocalLimiter(fraction float64, clock utils.Clock) *LocalLimiter {
+	ll := &LocalLimiter{fraction: fraction, clock: clock}
+	for i := range ll.shards {
+		ll.shards[i].buckets = make(map[string]*localBucket)
+		ll.shards[i].nextSweep = localSweepMin
+	}
+	return ll
+}
+
+// Check applies the scaled-down limit for req in memory
+// Every algorithm is approximated by a token bucket with the same sustained rate
+func (ll *LocalLimiter) Check(ctx context.Context, req CheckRequest) *CheckResponse {
+	capacity := math.Max(1, math.Floor(float64(req.Capacity)*ll.fraction))
+	rate := localRate(req) * ll.fraction
+	if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		// Can't model the limit - same as failing open
+		return &CheckResponse{Allowed: true}
+	}
+
+	// A cost the scaled bucket can never hold would block the key for good
+	cost := math.Min(float64(req.Cost), capacity)
+	now := utils.NowMillisCtx(ctx, ll.clock)
+
+	shard := &ll.shards[localShardFor(req.Key)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, ok := shard.buckets[req.Key]
+	if !ok {
+		shard.sweep(now)
+		b = &localBucket{tokens: capacity, lastMs: now}
+		shard.buckets[req.Key] = b
+	}
+	b.capacity, b.rate = capacity, rate
+	b.refill(now)
+
+	if b.tokens >= cost {
+		b.tokens -= cost
+		return &CheckResponse{Allowed: true, Remaining: int64(b.tokens)}
+	}
+
+	retryAfter := time.Duration(math.Ceil((cost-b.tokens)/rate*1000)) * time.Millisecond
+	return &CheckResponse{Allowed: false, Remaining: int64(b.tokens), RetryAfter: retryAfter}
+}
+
+// refill tops up tokens for the time elapsed since the last check
+func (b *localBucket) refill(now int64) {
+	if elapsed := float64(now-b.lastMs) / 1000.0; elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+	}
+	b.lastMs = now
+}
+
+// sweep drops buckets that have refilled completely - recreating them is equivalent
+// Runs only once the shard has doubled since the last sweep, so inserts stay amortized O(1)
+func (s *localShard) sweep(now int64) {
+	if len(s.buckets) < s.nextSweep {
+		return
+	}
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= b.capacity {
+			delete(s.buckets, key)
+		}
+	}
+	s.nextSweep = max(localSweepMin, 2*len(s.buckets))
+}
+
+// localRate is the sustained requests/second a limit allows
+func localRate(req CheckRequest) float64 {
+	switch req.Algorithm {
+	case AlgorithmTokenBucket, AlgorithmGCRA:
+		return req.RefillRate
+	case AlgorithmLeakyBucket:
+		return req.LeakRate
+	case AlgorithmSlidingWindow, AlgorithmSlidingWindowCounter:
+		if req.WindowMillis > 0 {
+			return float64(req.Capacity) * 1000 / float64(req.WindowMillis)
+		}
+	case AlgorithmQuota:
+		// Spread evenly over the period - a local bucket can't see the calendar
+		return float64(req.Capacity) / periodSeconds(req.Period)
+	}
+	return 0
+}
+
+func localShardFor(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % localShards
+}
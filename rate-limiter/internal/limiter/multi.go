@@ -0,0 +1,240 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// MultiResponse is the combined outcome of CheckAll
+type MultiResponse struct {
+	// Allowed is true only when every limit allowed the request
+	Allowed bool
+
+	// Remaining is the lowest remaining across the limits
+	Remaining int64
+
+	// RetryAfter is the longest wait among the limits that rejected (0 when allowed)
+	RetryAfter time.Duration
+
+	// ResetAt is when Remaining next goes up - that of the limit with the lowest remaining
+	ResetAt time.Time
+
+	// Degraded means Redis couldn't be reached and FAIL_MODE made the decision
+	Degraded bool
+
+	// Results holds each limit's own decision, in request order
+	// When Allowed is false nothing was consumed, so Remaining reflects the current state
+	Results []CheckResponse
+}
+
+// CheckAll enforces several limits on one request (e.g. 10/sec AND 100/min)
+// All limits are evaluated in a single script, and tokens are only consumed when
+// every one of them allows - a rejection by one limit never drains the others.
+// In cluster mode the keys must share a {hash tag}, otherwise the check fails with
+// redisclient.ErrCrossSlot.
+func (l *Limiter) CheckAll(ctx context.Context, reqs []CheckRequest) (*MultiResponse, error) {
+	if len(reqs) == 0 {
+		return nil, errors.New("at least one limit is required")
+	}
+
+	start := time.Now()
+	defer func() {
+		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
+		l.metrics.ObserveVec(l.metrics.CheckLatency, latencyMs, "multi")
+	}()
+
+	keys := make([]string, 0, len(reqs)*2)
+	args := make([]interface{}, 0, len(reqs)*5)
+	seen := make(map[string]bool, len(reqs))
+	prepared := make([]CheckRequest, 0, len(reqs))
+
+	for _, req := range reqs {
+		key, err := l.callerKey(req.Key)
+		if err != nil {
+			return nil, err
+		}
+		req.Key = key
+		req = l.normalize(req)
+		// The script reads every key before writing any, so a repeated key would lose an update
+		if seen[req.Key] {
+			return nil, fmt.Errorf("key %q appears in more than one limit", req.Key)
+		}
+		seen[req.Key] = true
+		if req.DryRun {
+			return nil, errors.New("dry_run is not supported for multi-limit checks")
+		}
+		if req.PenaltyBase > 0 {
+			return nil, errors.New("penalties are not supported for multi-limit checks")
+		}
+		// multi.lua has no replay record, so a retry would be consumed again
+		if req.RequestID != "" {
+			return nil, errors.New("request_id is not supported for multi-limit checks")
+		}
+
+		entryCtx := ctx
+		if req.NowMillis > 0 {
+			entryCtx = utils.WithNowMillis(ctx, req.NowMillis)
+		}
+
+		// Reuse each algorithm's validation and argument building
+		call, _, err := l.prepare(entryCtx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		// multi.lua takes every limit's key with its counter companion (only sliding_window
+		// uses the counter, but fixed pairs keep the arguments easy to walk)
+		keys = append(keys, call.Keys[0], companionKey(call.Keys[0], "counter"))
+		// multi.lua takes the first four arguments of each algorithm's script (no dry_run flag)
+		args = append(args, req.Algorithm)
+		args = append(args, call.Args[:4]...)
+		prepared = append(prepared, req)
+	}
+
+	// A limit that just rejected its key rejects the whole check without going to Redis
+	if resp := l.cachedDeny(ctx, prepared); resp != nil {
+		return resp, nil
+	}
+
+	// Admission waits until every limit is known to be valid
+	for _, req := range prepared {
+		if l.sourceQuota == nil || req.Source == "" {
+			continue
+		}
+		if err := l.sourceQuota.Admit(ctx, req.Source, req.Key); err != nil {
+			var failOpenErr *redisclient.FailOpenError
+			if errors.As(err, &failOpenErr) {
+				l.metrics.RedisErrors.Inc()
+				return l.decideAll(ctx, prepared, err)
+			}
+			return nil, err
+		}
+	}
+
+	args = append(args, l.jitter.arg())
+
+	redisStart := time.Now()
+	result, err := l.redis.EvalLua(ctx, l.scripts.multi, keys, args...)
+	redisLatency := float64(time.Since(redisStart).Microseconds()) / 1000.0
+	l.metrics.RedisLatency.Observe(redisLatency)
+
+	if err != nil {
+		var failOpenErr *redisclient.FailOpenError
+		if errors.As(err, &failOpenErr) {
+			l.metrics.RedisErrors.Inc()
+			return l.decideAll(ctx, prepared, err)
+		}
+		return nil, fmt.Errorf("multi-limit check failed: %w", err)
+	}
+
+	results, err := parseMultiResult(result, len(prepared))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := combineResults(results)
+
+	// Every limit counts the request as allowed when it went through; on a rejection
+	// only the limits that actually rejected count it as blocked
+	for i, req := range prepared {
+		if resp.Allowed {
+			l.metrics.IncCounter(l.metrics.RequestsAllowed, req.Algorithm, req.Tier, dryRunLabel(false))
+		} else if !results[i].Allowed {
+			l.metrics.IncCounter(l.metrics.RequestsBlocked, req.Algorithm, req.Tier, dryRunLabel(false))
+			l.logBlocked(req, &results[i])
+			if l.denyCache != nil {
+				l.denyCache.Record(ctx, req, &results[i])
+			}
+		}
+		l.watch(req, &results[i])
+		l.fills.record(req.Algorithm, results[i].Remaining, req.Capacity)
+	}
+
+	return resp, nil
+}
+
+// cachedDeny answers a multi-limit check from the deny cache when any of its limits
+// was just blocked with the same parameters, or returns nil if Redis has to be asked
+// Only the cached limits are known - the others are reported as not rejecting
+func (l *Limiter) cachedDeny(ctx context.Context, prepared []CheckRequest) *MultiResponse {
+	if l.denyCache == nil {
+		return nil
+	}
+
+	results := make([]CheckResponse, len(prepared))
+	hit := false
+	for i, req := range prepared {
+		if cached := l.denyCache.Lookup(ctx, req); cached != nil {
+			l.logBlocked(req, cached)
+			l.watch(req, cached)
+			results[i] = *cached
+			hit = true
+		} else {
+			results[i] = CheckResponse{Allowed: true}
+		}
+	}
+	if !hit {
+		return nil
+	}
+	return combineResults(results)
+}
+
+// decideAll has FAIL_MODE decide every limit when Redis couldn't be reached (cause)
+func (l *Limiter) decideAll(ctx context.Context, prepared []CheckRequest, cause error) (*MultiResponse, error) {
+	results := make([]CheckResponse, len(prepared))
+	for i, req := range prepared {
+		decision, err := l.failure.Decide(ctx, req, cause)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = *decision
+	}
+	resp := combineResults(results)
+	resp.Degraded = true
+	return resp, nil
+}
+
+// parseMultiResult decodes the {allowed, (allowed, remaining, retry_after_ms, reset_ms)...} reply of multi.lua
+// Like parseCheckResult, entries without reset_ms are accepted
+func parseMultiResult(result interface{}, n int) ([]CheckResponse, error) {
+	resultSlice, ok := result.([]interface{})
+	if !ok || n == 0 || (len(resultSlice) != 1+4*n && len(resultSlice) != 1+3*n) {
+		return nil, errors.New("unexpected response format from Lua script")
+	}
+	stride := (len(resultSlice) - 1) / n
+
+	results := make([]CheckResponse, n)
+	for i := range results {
+		resp, err := parseCheckResult(resultSlice[1+stride*i : 1+stride*(i+1)])
+		if err != nil {
+			return nil, err
+		}
+		results[i] = *resp
+	}
+	return results, nil
+}
+
+// combineResults applies AND semantics to per-limit decisions
+func combineResults(results []CheckResponse) *MultiResponse {
+	resp := &MultiResponse{Allowed: true, Results: results}
+	for i, r := range results {
+		if !r.Allowed {
+			resp.Allowed = false
+			if r.RetryAfter > resp.RetryAfter {
+				resp.RetryAfter = r.RetryAfter
+			}
+		}
+		if i == 0 || r.Remaining < resp.Remaining {
+			resp.Remaining = r.Remaining
+			resp.ResetAt = r.ResetAt
+		} else if r.Remaining == resp.Remaining && r.ResetAt.After(resp.ResetAt) {
+			resp.ResetAt = r.ResetAt
+		}
+	}
+	return resp
+}
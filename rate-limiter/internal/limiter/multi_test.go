@@ -0,0 +1,192 @@
+package limiter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// multiAlgorithms is every algorithm multi.lua can evaluate
+var multiAlgorithms = []string{
+	AlgorithmTokenBucket,
+	AlgorithmSlidingWindow,
+	AlgorithmLeakyBucket,
+	AlgorithmGCRA,
+	AlgorithmSlidingWindowCounter,
+	AlgorithmQuota,
+}
+
+// algorithmRequest is a check on key that allows capacity requests per second
+// (per day for quota)
+func algorithmRequest(algorithm, key string, capacity int64) CheckRequest {
+	req := CheckRequest{Key: key, Algorithm: algorithm, Capacity: capacity}
+	switch algorithm {
+	case AlgorithmTokenBucket, AlgorithmGCRA:
+		req.RefillRate = float64(capacity)
+	case AlgorithmSlidingWindow, AlgorithmSlidingWindowCounter:
+		req.WindowMillis = time.Second.Milliseconds()
+	case AlgorithmLeakyBucket:
+		req.LeakRate = float64(capacity)
+	case AlgorithmQuota:
+		req.Period = PeriodDaily
+	}
+	return req
+}
+
+// newMultiTestLimiter is newTestLimiter with Redis's clock at the test clock's start,
+// which quota's PEXPIREAT is measured against
+func newMultiTestLimiter(t *testing.T) *testLimiter {
+	t.Helper()
+	tl := newTestLimiter(t, nil)
+	tl.redis.SetTime(testStart)
+	return tl
+}
+
+// checkAll runs reqs together and fails the test on an error
+func (tl *testLimiter) checkAll(t *testing.T, reqs ...CheckRequest) *MultiResponse {
+	t.Helper()
+	resp, err := tl.CheckAll(context.Background(), reqs)
+	if err != nil {
+		t.Fatalf("CheckAll: %v", err)
+	}
+	return resp
+}
+
+func TestCheckAllRejectionLeavesOtherLimitsUnconsumed(t *testing.T) {
+	for _, blocking := range multiAlgorithms {
+		for _, other := range multiAlgorithms {
+			if blocking == other {
+				continue
+			}
+			t.Run(blocking+"/"+other, func(t *testing.T) {
+				tl := newMultiTestLimiter(t)
+				full := algorithmRequest(blocking, "full", 2)
+				open := algorithmRequest(other, "open", 3)
+				for i := 0; i < 2; i++ {
+					tl.check(t, full)
+				}
+
+				// Both orders, so the blocking limit is evaluated before and after the other
+				for _, reqs := range [][]CheckRequest{{full, open}, {open, full}} {
+					resp := tl.checkAll(t, reqs...)
+					if resp.Allowed {
+						t.Fatal("multi check allowed with one limit exhausted")
+					}
+					for i, r := range resp.Results {
+						if reqs[i].Key == "full" {
+							if r.Allowed || r.RetryAfter <= 0 {
+								t.Errorf("exhausted limit = %+v, want rejected with a retry after", r)
+							}
+							continue
+						}
+						// Nothing was consumed, so the cost is added back to what's left
+						if !r.Allowed || r.Remaining != 3 {
+							t.Errorf("open limit = %+v, want allowed with all 3 remaining", r)
+						}
+					}
+					if resp.Remaining != 0 {
+						t.Errorf("combined remaining = %d, want 0", resp.Remaining)
+					}
+				}
+
+				for i := 0; i < 3; i++ {
+					if !tl.check(t, open).Allowed {
+						t.Fatalf("check %d on the open limit denied - the rejected multi check consumed it", i+1)
+					}
+				}
+				if tl.check(t, open).Allowed {
+					t.Error("open limit allowed past its capacity of 3")
+				}
+			})
+		}
+	}
+}
+
+func TestCheckAllConsumesEveryLimitWhenAllAllow(t *testing.T) {
+	tl := newMultiTestLimiter(t)
+	reqs := make([]CheckRequest, len(multiAlgorithms))
+	for i, algorithm := range multiAlgorithms {
+		reqs[i] = algorithmRequest(algorithm, algorithm, 3)
+	}
+	reqs[1].Cost = 2
+
+	resp := tl.checkAll(t, reqs...)
+	if !resp.Allowed || resp.Remaining != 1 {
+		t.Fatalf("multi check = allowed %v remaining %d, want allowed with 1 remaining", resp.Allowed, resp.Remaining)
+	}
+	for i, r := range resp.Results {
+		want := 3 - reqs[i].Cost
+		if reqs[i].Cost == 0 {
+			want = 2
+		}
+		if !r.Allowed || r.Remaining != want {
+			t.Errorf("%s = %+v, want allowed with %d remaining", reqs[i].Algorithm, r, want)
+		}
+	}
+}
+
+func TestCheckAllRejectsUnsupportedOptions(t *testing.T) {
+	tl := newMultiTestLimiter(t)
+	base := tokenBucketRequest("user:1", 5, 1)
+
+	tests := []struct {
+		name  string
+		edit  func(req *CheckRequest)
+		other string
+		want  string
+	}{
+		{"dry run", func(req *CheckRequest) { req.DryRun = true }, "user:2", "dry_run"},
+		{"penalty", func(req *CheckRequest) { req.PenaltyBase = time.Second }, "user:2", "penalties"},
+		{"request id", func(req *CheckRequest) { req.RequestID = "req-1" }, "user:2", "request_id"},
+		{"repeated key", func(req *CheckRequest) {}, "user:1", "more than one limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base
+			tt.edit(&req)
+			_, err := tl.CheckAll(context.Background(), []CheckRequest{tokenBucketRequest(tt.other, 5, 1), req})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckAll error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+	if keys := tl.redis.Keys(); len(keys) != 0 {
+		t.Errorf("rejected checks wrote %v", keys)
+	}
+}
+
+// TestMultiEvaluatorsMatchStandaloneScripts replays the same requests against each
+// algorithm's own script and its multi.lua evaluator, on keys with identical histories
+func TestMultiEvaluatorsMatchStandaloneScripts(t *testing.T) {
+	steps := []struct {
+		after time.Duration
+		cost  int64
+	}{
+		{0, 1}, {100 * time.Millisecond, 1}, {50 * time.Millisecond, 2}, {200 * time.Millisecond, 1},
+		{300 * time.Millisecond, 3}, {700 * time.Millisecond, 1}, {0, 1}, {1500 * time.Millisecond, 2},
+		{10 * time.Millisecond, 4}, {250 * time.Millisecond, 1},
+	}
+
+	for _, algorithm := range multiAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			tl := newMultiTestLimiter(t)
+			single := algorithmRequest(algorithm, "single", 4)
+			multi := algorithmRequest(algorithm, "multi", 4)
+
+			for i, step := range steps {
+				tl.advance(step.after)
+				single.Cost, multi.Cost = step.cost, step.cost
+
+				want := tl.check(t, single)
+				got := tl.checkAll(t, multi).Results[0]
+				if got.Allowed != want.Allowed || got.Remaining != want.Remaining ||
+					got.RetryAfter != want.RetryAfter || !got.ResetAt.Equal(want.ResetAt) {
+					t.Errorf("step %d (cost %d): multi = allowed %v remaining %d retry %v reset %v, standalone = allowed %v remaining %d retry %v reset %v",
+						i+1, step.cost, got.Allowed, got.Remaining, got.RetryAfter, got.ResetAt,
+						want.Allowed, want.Remaining, want.RetryAfter, want.ResetAt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package limiter
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestKeysArePrefixedAndNamespaced(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.RedisKeyPrefix = "rl"
+	})
+
+	bucket := tokenBucketRequest("user:1", 5, 1)
+	bucket.Namespace = "billing"
+	tl.check(t, bucket)
+
+	window := slidingWindowRequest("user:2", 5, time.Minute)
+	window.Namespace = "billing"
+	tl.check(t, window)
+
+	plain := tokenBucketRequest("user:3", 5, 1)
+	tl.check(t, plain)
+
+	// The sliding window's counter carries the whole prefixed key in its hash tag
+	want := []string{
+		"rl:billing:user:1",
+		"rl:billing:user:2",
+		"rl:user:3",
+		"{rl:billing:user:2}:counter",
+	}
+	got := tl.redis.Keys()
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestNamespacesKeepSameKeysApart(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	teamA := tokenBucketRequest("user:1", 1, 1)
+	teamA.Namespace = "team-a"
+	teamB := teamA
+	teamB.Namespace = "team-b"
+
+	if !tl.check(t, teamA).Allowed {
+		t.Fatal("team-a's first check blocked")
+	}
+	if tl.check(t, teamA).Allowed {
+		t.Fatal("team-a's second check allowed, want its bucket empty")
+	}
+	if !tl.check(t, teamB).Allowed {
+		t.Error("team-b blocked by team-a's bucket")
+	}
+
+	// Peeks read the same namespaced key the checks wrote
+	peek, err := tl.Peek(context.Background(), teamB)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if peek.Remaining != 0 {
+		t.Errorf("peeked remaining = %d, want team-b's 0", peek.Remaining)
+	}
+}
+
+func TestResetByNamespaceClearsCompanionKeys(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.RedisKeyPrefix = "rl"
+	})
+	window := slidingWindowRequest("user:1", 5, time.Minute)
+	window.Namespace = "billing"
+	tl.check(t, window)
+	other := window
+	other.Namespace = "billing2"
+	tl.check(t, other)
+
+	deleted, err := tl.ResetByPrefix(context.Background(), tl.NamespacePrefix("billing"))
+	if err != nil {
+		t.Fatalf("ResetByPrefix: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d keys, want the window and its counter", deleted)
+	}
+	if got := tl.redis.Keys(); len(got) != 2 || tl.redis.Exists("rl:billing:user:1") {
+		t.Errorf("keys left = %v, want only billing2's", got)
+	}
+}
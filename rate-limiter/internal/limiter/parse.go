@@ -0,0 +1,115 @@
+package limiter
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// parseCheckResult decodes the {allowed, remaining, retry_after_ms, reset_ms} reply every algorithm script returns
+// A script without reset_ms (e.g. an older LUA_DIR override) still parses, with ResetAt left zero.
+// Scripts wrapped with a penalty (see withPenalty) append penalty_until_ms, and a precise
+// token bucket appends its unfloored token count after that
+func parseCheckResult(result interface{}) (*CheckResponse, error) {
+	resultSlice, ok := result.([]interface{})
+	if !ok || len(resultSlice) < 3 || len(resultSlice) > 6 {
+		return nil, errors.New("unexpected response format from Lua script")
+	}
+
+	allowedInt, ok1 := toInt64(resultSlice[0])
+	remainingInt, ok2 := toInt64(resultSlice[1])
+	retryAfterMs, ok3 := toInt64(resultSlice[2])
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errors.New("failed to parse Lua script response")
+	}
+
+	resp := &CheckResponse{
+		Allowed:    allowedInt == 1,
+		Remaining:  remainingInt,
+		RetryAfter: millisDuration(retryAfterMs),
+
+		RemainingFloat: float64(remainingInt),
+	}
+	if len(resultSlice) >= 4 {
+		resetMs, ok := toInt64(resultSlice[3])
+		if !ok {
+			return nil, errors.New("failed to parse Lua script response")
+		}
+		resp.ResetAt = time.UnixMilli(resetMs)
+	}
+	if len(resultSlice) >= 5 {
+		penaltyUntilMs, ok := toInt64(resultSlice[4])
+		if !ok {
+			return nil, errors.New("failed to parse Lua script response")
+		}
+		if penaltyUntilMs > 0 {
+			resp.PenaltyUntil = time.UnixMilli(penaltyUntilMs)
+		}
+	}
+	if len(resultSlice) == 6 {
+		remaining, ok := toFloat64(resultSlice[5])
+		if !ok {
+			return nil, errors.New("failed to parse Lua script response")
+		}
+		resp.RemainingFloat = remaining
+	}
+	return resp, nil
+}
+
+// millisDuration converts a script's milliseconds, saturating where a Duration (~292 years)
+// would overflow into a negative wait
+func millisDuration(ms int64) time.Duration {
+	if ms > int64(math.MaxInt64/time.Millisecond) {
+		return math.MaxInt64
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// toInt64 accepts the numeric forms a script reply can arrive in
+// Redis truncates Lua numbers to integers, but RESP3 doubles and string replies
+// show up depending on the go-redis version and protocol, so take those too
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, false
+		}
+		return int64(math.Floor(n)), true
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i, true
+		}
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, false
+		}
+		return int64(math.Floor(f)), true
+	}
+	return 0, false
+}
+
+// toFloat64 accepts a precise number, which scripts send as a string so Redis won't truncate it
+func toFloat64(v interface{}) (float64, bool) {
+	var f float64
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		f = n
+	case string:
+		parsed, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, false
+		}
+		f = parsed
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
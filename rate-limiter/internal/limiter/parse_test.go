@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestToInt64AcceptsEveryReplyForm(t *testing.T) {
+	tests := []struct {
+		in     interface{}
+		want   int64
+		wantOK bool
+	}{
+		{int64(7), 7, true},
+		{int64(-3), -3, true},
+		{float64(7), 7, true},
+		{7.9, 7, true}, // floored like the script's math.floor
+		{"7", 7, true},
+		{"7.5", 7, true},
+		{"-1.5", -2, true},
+		{"9007199254740993", 9007199254740993, true}, // integers in strings stay exact
+		{math.NaN(), 0, false},
+		{math.Inf(1), 0, false},
+		{"Inf", 0, false},
+		{"seven", 0, false},
+		{"", 0, false},
+		{nil, 0, false},
+		{int(7), 0, false},
+		{[]byte("7"), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := toInt64(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("toInt64(%#v) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseCheckResultAcceptsMixedForms(t *testing.T) {
+	// RESP3 doubles and string replies alongside plain integers
+	resp, err := parseCheckResult([]interface{}{int64(1), 4.0, "250", "1767225600000"})
+	if err != nil {
+		t.Fatalf("parseCheckResult: %v", err)
+	}
+	if !resp.Allowed || resp.Remaining != 4 || resp.RetryAfter != 250*time.Millisecond {
+		t.Errorf("parsed %+v, want allowed, 4 remaining, 250ms retry", resp)
+	}
+	if !resp.ResetAt.Equal(testStart) {
+		t.Errorf("ResetAt = %v, want %v", resp.ResetAt, testStart)
+	}
+
+	// Replies without reset_ms (older LUA_DIR overrides) still parse
+	if resp, err := parseCheckResult([]interface{}{int64(0), int64(0), int64(1000)}); err != nil || !resp.ResetAt.IsZero() {
+		t.Errorf("three-element reply = %+v, %v, want parsed with no ResetAt", resp, err)
+	}
+}
+
+func TestParseCheckResultRejectsMalformedReplies(t *testing.T) {
+	for _, reply := range []interface{}{
+		"1",
+		[]interface{}{int64(1), int64(2)},
+		[]interface{}{int64(1), "many", int64(0)},
+		[]interface{}{int64(1), int64(2), int64(0), "soon"},
+		[]interface{}{int64(1), int64(2), int64(0), int64(0), int64(0), int64(0), int64(0)},
+	} {
+		if _, err := parseCheckResult(reply); err == nil {
+			t.Errorf("parseCheckResult(%#v) succeeded, want an error", reply)
+		}
+	}
+}
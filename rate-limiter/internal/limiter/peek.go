@@ -0,0 +1,108 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// PeekResponse is the current state of a limit, read without consuming from it
+type PeekResponse struct {
+	Remaining int64
+
+	// ResetAfter is how long until the limit is fully replenished (0 if it already is)
+	ResetAfter time.Duration
+
+	// Decisions is the key's allow/deny counts - nil when DECISION_COUNTS_TTL is off
+	Decisions *DecisionCounts
+}
+
+// Peek reports how much of a limit is left without counting a request against it
+// Meant for dashboards - unlike Check it doesn't fail open, since there's no decision to make
+func (l *Limiter) Peek(ctx context.Context, req CheckRequest) (*PeekResponse, error) {
+	key, err := l.callerKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if req.Capacity <= 0 {
+		return nil, errors.New("capacity must be positive")
+	}
+	req.Key = l.redisKey(req.Namespace, key)
+	// Report against the capacity checks are currently held to
+	if l.adaptive != nil {
+		req = l.adaptive.apply(req)
+	}
+
+	if req.NowMillis > 0 {
+		ctx = utils.WithNowMillis(ctx, req.NowMillis)
+	}
+
+	var script string
+	var args []interface{}
+	switch req.Algorithm {
+	case AlgorithmTokenBucket:
+		script = l.scripts.tokenBucketPeek
+		args = []interface{}{req.Capacity, req.RefillRate, scriptNow(ctx, l.clock)}
+
+	case AlgorithmSlidingWindow:
+		script = l.scripts.slidingWindowPeek
+		args = []interface{}{req.Capacity, req.WindowMillis, scriptNow(ctx, l.clock)}
+
+	case AlgorithmLeakyBucket:
+		script = l.scripts.leakyBucketPeek
+		args = []interface{}{req.Capacity, req.LeakRate, scriptNow(ctx, l.clock)}
+
+	case AlgorithmGCRA:
+		script = l.scripts.gcraPeek
+		args = []interface{}{req.Capacity, req.RefillRate, scriptNow(ctx, l.clock)}
+
+	case AlgorithmSlidingWindowCounter:
+		script = l.scripts.slidingWindowCounterPeek
+		args = []interface{}{req.Capacity, req.WindowMillis, scriptNow(ctx, l.clock)}
+
+	case AlgorithmQuota:
+		// The counter for the current period is its own key
+		now := utils.NowMillisCtx(ctx, l.clock)
+		key, resetMs, err := l.quota.periodKey(req.Key, req.Period, now)
+		if err != nil {
+			return nil, err
+		}
+		req.Key = key
+		script = l.scripts.quotaPeek
+		args = []interface{}{req.Capacity, resetMs, now}
+
+	default:
+		return nil, unsupportedAlgorithm(req.Algorithm)
+	}
+
+	result, err := l.redis.EvalLua(ctx, script, []string{req.Key}, args...)
+	if err != nil {
+		return nil, fmt.Errorf("peek failed: %w", err)
+	}
+
+	// Parse Lua response: {remaining, reset_after_ms}
+	resultSlice, ok := result.([]interface{})
+	if !ok || len(resultSlice) != 2 {
+		return nil, errors.New("unexpected response format from Lua script")
+	}
+
+	remaining, ok1 := toInt64(resultSlice[0])
+	resetAfterMs, ok2 := toInt64(resultSlice[1])
+	if !ok1 || !ok2 {
+		return nil, errors.New("failed to parse Lua script response")
+	}
+
+	decisions, err := l.decisionCounts(ctx, req.Key)
+	if err != nil {
+		return nil, fmt.Errorf("peek failed: %w", err)
+	}
+
+	return &PeekResponse{
+		Remaining:  remaining,
+		ResetAfter: millisDuration(resetAfterMs),
+		Decisions:  decisions,
+	}, nil
+}
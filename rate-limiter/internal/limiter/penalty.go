@@ -0,0 +1,62 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// withPenalty wraps an algorithm's script call with the repeat-offender penalty
+// The algorithm's script becomes a function the penalty calls, so serving a penalty,
+// checking the limit and recording a strike all happen in one atomic script
+func (l *Limiter) withPenalty(ctx context.Context, call redisclient.ScriptCall, req CheckRequest) (redisclient.ScriptCall, error) {
+	if req.PenaltyMax < req.PenaltyBase {
+		return redisclient.ScriptCall{}, errors.New("penalty max must be at least the penalty base")
+	}
+	// A retried request id must get its first decision back, not another strike
+	if req.RequestID != "" {
+		return redisclient.ScriptCall{}, errors.New("request_id cannot be combined with penalties")
+	}
+	// A ReloadScripts just adds entries for the new sources
+	script, ok := l.scripts.penalized.Load(call.Script)
+	if !ok {
+		script, _ = l.scripts.penalized.LoadOrStore(call.Script,
+			"local function algorithm_check()\n"+call.Script+"\nend\n"+l.scripts.penalty)
+	}
+
+	// The penalty state goes last, after whatever keys the algorithm's script reads
+	keys := make([]string, 0, len(call.Keys)+1)
+	keys = append(keys, call.Keys...)
+	keys = append(keys, companionKey(call.Keys[0], "penalty"))
+
+	args := make([]interface{}, 0, len(call.Args)+4)
+	args = append(args, call.Args...)
+	args = append(args, req.PenaltyBase.Milliseconds(), req.PenaltyMax.Milliseconds(), scriptNow(ctx, l.clock), dryRunArg(req.DryRun))
+
+	return redisclient.ScriptCall{Script: script.(string), Keys: keys, Args: args}, nil
+}
+
+// checkPrepared runs a check through the shared prepare/finish path, for checks whose
+// script prepare wraps (a penalty, decision counts)
+func (l *Limiter) checkPrepared(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	start := time.Now()
+	defer func() {
+		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
+		l.metrics.ObserveVec(l.metrics.CheckLatency, latencyMs, req.Algorithm)
+	}()
+
+	call, finish, err := l.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	redisStart := time.Now()
+	result, err := l.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	l.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
@@ -0,0 +1,43 @@
+package limiter
+
+import "hash/fnv"
+
+// Policy names reported back when a check runs under an A/B experiment
+const (
+	PolicyControl    = "control"
+	PolicyExperiment = "experiment"
+)
+
+// SelectPolicy deterministically routes a key to the control or experiment policy
+// The key is hashed into [0, 1) so the same key always lands on the same side,
+// and roughly `weight` of all keys land on the experiment side.
+func SelectPolicy(key string, weight float64) string {
+	if weight <= 0 {
+		return PolicyControl
+	}
+	if weight >= 1 {
+		return PolicyExperiment
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// Top 53 bits give an evenly distributed float64 in [0, 1) - once mixed, since FNV
+	// leaves the top bits of keys that differ only at the end (user:1, user:2) close together
+	bucket := float64(mix64(h.Sum64())>>11) / (1 << 53)
+
+	if bucket < weight {
+		return PolicyExperiment
+	}
+	return PolicyControl
+}
+
+// mix64 is MurmurHash3's 64-bit finalizer: every input bit flips each output bit
+// with probability about one half
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
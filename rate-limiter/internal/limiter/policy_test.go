@@ -0,0 +1,56 @@
+package limiter
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSelectPolicy(t *testing.T) {
+	const keys = 10000
+	tests := []struct {
+		weight    float64
+		tolerance float64 // allowed gap between the experiment share and weight
+	}{
+		{0, 0},
+		{0.01, 0.005},
+		{0.1, 0.01},
+		{0.25, 0.015},
+		{0.5, 0.02},
+		{0.9, 0.01},
+		{1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.weight), func(t *testing.T) {
+			experiment := 0
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("user:%d", i)
+				policy := SelectPolicy(key, tt.weight)
+				if again := SelectPolicy(key, tt.weight); again != policy {
+					t.Fatalf("%s routed to %s, then %s", key, policy, again)
+				}
+				switch policy {
+				case PolicyExperiment:
+					experiment++
+				case PolicyControl:
+				default:
+					t.Fatalf("%s routed to unknown policy %q", key, policy)
+				}
+			}
+			share := float64(experiment) / keys
+			if math.Abs(share-tt.weight) > tt.tolerance {
+				t.Errorf("experiment share = %.4f, want %v ± %v", share, tt.weight, tt.tolerance)
+			}
+		})
+	}
+}
+
+func TestSelectPolicyKeepsKeysAsWeightGrows(t *testing.T) {
+	// A key already in the experiment stays there when more traffic is moved over
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		if SelectPolicy(key, 0.2) == PolicyExperiment && SelectPolicy(key, 0.5) != PolicyExperiment {
+			t.Fatalf("%s left the experiment when its weight went from 0.2 to 0.5", key)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// Quota periods - when a quota's counter starts again from zero
+const (
+	PeriodDaily   = "daily"   // at midnight
+	PeriodMonthly = "monthly" // at midnight on the 1st
+)
+
+// IsPeriod reports whether p is a supported quota period
+func IsPeriod(p string) bool {
+	return p == PeriodDaily || p == PeriodMonthly
+}
+
+// QuotaLimiter enforces absolute caps per calendar period (e.g. 10000 requests a day)
+// Each period gets its own counter key that expires at the period's end, so nothing
+// ever has to reset it. Boundaries are midnight in loc (QUOTA_TIMEZONE)
+type QuotaLimiter struct {
+	redis   *redisclient.Client
+	failure *FailurePolicy
+	metrics *metrics.Metrics
+	fills   *fillTracker
+	loc     *time.Location
+	clock   utils.Clock
+	scripts *scriptSet
+}
+
+func NewQuotaLimiter(redis *redisclient.Client, failure *FailurePolicy, m *metrics.Metrics, fills *fillTracker, loc *time.Location, scripts *scriptSet, clock utils.Clock) *QuotaLimiter {
+	return &QuotaLimiter{redis: redis, failure: failure, metrics: m, fills: fills, loc: loc, scripts: scripts, clock: clock}
+}
+
+// Check determines if a request fits in what's left of the current period
+// capacity: requests allowed per period
+// period: daily or monthly (req.Period)
+// cost: units this request consumes (req.Cost)
+func (q *QuotaLimiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	start := time.Now()
+	defer func() {
+		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
+		q.metrics.ObserveVec(q.metrics.CheckLatency, latencyMs, "quota")
+	}()
+
+	call, err := q.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute Lua script atomically
+	redisStart := time.Now()
+	result, err := q.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	q.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := q.finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
+
+// prepare validates the parameters and builds the script call for a check
+func (q *QuotaLimiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, error) {
+	capacity, cost := req.Capacity, req.Cost
+
+	if capacity <= 0 {
+		return redisclient.ScriptCall{}, errors.New("capacity must be positive")
+	}
+
+	if cost <= 0 || cost > capacity {
+		return redisclient.ScriptCall{}, errors.New("cost must be positive and no more than capacity")
+	}
+
+	if capacity > MaxSafeInteger {
+		return redisclient.ScriptCall{}, errors.New("capacity exceeds the safe numeric range")
+	}
+
+	now := utils.NowMillisCtx(ctx, q.clock)
+	key, resetMs, err := q.periodKey(req.Key, req.Period, now)
+	if err != nil {
+		return redisclient.ScriptCall{}, err
+	}
+
+	return redisclient.ScriptCall{
+		Script: *q.scripts.quota.Load(),
+		Keys:   []string{key},
+		Args:   []interface{}{capacity, resetMs, now, cost, dryRunArg(req.DryRun)},
+	}, nil
+}
+
+// periodKey is the counter key for the period containing nowMs, and when that period ends
+func (q *QuotaLimiter) periodKey(key, period string, nowMs int64) (string, int64, error) {
+	now := time.UnixMilli(nowMs).In(q.loc)
+	end, err := periodEnd(period, now)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Each period's counter is its own key, named after the day (or month) it counts
+	label := now.Format("2006-01-02")
+	if period == PeriodMonthly {
+		label = now.Format("2006-01")
+	}
+	return key + ":" + label, end.UnixMilli(), nil
+}
+
+// periodEnd returns when the period containing now ends, in now's location
+// time.Date normalizes wall clock times, so days around a DST change come out 23 or 25
+// hours long rather than ending an hour off midnight
+func periodEnd(period string, now time.Time) (time.Time, error) {
+	year, month, day := now.Date()
+	loc := now.Location()
+
+	switch period {
+	case PeriodDaily:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, loc), nil
+	case PeriodMonthly:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, loc), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported period %q (supported: %s, %s)", period, PeriodDaily, PeriodMonthly)
+}
+
+// finish turns the script reply (or error) into a CheckResponse and records metrics
+// req.Tier must already be normalized (see Limiter.tierLabel) to keep cardinality bounded
+func (q *QuotaLimiter) finish(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error) {
+	if err != nil {
+		var failOpenErr *redisclient.FailOpenError
+		if errors.As(err, &failOpenErr) {
+			q.metrics.RedisErrors.Inc()
+			// Redis couldn't decide - FAIL_MODE picks open, closed or a local limit
+			return q.failure.Decide(ctx, req, err)
+		}
+		return nil, fmt.Errorf("quota check failed: %w", err)
+	}
+
+	resp, err := parseCheckResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Allowed {
+		q.metrics.IncCounter(q.metrics.RequestsAllowed, "quota", req.Tier, dryRunLabel(req.DryRun))
+	} else {
+		q.metrics.IncCounter(q.metrics.RequestsBlocked, "quota", req.Tier, dryRunLabel(req.DryRun))
+	}
+	if req.DryRun {
+		return dryRunResponse(resp), nil
+	}
+	q.fills.record("quota", resp.Remaining, req.Capacity)
+
+	return resp, nil
+}
+
+// periodSeconds is the nominal length of a period, for the local fallback's rate
+func periodSeconds(period string) float64 {
+	if period == PeriodMonthly {
+		return 30 * 24 * 60 * 60
+	}
+	return 24 * 60 * 60
+}
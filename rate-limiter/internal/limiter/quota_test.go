@@ -0,0 +1,140 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestPeriodEndAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	tests := []struct {
+		name    string
+		period  string
+		now     time.Time
+		want    time.Time
+		wantLen time.Duration // from the start of the period to its end
+	}{
+		{
+			name:    "ordinary day",
+			period:  PeriodDaily,
+			now:     time.Date(2026, 3, 7, 12, 0, 0, 0, ny),
+			want:    time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+			wantLen: 24 * time.Hour,
+		},
+		{
+			name:    "spring forward day is 23 hours",
+			period:  PeriodDaily,
+			now:     time.Date(2026, 3, 8, 12, 0, 0, 0, ny),
+			want:    time.Date(2026, 3, 9, 0, 0, 0, 0, ny),
+			wantLen: 23 * time.Hour,
+		},
+		{
+			name:    "fall back day is 25 hours",
+			period:  PeriodDaily,
+			now:     time.Date(2026, 11, 1, 1, 30, 0, 0, ny),
+			want:    time.Date(2026, 11, 2, 0, 0, 0, 0, ny),
+			wantLen: 25 * time.Hour,
+		},
+		{
+			name:    "last second of the day",
+			period:  PeriodDaily,
+			now:     time.Date(2026, 3, 8, 23, 59, 59, 0, ny),
+			want:    time.Date(2026, 3, 9, 0, 0, 0, 0, ny),
+			wantLen: 23 * time.Hour,
+		},
+		{
+			name:    "month losing an hour",
+			period:  PeriodMonthly,
+			now:     time.Date(2026, 3, 15, 0, 0, 0, 0, ny),
+			want:    time.Date(2026, 4, 1, 0, 0, 0, 0, ny),
+			wantLen: 31*24*time.Hour - time.Hour,
+		},
+		{
+			name:    "month gaining an hour",
+			period:  PeriodMonthly,
+			now:     time.Date(2026, 11, 30, 23, 0, 0, 0, ny),
+			want:    time.Date(2026, 12, 1, 0, 0, 0, 0, ny),
+			wantLen: 30*24*time.Hour + time.Hour,
+		},
+		{
+			name:    "december rolls into the next year",
+			period:  PeriodMonthly,
+			now:     time.Date(2026, 12, 31, 18, 0, 0, 0, ny),
+			want:    time.Date(2027, 1, 1, 0, 0, 0, 0, ny),
+			wantLen: 31 * 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, err := periodEnd(tt.period, tt.now)
+			if err != nil {
+				t.Fatalf("periodEnd: %v", err)
+			}
+			if !end.Equal(tt.want) {
+				t.Errorf("end = %v, want %v", end, tt.want)
+			}
+			// Midnight local time, whatever the offset
+			if h, m, s := end.Clock(); h != 0 || m != 0 || s != 0 {
+				t.Errorf("end = %v, want midnight", end)
+			}
+
+			year, month, day := tt.now.Date()
+			start := time.Date(year, month, day, 0, 0, 0, 0, ny)
+			if tt.period == PeriodMonthly {
+				start = time.Date(year, month, 1, 0, 0, 0, 0, ny)
+			}
+			if got := end.Sub(start); got != tt.wantLen {
+				t.Errorf("period lasts %v, want %v", got, tt.wantLen)
+			}
+		})
+	}
+
+	if _, err := periodEnd("weekly", time.Now()); err == nil {
+		t.Error("periodEnd accepted an unsupported period")
+	}
+}
+
+func TestQuotaResetsAtLocalMidnight(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.QuotaTimezone = "America/New_York"
+	})
+	// 11pm on the spring forward day - an hour before the quota starts again
+	now := time.Date(2026, 3, 8, 23, 0, 0, 0, ny)
+	tl.clock.Set(now)
+	tl.redis.SetTime(now) // PEXPIREAT is measured against Redis's clock
+	midnight := time.Date(2026, 3, 9, 0, 0, 0, 0, ny)
+
+	req := CheckRequest{Key: "user:1", Algorithm: AlgorithmQuota, Capacity: 2, Period: PeriodDaily}
+	tl.check(t, req)
+	tl.check(t, req)
+	resp := tl.check(t, req)
+	if resp.Allowed {
+		t.Fatal("check over the daily quota allowed")
+	}
+	if resp.RetryAfter != time.Hour || !resp.ResetAt.Equal(midnight) {
+		t.Errorf("blocked response = %+v, want a retry in 1h at %v", resp, midnight)
+	}
+
+	// The day's counter is named for the local date and expires at local midnight
+	key := "user:1:2026-03-08"
+	if got := tl.redis.TTL(key); got != time.Hour {
+		t.Errorf("TTL of %s = %v, want 1h (keys %v)", key, got, tl.redis.Keys())
+	}
+
+	tl.advance(time.Hour)
+	if resp := tl.check(t, req); !resp.Allowed || resp.Remaining != 1 {
+		t.Errorf("first check of the new day = %+v, want allowed with 1 remaining", resp)
+	}
+	if !tl.redis.Exists("user:1:2026-03-09") {
+		t.Errorf("keys = %v, want a counter for 2026-03-09", tl.redis.Keys())
+	}
+}
@@ -0,0 +1,80 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestChecksDuringReconnectFollowFailMode(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close() // Redis is down when the service starts
+
+	cfg := config.Load()
+	cfg.RedisAddr = addr
+	cfg.RedisKeyPrefix = ""
+	cfg.CircuitBreakerThreshold = 0 // every check should see the client itself, not the breaker
+	cfg.RedisTimeout = time.Second  // the reconnect's ping races the checking goroutines for CPU
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	m := metrics.New(prometheus.NewRegistry(), nil, nil)
+	client := redisclient.NewDisconnectedClient(cfg, m)
+	t.Cleanup(func() { client.Close() })
+	l := NewLimiterWithClock(client, cfg, m, utils.NewManualClock(testStart))
+	ctx := context.Background()
+
+	// Until a connection is published, each check gets its fail mode's decision
+	req := tokenBucketRequest("user:1", 1, 1)
+	req.FailMode = config.FailModeOpen
+	if resp, err := l.Check(ctx, req); err != nil || !resp.Allowed || !resp.Degraded {
+		t.Errorf("fail open while disconnected = %+v, %v, want a degraded allow", resp, err)
+	}
+	req.FailMode = config.FailModeClosed
+	if resp, err := l.Check(ctx, req); err != nil || resp.Allowed || !resp.Degraded {
+		t.Errorf("fail closed while disconnected = %+v, %v, want a degraded deny", resp, err)
+	}
+	req.FailMode = config.FailModeError
+	if _, err := l.Check(ctx, req); !errors.Is(err, ErrRedisUnavailable) {
+		t.Errorf("fail error while disconnected: err = %v, want ErrRedisUnavailable", err)
+	}
+
+	// Checks keep running while the connection is swapped in underneath them
+	if err := mr.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	reconnectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	go client.Reconnect(reconnectCtx, 10*time.Millisecond)
+
+	req.FailMode = config.FailModeOpen
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !client.Ready() {
+				if _, err := l.Check(ctx, req); err != nil {
+					t.Errorf("check during reconnect: %v", err)
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if resp, err := l.Check(ctx, req); err != nil || resp.Degraded {
+		t.Errorf("check after reconnect = %+v, %v, want a decision from Redis", resp, err)
+	}
+}
@@ -0,0 +1,85 @@
+package limiter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// RedisClock is the local clock corrected by its offset from Redis's TIME
+// Scripts don't use it - under CLOCK_SOURCE=redis they read TIME themselves (see scriptNow).
+// It's for the times decided here: quota periods, the deny cache and the local fallback,
+// which would otherwise drift from the scripts' "now" by however far off this machine is
+type RedisClock struct {
+	redis    *redisclient.Client
+	offsetMs atomic.Int64
+}
+
+func NewRedisClock(redis *redisclient.Client) *RedisClock {
+	return &RedisClock{redis: redis}
+}
+
+// NowMillis is the local time plus the last measured offset (0 until the first Sync)
+func (rc *RedisClock) NowMillis() int64 {
+	return utils.NowMillis() + rc.offsetMs.Load()
+}
+
+// Offset is how far ahead of the local clock Redis was at the last Sync
+func (rc *RedisClock) Offset() time.Duration {
+	return time.Duration(rc.offsetMs.Load()) * time.Millisecond
+}
+
+// Sync measures the offset from Redis TIME
+// The reply is assumed to have been read halfway through the round trip
+func (rc *RedisClock) Sync(ctx context.Context) error {
+	sent := time.Now()
+	redisNow, err := rc.redis.Time(ctx)
+	if err != nil {
+		return err
+	}
+	localNow := sent.Add(time.Since(sent) / 2)
+	rc.offsetMs.Store(redisNow.Sub(localNow).Milliseconds())
+	return nil
+}
+
+// Run re-measures the offset every interval until ctx is done
+// While Redis is unreachable the last offset stays in effect
+func (rc *RedisClock) Run(ctx context.Context, interval time.Duration) {
+	if err := rc.Sync(ctx); err != nil {
+		logging.Printf("clock not synced with Redis yet: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Only log an outage once rather than on every tick until it's over
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := rc.Sync(ctx)
+			if err != nil && !failing {
+				logging.Printf("clock sync with Redis failed, keeping the last offset: %v", err)
+			}
+			failing = err != nil
+		}
+	}
+}
+
+// scriptNow is the now argument for a script. Under CLOCK_SOURCE=redis it's left empty so
+// the script reads Redis TIME itself, and every instance refills the same key from the
+// same clock; a check that pinned its own time (now_ms) still sends it
+func scriptNow(ctx context.Context, clock utils.Clock) interface{} {
+	if _, ok := clock.(*RedisClock); ok {
+		if _, pinned := utils.PinnedNowMillis(ctx); !pinned {
+			return ""
+		}
+	}
+	return utils.NowMillisCtx(ctx, clock)
+}
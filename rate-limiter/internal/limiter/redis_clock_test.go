@@ -0,0 +1,111 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newInstance builds a Limiter on mr with its own client and clock, the way each
+// service instance in a deployment has its own. clock nil means a RedisClock
+func newInstance(t *testing.T, mr *miniredis.Miniredis, clock utils.Clock) (*Limiter, *RedisClock) {
+	t.Helper()
+	cfg := config.Load()
+	cfg.RedisAddr = mr.Addr()
+	cfg.RedisKeyPrefix = ""
+	cfg.RedisTimeout = time.Second
+	m := metrics.New(prometheus.NewRegistry(), nil, nil)
+	client, err := redisclient.NewClient(cfg, m)
+	if err != nil {
+		t.Fatalf("redis client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	var rc *RedisClock
+	if clock == nil {
+		rc = NewRedisClock(client)
+		clock = rc
+	}
+	return NewLimiterWithClock(client, cfg, m, clock), rc
+}
+
+func TestSkewedInstancesDisagreeOnLocalClocks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a, _ := newInstance(t, mr, utils.NewManualClock(testStart))
+	b, _ := newInstance(t, mr, utils.NewManualClock(testStart.Add(time.Minute))) // a minute fast
+	req := tokenBucketRequest("user:1", 2, 1.0/60)                               // a token a minute
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if resp, err := a.Check(ctx, req); err != nil || !resp.Allowed {
+			t.Fatalf("a's check %d = %+v, %v, want allowed", i+1, resp, err)
+		}
+	}
+	// With CLOCK_SOURCE=local, b's fast clock refills the bucket a just emptied
+	if resp, err := b.Check(ctx, req); err != nil || !resp.Allowed {
+		t.Fatalf("b's check = %+v, %v - expected the skew to let it through", resp, err)
+	}
+}
+
+func TestSkewedInstancesAgreeOnRedisTime(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(testStart)
+	a, clockA := newInstance(t, mr, nil)
+	b, clockB := newInstance(t, mr, nil)
+	ctx := context.Background()
+
+	// a has measured its offset from Redis and b hasn't, so their clocks are far apart
+	if err := clockA.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if skew := clockB.NowMillis() - clockA.NowMillis(); skew < time.Hour.Milliseconds() {
+		t.Fatalf("instances only %dms apart, want a large skew", skew)
+	}
+
+	req := tokenBucketRequest("user:1", 2, 1.0/60)
+	for i := 0; i < 2; i++ {
+		if resp, err := a.Check(ctx, req); err != nil || !resp.Allowed {
+			t.Fatalf("a's check %d = %+v, %v, want allowed", i+1, resp, err)
+		}
+	}
+
+	// Both scripts read Redis TIME, so b sees the same empty bucket a left
+	resp, err := b.Check(ctx, req)
+	if err != nil {
+		t.Fatalf("b's check: %v", err)
+	}
+	if resp.Allowed {
+		t.Fatal("b's check allowed, want the bucket a emptied")
+	}
+	if next := testStart.Add(time.Minute); resp.RetryAfter != time.Minute || !resp.ResetAt.Equal(next) {
+		t.Errorf("b's response = %+v, want the next token in 1m, at %v on Redis's clock", resp, next)
+	}
+
+	// Once Redis's clock moves on a token is back, whichever instance asks
+	mr.SetTime(testStart.Add(time.Minute))
+	if resp, err := b.Check(ctx, req); err != nil || !resp.Allowed {
+		t.Errorf("b's check a minute later by Redis = %+v, %v, want allowed", resp, err)
+	}
+}
+
+func TestScriptNowDefersToRedisTime(t *testing.T) {
+	rc := NewRedisClock(nil)
+	ctx := context.Background()
+
+	if got := scriptNow(ctx, rc); got != "" {
+		t.Errorf("scriptNow with a RedisClock = %v, want empty so the script reads TIME", got)
+	}
+	if got := scriptNow(utils.WithNowMillis(ctx, 1234), rc); got != int64(1234) {
+		t.Errorf("scriptNow with a pinned time = %v, want 1234", got)
+	}
+	if got := scriptNow(ctx, utils.NewManualClock(testStart)); got != testStart.UnixMilli() {
+		t.Errorf("scriptNow with a local clock = %v, want %d", got, testStart.UnixMilli())
+	}
+}
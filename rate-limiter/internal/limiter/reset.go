@@ -0,0 +1,52 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// NamespacePrefix is the key prefix every key in a namespace starts with
+// The trailing ':' keeps "billing" from also matching "billing2"
+func (l *Limiter) NamespacePrefix(namespace string) string {
+	return joinKey(l.keyPrefix, namespace) + ":"
+}
+
+// ResetByPrefix deletes every key starting with prefix and returns how many were removed
+// Keys are found with SCAN (never KEYS, which blocks Redis) and unlinked a page at a time,
+// so a large namespace is cleared in resetScanCount-sized batches. Companion keys of
+// untagged keys start with '{' (see companionKey), so they get a second pass
+func (l *Limiter) ResetByPrefix(ctx context.Context, prefix string) (int64, error) {
+	if prefix == "" {
+		return 0, errors.New("prefix cannot be empty")
+	}
+
+	var deleted int64
+	for _, match := range []string{escapeGlob(prefix) + "*", escapeGlob("{"+prefix) + "*"} {
+		err := l.redis.ScanAll(ctx, match, l.resetScanCount, func(keys []string) error {
+			if len(keys) == 0 {
+				return nil
+			}
+			n, err := l.redis.Unlink(ctx, keys...)
+			deleted += n
+			return err
+		})
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// escapeGlob makes a literal string safe to use as a SCAN MATCH prefix
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package limiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RoutingRule picks the algorithm and limits for checks that don't name an algorithm
+// A rule matches when every condition it sets matches; one with no conditions matches
+// everything, so it's the fallback
+type RoutingRule struct {
+	// KeyPattern matches the caller's key (before namespacing) - '*' matches any run of characters
+	KeyPattern string `json:"key_pattern,omitempty"`
+	Tier       string `json:"tier,omitempty"`
+
+	Algorithm     string  `json:"algorithm"`
+	Capacity      int64   `json:"capacity"`
+	RefillRate    float64 `json:"refill_rate,omitempty"`
+	WindowMillis  int64   `json:"window_ms,omitempty"`
+	WindowSeconds int64   `json:"window_seconds,omitempty"` // converted to window_ms on load
+	LeakRate      float64 `json:"leak_rate,omitempty"`
+	Period        string  `json:"period,omitempty"`
+}
+
+// Router holds the ROUTING_RULES_FILE rules, evaluated in file order
+type Router struct {
+	rules []RoutingRule
+}
+
+// LoadRouter reads a routing rules file - an empty path gives a nil router (no routing)
+// The file is a JSON array of rules, first match wins
+func LoadRouter(path string) (*Router, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read routing rules file: %w", err)
+	}
+
+	var rules []RoutingRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse routing rules file: %w", err)
+	}
+	return NewRouter(rules)
+}
+
+// NewRouter validates rules and builds a router from them
+func NewRouter(rules []RoutingRule) (*Router, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("routing rules file has no rules")
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.WindowMillis == 0 && rule.WindowSeconds > 0 {
+			rule.WindowMillis = rule.WindowSeconds * 1000
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("routing rule %d: %w", i, err)
+		}
+		// Anything after a catch-all could never be reached - almost certainly a mistake
+		if rule.KeyPattern == "" && rule.Tier == "" && i != len(rules)-1 {
+			return nil, fmt.Errorf("routing rule %d matches everything, so the rules after it are unreachable", i)
+		}
+	}
+	return &Router{rules: rules}, nil
+}
+
+// validate makes sure a matched rule alone is enough for a valid check
+func (r RoutingRule) validate() error {
+	if !IsSupported(r.Algorithm) {
+		return fmt.Errorf("unsupported algorithm %q", r.Algorithm)
+	}
+	if r.Capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	switch r.Algorithm {
+	case AlgorithmTokenBucket, AlgorithmGCRA:
+		if r.RefillRate <= 0 {
+			return fmt.Errorf("refill_rate must be positive for %s", r.Algorithm)
+		}
+	case AlgorithmSlidingWindow, AlgorithmSlidingWindowCounter:
+		if r.WindowMillis <= 0 {
+			return fmt.Errorf("window_ms or window_seconds must be positive for %s", r.Algorithm)
+		}
+	case AlgorithmLeakyBucket:
+		if r.LeakRate <= 0 {
+			return fmt.Errorf("leak_rate must be positive for %s", r.Algorithm)
+		}
+	case AlgorithmQuota:
+		if !IsPeriod(r.Period) {
+			return fmt.Errorf("period must be %q or %q for %s", PeriodDaily, PeriodMonthly, r.Algorithm)
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule matching key and tier
+func (rt *Router) Match(key, tier string) (RoutingRule, bool) {
+	for _, rule := range rt.rules {
+		if rule.Tier != "" && rule.Tier != tier {
+			continue
+		}
+		if rule.KeyPattern != "" && !globMatch(rule.KeyPattern, key) {
+			continue
+		}
+		return rule, true
+	}
+	return RoutingRule{}, false
+}
+
+// globMatch reports whether s matches pattern, where '*' matches any run of characters
+// Unlike path.Match, '*' also crosses '/' and ':', which keys are full of
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
+
+// SetRouter installs the routing rules - call it before the limiter starts serving
+func (l *Limiter) SetRouter(router *Router) {
+	l.router = router
+}
+
+// Route returns the rule for a check that left out its algorithm, if any matches
+func (l *Limiter) Route(key, tier string) (RoutingRule, bool) {
+	if l.router == nil {
+		return RoutingRule{}, false
+	}
+	// The API routes raw keys - match them as checks will see them
+	key, err := l.keyNormalizer.NormalizeKey(key)
+	if err != nil {
+		return RoutingRule{}, false
+	}
+	return l.router.Match(key, tier)
+}
+
+// route fills in an algorithm-less request from its rule; fields the caller set are kept
+func (l *Limiter) route(req CheckRequest) CheckRequest {
+	rule, ok := l.Route(req.Key, req.Tier)
+	if !ok {
+		return req
+	}
+	req.Algorithm = rule.Algorithm
+	if req.Capacity == 0 {
+		req.Capacity = rule.Capacity
+	}
+	if req.RefillRate == 0 {
+		req.RefillRate = rule.RefillRate
+	}
+	if req.WindowMillis == 0 {
+		req.WindowMillis = rule.WindowMillis
+	}
+	if req.LeakRate == 0 {
+		req.LeakRate = rule.LeakRate
+	}
+	if req.Period == "" {
+		req.Period = rule.Period
+	}
+	return req
+}
@@ -0,0 +1,286 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"github.com/piyushpatra/rate-limiter/internal/redis/lua"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// scriptValidationKey is a throwaway key used to dry-run candidate scripts
+const scriptValidationKey = "__script_validation__"
+
+// algorithmScriptFiles names each algorithm's check script
+var algorithmScriptFiles = map[string]string{
+	AlgorithmTokenBucket:          "token_bucket.lua",
+	AlgorithmSlidingWindow:        "sliding_window.lua",
+	AlgorithmLeakyBucket:          "leaky_bucket.lua",
+	AlgorithmGCRA:                 "gcra.lua",
+	AlgorithmSlidingWindowCounter: "sliding_window_counter.lua",
+	AlgorithmQuota:                "quota.lua",
+}
+
+// scriptSet is the Lua a Limiter runs, read once when the Limiter is built
+// Each Limiter has its own, so limiters with different LUA_DIRs (or reloads) in one
+// process don't see each other's scripts
+type scriptSet struct {
+	// dir is LUA_DIR - files found there shadow the embedded scripts
+	dir string
+
+	// The check scripts are swapped atomically by ReloadScripts, so read them via Load()
+	tokenBucket          atomic.Pointer[string]
+	slidingWindow        atomic.Pointer[string]
+	leakyBucket          atomic.Pointer[string]
+	gcra                 atomic.Pointer[string]
+	slidingWindowCounter atomic.Pointer[string]
+	quota                atomic.Pointer[string]
+
+	tokenBucketPeek          string
+	slidingWindowPeek        string
+	leakyBucketPeek          string
+	gcraPeek                 string
+	slidingWindowCounterPeek string
+	quotaPeek                string
+
+	multi              string
+	penalty            string
+	decisions          string
+	sourceQuota        string
+	concurrencyAcquire string
+	concurrencyRelease string
+
+	// penalized caches check scripts wrapped with penalty.lua, keyed by the check script
+	penalized sync.Map
+
+	// counted does the same for decisions.lua
+	counted sync.Map
+}
+
+func newScriptSet(dir string) *scriptSet {
+	s := &scriptSet{dir: dir}
+	for name, file := range algorithmScriptFiles {
+		body := s.load(file)
+		s.check(name).Store(&body)
+	}
+
+	s.tokenBucketPeek = s.load("token_bucket_peek.lua")
+	s.slidingWindowPeek = s.load("sliding_window_peek.lua")
+	s.leakyBucketPeek = s.load("leaky_bucket_peek.lua")
+	s.gcraPeek = s.load("gcra_peek.lua")
+	s.slidingWindowCounterPeek = s.load("sliding_window_counter_peek.lua")
+	s.quotaPeek = s.load("quota_peek.lua")
+
+	s.multi = s.load("multi.lua")
+	s.penalty = s.load("penalty.lua")
+	s.decisions = s.load("decisions.lua")
+	s.sourceQuota = s.load("source_quota.lua")
+	s.concurrencyAcquire = s.load("concurrency_acquire.lua")
+	s.concurrencyRelease = s.load("concurrency_release.lua")
+	return s
+}
+
+// check returns where an algorithm's check script is kept
+func (s *scriptSet) check(algorithm string) *atomic.Pointer[string] {
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return &s.tokenBucket
+	case AlgorithmSlidingWindow:
+		return &s.slidingWindow
+	case AlgorithmLeakyBucket:
+		return &s.leakyBucket
+	case AlgorithmGCRA:
+		return &s.gcra
+	case AlgorithmSlidingWindowCounter:
+		return &s.slidingWindowCounter
+	case AlgorithmQuota:
+		return &s.quota
+	}
+	// Callers only pass names from algorithmScriptFiles
+	panic("no script for algorithm " + algorithm)
+}
+
+// all is every script in the set, for loading them into Redis
+func (s *scriptSet) all() []string {
+	scripts := []string{
+		s.tokenBucketPeek,
+		s.slidingWindowPeek,
+		s.leakyBucketPeek,
+		s.gcraPeek,
+		s.slidingWindowCounterPeek,
+		s.quotaPeek,
+		s.multi,
+		s.sourceQuota,
+		s.concurrencyAcquire,
+		s.concurrencyRelease,
+	}
+	for name := range algorithmScriptFiles {
+		scripts = append(scripts, *s.check(name).Load())
+	}
+	return scripts
+}
+
+// read returns a script from LUA_DIR if it's there, otherwise the embedded copy
+func (s *scriptSet) read(name string) (string, error) {
+	if s.dir != "" {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	data, err := lua.Scripts.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// load is read for the constructor, which has no caller to return an error to -
+// a broken override is logged and the embedded script used
+func (s *scriptSet) load(name string) string {
+	script, err := s.read(name)
+	if err == nil {
+		return script
+	}
+	logging.Printf("reading %s from LUA_DIR failed, using the built-in script: %v", name, err)
+
+	data, err := lua.Scripts.ReadFile(name)
+	if err != nil {
+		// Only possible if the name is wrong, which is a programming error
+		panic(err)
+	}
+	return string(data)
+}
+
+// ReloadScripts swaps in new Lua scripts at runtime
+// bodies maps algorithm name to script source; algorithms missing from the map are
+// re-read (from LUA_DIR, or the embedded copy). Every candidate is run against a throwaway key first, and
+// nothing is swapped unless all of them pass - in-flight checks keep using
+// whichever script they loaded, so there's no window with a half-applied reload.
+func (l *Limiter) ReloadScripts(ctx context.Context, bodies map[string]string) error {
+	for name := range bodies {
+		if !IsSupported(name) {
+			return fmt.Errorf("unknown algorithm %q", name)
+		}
+	}
+
+	candidates := make(map[string]string, len(algorithmScriptFiles))
+	for name, file := range algorithmScriptFiles {
+		if body, ok := bodies[name]; ok {
+			candidates[name] = body
+			continue
+		}
+		body, err := l.scripts.read(file)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", file, err)
+		}
+		candidates[name] = body
+	}
+
+	for name, body := range candidates {
+		if err := l.validateScript(ctx, name, body); err != nil {
+			return fmt.Errorf("%s script rejected: %w", name, err)
+		}
+	}
+
+	for name, body := range candidates {
+		body := body
+		l.scripts.check(name).Store(&body)
+
+		// It's just been run, so the readiness probe needn't run it again
+		l.scriptChecks.passed(name, body)
+	}
+	return nil
+}
+
+// CheckScripts runs every algorithm's current script against a throwaway key, for the
+// readiness probe. It returns the algorithms that failed, or nil when all passed - a
+// broken LUA_DIR override or an ACL that forbids EVAL shows up here before real checks.
+// A script is only run until it passes once; after that probes don't touch Redis for it
+// until a reload swaps in a different body
+func (l *Limiter) CheckScripts(ctx context.Context) map[string]error {
+	var failed map[string]error
+	for name := range algorithmScriptFiles {
+		body := *l.scripts.check(name).Load()
+		if l.scriptChecks.known(name, body) {
+			continue
+		}
+		if err := l.validateScript(ctx, name, body); err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[name] = err
+			continue
+		}
+		l.scriptChecks.passed(name, body)
+	}
+	return failed
+}
+
+// scriptChecks remembers which script bodies have already run successfully, per algorithm
+type scriptChecks struct {
+	mu     sync.Mutex
+	bodies map[string]string
+}
+
+// known reports whether body is the last script seen to pass for algorithm
+func (c *scriptChecks) known(algorithm, body string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	passed, ok := c.bodies[algorithm]
+	return ok && passed == body
+}
+
+// passed records that body ran successfully for algorithm
+func (c *scriptChecks) passed(algorithm, body string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bodies == nil {
+		c.bodies = make(map[string]string)
+	}
+	c.bodies[algorithm] = body
+}
+
+// validateScript runs a candidate against a throwaway key and checks the reply shape
+// The key sits under REDIS_KEY_PREFIX like every other key this limiter writes
+func (l *Limiter) validateScript(ctx context.Context, algorithm, body string) error {
+	key := joinKey(l.keyPrefix, scriptValidationKey, algorithm)
+	keys := []string{key, companionKey(key, "counter")}
+	defer l.redis.Del(ctx, keys...)
+
+	// The same now a real check would send, so CLOCK_SOURCE=redis is exercised too
+	now := scriptNow(ctx, l.clock)
+	var args []interface{}
+	switch algorithm {
+	case AlgorithmTokenBucket, AlgorithmLeakyBucket, AlgorithmGCRA:
+		args = []interface{}{10, 1, now, 1}
+	case AlgorithmSlidingWindow, AlgorithmSlidingWindowCounter:
+		args = []interface{}{10, 60000, now, 1}
+	case AlgorithmQuota:
+		// Quota always sends its own time, which it needs for the period key
+		nowMs := utils.NowMillisCtx(ctx, l.clock)
+		args = []interface{}{10, nowMs + 60000, nowMs, 1}
+	}
+
+	result, err := l.redis.EvalLua(ctx, body, keys, args...)
+	if err != nil {
+		return err
+	}
+
+	// Must parse the same way Check replies do: {allowed, remaining, retry_after_ms}
+	if _, err := parseCheckResult(result); err != nil {
+		return fmt.Errorf("%w: %v", err, result)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package limiter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/redis/lua"
+)
+
+// alwaysDeny is a token bucket replacement that blocks everything for a second
+const alwaysDeny = "return {0, 0, 1000, 0}"
+
+func TestReloadScriptsSwapsBehavior(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	ctx := context.Background()
+	req := tokenBucketRequest("user:1", 10, 1)
+
+	if resp := tl.check(t, req); !resp.Allowed {
+		t.Fatal("check before the reload blocked, want allowed")
+	}
+	if err := tl.ReloadScripts(ctx, map[string]string{AlgorithmTokenBucket: alwaysDeny}); err != nil {
+		t.Fatalf("ReloadScripts: %v", err)
+	}
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Error("check after the reload allowed, want the new script's deny")
+	}
+
+	// Other algorithms went back to their own scripts, unchanged
+	if resp := tl.check(t, slidingWindowRequest("user:2", 1, time.Minute)); !resp.Allowed {
+		t.Error("sliding window check after the reload blocked, want allowed")
+	}
+}
+
+func TestReloadScriptsRejectsInvalidScripts(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	ctx := context.Background()
+	req := tokenBucketRequest("user:1", 2, 1)
+
+	tl.check(t, req)
+	for name, body := range map[string]string{
+		"syntax error": "return {",
+		"wrong reply":  "return 'nope'",
+		"error reply":  "return redis.error_reply('broken')",
+		"too short":    "return {1}",
+		"wrong type":   "return {'yes', 'no', 'maybe'}",
+	} {
+		if err := tl.ReloadScripts(ctx, map[string]string{AlgorithmTokenBucket: body}); err == nil {
+			t.Errorf("%s: ReloadScripts accepted %q", name, body)
+		}
+	}
+	if err := tl.ReloadScripts(ctx, map[string]string{"no_such_algorithm": alwaysDeny}); err == nil {
+		t.Error("ReloadScripts accepted an unknown algorithm")
+	}
+
+	// The running script is untouched: one token left, then empty
+	if resp := tl.check(t, req); !resp.Allowed || resp.Remaining != 0 {
+		t.Errorf("check after rejected reloads = %+v, want allowed with 0 remaining", resp)
+	}
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Error("bucket still allowing once empty")
+	}
+}
+
+func TestReloadScriptsRereadsLuaDir(t *testing.T) {
+	dir := t.TempDir()
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.LuaDir = dir
+	})
+	req := tokenBucketRequest("user:1", 10, 1)
+
+	if resp := tl.check(t, req); !resp.Allowed {
+		t.Fatal("check with the built-in script blocked, want allowed")
+	}
+
+	// An override dropped into LUA_DIR only takes effect on reload
+	if err := os.WriteFile(filepath.Join(dir, "token_bucket.lua"), []byte(alwaysDeny), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resp := tl.check(t, req); !resp.Allowed {
+		t.Fatal("override applied before a reload")
+	}
+	if err := tl.ReloadScripts(context.Background(), nil); err != nil {
+		t.Fatalf("ReloadScripts: %v", err)
+	}
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Error("check after reloading LUA_DIR allowed, want the override's deny")
+	}
+}
+
+func TestLuaDirShadowsOnlyItsOwnFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token_bucket.lua"), []byte(alwaysDeny), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file that can't be read falls back to the embedded script rather than failing
+	if err := os.Mkdir(filepath.Join(dir, "sliding_window.lua"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newScriptSet(dir)
+	if got := *s.tokenBucket.Load(); got != alwaysDeny {
+		t.Errorf("token bucket script = %q, want the LUA_DIR override", got)
+	}
+	for file, got := range map[string]string{
+		"sliding_window.lua": *s.slidingWindow.Load(),
+		"gcra.lua":           *s.gcra.Load(),
+	} {
+		want, err := lua.Scripts.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(want) {
+			t.Errorf("%s is not the embedded script", file)
+		}
+	}
+}
+
+func TestReloadScriptsValidatesOnTheLimiterClock(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	ctx := context.Background()
+	tl.advance(time.Hour)
+
+	// Records the now argument it was validated with
+	recordNow := "redis.call('SET', 'validated_at', ARGV[3]) return {1, 0, 0, 0}"
+	if err := tl.ReloadScripts(ctx, map[string]string{AlgorithmTokenBucket: recordNow}); err != nil {
+		t.Fatalf("ReloadScripts: %v", err)
+	}
+	want := strconv.FormatInt(testStart.Add(time.Hour).UnixMilli(), 10)
+	if got, _ := tl.redis.Get("validated_at"); got != want {
+		t.Errorf("validated with now %q, want the limiter clock's %s", got, want)
+	}
+
+	// Under CLOCK_SOURCE=redis the script is left to read TIME, as in a real check
+	tl.Limiter.clock = NewRedisClock(tl.Limiter.redis)
+	if err := tl.ReloadScripts(ctx, map[string]string{AlgorithmTokenBucket: recordNow}); err != nil {
+		t.Fatalf("ReloadScripts: %v", err)
+	}
+	if got, _ := tl.redis.Get("validated_at"); got != "" {
+		t.Errorf("validated with now %q under the Redis clock, want it left empty", got)
+	}
+}
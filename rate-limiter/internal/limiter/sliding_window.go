@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"sync"
 	"time"
 
 	"github.com/piyushpatra/rate-limiter/internal/metrics"
@@ -13,66 +11,21 @@ import (
 	"github.com/piyushpatra/rate-limiter/internal/utils"
 )
 
-var (
-	slidingWindowScript string
-	slidingWindowOnce   sync.Once
-)
-
-func loadSlidingWindowScript() {
-	slidingWindowOnce.Do(func() {
-		// Try multiple possible paths
-		paths := []string{
-			"internal/redis/lua/sliding_window.lua",
-			"../redis/lua/sliding_window.lua",
-			"../../redis/lua/sliding_window.lua",
-		}
-		
-		for _, path := range paths {
-			if data, err := os.ReadFile(path); err == nil {
-				slidingWindowScript = string(data)
-				return
-			}
-		}
-		
-		// Fallback: inline the script
-		slidingWindowScript = `
--- Sliding Window Log Rate Limiter
-local key = KEYS[1]
-local capacity = tonumber(ARGV[1])
-local window = tonumber(ARGV[2])
-local now = tonumber(ARGV[3])
-
-local window_start = now - window
-redis.call('ZREMRANGEBYSCORE', key, 0, window_start)
-local current_count = redis.call('ZCARD', key)
-
-local allowed = 0
-local remaining = capacity - current_count
-
-if current_count < capacity then
-    local member = now .. ':' .. redis.call('INCR', key .. ':counter')
-    redis.call('ZADD', key, now, member)
-    allowed = 1
-    remaining = remaining - 1
-end
-
-redis.call('EXPIRE', key, window + 10)
-redis.call('EXPIRE', key .. ':counter', window + 10)
-
-return {allowed, math.max(0, remaining)}
-`
-	})
-}
-
 // SlidingWindowLimiter implements sliding window log algorithm
 // More accurate than fixed windows, prevents boundary exploits
 // Uses sorted sets to track individual request timestamps
 type SlidingWindowLimiter struct {
-	redis *redisclient.Client
+	redis   *redisclient.Client
+	failure *FailurePolicy
+	metrics *metrics.Metrics
+	fills   *fillTracker
+	clock   utils.Clock
+	scripts *scriptSet
+	jitter  ttlJitter
 }
 
-func NewSlidingWindowLimiter(redis *redisclient.Client) *SlidingWindowLimiter {
-	return &SlidingWindowLimiter{redis: redis}
+func NewSlidingWindowLimiter(redis *redisclient.Client, failure *FailurePolicy, m *metrics.Metrics, fills *fillTracker, scripts *scriptSet, jitter ttlJitter, clock utils.Clock) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{redis: redis, failure: failure, metrics: m, fills: fills, scripts: scripts, jitter: jitter, clock: clock}
 }
 
 // Check determines if a request should be allowed under sliding window
@@ -81,59 +34,80 @@ func NewSlidingWindowLimiter(redis *redisclient.Client) *SlidingWindowLimiter {
 //
 // Example: capacity=100, windowSeconds=60 means max 100 requests per minute
 // Unlike fixed windows, this counts requests in a rolling 60-second period
-func (sw *SlidingWindowLimiter) Check(ctx context.Context, key string, capacity int64, windowSeconds int64) (allowed bool, remaining int64, err error) {
-	loadSlidingWindowScript() // Ensure script is loaded
-	
+// cost: units this request consumes (req.Cost)
+func (sw *SlidingWindowLimiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
 	start := time.Now()
 	defer func() {
 		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
-		metrics.CheckLatency.WithLabelValues("sliding_window").Observe(latencyMs)
+		sw.metrics.ObserveVec(sw.metrics.CheckLatency, latencyMs, "sliding_window")
 	}()
 
-	if capacity <= 0 || windowSeconds <= 0 {
-		return false, 0, errors.New("capacity and windowSeconds must be positive")
+	call, err := sw.prepare(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 
-	now := utils.NowSeconds()
-	
 	// Execute Lua script atomically
 	// This removes old entries, counts current entries, and adds new entry in one operation
 	redisStart := time.Now()
-	result, err := sw.redis.EvalLua(ctx, slidingWindowScript, []string{key}, capacity, windowSeconds, now)
-	redisLatency := float64(time.Since(redisStart).Microseconds()) / 1000.0
-	metrics.RedisLatency.Observe(redisLatency)
+	result, err := sw.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	sw.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := sw.finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
+
+// prepare validates the parameters and builds the script call for a check
+func (sw *SlidingWindowLimiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, error) {
+	capacity, windowMillis, cost := req.Capacity, req.WindowMillis, req.Cost
+
+	if capacity <= 0 || windowMillis <= 0 {
+		return redisclient.ScriptCall{}, errors.New("capacity and windowMillis must be positive")
+	}
+
+	if cost <= 0 || cost > capacity {
+		return redisclient.ScriptCall{}, errors.New("cost must be positive and no more than capacity")
+	}
+
+	// Milliseconds so windows can be shorter than a second (e.g. 500ms)
+	now := scriptNow(ctx, sw.clock)
+
+	return redisclient.ScriptCall{
+		Script: *sw.scripts.slidingWindow.Load(),
+		Keys:   []string{req.Key, companionKey(req.Key, "counter")},
+		Args:   []interface{}{capacity, windowMillis, now, cost, dryRunArg(req.DryRun), sw.jitter.arg()},
+	}, nil
+}
 
+// finish turns the script reply (or error) into a CheckResponse and records metrics
+// req.Tier must already be normalized (see Limiter.tierLabel) to keep cardinality bounded
+func (sw *SlidingWindowLimiter) finish(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error) {
 	if err != nil {
 		var failOpenErr *redisclient.FailOpenError
 		if errors.As(err, &failOpenErr) {
-			metrics.RedisErrors.Inc()
-			// Fail open on Redis errors
-			return true, 0, nil
+			sw.metrics.RedisErrors.Inc()
+			// Redis couldn't decide - FAIL_MODE picks open, closed or a local limit
+			return sw.failure.Decide(ctx, req, err)
 		}
-		return false, 0, fmt.Errorf("sliding window check failed: %w", err)
+		return nil, fmt.Errorf("sliding window check failed: %w", err)
 	}
 
-	// Parse response from Lua: {allowed, remaining}
-	resultSlice, ok := result.([]interface{})
-	if !ok || len(resultSlice) != 2 {
-		return false, 0, errors.New("unexpected response format from Lua script")
-	}
-
-	allowedInt, ok1 := resultSlice[0].(int64)
-	remainingInt, ok2 := resultSlice[1].(int64)
-	if !ok1 || !ok2 {
-		return false, 0, errors.New("failed to parse Lua script response")
+	resp, err := parseCheckResult(result)
+	if err != nil {
+		return nil, err
 	}
 
-	allowed = allowedInt == 1
-	remaining = remainingInt
-
-	if allowed {
-		metrics.RequestsAllowed.WithLabelValues("sliding_window").Inc()
+	if resp.Allowed {
+		sw.metrics.IncCounter(sw.metrics.RequestsAllowed, "sliding_window", req.Tier, dryRunLabel(req.DryRun))
 	} else {
-		metrics.RequestsBlocked.WithLabelValues("sliding_window").Inc()
+		sw.metrics.IncCounter(sw.metrics.RequestsBlocked, "sliding_window", req.Tier, dryRunLabel(req.DryRun))
+	}
+	if req.DryRun {
+		return dryRunResponse(resp), nil
 	}
+	sw.fills.record("sliding_window", resp.Remaining, req.Capacity)
 
-	return allowed, remaining, nil
+	return resp, nil
 }
 
@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/piyushpatra/rate-limiter/internal/utils"
+)
+
+// SlidingWindowCounterLimiter approximates the sliding window log with two fixed-window
+// counters, weighting the previous window by how much of it the sliding window still covers
+// O(1) memory per key instead of one sorted set entry per request - the trade-off is
+// that it assumes the previous window's requests were spread evenly
+type SlidingWindowCounterLimiter struct {
+	redis   *redisclient.Client
+	failure *FailurePolicy
+	metrics *metrics.Metrics
+	fills   *fillTracker
+	clock   utils.Clock
+	scripts *scriptSet
+	jitter  ttlJitter
+}
+
+func NewSlidingWindowCounterLimiter(redis *redisclient.Client, failure *FailurePolicy, m *metrics.Metrics, fills *fillTracker, scripts *scriptSet, jitter ttlJitter, clock utils.Clock) *SlidingWindowCounterLimiter {
+	return &SlidingWindowCounterLimiter{redis: redis, failure: failure, metrics: m, fills: fills, scripts: scripts, jitter: jitter, clock: clock}
+}
+
+// Check determines if a request should be allowed under the sliding window counter
+// capacity: max requests allowed in the window
+// windowSeconds: time window in seconds
+// cost: units this request consumes (req.Cost)
+func (swc *SlidingWindowCounterLimiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	start := time.Now()
+	defer func() {
+		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
+		swc.metrics.ObserveVec(swc.metrics.CheckLatency, latencyMs, "sliding_window_counter")
+	}()
+
+	call, err := swc.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute Lua script atomically
+	redisStart := time.Now()
+	result, err := swc.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	swc.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := swc.finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
+
+// prepare validates the parameters and builds the script call for a check
+func (swc *SlidingWindowCounterLimiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, error) {
+	capacity, windowMillis, cost := req.Capacity, req.WindowMillis, req.Cost
+
+	if capacity <= 0 || windowMillis <= 0 {
+		return redisclient.ScriptCall{}, errors.New("capacity and windowMillis must be positive")
+	}
+
+	if cost <= 0 || cost > capacity {
+		return redisclient.ScriptCall{}, errors.New("cost must be positive and no more than capacity")
+	}
+
+	if capacity > MaxSafeInteger {
+		return redisclient.ScriptCall{}, errors.New("capacity exceeds the safe numeric range")
+	}
+
+	// The interpolation needs sub-second precision even for long windows
+	now := scriptNow(ctx, swc.clock)
+
+	return redisclient.ScriptCall{
+		Script: *swc.scripts.slidingWindowCounter.Load(),
+		Keys:   []string{req.Key},
+		Args:   []interface{}{capacity, windowMillis, now, cost, dryRunArg(req.DryRun), swc.jitter.arg()},
+	}, nil
+}
+
+// finish turns the script reply (or error) into a CheckResponse and records metrics
+// req.Tier must already be normalized (see Limiter.tierLabel) to keep cardinality bounded
+func (swc *SlidingWindowCounterLimiter) finish(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error) {
+	if err != nil {
+		var failOpenErr *redisclient.FailOpenError
+		if errors.As(err, &failOpenErr) {
+			swc.metrics.RedisErrors.Inc()
+			// Redis couldn't decide - FAIL_MODE picks open, closed or a local limit
+			return swc.failure.Decide(ctx, req, err)
+		}
+		return nil, fmt.Errorf("sliding window counter check failed: %w", err)
+	}
+
+	resp, err := parseCheckResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Allowed {
+		swc.metrics.IncCounter(swc.metrics.RequestsAllowed, "sliding_window_counter", req.Tier, dryRunLabel(req.DryRun))
+	} else {
+		swc.metrics.IncCounter(swc.metrics.RequestsBlocked, "sliding_window_counter", req.Tier, dryRunLabel(req.DryRun))
+	}
+	if req.DryRun {
+		return dryRunResponse(resp), nil
+	}
+	swc.fills.record("sliding_window_counter", resp.Remaining, req.Capacity)
+
+	return resp, nil
+}
@@ -0,0 +1,96 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func slidingWindowCounterRequest(key string, capacity int64, window time.Duration) CheckRequest {
+	return CheckRequest{Key: key, Algorithm: AlgorithmSlidingWindowCounter, Capacity: capacity, WindowMillis: window.Milliseconds()}
+}
+
+func TestSlidingWindowCounterTracksTheLog(t *testing.T) {
+	const capacity, window = 100, 10 * time.Second
+	tests := []struct {
+		name string
+		load float64 // offered requests per window, as a multiple of capacity
+	}{
+		{name: "half load", load: 0.5},
+		{name: "at capacity", load: 1},
+		{name: "double load", load: 2},
+		{name: "five times load", load: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := newTestLimiter(t, nil)
+			counter := slidingWindowCounterRequest("counter", capacity, window)
+			log := slidingWindowRequest("log", capacity, window)
+			interval := time.Duration(float64(window) / (tt.load * capacity))
+
+			// Warm up for two windows, then compare over the next six
+			var allowedCounter, allowedLog int
+			for elapsed := time.Duration(0); elapsed < 8*window; elapsed += interval {
+				c, l := tl.check(t, counter), tl.check(t, log)
+				if elapsed >= 2*window {
+					if c.Allowed {
+						allowedCounter++
+					}
+					if l.Allowed {
+						allowedLog++
+					}
+				}
+				tl.advance(interval)
+			}
+
+			// The counter assumes the previous window was spread evenly, which a steady
+			// load is - so it should land within a few percent of the exact count
+			diff := float64(allowedCounter-allowedLog) / float64(allowedLog)
+			if diff < -0.05 || diff > 0.05 {
+				t.Errorf("counter allowed %d, log %d: off by %.1f%%, want within 5%%", allowedCounter, allowedLog, diff*100)
+			}
+			if tt.load <= 1 && allowedLog < int(tt.load*capacity*6)-1 {
+				t.Errorf("log allowed %d under capacity, want every request", allowedLog)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowCounterStateIsConstant(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowCounterRequest("user:1", 1000, time.Minute)
+
+	for i := 0; i < 500; i++ {
+		tl.check(t, req)
+		tl.advance(10 * time.Millisecond)
+	}
+
+	// However many requests, one hash of window, counters and capacity
+	if typ := tl.redis.Type("user:1"); typ != "hash" {
+		t.Fatalf("key type = %q, want hash", typ)
+	}
+	if fields, _ := tl.redis.HKeys("user:1"); len(fields) != 4 {
+		t.Errorf("fields = %v, want window, curr, prev and capacity", fields)
+	}
+}
+
+func TestSlidingWindowCounterWeightsPreviousWindow(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowCounterRequest("user:1", 10, 10*time.Second)
+
+	// testStart is on a window boundary: fill the first window
+	for i := 0; i < 10; i++ {
+		if !tl.check(t, req).Allowed {
+			t.Fatalf("check %d blocked, want allowed", i+1)
+		}
+	}
+	if tl.check(t, req).Allowed {
+		t.Fatal("check past capacity allowed")
+	}
+
+	// A quarter into the next window, 75% of the previous 10 still counts
+	tl.advance(12500 * time.Millisecond)
+	resp := tl.check(t, req)
+	if !resp.Allowed || resp.Remaining != 1 {
+		t.Errorf("response = %+v, want allowed with 1 remaining (10 - 7.5 - 1)", resp)
+	}
+}
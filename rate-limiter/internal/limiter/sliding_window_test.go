@@ -0,0 +1,137 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowRemainingCountsDown(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowRequest("user:1", 5, 10*time.Second)
+
+	// One request a second: remaining falls by one each time and never goes below 0
+	for want := int64(4); want >= 0; want-- {
+		resp := tl.check(t, req)
+		if !resp.Allowed || resp.Remaining != want {
+			t.Fatalf("check with %d left = %+v, want allowed with remaining %d", want+1, resp, want)
+		}
+		tl.advance(time.Second)
+	}
+	for i := 0; i < 3; i++ {
+		resp := tl.check(t, req)
+		if resp.Allowed || resp.Remaining != 0 {
+			t.Fatalf("check at capacity = %+v, want blocked with remaining 0", resp)
+		}
+	}
+
+	// 10s after the first request it leaves the window, so exactly one more fits
+	tl.advance(5 * time.Second)
+	if resp := tl.check(t, req); !resp.Allowed || resp.Remaining != 0 {
+		t.Fatalf("check once the first entry expired = %+v, want allowed with remaining 0", resp)
+	}
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Fatal("check right after, want blocked")
+	}
+}
+
+func TestSlidingWindowBlocksAddNoEntries(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowRequest("user:1", 2, 10*time.Second)
+
+	tl.check(t, req)
+	tl.check(t, req)
+	for i := 0; i < 5; i++ {
+		tl.check(t, req)
+	}
+
+	members, err := tl.redis.ZMembers("user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Errorf("%d entries in the window, want 2 - blocked checks mustn't add any", len(members))
+	}
+}
+
+func TestSlidingWindowKeepsCounterBesideKey(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowRequest("user:1", 1, time.Minute)
+
+	if resp := tl.check(t, req); !resp.Allowed {
+		t.Fatal("first check blocked, want allowed")
+	}
+	if resp := tl.check(t, req); resp.Allowed {
+		t.Fatal("second check allowed, want blocked")
+	}
+
+	// The counter shares the key's hash tag, so cluster mode puts both on one node
+	if !tl.redis.Exists("{user:1}:counter") {
+		t.Errorf("counter key missing, have %v", tl.redis.Keys())
+	}
+}
+
+func TestSlidingWindowRetryAfterIsWindowMinusOldestAge(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	const window = 10 * time.Second
+	req := slidingWindowRequest("user:1", 3, window)
+
+	// Entries at 0s, 2s and 5s fill the window
+	for _, gap := range []time.Duration{0, 2 * time.Second, 3 * time.Second} {
+		tl.advance(gap)
+		if !tl.check(t, req).Allowed {
+			t.Fatal("check under capacity blocked")
+		}
+	}
+
+	tests := []struct {
+		name      string
+		at        time.Duration // since the first entry
+		cost      int64
+		wantRetry time.Duration
+	}{
+		// The oldest entry is 7s old, so it leaves in 3s
+		{name: "oldest entry", at: 7 * time.Second, cost: 1, wantRetry: 3 * time.Second},
+		{name: "a millisecond before it leaves", at: window - time.Millisecond, cost: 1, wantRetry: time.Millisecond},
+		// Room for 2 needs the 2s entry gone too
+		{name: "cost of two", at: 7 * time.Second, cost: 2, wantRetry: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl.clock.Set(testStart.Add(tt.at))
+			r := req
+			r.Cost = tt.cost
+			resp := tl.check(t, r)
+			if resp.Allowed {
+				t.Fatal("check at capacity allowed")
+			}
+			if resp.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", resp.RetryAfter, tt.wantRetry)
+			}
+			// Capacity frees up when the oldest entry leaves, whatever the cost
+			if want := testStart.Add(window); !resp.ResetAt.Equal(want) {
+				t.Errorf("ResetAt = %v, want %v", resp.ResetAt, want)
+			}
+		})
+	}
+
+	// Retrying when told to succeeds
+	tl.clock.Set(testStart.Add(window))
+	if !tl.check(t, req).Allowed {
+		t.Error("check once the oldest entry left blocked")
+	}
+}
+
+func TestSlidingWindowEmptySetHasNoWait(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	req := slidingWindowRequest("user:1", 3, 10*time.Second)
+
+	// A dry run adds no entry, so the set has no oldest entry to wait on
+	req.DryRun = true
+	resp := tl.check(t, req)
+	if !resp.Allowed || resp.RetryAfter != 0 {
+		t.Errorf("check on an empty window = %+v, want allowed with no retry", resp)
+	}
+	if !resp.ResetAt.Equal(testStart) {
+		t.Errorf("ResetAt = %v, want now - nothing to wait for", resp.ResetAt)
+	}
+}
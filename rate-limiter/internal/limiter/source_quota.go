@@ -0,0 +1,77 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// ErrSourceKeyQuota means the source has created as many distinct keys as it's allowed
+var ErrSourceKeyQuota = errors.New("source has reached its limit of distinct keys")
+
+// SourceQuotaLimiter caps how many distinct keys a single source can create
+// Protects Redis memory from callers minting a fresh key per request
+// The script only touches the source's set; whether the checked key already exists is
+// asked separately, since in cluster mode the two usually live on different nodes
+type SourceQuotaLimiter struct {
+	redis         *redisclient.Client
+	maxKeys       int64
+	windowSeconds int64
+
+	// keyPrefix is REDIS_KEY_PREFIX - a source's quota spans all namespaces
+	keyPrefix string
+
+	scripts *scriptSet
+}
+
+func NewSourceQuotaLimiter(redis *redisclient.Client, maxKeys, windowSeconds int64, keyPrefix string, scripts *scriptSet) *SourceQuotaLimiter {
+	return &SourceQuotaLimiter{redis: redis, maxKeys: maxKeys, windowSeconds: windowSeconds, keyPrefix: keyPrefix, scripts: scripts}
+}
+
+// Admit returns ErrSourceKeyQuota if checking key would exceed the source's cap
+// key must already be the full Redis key (see Limiter.redisKey)
+// Redis errors are returned as they are; Limiter.admitSource hands them to FAIL_MODE
+func (sq *SourceQuotaLimiter) Admit(ctx context.Context, source, key string) error {
+	result, err := sq.redis.EvalLua(ctx, sq.scripts.sourceQuota,
+		[]string{joinKey(sq.keyPrefix, "source_keys:"+source)}, sq.maxKeys, sq.windowSeconds, key)
+	if err != nil {
+		return fmt.Errorf("source quota check failed: %w", err)
+	}
+
+	admitted, ok := result.(int64)
+	if !ok {
+		return errors.New("unexpected response format from Lua script")
+	}
+	if admitted == 1 {
+		return nil
+	}
+
+	// At the cap, but a key that already exists always keeps working
+	exists, err := sq.redis.Exists(ctx, key)
+	if err != nil {
+		return fmt.Errorf("source quota check failed: %w", &redisclient.FailOpenError{Cause: err})
+	}
+	if !exists {
+		return ErrSourceKeyQuota
+	}
+	return nil
+}
+
+// admitSource applies the distinct-key quota to a check that has already been validated,
+// so malformed requests never use up a source's keys
+// A non-nil response is the decision FAIL_MODE made because Redis couldn't be reached
+func (l *Limiter) admitSource(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
+	if l.sourceQuota == nil || req.Source == "" {
+		return nil, nil
+	}
+
+	err := l.sourceQuota.Admit(ctx, req.Source, req.Key)
+	var failOpenErr *redisclient.FailOpenError
+	if errors.As(err, &failOpenErr) {
+		l.metrics.RedisErrors.Inc()
+		return l.failure.Decide(ctx, req, err)
+	}
+	return nil, err
+}
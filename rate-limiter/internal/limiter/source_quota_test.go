@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// sourceRequest is a token bucket check on key made by source
+func sourceRequest(source, key string) CheckRequest {
+	req := tokenBucketRequest(key, 10, 1)
+	req.Source = source
+	return req
+}
+
+func TestSourceQuotaCapsNewKeys(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.SourceKeyLimit = 2
+		cfg.SourceKeyWindow = time.Hour
+	})
+	ctx := context.Background()
+
+	tl.check(t, sourceRequest("1.2.3.4", "key:a"))
+	tl.check(t, sourceRequest("1.2.3.4", "key:b"))
+	if _, err := tl.Check(ctx, sourceRequest("1.2.3.4", "key:c")); !errors.Is(err, ErrSourceKeyQuota) {
+		t.Fatalf("third distinct key: err = %v, want ErrSourceKeyQuota", err)
+	}
+	if tl.redis.Exists("key:c") {
+		t.Error("a refused key was created anyway")
+	}
+
+	// Keys the source already has keep working
+	if resp := tl.check(t, sourceRequest("1.2.3.4", "key:a")); !resp.Allowed || resp.Remaining != 8 {
+		t.Errorf("existing key at the cap = %+v, want allowed with 8 remaining", resp)
+	}
+
+	// So do keys someone else created, and other sources have their own cap
+	tl.check(t, sourceRequest("5.6.7.8", "key:shared"))
+	if _, err := tl.Check(ctx, sourceRequest("1.2.3.4", "key:shared")); err != nil {
+		t.Errorf("existing key from another source: %v", err)
+	}
+
+	// The window starts with the source's first key; after it the cap starts over
+	tl.redis.FastForward(time.Hour)
+	if _, err := tl.Check(ctx, sourceRequest("1.2.3.4", "key:c")); err != nil {
+		t.Errorf("new key after the window: %v", err)
+	}
+}
+
+func TestSourceQuotaIgnoresChecksWithoutSource(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.SourceKeyLimit = 1
+	})
+
+	for _, key := range []string{"key:a", "key:b", "key:c"} {
+		tl.check(t, tokenBucketRequest(key, 10, 1))
+	}
+}
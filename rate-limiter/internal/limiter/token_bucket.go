@@ -4,8 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"sync"
+	"math"
 	"time"
 
 	"github.com/piyushpatra/rate-limiter/internal/metrics"
@@ -13,133 +12,120 @@ import (
 	"github.com/piyushpatra/rate-limiter/internal/utils"
 )
 
-var (
-	tokenBucketScript string
-	tokenBucketOnce   sync.Once
-)
-
-func loadTokenBucketScript() {
-	tokenBucketOnce.Do(func() {
-		// Try multiple possible paths
-		paths := []string{
-			"internal/redis/lua/token_bucket.lua",
-			"../redis/lua/token_bucket.lua",
-			"../../redis/lua/token_bucket.lua",
-		}
-		
-		for _, path := range paths {
-			if data, err := os.ReadFile(path); err == nil {
-				tokenBucketScript = string(data)
-				return
-			}
-		}
-		
-		// Fallback: inline the script
-		tokenBucketScript = `
--- Token Bucket Rate Limiter
-local key = KEYS[1]
-local capacity = tonumber(ARGV[1])
-local refill_rate = tonumber(ARGV[2])
-local now = tonumber(ARGV[3])
-
-local bucket = redis.call('HMGET', key, 'tokens', 'last_refill')
-local tokens = tonumber(bucket[1])
-local last_refill = tonumber(bucket[2])
-
-if tokens == nil then
-    tokens = capacity
-    last_refill = now
-end
-
-local elapsed_seconds = (now - last_refill) / 1000.0
-local tokens_to_add = elapsed_seconds * refill_rate
-tokens = math.min(capacity, tokens + tokens_to_add)
-last_refill = now
-
-local allowed = 0
-if tokens >= 1 then
-    tokens = tokens - 1
-    allowed = 1
-end
-
-redis.call('HMSET', key, 'tokens', tokens, 'last_refill', last_refill)
-local ttl = math.ceil(capacity / refill_rate * 2)
-redis.call('EXPIRE', key, ttl)
-
-return {allowed, math.floor(tokens)}
-`
-	})
-}
-
 // TokenBucketLimiter implements the token bucket algorithm
 // Good for allowing bursts while maintaining average rate
 type TokenBucketLimiter struct {
-	redis *redisclient.Client
+	redis   *redisclient.Client
+	failure *FailurePolicy
+	metrics *metrics.Metrics
+	fills   *fillTracker
+	clock   utils.Clock
+	scripts *scriptSet
+	jitter  ttlJitter
+
+	// dedupTTL is how long request ids are remembered for replay (DEDUP_TTL)
+	dedupTTL time.Duration
 }
 
-func NewTokenBucketLimiter(redis *redisclient.Client) *TokenBucketLimiter {
-	return &TokenBucketLimiter{redis: redis}
+func NewTokenBucketLimiter(redis *redisclient.Client, failure *FailurePolicy, m *metrics.Metrics, fills *fillTracker, dedupTTL time.Duration, scripts *scriptSet, jitter ttlJitter, clock utils.Clock) *TokenBucketLimiter {
+	return &TokenBucketLimiter{redis: redis, failure: failure, metrics: m, fills: fills, dedupTTL: dedupTTL, scripts: scripts, jitter: jitter, clock: clock}
 }
 
 // Check determines if a request should be allowed under token bucket
 // capacity: max tokens in bucket (allows bursts up to this size)
 // refillRate: tokens added per second (average rate limit)
-func (tb *TokenBucketLimiter) Check(ctx context.Context, key string, capacity int64, refillRate float64) (allowed bool, remaining int64, err error) {
-	loadTokenBucketScript() // Ensure script is loaded
-	
+// cost: units this request consumes (req.Cost)
+func (tb *TokenBucketLimiter) Check(ctx context.Context, req CheckRequest) (*CheckResponse, error) {
 	start := time.Now()
 	defer func() {
-		// Track latency for this algorithm
 		latencyMs := float64(time.Since(start).Microseconds()) / 1000.0
-		metrics.CheckLatency.WithLabelValues("token_bucket").Observe(latencyMs)
+		tb.metrics.ObserveVec(tb.metrics.CheckLatency, latencyMs, "token_bucket")
 	}()
 
-	if capacity <= 0 || refillRate <= 0 {
-		return false, 0, errors.New("capacity and refillRate must be positive")
+	call, err := tb.prepare(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 
-	now := utils.NowMillis()
-	
 	// Execute Lua script atomically
 	redisStart := time.Now()
-	result, err := tb.redis.EvalLua(ctx, tokenBucketScript, []string{key}, capacity, refillRate, now)
-	redisLatency := float64(time.Since(redisStart).Microseconds()) / 1000.0
-	metrics.RedisLatency.Observe(redisLatency)
+	result, err := tb.redis.EvalLua(ctx, call.Script, call.Keys, call.Args...)
+	redisTime := time.Since(redisStart)
+	tb.metrics.RedisLatency.Observe(float64(redisTime.Microseconds()) / 1000.0)
+
+	resp, err := tb.finish(ctx, result, err, req)
+	return withRedisTime(resp, redisTime), err
+}
+
+// prepare validates the parameters and builds the script call for a check
+func (tb *TokenBucketLimiter) prepare(ctx context.Context, req CheckRequest) (redisclient.ScriptCall, error) {
+	capacity, refillRate, cost := req.Capacity, req.RefillRate, req.Cost
+
+	if capacity <= 0 || refillRate <= 0 {
+		return redisclient.ScriptCall{}, errors.New("capacity and refillRate must be positive")
+	}
+
+	if cost <= 0 || cost > capacity {
+		return redisclient.ScriptCall{}, errors.New("cost must be positive and no more than capacity")
+	}
 
+	// Values travel to Lua as doubles - beyond 2^53 integers stop being exact
+	if capacity > MaxSafeInteger || refillRate > MaxSafeInteger || math.IsNaN(refillRate) || math.IsInf(refillRate, 0) {
+		return redisclient.ScriptCall{}, errors.New("capacity and refillRate exceed the safe numeric range")
+	}
+
+	now := scriptNow(ctx, tb.clock)
+
+	keys := []string{req.Key}
+	if req.RequestID != "" {
+		keys = append(keys, companionKey(req.Key, "req:"+req.RequestID))
+	}
+
+	return redisclient.ScriptCall{
+		Script: *tb.scripts.tokenBucket.Load(),
+		Keys:   keys,
+		Args:   []interface{}{capacity, refillRate, now, cost, dryRunArg(req.DryRun), req.RequestID, tb.dedupTTL.Milliseconds(), tb.jitter.arg(), preciseArg(req.Precise)},
+	}, nil
+}
+
+// finish turns the script reply (or error) into a CheckResponse and records metrics
+// req.Tier must already be normalized (see Limiter.tierLabel) to keep cardinality bounded
+func (tb *TokenBucketLimiter) finish(ctx context.Context, result interface{}, err error, req CheckRequest) (*CheckResponse, error) {
 	if err != nil {
 		// Check if this is a fail-open error
 		var failOpenErr *redisclient.FailOpenError
 		if errors.As(err, &failOpenErr) {
-			metrics.RedisErrors.Inc()
-			// Fail open: allow request when Redis is unavailable
-			// This prevents rate limiter from becoming a single point of failure
-			return true, 0, nil
+			tb.metrics.RedisErrors.Inc()
+			// Redis couldn't decide - FAIL_MODE picks open, closed or a local limit
+			return tb.failure.Decide(ctx, req, err)
 		}
-		return false, 0, fmt.Errorf("token bucket check failed: %w", err)
+		return nil, fmt.Errorf("token bucket check failed: %w", err)
 	}
 
-	// Parse Lua response: {allowed, remaining}
-	resultSlice, ok := result.([]interface{})
-	if !ok || len(resultSlice) != 2 {
-		return false, 0, errors.New("unexpected response format from Lua script")
-	}
-
-	allowedInt, ok1 := resultSlice[0].(int64)
-	remainingInt, ok2 := resultSlice[1].(int64)
-	if !ok1 || !ok2 {
-		return false, 0, errors.New("failed to parse Lua script response")
+	resp, err := parseCheckResult(result)
+	if err != nil {
+		return nil, err
 	}
 
-	allowed = allowedInt == 1
-	remaining = remainingInt
-
 	// Update metrics
-	if allowed {
-		metrics.RequestsAllowed.WithLabelValues("token_bucket").Inc()
+	if resp.Allowed {
+		tb.metrics.IncCounter(tb.metrics.RequestsAllowed, "token_bucket", req.Tier, dryRunLabel(req.DryRun))
 	} else {
-		metrics.RequestsBlocked.WithLabelValues("token_bucket").Inc()
+		tb.metrics.IncCounter(tb.metrics.RequestsBlocked, "token_bucket", req.Tier, dryRunLabel(req.DryRun))
+	}
+	if req.DryRun {
+		return dryRunResponse(resp), nil
 	}
+	tb.fills.record("token_bucket", resp.Remaining, req.Capacity)
 
-	return allowed, remaining, nil
+	return resp, nil
 }
 
+
+// preciseArg is the script's precise argument
+func preciseArg(precise bool) string {
+	if precise {
+		return "1"
+	}
+	return "0"
+}
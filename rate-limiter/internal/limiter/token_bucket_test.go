@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketExtremeValuesStayExact(t *testing.T) {
+	const century = 100 * 365 * 24 * time.Hour
+	tests := []struct {
+		name       string
+		capacity   int64
+		refillRate float64
+		idle       time.Duration
+	}{
+		{name: "largest capacity and rate", capacity: MaxSafeInteger, refillRate: MaxSafeInteger, idle: century},
+		{name: "largest capacity, tiny rate", capacity: MaxSafeInteger, refillRate: 1e-9, idle: century},
+		{name: "tiny capacity, huge rate", capacity: 1, refillRate: MaxSafeInteger, idle: century},
+		{name: "huge rate, no idle", capacity: 1000, refillRate: MaxSafeInteger, idle: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := newTestLimiter(t, nil)
+			req := tokenBucketRequest("user:1", tt.capacity, tt.refillRate)
+
+			resp := tl.check(t, req)
+			if !resp.Allowed || resp.Remaining != tt.capacity-1 {
+				t.Fatalf("first check = %+v, want allowed with %d remaining", resp, tt.capacity-1)
+			}
+
+			// However long the bucket sat idle, it refills to exactly capacity and no further
+			tl.advance(tt.idle)
+			resp = tl.check(t, req)
+			if !resp.Allowed {
+				t.Fatalf("check after %v idle blocked", tt.idle)
+			}
+			if tt.idle > 0 && resp.Remaining != tt.capacity-1 {
+				t.Errorf("remaining after %v idle = %d, want %d", tt.idle, resp.Remaining, tt.capacity-1)
+			}
+			if resp.Remaining < 0 || resp.Remaining > tt.capacity {
+				t.Errorf("remaining = %d, outside 0..%d", resp.Remaining, tt.capacity)
+			}
+			if resp.RetryAfter < 0 {
+				t.Errorf("RetryAfter = %v, want never negative", resp.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestTokenBucketRejectsUnsafeNumbers(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	tests := []CheckRequest{
+		tokenBucketRequest("user:1", MaxSafeInteger+1, 1),
+		tokenBucketRequest("user:1", 10, MaxSafeInteger*2),
+		tokenBucketRequest("user:1", 10, math.Inf(1)),
+		tokenBucketRequest("user:1", 10, math.NaN()),
+	}
+	for _, req := range tests {
+		if _, err := tl.Check(context.Background(), req); err == nil {
+			t.Errorf("Check(capacity %d, refill_rate %v) succeeded, want an error", req.Capacity, req.RefillRate)
+		}
+	}
+	if tl.redis.Exists("user:1") {
+		t.Error("a rejected check wrote the bucket")
+	}
+}
+
+func TestTokenBucketSlowRefillWaitDoesNotOverflow(t *testing.T) {
+	tl := newTestLimiter(t, nil)
+	// One token every ~31,700 years: the wait is past what a Duration holds
+	req := tokenBucketRequest("user:1", 1, 1e-12)
+
+	tl.check(t, req)
+	resp := tl.check(t, req)
+	if resp.Allowed {
+		t.Fatal("second check allowed, want blocked")
+	}
+	if resp.RetryAfter != math.MaxInt64 {
+		t.Errorf("RetryAfter = %v, want the largest Duration", resp.RetryAfter)
+	}
+	if !resp.ResetAt.After(testStart.AddDate(1000, 0, 0)) {
+		t.Errorf("ResetAt = %v, want millennia away", resp.ResetAt)
+	}
+	if ttl := tl.redis.TTL("user:1"); ttl <= 0 {
+		t.Errorf("TTL = %v, want the bucket to still expire", ttl)
+	}
+}
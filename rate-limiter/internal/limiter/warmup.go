@@ -0,0 +1,19 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+)
+
+// Warmup sends every script to Redis up front so the first check for each algorithm
+// doesn't pay for shipping it. The scripts themselves were read when the Limiter was
+// built; if Redis isn't reachable this is skipped and EvalLua's NOSCRIPT fallback
+// covers it later.
+func (l *Limiter) Warmup(ctx context.Context) error {
+	for _, script := range l.scripts.all() {
+		if err := l.redis.LoadScript(ctx, script); err != nil {
+			return fmt.Errorf("loading scripts into redis: %w", err)
+		}
+	}
+	return nil
+}
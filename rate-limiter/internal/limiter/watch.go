@@ -0,0 +1,85 @@
+package limiter
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/piyushpatra/rate-limiter/internal/tracing"
+)
+
+// watchList is the fixed set of keys (WATCH_KEYS) that get per-key metrics
+// It's built once and never grows, so it's read without locking. Its size is the
+// cardinality guard: config.Validate caps it at MAX_WATCH_KEYS, one label value each
+type watchList struct {
+	keys map[string]*watchedKey
+}
+
+// watchedKey is one watched key's metric label and latest remaining
+type watchedKey struct {
+	// label is the key hashed like the trace attribute and block log lines, so raw
+	// keys (often customer ids) never reach the metrics
+	label string
+
+	// remaining is what the key's latest check on this instance left; seen is false
+	// until there has been one
+	remaining atomic.Int64
+	seen      atomic.Bool
+}
+
+// WatchedRemaining is a watched key's remaining after its latest check on this instance
+type WatchedRemaining struct {
+	// Label is the key's hash, the same as its metric label
+	Label     string
+	Remaining int64
+}
+
+// newWatchList watches keys, which are Redis keys as stored (see Limiter.redisKey)
+// Returns nil when there are none
+func newWatchList(keys []string) *watchList {
+	if len(keys) == 0 {
+		return nil
+	}
+	w := &watchList{keys: make(map[string]*watchedKey, len(keys))}
+	for _, key := range keys {
+		w.keys[key] = &watchedKey{label: tracing.HashKey(key)}
+	}
+	return w
+}
+
+// lookup returns key's entry, or nil when it isn't watched (or w is nil)
+func (w *watchList) lookup(key string) *watchedKey {
+	if w == nil {
+		return nil
+	}
+	return w.keys[key]
+}
+
+// watch records a decision on a watched key, req having been through normalize
+// Dry runs and FAIL_MODE decisions say nothing about the key, like in logBlocked
+func (l *Limiter) watch(req CheckRequest, resp *CheckResponse) {
+	wk := l.watched.lookup(req.Key)
+	if wk == nil || resp.Degraded || req.DryRun {
+		return
+	}
+	wk.remaining.Store(resp.Remaining)
+	wk.seen.Store(true)
+	if !resp.Allowed {
+		l.metrics.ObserveVec(l.metrics.WatchedRetryAfter, resp.RetryAfter.Seconds(), wk.label)
+	}
+}
+
+// WatchedRemaining lists the remaining of each watched key checked on this instance,
+// sorted by label. At most MAX_WATCH_KEYS entries; empty when WATCH_KEYS is
+func (l *Limiter) WatchedRemaining() []WatchedRemaining {
+	if l.watched == nil {
+		return nil
+	}
+	out := make([]WatchedRemaining, 0, len(l.watched.keys))
+	for _, wk := range l.watched.keys {
+		if wk.seen.Load() {
+			out = append(out, WatchedRemaining{Label: wk.label, Remaining: wk.remaining.Load()})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Label < out[j].Label })
+	return out
+}
@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// retryAfterSamples reads how many retry-afters were observed for key, and their sum
+func retryAfterSamples(t *testing.T, tl *testLimiter, key string) (uint64, float64) {
+	t.Helper()
+	var m dto.Metric
+	if err := tl.metrics.WatchedRetryAfter.WithLabelValues(tracing.HashKey(key)).(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestWatchedKeyObservesRetryAfterOnDenial(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.WatchKeys = []string{"user:1"}
+	})
+	req := tokenBucketRequest("user:1", 1, 0.5) // a token every 2s
+
+	tl.check(t, req)
+	if n, _ := retryAfterSamples(t, tl, "user:1"); n != 0 {
+		t.Fatalf("%d samples after an allowed check, want 0", n)
+	}
+
+	tl.check(t, req)
+	n, sum := retryAfterSamples(t, tl, "user:1")
+	if n != 1 || sum != 2 {
+		t.Errorf("after a denial: %d samples summing to %v, want 1 of 2s", n, sum)
+	}
+
+	// Dry runs only say what would happen, so they aren't counted
+	req.DryRun = true
+	tl.check(t, req)
+	if n, _ := retryAfterSamples(t, tl, "user:1"); n != 1 {
+		t.Errorf("%d samples after a dry run, want still 1", n)
+	}
+}
+
+func TestUnwatchedKeysAddNoSeries(t *testing.T) {
+	tl := newTestLimiter(t, func(cfg *config.Config) {
+		cfg.WatchKeys = []string{"user:1"}
+	})
+	req := slidingWindowRequest("user:2", 1, time.Minute)
+
+	tl.check(t, req)
+	tl.check(t, req)
+
+	if n := testutil.CollectAndCount(tl.metrics.WatchedRetryAfter); n != 0 {
+		t.Errorf("%d series for an unwatched key, want 0", n)
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+// jsonLogger is set when LOG_FORMAT=json - nil keeps the standard log package's text output
+// Configured once in Setup before any goroutines start, so it isn't synchronized
+var jsonLogger *slog.Logger
+
+// Setup picks the output format for every log line the service writes
+// Call it right after loading config, before anything else logs
+func Setup(cfg *config.Config) {
+	if cfg.LogFormat == config.LogFormatJSON {
+		jsonLogger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+		return
+	}
+	jsonLogger = nil
+}
+
+// Printf logs a formatted message - the "msg" field in json format
+func Printf(format string, args ...interface{}) {
+	if jsonLogger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	jsonLogger.Info(fmt.Sprintf(format, args...))
+}
+
+// Println logs its operands like log.Println
+func Println(args ...interface{}) {
+	if jsonLogger == nil {
+		log.Println(args...)
+		return
+	}
+	jsonLogger.Info(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Fatalf logs a formatted message at error level and exits with status 1
+func Fatalf(format string, args ...interface{}) {
+	if jsonLogger == nil {
+		log.Fatalf(format, args...)
+	}
+	jsonLogger.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// Request describes one completed HTTP request
+type Request struct {
+	Method     string
+	Path       string
+	RemoteAddr string
+	Status     int
+	Duration   time.Duration
+	RequestID  string
+}
+
+// LogRequest writes a single line for a completed request
+// In json format the fields are emitted individually so log pipelines can index them
+func LogRequest(req Request) {
+	if jsonLogger == nil {
+		line := fmt.Sprintf("[%s] %s %s - %d (%v)", req.Method, req.Path, req.RemoteAddr, req.Status, req.Duration)
+		if req.RequestID != "" {
+			line += " id=" + req.RequestID
+		}
+		log.Println(line)
+		return
+	}
+	jsonLogger.LogAttrs(context.Background(), slog.LevelInfo, "request",
+		slog.String("method", req.Method),
+		slog.String("path", req.Path),
+		slog.String("remote_addr", req.RemoteAddr),
+		slog.Int("status", req.Status),
+		slog.Float64("duration_ms", float64(req.Duration.Microseconds())/1000.0),
+		slog.String("request_id", req.RequestID),
+	)
+}
+
+// Blocked describes one sampled blocked check
+type Blocked struct {
+	KeyHash    string
+	Algorithm  string
+	Tier       string
+	Suppressed int64 // blocks skipped by the sampler since the previous line
+}
+
+// LogBlocked writes the line for a sampled blocked check
+func LogBlocked(b Blocked) {
+	if jsonLogger == nil {
+		log.Printf("blocked key_hash=%s algorithm=%s tier=%s suppressed=%d", b.KeyHash, b.Algorithm, b.Tier, b.Suppressed)
+		return
+	}
+	jsonLogger.LogAttrs(context.Background(), slog.LevelInfo, "blocked",
+		slog.String("key_hash", b.KeyHash),
+		slog.String("algorithm", b.Algorithm),
+		slog.String("tier", b.Tier),
+		slog.Int64("suppressed", b.Suppressed),
+	)
+}
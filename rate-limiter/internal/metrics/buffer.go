@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The per-check counters and labelled histograms are written through IncCounter/ObserveVec
+// While RunBuffer runs, counter increments are summed in sharded in-memory accumulators
+// and added to the collectors in batches, so concurrent checks don't all hit the same
+// atomic. Histograms can't take pre-aggregated buckets, so those are still observed
+// immediately, but through a cached child instead of a label lookup per call.
+// Without it (METRICS_FLUSH_INTERVAL=0) every call goes straight to the collector.
+
+// bufferShards spreads concurrent writers so they rarely share a lock
+const bufferShards = 64
+
+// maxBufferedLabels is the most label values a buffered series can have - series with
+// more are written through directly
+const maxBufferedLabels = 3
+
+// Buffer accumulates counter increments between flushes
+type Buffer struct {
+	shards [bufferShards]bufferShard
+
+	// observers caches resolved histogram children by seriesKey
+	observers sync.Map
+}
+
+type bufferShard struct {
+	mu     sync.Mutex
+	counts map[seriesKey]float64
+
+	// closed is set by the final flush; writers that got here first then write through
+	closed bool
+}
+
+// seriesKey identifies one series without building a label string per call
+type seriesKey struct {
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
+	labels    [maxBufferedLabels]string
+	n         int
+}
+
+func newBuffer() *Buffer {
+	b := &Buffer{}
+	for i := range b.shards {
+		b.shards[i].counts = make(map[seriesKey]float64)
+	}
+	return b
+}
+
+// RunBuffer switches the hot-path metrics to buffered mode and flushes every interval
+// until ctx is done, then flushes once more and goes back to writing through
+func (m *Metrics) RunBuffer(ctx context.Context, interval time.Duration) {
+	b := newBuffer()
+	m.buffer.Store(b)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.buffer.Store(nil)
+			b.close()
+			return
+		case <-ticker.C:
+			b.Flush()
+		}
+	}
+}
+
+// Flush pushes everything buffered so far to the collectors
+// Called before anything reads the registry, so scrapes never see stale counts
+func (m *Metrics) Flush() {
+	if b := m.buffer.Load(); b != nil {
+		b.Flush()
+	}
+}
+
+// IncCounter adds one to the series of vec with the given label values
+func (m *Metrics) IncCounter(vec *prometheus.CounterVec, labels ...string) {
+	b := m.buffer.Load()
+	if b == nil || len(labels) > maxBufferedLabels {
+		vec.WithLabelValues(labels...).Inc()
+		return
+	}
+	key := seriesKey{counter: vec, n: len(labels)}
+	copy(key.labels[:], labels)
+
+	// Random rather than per-goroutine - the top-level math/rand functions don't share a lock
+	s := &b.shards[rand.Intn(bufferShards)]
+	s.mu.Lock()
+	if s.closed {
+		// The buffer was shut down after we loaded it - nothing would flush this count
+		s.mu.Unlock()
+		vec.WithLabelValues(labels...).Inc()
+		return
+	}
+	s.counts[key]++
+	s.mu.Unlock()
+}
+
+// ObserveVec records v in the series of vec with the given label values
+func (m *Metrics) ObserveVec(vec *prometheus.HistogramVec, v float64, labels ...string) {
+	b := m.buffer.Load()
+	if b == nil || len(labels) > maxBufferedLabels {
+		vec.WithLabelValues(labels...).Observe(v)
+		return
+	}
+	key := seriesKey{histogram: vec, n: len(labels)}
+	copy(key.labels[:], labels)
+
+	o, ok := b.observers.Load(key)
+	if !ok {
+		o, _ = b.observers.LoadOrStore(key, vec.WithLabelValues(labels...))
+	}
+	o.(prometheus.Observer).Observe(v)
+}
+
+// Flush adds every shard's accumulated counts to the collectors
+// Each shard's map is swapped out under its lock, so writers only wait for the swap
+func (b *Buffer) Flush() {
+	b.drain(false)
+}
+
+// close flushes for the last time and turns writers still holding b away to the
+// collectors, so increments racing with shutdown aren't left in a map nobody flushes
+func (b *Buffer) close() {
+	b.drain(true)
+}
+
+func (b *Buffer) drain(closing bool) {
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		counts := s.counts
+		s.counts = make(map[seriesKey]float64, len(counts))
+		s.closed = s.closed || closing
+		s.mu.Unlock()
+
+		for key, n := range counts {
+			key.counter.WithLabelValues(key.labels[:key.n]...).Add(n)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestMetrics() *Metrics {
+	return New(prometheus.NewRegistry(), nil, nil)
+}
+
+func newTestCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "test"}, []string{"algorithm", "tier", "dry_run"})
+}
+
+func TestBufferFlushAddsCounts(t *testing.T) {
+	vec := newTestCounter()
+	m := newTestMetrics()
+	b := newBuffer()
+	m.buffer.Store(b)
+
+	for i := 0; i < 100; i++ {
+		m.IncCounter(vec, "token_bucket", "free", "false")
+	}
+	if got := testutil.ToFloat64(vec.WithLabelValues("token_bucket", "free", "false")); got != 0 {
+		t.Fatalf("counter before flush = %v, want 0", got)
+	}
+
+	m.Flush()
+	if got := testutil.ToFloat64(vec.WithLabelValues("token_bucket", "free", "false")); got != 100 {
+		t.Fatalf("counter after flush = %v, want 100", got)
+	}
+}
+
+func TestClosedBufferWritesThrough(t *testing.T) {
+	vec := newTestCounter()
+	m := newTestMetrics()
+	b := newBuffer()
+	m.buffer.Store(b)
+
+	// Stands in for a writer that loaded b just before the final flush
+	b.close()
+	m.IncCounter(vec, "token_bucket", "free", "false")
+
+	if got := testutil.ToFloat64(vec.WithLabelValues("token_bucket", "free", "false")); got != 1 {
+		t.Fatalf("counter = %v, want 1", got)
+	}
+}
+
+func TestRunBufferKeepsIncrementsAcrossShutdown(t *testing.T) {
+	m := newTestMetrics()
+	vec := newTestCounter()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.RunBuffer(ctx, time.Hour)
+		close(done)
+	}()
+	for m.buffer.Load() == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	const writers, perWriter = 8, 5000
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				m.IncCounter(vec, "token_bucket", "free", "false")
+			}
+		}()
+	}
+
+	// Shut down while the writers are still going
+	time.Sleep(time.Millisecond)
+	cancel()
+	<-done
+	wg.Wait()
+
+	if got := testutil.ToFloat64(vec.WithLabelValues("token_bucket", "free", "false")); got != writers*perWriter {
+		t.Fatalf("counter = %v, want %d", got, writers*perWriter)
+	}
+}
+
+// BenchmarkIncCounter compares writing straight to a shared counter with buffering
+// under parallel writers (run with -cpu to vary the contention)
+func BenchmarkIncCounter(b *testing.B) {
+	b.Run("direct", func(b *testing.B) {
+		m := newTestMetrics()
+		vec := newTestCounter()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m.IncCounter(vec, "token_bucket", "free", "false")
+			}
+		})
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		m := newTestMetrics()
+		m.buffer.Store(newBuffer())
+		vec := newTestCounter()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m.IncCounter(vec, "token_bucket", "free", "false")
+			}
+		})
+	})
+}
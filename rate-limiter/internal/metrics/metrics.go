@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"sync/atomic"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -8,52 +10,199 @@ import (
 // These metrics give us visibility into rate limiter behavior and Redis performance
 // In production, we'd watch redis_latency_ms and redis_errors_total closely
 
-var (
+// Metrics holds every collector the service records to
+// Built once with New and passed to the limiters and the Redis client, so tests can use
+// their own registry and several limiters can live in one process
+type Metrics struct {
 	// RequestsAllowed tracks successful rate limit checks by algorithm
-	RequestsAllowed = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "requests_allowed_total",
-			Help: "Total number of requests allowed through the rate limiter",
-		},
-		[]string{"algorithm"}, // token_bucket or sliding_window
-	)
+	RequestsAllowed *prometheus.CounterVec
 
 	// RequestsBlocked tracks rejected requests by algorithm
-	RequestsBlocked = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "requests_blocked_total",
-			Help: "Total number of requests blocked by the rate limiter",
-		},
-		[]string{"algorithm"},
-	)
+	RequestsBlocked *prometheus.CounterVec
+
+	// RequestsFailOpen counts requests allowed only because Redis couldn't be reached
+	// These aren't in requests_allowed_total - add the two for everything let through
+	RequestsFailOpen *prometheus.CounterVec
+
+	// DenyCacheHits counts checks blocked from DENY_CACHE_TTL without asking Redis
+	// They're also in requests_blocked_total
+	DenyCacheHits *prometheus.CounterVec
 
 	// RedisLatency measures how long Redis operations take
 	// Most requests should be <1ms, alert if p99 goes over 2ms
-	RedisLatency = promauto.NewHistogram(
-		prometheus.HistogramOpts{
-			Name:    "redis_latency_ms",
-			Help:    "Redis operation latency in milliseconds",
-			Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 25, 50, 100}, // ms
-		},
-	)
+	RedisLatency prometheus.Histogram
 
 	// RedisErrors counts Redis failures that trigger fail-open
 	// Spike in this metric means Redis is having issues
-	RedisErrors = promauto.NewCounter(
-		prometheus.CounterOpts{
-			Name: "redis_errors_total",
-			Help: "Total number of Redis errors encountered",
-		},
-	)
+	RedisErrors prometheus.Counter
+
+	// RedisRetries counts script calls retried after a network error (REDIS_RETRY_BACKOFF)
+	RedisRetries prometheus.Counter
+
+	// ChecksShed counts script calls refused because MAX_INFLIGHT_CHECKS were already running
+	ChecksShed prometheus.Counter
 
 	// CheckLatency tracks end-to-end latency of rate limit checks
-	CheckLatency = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "check_latency_ms",
-			Help:    "Rate limit check latency in milliseconds",
-			Buckets: []float64{0.5, 1, 2, 3, 5, 10, 25, 50},
-		},
-		[]string{"algorithm"},
-	)
-)
+	CheckLatency *prometheus.HistogramVec
+
+	// CircuitBreakerState is the Redis circuit breaker state: 0 = closed, 1 = open, 2 = half-open
+	// Anything other than 0 means checks are failing open without reaching Redis
+	CircuitBreakerState prometheus.Gauge
+
+	// FillLevel is a smoothed average of how full buckets are (0 = idle, 1 = exhausted)
+	// Sampled from check results - intended as an autoscaling signal, not per-key detail
+	FillLevel *prometheus.GaugeVec
+
+	// RemainingRatio is remaining/capacity after each check, unsampled
+	// Mass near 0 means keys routinely run at their limit - limits or capacity are undersized
+	RemainingRatio *prometheus.HistogramVec
+
+	// WatchedRetryAfter is the retry-after of each blocked check on a WATCH_KEYS key,
+	// labelled by the key's hash - shows whether a key is throttled briefly or for long
+	WatchedRetryAfter *prometheus.HistogramVec
+
+	// RedisPoolTotalConns and RedisPoolIdleConns are sampled from the go-redis pool
+	// Total pinned at REDIS_POOL_SIZE with no idle conns means the pool is exhausted
+	RedisPoolTotalConns prometheus.Gauge
+	RedisPoolIdleConns  prometheus.Gauge
+
+	// RedisPoolTimeouts counts waits for a pool connection that hit PoolTimeout
+	// Any increase means checks queued behind an exhausted pool
+	RedisPoolTimeouts prometheus.Counter
+
+	// buffer is set while RunBuffer runs (see IncCounter)
+	buffer atomic.Pointer[Buffer]
+}
+
+// New creates the collectors and registers them with reg
+// Latency bucket bounds are in milliseconds (CHECK_LATENCY_BUCKETS, REDIS_LATENCY_BUCKETS)
+func New(reg prometheus.Registerer, checkLatencyBuckets, redisLatencyBuckets []float64) *Metrics {
+	factory := promauto.With(reg)
+	return &Metrics{
+		RequestsAllowed: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "requests_allowed_total",
+				Help: "Total number of requests allowed through the rate limiter",
+			},
+			// tier is bounded by METRIC_TIERS ("none" if unset, "other" if not listed)
+			// dry_run="true" counts decisions that were only observed, not enforced
+			[]string{"algorithm", "tier", "dry_run"},
+		),
+
+		RequestsBlocked: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "requests_blocked_total",
+				Help: "Total number of requests blocked by the rate limiter",
+			},
+			[]string{"algorithm", "tier", "dry_run"},
+		),
+
+		RequestsFailOpen: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "requests_fail_open_total",
+				Help: "Total number of requests allowed without a decision from Redis",
+			},
+			[]string{"algorithm"},
+		),
+
+		DenyCacheHits: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "deny_cache_hits_total",
+				Help: "Total number of checks blocked from the in-process deny cache",
+			},
+			[]string{"algorithm"},
+		),
+
+		RedisLatency: factory.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "redis_latency_ms",
+				Help:    "Redis operation latency in milliseconds",
+				Buckets: redisLatencyBuckets,
+			},
+		),
+
+		RedisErrors: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "redis_errors_total",
+				Help: "Total number of Redis errors encountered",
+			},
+		),
+
+		RedisRetries: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "redis_retries_total",
+				Help: "Total number of script calls retried after a transient Redis error",
+			},
+		),
+
+		ChecksShed: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "checks_shed_total",
+				Help: "Total number of script calls shed because too many were in flight",
+			},
+		),
+
+		CheckLatency: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "check_latency_ms",
+				Help:    "Rate limit check latency in milliseconds",
+				Buckets: checkLatencyBuckets,
+			},
+			[]string{"algorithm"},
+		),
+
+		CircuitBreakerState: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "circuit_breaker_state",
+				Help: "Redis circuit breaker state (0=closed, 1=open, 2=half-open)",
+			},
+		),
+
+		FillLevel: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "bucket_fill_ratio",
+				Help: "Smoothed average fraction of capacity in use across sampled checks",
+			},
+			[]string{"algorithm"},
+		),
+
+		RemainingRatio: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "remaining_ratio",
+				Help:    "Fraction of capacity left after each rate limit check",
+				Buckets: []float64{0, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+			},
+			[]string{"algorithm"},
+		),
+
+		WatchedRetryAfter: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "watched_key_retry_after_seconds",
+				Help:    "Retry-after of blocked checks on watched keys",
+				Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 3600},
+			},
+			[]string{"key"},
+		),
+
+		RedisPoolTotalConns: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_total_conns",
+				Help: "Connections currently open in the Redis pool",
+			},
+		),
+
+		RedisPoolIdleConns: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_idle_conns",
+				Help: "Idle connections in the Redis pool",
+			},
+		),
 
+		RedisPoolTimeouts: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "redis_pool_timeouts_total",
+				Help: "Total number of times waiting for a Redis pool connection timed out",
+			},
+		),
+	}
+}
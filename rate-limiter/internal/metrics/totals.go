@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Totals is a flat summary of this instance's decision counters
+// Read from the collectors themselves so the hot path doesn't maintain a second set of counters
+type Totals struct {
+	Allowed     float64 `json:"allowed"`
+	Blocked     float64 `json:"blocked"`
+	RedisErrors float64 `json:"redis_errors"`
+}
+
+// ReadTotals sums m's decision counters across labels
+func (m *Metrics) ReadTotals() (Totals, error) {
+	m.Flush()
+
+	var t Totals
+	var err error
+	if t.Allowed, err = sumCounter(m.RequestsAllowed); err != nil {
+		return Totals{}, err
+	}
+	if t.Blocked, err = sumCounter(m.RequestsBlocked); err != nil {
+		return Totals{}, err
+	}
+	if t.RedisErrors, err = sumCounter(m.RedisErrors); err != nil {
+		return Totals{}, err
+	}
+	return t, nil
+}
+
+// sumCounter adds up every series except dry runs, which weren't real decisions
+func sumCounter(c prometheus.Collector) (float64, error) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var total float64
+	var err error
+	for metric := range ch {
+		// Keep draining after an error so the collecting goroutine can finish
+		var m dto.Metric
+		if writeErr := metric.Write(&m); writeErr != nil {
+			err = writeErr
+			continue
+		}
+		if isDryRun(&m) {
+			continue
+		}
+		total += m.GetCounter().GetValue()
+	}
+	return total, err
+}
+
+func isDryRun(m *dto.Metric) bool {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == "dry_run" && label.GetValue() == "true" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package profiles
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/limiter"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+)
+
+// Profile is a named set of limit parameters (e.g. "free", "pro")
+// Checks that name a profile get these as defaults; explicit request fields still win
+type Profile struct {
+	Algorithm     string  `json:"algorithm"`
+	Capacity      int64   `json:"capacity"`
+	RefillRate    float64 `json:"refill_rate,omitempty"`    // token_bucket and gcra
+	WindowSeconds int64   `json:"window_seconds,omitempty"` // sliding_window and sliding_window_counter
+	LeakRate      float64 `json:"leak_rate,omitempty"`      // leaky_bucket
+	Period        string  `json:"period,omitempty"`         // quota: daily or monthly
+}
+
+// Store holds the current profile set and the version it was loaded from
+// The set is swapped whole, so readers never see a half-applied file
+type Store struct {
+	current atomic.Pointer[snapshot]
+}
+
+// snapshot is one loaded profiles file
+type snapshot struct {
+	profiles map[string]Profile
+	version  string
+}
+
+// NewStore creates a store with the given profiles (nil means none configured)
+func NewStore(profiles map[string]Profile) *Store {
+	s := &Store{}
+	s.Set(profiles, "")
+	return s
+}
+
+// Load reads PROFILES_FILE into a new store - an empty path gives an empty store
+func Load(path string) (*Store, error) {
+	if path == "" {
+		return NewStore(nil), nil
+	}
+	profiles, version, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{}
+	s.Set(profiles, version)
+	return s, nil
+}
+
+// ReadFile parses and validates a profiles file
+// The file is a JSON object keyed by profile name. The version is a hash of its contents
+func ReadFile(path string) (map[string]Profile, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("read profiles file: %w", err)
+	}
+
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, "", fmt.Errorf("parse profiles file: %w", err)
+	}
+
+	for name, p := range profiles {
+		if err := p.validate(); err != nil {
+			return nil, "", fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return profiles, fileVersion(data), nil
+}
+
+// fileVersion is short enough to eyeball in /health but still changes with any edit
+func fileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// validate catches profiles that could never produce a valid check
+// Per-algorithm parameters are left to request validation, since a request may supply them
+func (p Profile) validate() error {
+	if p.Algorithm != "" && !limiter.IsSupported(p.Algorithm) {
+		return fmt.Errorf("unsupported algorithm %q", p.Algorithm)
+	}
+	if p.Capacity < 0 || p.RefillRate < 0 || p.WindowSeconds < 0 || p.LeakRate < 0 {
+		return fmt.Errorf("limit parameters cannot be negative")
+	}
+	if p.Period != "" && !limiter.IsPeriod(p.Period) {
+		return fmt.Errorf("unsupported period %q", p.Period)
+	}
+	return nil
+}
+
+// Set replaces the whole profile set
+func (s *Store) Set(profiles map[string]Profile, version string) {
+	if profiles == nil {
+		profiles = map[string]Profile{}
+	}
+	s.current.Store(&snapshot{profiles: profiles, version: version})
+}
+
+// Get looks up a profile by name
+func (s *Store) Get(name string) (Profile, bool) {
+	p, ok := s.current.Load().profiles[name]
+	return p, ok
+}
+
+// Names lists the loaded profiles in sorted order
+func (s *Store) Names() []string {
+	profiles := s.current.Load().profiles
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Version identifies the loaded file (empty when no file is configured)
+func (s *Store) Version() string {
+	return s.current.Load().version
+}
+
+// Watch polls the profiles file and swaps in new contents when they change
+// A file that fails to read or validate is logged and skipped - the last good set stays live
+func (s *Store) Watch(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Only log a broken file once rather than on every tick until it's fixed
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			profiles, version, err := ReadFile(path)
+			if err != nil {
+				if err.Error() != lastErr {
+					logging.Printf("profiles reload rejected, keeping version %s: %v", s.Version(), err)
+					lastErr = err.Error()
+				}
+				continue
+			}
+			lastErr = ""
+			if version == s.Version() {
+				continue
+			}
+			s.Set(profiles, version)
+			logging.Printf("profiles reloaded from %s (version %s, %d profiles)", path, version, len(profiles))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is the cause attached to FailOpenErrors raised without calling Redis
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ErrLoadShed is the cause attached to FailOpenErrors for calls shed by MAX_INFLIGHT_CHECKS
+var ErrLoadShed = errors.New("too many checks in flight")
+
+// Circuit breaker states - values double as the circuit_breaker_state gauge value
+const (
+	breakerClosed   int32 = 0
+	breakerOpen     int32 = 1
+	breakerHalfOpen int32 = 2
+)
+
+// circuitBreaker stops us paying the full Redis timeout on every request while Redis is down
+// closed: calls go through, consecutive failures are counted
+// open: calls fail open immediately until the cooldown passes
+// half-open: a single probe call goes through; success closes, failure re-opens
+type circuitBreaker struct {
+	state atomic.Int32
+
+	// failing is set while failures > 0, so successes can skip the lock in the common case
+	failing atomic.Bool
+
+	mu           sync.Mutex
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	// stateGauge is circuit_breaker_state, kept in step with state
+	stateGauge prometheus.Gauge
+}
+
+func newCircuitBreaker(threshold int, window, cooldown time.Duration, stateGauge prometheus.Gauge) *circuitBreaker {
+	stateGauge.Set(float64(breakerClosed))
+	return &circuitBreaker{threshold: threshold, window: window, cooldown: cooldown, stateGauge: stateGauge}
+}
+
+// allow reports whether a call may go to Redis
+// Closed is checked without locking since that's the steady state on the hot path
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 || b.state.Load() == breakerClosed {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state.Load() {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return true
+
+	case breakerHalfOpen:
+		// Only one probe at a time - everyone else keeps failing open
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+
+	return true
+}
+
+// onSuccess records a call that reached Redis and got an answer
+func (b *circuitBreaker) onSuccess() {
+	if b.threshold <= 0 {
+		return
+	}
+	if b.state.Load() == breakerClosed && !b.failing.Load() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.failing.Store(false)
+	b.probing = false
+	b.setState(breakerClosed)
+}
+
+// onFailure records a call that triggered fail-open
+func (b *circuitBreaker) onFailure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state.Load() == breakerHalfOpen {
+		// Probe failed - back to open for another cooldown
+		b.probing = false
+		b.openedAt = now
+		b.setState(breakerOpen)
+		return
+	}
+
+	// Only consecutive failures inside the window count towards tripping
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	b.failing.Store(true)
+
+	if b.failures >= b.threshold {
+		b.openedAt = now
+		b.setState(breakerOpen)
+	}
+}
+
+// onAbandoned releases a half-open probe whose caller gave up (e.g. context cancelled)
+// without telling us anything about Redis health
+func (b *circuitBreaker) onAbandoned() {
+	if b.threshold <= 0 || b.state.Load() != breakerHalfOpen {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// setState must be called with mu held
+func (b *circuitBreaker) setState(state int32) {
+	b.state.Store(state)
+	b.stateGauge.Set(float64(state))
+}
+
+// stateName is the state as reported by /stats
+func (b *circuitBreaker) stateName() string {
+	switch b.state.Load() {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
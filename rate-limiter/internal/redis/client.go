@@ -2,83 +2,855 @@ package redis
 
 import (
 	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
-	"log"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer creates the Redis child spans - a no-op until tracing.Setup installs a provider
+var tracer = otel.Tracer("github.com/piyushpatra/rate-limiter/internal/redis")
+
+// backend is the go-redis client in use - a single node, a cluster or a Sentinel failover client
+// All implement UniversalClient, so the rest of this package doesn't care which
+// A script's companion keys ("{key}:counter" and so on) share its key's hash tag, so in
+// cluster mode one node holds them all; calls whose keys still span slots are rejected
+// with ErrCrossSlot before they're sent (see crossSlot)
+type backend struct {
+	redis.UniversalClient
+}
+
 type Client struct {
-	rdb *redis.Client
+	// rdb is swapped in atomically once a connection is established
+	// nil means we're still (re)connecting - callers get ErrNotReady instead of racing
+	rdb atomic.Pointer[backend]
 	cfg *config.Config
+
+	// shas caches script body -> SHA1 so the hot path doesn't rehash every call
+	shas sync.Map
+
+	breaker *circuitBreaker
+	metrics *metrics.Metrics
+
+	// inflight holds a slot per running script call - nil when MAX_INFLIGHT_CHECKS is 0
+	inflight chan struct{}
 }
 
+// ErrNotReady is returned while the client has no live connection
+var ErrNotReady = errors.New("redis client not connected")
+
 // NewClient creates a Redis client with connection pooling
 // Pool is pre-warmed to avoid cold start latency on first requests
-func NewClient(cfg *config.Config) (*Client, error) {
-	rdb := redis.NewClient(&redis.Options{
+func NewClient(cfg *config.Config, m *metrics.Metrics) (*Client, error) {
+	rdb, err := connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Println("Redis connection established successfully")
+
+	c := NewDisconnectedClient(cfg, m)
+	c.rdb.Store(rdb)
+	return c, nil
+}
+
+// NewDisconnectedClient returns a client with no connection yet
+// Checks follow the fail-open policy until Reconnect succeeds
+func NewDisconnectedClient(cfg *config.Config, m *metrics.Metrics) *Client {
+	c := &Client{
+		cfg:     cfg,
+		breaker: newCircuitBreaker(cfg.CircuitBreakerThreshold, cfg.CircuitBreakerWindow, cfg.CircuitBreakerCooldown, m.CircuitBreakerState),
+		metrics: m,
+	}
+	if cfg.MaxInflightChecks > 0 {
+		c.inflight = make(chan struct{}, cfg.MaxInflightChecks)
+	}
+	return c
+}
+
+// Reconnect retries connecting every interval until it succeeds or ctx is done
+// The new connection is published with a single atomic store, so in-flight checks
+// either see no client (fail open) or a fully verified one - never a half-ready one
+func (c *Client) Reconnect(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for !c.Ready() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		rdb, err := connect(c.cfg)
+		if err != nil {
+			logging.Printf("Redis reconnect failed: %v", err)
+			continue
+		}
+
+		c.rdb.Store(rdb)
+		logging.Println("✅ Redis reconnected")
+	}
+}
+
+// Ready reports whether a verified connection is available
+func (c *Client) Ready() bool {
+	return c.rdb.Load() != nil
+}
+
+// CircuitState is the circuit breaker's state: closed, open or half_open
+func (c *Client) CircuitState() string {
+	return c.breaker.stateName()
+}
+
+// conn returns the live client or ErrNotReady
+func (c *Client) conn() (redis.UniversalClient, error) {
+	if rdb := c.rdb.Load(); rdb != nil {
+		return rdb, nil
+	}
+	return nil, ErrNotReady
+}
+
+// connect builds the client for the configured topology and verifies it with a ping
+func connect(cfg *config.Config) (*backend, error) {
+	tlsCfg, err := cfg.RedisTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	rdb := newUniversalClient(cfg, tlsCfg)
+
+	// Verify connection before handing it out
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, err
+	}
+
+	return &backend{rdb}, nil
+}
+
+// newUniversalClient picks the go-redis client for the topology in cfg:
+// cluster seeds, Sentinel (failover to whichever node is master) or a single node
+// Validate guarantees at most one of them is configured
+func newUniversalClient(cfg *config.Config, tlsCfg *tls.Config) redis.UniversalClient {
+	switch {
+	case len(cfg.RedisClusterAddrs) > 0:
+		return newClusterClient(cfg, tlsCfg)
+	case len(cfg.RedisSentinelAddrs) > 0:
+		return newFailoverClient(cfg, tlsCfg)
+	default:
+		return newSingleClient(cfg, tlsCfg)
+	}
+}
+
+func newClusterClient(cfg *config.Config, tlsCfg *tls.Config) redis.UniversalClient {
+	// Pool settings apply per cluster node
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        cfg.RedisClusterAddrs,
+		Username:     cfg.RedisUsername,
+		Password:     cfg.RedisPassword,
+		TLSConfig:    tlsCfg,
+		PoolSize:     cfg.RedisPoolSize,
+		MinIdleConns: cfg.RedisMinIdleConns,
+
+		// go-redis follows MOVED/ASK itself (refreshing its slot map on MOVED), so a
+		// resharding is invisible to checks unless a key moves more often than this
+		MaxRedirects: cfg.RedisClusterMaxRedirects,
+		MaxRetries:   -1, // see newSingleClient
+
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  cfg.RedisTimeout,
+		WriteTimeout: cfg.RedisTimeout,
+		PoolTimeout:  1 * time.Second,
+	})
+}
+
+// newFailoverClient asks the sentinels for the current master and follows failovers
+// Only the master is used - reads from replicas could see stale limits
+func newFailoverClient(cfg *config.Config, tlsCfg *tls.Config) redis.UniversalClient {
+	return redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:    cfg.RedisMasterName,
+		SentinelAddrs: cfg.RedisSentinelAddrs,
+		Username:      cfg.RedisUsername,
+		Password:      cfg.RedisPassword,
+		TLSConfig:     tlsCfg,
+		DB:            cfg.RedisDB,
+		PoolSize:      cfg.RedisPoolSize,
+		MinIdleConns:  cfg.RedisMinIdleConns,
+		MaxRetries:    -1, // see newSingleClient
+
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  cfg.RedisTimeout,
+		WriteTimeout: cfg.RedisTimeout,
+		PoolTimeout:  1 * time.Second,
+	})
+}
+
+func newSingleClient(cfg *config.Config, tlsCfg *tls.Config) redis.UniversalClient {
+	return redis.NewClient(&redis.Options{
 		Addr:         cfg.RedisAddr,
+		Username:     cfg.RedisUsername,
 		Password:     cfg.RedisPassword,
+		TLSConfig:    tlsCfg,
 		DB:           cfg.RedisDB,
 		PoolSize:     cfg.RedisPoolSize,
 		MinIdleConns: cfg.RedisMinIdleConns,
-		
+
+		// go-redis would otherwise resend a failed command up to 3 times, and a resent
+		// script can count a request twice - REDIS_RETRY_BACKOFF is the only retry
+		MaxRetries: -1,
+
 		// These timeouts are critical for fail-open behavior
 		DialTimeout:  2 * time.Second,
 		ReadTimeout:  cfg.RedisTimeout,
 		WriteTimeout: cfg.RedisTimeout,
-		
+
 		// Pool timeout should be tight to avoid queueing requests
 		PoolTimeout: 1 * time.Second,
 	})
-
-	// Verify connection on startup
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		return nil, err
-	}
-
-	log.Println("Redis connection established successfully")
-
-	return &Client{
-		rdb: rdb,
-		cfg: cfg,
-	}, nil
 }
 
 // EvalLua executes a Lua script atomically
 // This is the core of our rate limiting - everything happens in one round trip
 func (c *Client) EvalLua(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
-	// Add timeout to context if not already present
-	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, c.cfg.RedisTimeout)
-		defer cancel()
+	ctx, cancel := c.withRedisTimeout(ctx)
+	defer cancel()
+
+	// The caller already gave up - don't tie up a connection on a reply nobody reads
+	if err := ctx.Err(); err != nil {
+		return nil, classifyScriptError(err)
 	}
 
-	result, err := c.rdb.Eval(ctx, script, keys, args...).Result()
-	
-	// Check if error is due to Redis being unavailable or timeout
-	// In production, we fail open to avoid cascading failures
-	if err != nil && shouldFailOpen(err) {
+	if c.crossSlot(keys) {
+		return nil, ErrCrossSlot
+	}
+
+	rdb, err := c.conn()
+	if err != nil {
+		// Still connecting - same policy as Redis being down
 		return nil, &FailOpenError{Cause: err}
 	}
+
+	// Already as many calls in flight as we allow - shed this one rather than queue it
+	if !c.acquireSlot() {
+		return nil, &FailOpenError{Cause: ErrLoadShed}
+	}
+	defer c.releaseSlot()
+
+	// Redis has been failing - skip the call instead of waiting out another timeout
+	if !c.breaker.allow() {
+		return nil, &FailOpenError{Cause: ErrCircuitOpen}
+	}
+
+	// Span covers just the round trip(s), so it shows how long Redis itself took
+	spanCtx, span := tracer.Start(ctx, "redis.eval", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "redis")))
+
+	result, err := c.evalScript(spanCtx, span, rdb, script, keys, args...)
+
+	// A single dropped connection shouldn't cost an authoritative decision - one more
+	// try, if it fits in what's left of the deadline
+	if err != nil && c.retryWait(ctx, err) {
+		span.AddEvent("retry")
+		c.metrics.RedisRetries.Inc()
+		result, err = c.evalScript(spanCtx, span, rdb, script, keys, args...)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
 	
+	switch {
+	case err != nil && callerGaveUp(ctx):
+		// The caller's own deadline or cancellation says nothing about Redis
+		c.breaker.onAbandoned()
+	case err != nil && shouldFailOpen(err):
+		// Redis is unavailable or slower than REDIS_TIMEOUT
+		c.breaker.onFailure()
+	default:
+		// Any real reply (even an error reply) means Redis is up
+		c.breaker.onSuccess()
+	}
+
+	// Unavailable or timed out comes back as a FailOpenError - in production we fail
+	// open to avoid cascading failures
+	if err != nil {
+		return nil, classifyScriptError(err)
+	}
+	return result, nil
+}
+
+// evalScript runs script by SHA, falling back to EVAL when Redis doesn't have it cached
+func (c *Client) evalScript(ctx context.Context, span trace.Span, rdb redis.UniversalClient, script string, keys []string, args ...interface{}) (interface{}, error) {
+	// EVALSHA avoids shipping the script body on every call
+	// NOSCRIPT means Redis doesn't have it (restart, SCRIPT FLUSH, failover) -
+	// EVAL runs it and loads it into the server's cache for next time
+	result, err := rdb.EvalSha(ctx, c.scriptSHA(script), keys, args...).Result()
+	if err != nil && isNoScript(err) {
+		span.AddEvent("noscript fallback")
+		result, err = rdb.Eval(ctx, script, keys, args...).Result()
+	}
 	return result, err
 }
 
+// retryWait sleeps a jittered REDIS_RETRY_BACKOFF before a retry of a call that failed
+// with err. It reports false, without waiting, when the retry is off, err isn't a network
+// error, or the wait plus another attempt wouldn't fit before ctx's deadline
+// A retry can count a request twice if Redis ran the script but the reply was lost,
+// which is why it's opt-in
+func (c *Client) retryWait(ctx context.Context, err error) bool {
+	backoff := c.cfg.RedisRetryBackoff
+	// Timeouts and cancellations end ctx, so only fast failures (reset, refused, EOF) get here
+	if backoff <= 0 || ctx.Err() != nil || !isNetworkError(err) {
+		return false
+	}
+
+	// Half to all of the backoff, so clients that lost the same connection don't retry in step
+	wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < 2*wait {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// ScriptCall is a single script invocation within EvalLuaBatch
+type ScriptCall struct {
+	Script string
+	Keys   []string
+	Args   []interface{}
+}
+
+// ScriptResult is the reply to one ScriptCall - Err is classified the same way EvalLua does
+type ScriptResult struct {
+	Value interface{}
+	Err   error
+}
+
+// EvalLuaBatch runs several scripts in one pipelined round trip of EVALSHAs, whatever
+// mix of algorithms the calls use. Results come back in call order; a failing call
+// doesn't affect the others
+func (c *Client) EvalLuaBatch(ctx context.Context, calls []ScriptCall) []ScriptResult {
+	results := make([]ScriptResult, len(calls))
+
+	ctx, cancel := c.withRedisTimeout(ctx)
+	defer cancel()
+
+	if err := ctx.Err(); err != nil {
+		for i := range results {
+			results[i].Err = classifyScriptError(err)
+		}
+		return results
+	}
+
+	rdb, err := c.conn()
+	if err == nil {
+		// The whole batch is one round trip, so it takes one slot
+		if !c.acquireSlot() {
+			err = ErrLoadShed
+		} else {
+			defer c.releaseSlot()
+		}
+	}
+	if err == nil && !c.breaker.allow() {
+		err = ErrCircuitOpen
+	}
+	if err != nil {
+		for i := range results {
+			results[i].Err = &FailOpenError{Cause: err}
+		}
+		return results
+	}
+
+	// A call whose keys span cluster slots fails on its own; the rest still go out
+	send := make([]int, 0, len(calls))
+	for i, call := range calls {
+		if c.crossSlot(call.Keys) {
+			results[i].Err = ErrCrossSlot
+			continue
+		}
+		send = append(send, i)
+	}
+
+	// Per-command errors are read off each Cmd below, so the pipeline's own error is ignored
+	cmds, _ := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, i := range send {
+			pipe.EvalSha(ctx, c.scriptSHA(calls[i].Script), calls[i].Keys, calls[i].Args...)
+		}
+		return nil
+	})
+
+	// Scripts Redis hasn't cached (restart, SCRIPT FLUSH, failover) are loaded once each
+	// rather than shipped as EVAL with every call that missed - a cold batch of 100 checks
+	// on one algorithm sends the body once. SCRIPT LOAD reaches every master in cluster
+	// mode, so the retried EVALSHAs find it whichever node their key lives on
+	var retry []int
+	for j, cmd := range cmds {
+		i := send[j]
+		results[i].Value, results[i].Err = cmd.(*redis.Cmd).Result()
+		if results[i].Err != nil && isNoScript(results[i].Err) {
+			retry = append(retry, i)
+		}
+	}
+	if len(retry) > 0 {
+		loadErrs := make(map[string]error)
+		resend := retry[:0]
+		for _, i := range retry {
+			script := calls[i].Script
+			err, loaded := loadErrs[script]
+			if !loaded {
+				err = c.LoadScript(ctx, script)
+				loadErrs[script] = err
+			}
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			resend = append(resend, i)
+		}
+
+		if len(resend) > 0 {
+			cmds, _ = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, i := range resend {
+					pipe.EvalSha(ctx, c.scriptSHA(calls[i].Script), calls[i].Keys, calls[i].Args...)
+				}
+				return nil
+			})
+			for j, cmd := range cmds {
+				i := resend[j]
+				results[i].Value, results[i].Err = cmd.(*redis.Cmd).Result()
+			}
+		}
+	}
+
+	// One round trip, so the breaker sees one outcome
+	failed := false
+	for i := range results {
+		if results[i].Err == nil || results[i].Err == ErrCrossSlot {
+			continue
+		}
+		if shouldFailOpen(results[i].Err) {
+			failed = true
+		}
+		results[i].Value = nil
+		results[i].Err = classifyScriptError(results[i].Err)
+	}
+
+	switch {
+	case callerGaveUp(ctx):
+		c.breaker.onAbandoned()
+	case failed:
+		c.breaker.onFailure()
+	default:
+		c.breaker.onSuccess()
+	}
+
+	return results
+}
+
+// acquireSlot takes an in-flight slot without waiting, reporting false when none is free
+// Waiting is what a slow Redis makes everyone do already - a full semaphore means the
+// pool would only queue this call behind the others
+func (c *Client) acquireSlot() bool {
+	if c.inflight == nil {
+		return true
+	}
+	select {
+	case c.inflight <- struct{}{}:
+		return true
+	default:
+		c.metrics.ChecksShed.Inc()
+		return false
+	}
+}
+
+// releaseSlot frees a slot taken by acquireSlot
+func (c *Client) releaseSlot() {
+	if c.inflight != nil {
+		<-c.inflight
+	}
+}
+
+// errRedisTimeout is the cause recorded when REDIS_TIMEOUT, not the caller, ends a call
+var errRedisTimeout = errors.New("redis timeout")
+
+// withRedisTimeout bounds ctx by REDIS_TIMEOUT
+// A caller deadline that's sooner still wins, so a client that aborts early frees
+// the connection early too
+func (c *Client) withRedisTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, c.cfg.RedisTimeout, errRedisTimeout)
+}
+
+// callerGaveUp reports whether ctx ended because of the caller (cancellation or its
+// own deadline) rather than REDIS_TIMEOUT
+func callerGaveUp(ctx context.Context) bool {
+	return ctx.Err() != nil && !errors.Is(context.Cause(ctx), errRedisTimeout)
+}
+
+// crossSlot reports whether keys can't all be served by one cluster node
+// Outside cluster mode every key lives on the same server, so nothing is cross-slot
+func (c *Client) crossSlot(keys []string) bool {
+	return len(c.cfg.RedisClusterAddrs) > 0 && !sameSlot(keys)
+}
+
+// classifyScriptError maps a script call error onto the error types callers branch on
+func classifyScriptError(err error) error {
+	if shouldFailOpen(err) {
+		return &FailOpenError{Cause: err}
+	}
+
+	// A slot kept moving after go-redis had followed all its redirects. Redis answered, so
+	// this isn't a fail-open case (nor a breaker failure) - the caller should just retry
+	if isRedirect(err) {
+		return fmt.Errorf("%w: %v", ErrClusterRedirect, err)
+	}
+
+	// Redis's own check, for keys that reach a cluster some other way than crossSlot
+	if strings.HasPrefix(err.Error(), "CROSSSLOT") {
+		return fmt.Errorf("%w: %v", ErrCrossSlot, err)
+	}
+
+	// Same key used with a different algorithm (e.g. hash vs sorted set)
+	if strings.Contains(err.Error(), "WRONGTYPE") {
+		return fmt.Errorf("%w: %v", ErrWrongType, err)
+	}
+
+	// The script itself rejected the input (error_reply or a Lua runtime error)
+	if isScriptError(err) {
+		return &ScriptError{Message: err.Error()}
+	}
+
+	return err
+}
+
+// scriptSHA returns the SHA1 Redis uses to identify a script body
+func (c *Client) scriptSHA(script string) string {
+	if sha, ok := c.shas.Load(script); ok {
+		return sha.(string)
+	}
+
+	sum := sha1.Sum([]byte(script))
+	sha := hex.EncodeToString(sum[:])
+	c.shas.Store(script, sha)
+	return sha
+}
+
+// isNoScript reports whether Redis didn't recognise the script SHA
+func isNoScript(err error) bool {
+	return strings.Contains(err.Error(), "NOSCRIPT")
+}
+
 // Ping checks Redis connectivity - used by health endpoint
 func (c *Client) Ping(ctx context.Context) error {
-	return c.rdb.Ping(ctx).Err()
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return rdb.Ping(ctx).Err()
+}
+
+// Time returns the Redis server's clock (TIME)
+func (c *Client) Time(ctx context.Context) (time.Time, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return rdb.Time(ctx).Result()
+}
+
+// ScanAll iterates every key matching pattern and hands each SCAN page to fn
+// Incremental, so it never blocks Redis the way KEYS would. In cluster mode each
+// master is scanned in turn, since SCAN only sees the keys on the node it runs on.
+func (c *Client) ScanAll(ctx context.Context, match string, count int64, fn func(keys []string) error) error {
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+
+	scanNode := func(ctx context.Context, node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, match, count).Result()
+			if err != nil {
+				return err
+			}
+			if err := fn(keys); err != nil {
+				return err
+			}
+			if cursor = next; cursor == 0 {
+				return nil
+			}
+		}
+	}
+
+	if cluster, ok := rdb.(*redis.ClusterClient); ok {
+		// fn isn't required to be concurrency-safe, so walk the masters one at a time
+		var mu sync.Mutex
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return scanNode(ctx, node)
+		})
+	}
+	return scanNode(ctx, rdb)
+}
+
+// Pipelined sends every command queued by fn in a single round trip
+func (c *Client) Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	return rdb.Pipelined(ctx, fn)
+}
+
+// Set stores a plain string value, ttl of 0 means no expiry
+func (c *Client) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return rdb.Set(ctx, key, value, ttl).Err()
+}
+
+// HSet sets one field of a hash
+func (c *Client) HSet(ctx context.Context, key, field string, value interface{}) error {
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return rdb.HSet(ctx, key, field, value).Err()
+}
+
+// HDel removes one field of a hash, a missing field is ignored
+func (c *Client) HDel(ctx context.Context, key, field string) error {
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return rdb.HDel(ctx, key, field).Err()
+}
+
+// HGetAll reads every field of a hash - empty if the key doesn't exist
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	return rdb.HGetAll(ctx, key).Result()
+}
+
+// Exists reports whether key is present
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return false, err
+	}
+	n, err := rdb.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+// Del removes keys, missing keys are ignored
+func (c *Client) Del(ctx context.Context, keys ...string) error {
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return rdb.Del(ctx, keys...).Err()
+}
+
+// Unlink removes keys without blocking on large values and returns how many existed
+// Sent as one UNLINK per key in a pipeline, since a multi-key UNLINK fails in cluster
+// mode when the keys span slots
+func (c *Client) Unlink(ctx context.Context, keys ...string) (int64, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return 0, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Unlink(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, cmd := range cmds {
+		removed += cmd.Val()
+	}
+	return removed, nil
+}
+
+// LoadScript caches a script body in Redis (SCRIPT LOAD) so the first EVALSHA hits
+// In cluster mode go-redis sends it to every master
+func (c *Client) LoadScript(ctx context.Context, script string) error {
+	rdb, err := c.conn()
+	if err != nil {
+		return err
+	}
+	sha, err := rdb.ScriptLoad(ctx, script).Result()
+	if err != nil {
+		return err
+	}
+	c.shas.Store(script, sha)
+	return nil
+}
+
+// PoolStats returns the connection pool counters (hits, misses, timeouts, conns)
+// Zero while disconnected. In cluster mode the counts are summed across nodes
+func (c *Client) PoolStats() redis.PoolStats {
+	rdb, err := c.conn()
+	if err != nil {
+		return redis.PoolStats{}
+	}
+	return *rdb.PoolStats()
+}
+
+// RecordPoolStats samples PoolStats into the pool metrics every interval until ctx is done
+func (c *Client) RecordPoolStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastTimeouts uint32
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats := c.PoolStats()
+		c.metrics.RedisPoolTotalConns.Set(float64(stats.TotalConns))
+		c.metrics.RedisPoolIdleConns.Set(float64(stats.IdleConns))
+
+		// go-redis keeps a running total; a reconnect starts a new pool from zero
+		if stats.Timeouts < lastTimeouts {
+			lastTimeouts = 0
+		}
+		c.metrics.RedisPoolTimeouts.Add(float64(stats.Timeouts - lastTimeouts))
+		lastTimeouts = stats.Timeouts
+	}
+}
+
+// ConfigGet reads a server config parameter (e.g. notify-keyspace-events)
+func (c *Client) ConfigGet(ctx context.Context, parameter string) (map[string]string, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	return rdb.ConfigGet(ctx, parameter).Result()
+}
+
+// SubscribeExpired subscribes to key expiry events for the configured DB
+// Requires notify-keyspace-events to include E and x (or A) on the server
+// In cluster mode keyevents are node-local, so this only sees one node's expiries
+func (c *Client) SubscribeExpired(ctx context.Context) (*redis.PubSub, error) {
+	rdb, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	return rdb.Subscribe(ctx, fmt.Sprintf("__keyevent@%d__:expired", c.cfg.RedisDB)), nil
 }
 
 // Close gracefully closes the Redis connection pool
 func (c *Client) Close() error {
-	return c.rdb.Close()
+	rdb := c.rdb.Swap(nil)
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
+}
+
+// ErrClusterRedirect means a command was still being redirected (MOVED/ASK/TRYAGAIN) when
+// REDIS_CLUSTER_MAX_REDIRECTS ran out - the slot is mid-migration and a retry will land.
+// Also seen when REDIS_ADDR points at a single node of a cluster
+var ErrClusterRedirect = errors.New("redis cluster slot is moving")
+
+// redirectPrefixes are the replies a cluster sends while a key's slot is moving
+var redirectPrefixes = []string{"MOVED ", "ASK ", "TRYAGAIN"}
+
+// isRedirect reports whether err is a cluster redirection reply
+func isRedirect(err error) bool {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return false
+	}
+	msg := err.Error()
+	for _, prefix := range redirectPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrWrongType means the key already holds data of another type in Redis
+// Usually two callers sharing a key with different algorithms
+var ErrWrongType = errors.New("key holds data of a different type")
+
+// ScriptError is an error raised by the Lua script's own logic, not by Redis infrastructure
+// These are deterministic - retrying or failing open won't help, the input needs fixing
+type ScriptError struct {
+	Message string
+}
+
+func (e *ScriptError) Error() string {
+	return "lua script error: " + e.Message
+}
+
+// Server replies that mean Redis itself is unhappy rather than the script
+var infraErrorPrefixes = []string{
+	"LOADING", "READONLY", "MASTERDOWN", "BUSY", "NOSCRIPT", "NOAUTH", "NOPERM",
+	"MOVED", "ASK", "CLUSTERDOWN", "TRYAGAIN", "OOM",
+}
+
+// isScriptError reports whether err is an error reply produced while running the script
+func isScriptError(err error) bool {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) || errors.Is(err, redis.Nil) {
+		return false
+	}
+
+	msg := err.Error()
+	for _, prefix := range infraErrorPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return false
+		}
+	}
+	return true
 }
 
 // FailOpenError signals that we should allow the request due to Redis issues
@@ -107,7 +879,7 @@ func shouldFailOpen(err error) bool {
 	}
 	
 	// Connection errors mean Redis is down
-	// TODO: might want to add circuit breaker here to avoid hammering dead Redis
+	// Repeated failures trip the circuit breaker in EvalLua, so we stop hammering it
 	if errors.Is(err, context.Canceled) {
 		return false // Don't fail open on explicit cancellation
 	}
@@ -117,26 +889,22 @@ func shouldFailOpen(err error) bool {
 }
 
 func isNetworkError(err error) bool {
-	// go-redis wraps network errors, so we check the error message
-	// Not ideal but works reliably in practice
-	errMsg := err.Error()
-	return contains(errMsg, "connection refused") ||
-		contains(errMsg, "connection reset") ||
-		contains(errMsg, "broken pipe") ||
-		contains(errMsg, "i/o timeout")
-}
-
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && 
-		(s == substr || len(s) > len(substr) && containsSlow(s, substr))
-}
+	// Dial/read/write failures surface as net.Error (*net.OpError etc.)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
 
-func containsSlow(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+	// Server closed the connection mid-reply (e.g. during failover)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
 	}
-	return false
-}
 
+	// go-redis doesn't always keep the original error wrapped, so fall back to the message
+	errMsg := err.Error()
+	return strings.Contains(errMsg, "connection refused") ||
+		strings.Contains(errMsg, "connection reset") ||
+		strings.Contains(errMsg, "broken pipe") ||
+		strings.Contains(errMsg, "i/o timeout") ||
+		strings.Contains(errMsg, "no route to host")
+}
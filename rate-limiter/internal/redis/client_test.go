@@ -0,0 +1,182 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestClient starts miniredis and connects a Client to it
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisAddr = mr.Addr()
+	cfg.RedisTimeout = time.Second
+	c, err := NewClient(cfg, metrics.New(prometheus.NewRegistry(), nil, nil))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, mr
+}
+
+// replyError is an error reply as go-redis returns it
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+func (replyError) RedisError()     {}
+
+func TestEvalLuaReportsScriptErrors(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"error_reply", "return redis.error_reply('invalid arguments: capacity must be positive')"},
+		{"runtime error", "local x = nil; return x.field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.EvalLua(ctx, tt.script, []string{"user:1"})
+			var scriptErr *ScriptError
+			if !errors.As(err, &scriptErr) {
+				t.Fatalf("err = %v (%T), want a *ScriptError", err, err)
+			}
+			var failOpen *FailOpenError
+			if errors.As(err, &failOpen) {
+				t.Error("a script error was treated as Redis being unavailable")
+			}
+		})
+	}
+
+	_, err := c.EvalLua(ctx, "return redis.error_reply('invalid arguments: capacity must be positive')", []string{"user:1"})
+	if want := "lua script error: invalid arguments: capacity must be positive"; err.Error() != want {
+		t.Errorf("message = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestClassifyScriptErrorKeepsInfrastructureErrorsApart(t *testing.T) {
+	for _, msg := range []string{
+		"READONLY You can't write against a read only replica.",
+		"BUSY Redis is busy running a script.",
+		"OOM command not allowed when used memory > 'maxmemory'.",
+		"NOPERM this user has no permissions to run the 'evalsha' command",
+	} {
+		var scriptErr *ScriptError
+		if errors.As(classifyScriptError(replyError(msg)), &scriptErr) {
+			t.Errorf("%q classified as a script error", msg)
+		}
+	}
+
+	var scriptErr *ScriptError
+	if !errors.As(classifyScriptError(replyError("ERR user_script:12: bad argument")), &scriptErr) {
+		t.Error("a Lua runtime error wasn't classified as a script error")
+	}
+	if err := classifyScriptError(replyError("WRONGTYPE Operation against a key holding the wrong kind of value")); !errors.Is(err, ErrWrongType) {
+		t.Errorf("WRONGTYPE classified as %v, want ErrWrongType", err)
+	}
+}
+
+func TestShouldFailOpen(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"wrapped deadline", fmt.Errorf("eval: %w", context.DeadlineExceeded), true},
+		{"cancelled", context.Canceled, false},
+		{"net.Error", dialErr, true},
+		{"wrapped net.Error", fmt.Errorf("eval: %w", dialErr), true},
+		{"EOF", io.EOF, true},
+		{"unexpected EOF", fmt.Errorf("reading reply: %w", io.ErrUnexpectedEOF), true},
+		{"connection refused", errors.New("dial tcp 10.0.0.1:6379: connect: connection refused"), true},
+		{"connection reset", errors.New("read tcp 10.0.0.2:5000->10.0.0.1:6379: read: connection reset by peer"), true},
+		{"broken pipe", errors.New("write tcp 10.0.0.2:5000->10.0.0.1:6379: write: broken pipe"), true},
+		{"i/o timeout", errors.New("read tcp 10.0.0.2:5000->10.0.0.1:6379: i/o timeout"), true},
+		{"no route to host", errors.New("dial tcp 10.0.0.1:6379: connect: no route to host"), true},
+		{"script error", replyError("ERR user_script:1: bad argument"), false},
+		{"wrong type", replyError("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{"other", errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldFailOpen(tt.err); got != tt.want {
+				t.Errorf("shouldFailOpen(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalLuaReloadsFlushedScripts(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	rdb, err := c.conn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "return redis.call('INCRBY', KEYS[1], ARGV[1])"
+	sha := c.scriptSHA(script)
+
+	cached := func() bool {
+		t.Helper()
+		exists, err := rdb.ScriptExists(ctx, sha).Result()
+		if err != nil {
+			t.Fatalf("SCRIPT EXISTS: %v", err)
+		}
+		return exists[0]
+	}
+
+	for i, want := range []int64{2, 4} {
+		if err := rdb.ScriptFlush(ctx).Err(); err != nil {
+			t.Fatalf("SCRIPT FLUSH: %v", err)
+		}
+		if cached() {
+			t.Fatal("script still cached after SCRIPT FLUSH")
+		}
+
+		// EVALSHA hits NOSCRIPT, and the EVAL fallback both answers and reloads the script
+		got, err := c.EvalLua(ctx, script, []string{"counter"}, 2)
+		if err != nil {
+			t.Fatalf("call %d after SCRIPT FLUSH: %v", i+1, err)
+		}
+		if got != want {
+			t.Errorf("call %d = %v, want %d", i+1, got, want)
+		}
+		if !cached() {
+			t.Errorf("call %d didn't reload the script", i+1)
+		}
+	}
+
+	if err := rdb.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("SCRIPT FLUSH: %v", err)
+	}
+	results := c.EvalLuaBatch(ctx, []ScriptCall{
+		{Script: script, Keys: []string{"counter"}, Args: []interface{}{1}},
+		{Script: script, Keys: []string{"counter"}, Args: []interface{}{1}},
+	})
+	for i, r := range results {
+		if want := int64(5 + i); r.Err != nil || r.Value != want {
+			t.Errorf("batch call %d = %v, %v, want %d", i+1, r.Value, r.Err, want)
+		}
+	}
+	if !cached() {
+		t.Error("the batch didn't reload the script")
+	}
+}
@@ -0,0 +1,96 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestClusterClient connects a cluster-mode Client to miniredis, which answers
+// CLUSTER SLOTS as a single node owning every slot
+func newTestClusterClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisClusterAddrs = []string{mr.Addr()}
+	cfg.RedisClusterMaxRedirects = 3
+	cfg.RedisTimeout = time.Second
+	c, err := NewClient(cfg, metrics.New(prometheus.NewRegistry(), nil, nil))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, mr
+}
+
+// movedOnce answers its first call with a MOVED redirect to ARGV[1] - the node it's
+// already on - the way a node replies while a slot is being migrated away
+const movedOnce = `
+if redis.call('GET', KEYS[1] .. ':moved') then
+    return 'ok'
+end
+redis.call('SET', KEYS[1] .. ':moved', '1')
+return redis.error_reply('MOVED 0 ' .. ARGV[1])`
+
+// alwaysMoved never stops redirecting
+const alwaysMoved = `return redis.error_reply('MOVED 0 ' .. ARGV[1])`
+
+func TestEvalLuaFollowsMovedRedirect(t *testing.T) {
+	c, mr := newTestClusterClient(t)
+
+	result, err := c.EvalLua(context.Background(), movedOnce, []string{"user:1"}, mr.Addr())
+	if err != nil {
+		t.Fatalf("EvalLua: %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("result = %v, want the retried call's ok", result)
+	}
+	if !mr.Exists("user:1:moved") {
+		t.Error("the script never saw the first call, so no redirect was followed")
+	}
+}
+
+func TestEvalLuaReportsEndlessRedirectsAsRedirects(t *testing.T) {
+	c, mr := newTestClusterClient(t)
+
+	_, err := c.EvalLua(context.Background(), alwaysMoved, []string{"user:1"}, mr.Addr())
+	if !errors.Is(err, ErrClusterRedirect) {
+		t.Fatalf("err = %v, want ErrClusterRedirect", err)
+	}
+	var failOpen *FailOpenError
+	if errors.As(err, &failOpen) {
+		t.Error("a redirect was treated as a fail-open condition")
+	}
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) {
+		t.Error("a redirect was treated as a script error")
+	}
+}
+
+func TestClassifyScriptErrorRecognizesRedirects(t *testing.T) {
+	for _, msg := range []string{
+		"MOVED 3999 127.0.0.1:6381",
+		"ASK 3999 127.0.0.1:6381",
+		"TRYAGAIN Multiple keys request during rehashing of slot",
+	} {
+		err := classifyScriptError(replyError(msg))
+		if !errors.Is(err, ErrClusterRedirect) {
+			t.Errorf("%q classified as %v, want ErrClusterRedirect", msg, err)
+		}
+		var failOpen *FailOpenError
+		if errors.As(err, &failOpen) {
+			t.Errorf("%q classified as fail open", msg)
+		}
+	}
+
+	// Only replies from Redis count - a message that merely starts the same way doesn't
+	if isRedirect(errors.New("MOVED 3999 127.0.0.1:6381")) {
+		t.Error("isRedirect matched a plain error")
+	}
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvalLuaSkipsCallsForExpiredContexts(t *testing.T) {
+	c, mr := newTestClient(t)
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantFailOpen bool
+	}{
+		{name: "deadline passed", ctx: expired, wantFailOpen: true},
+		{name: "cancelled", ctx: cancelled, wantFailOpen: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := mr.CommandCount()
+			_, err := c.EvalLua(tt.ctx, "return 1", []string{"user:1"})
+			if err == nil {
+				t.Fatal("EvalLua succeeded on a finished context")
+			}
+			var failOpen *FailOpenError
+			if errors.As(err, &failOpen) != tt.wantFailOpen {
+				t.Errorf("err = %v, want fail open %v", err, tt.wantFailOpen)
+			}
+
+			results := c.EvalLuaBatch(tt.ctx, []ScriptCall{{Script: "return 1", Keys: []string{"user:1"}}})
+			if results[0].Err == nil {
+				t.Error("EvalLuaBatch succeeded on a finished context")
+			}
+
+			if n := mr.CommandCount() - before; n != 0 {
+				t.Errorf("%d commands reached Redis, want none", n)
+			}
+		})
+	}
+}
+
+func TestWithRedisTimeoutKeepsTheSoonerDeadline(t *testing.T) {
+	c, _ := newTestClient(t) // REDIS_TIMEOUT 1s
+
+	tight, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ctx, cancel := c.withRedisTimeout(tight)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > 10*time.Millisecond {
+		t.Errorf("deadline in %v, want the caller's 10ms", time.Until(deadline))
+	}
+	<-ctx.Done()
+	if !callerGaveUp(ctx) {
+		t.Error("callerGaveUp = false after the caller's own deadline")
+	}
+
+	loose, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	ctx, cancel = c.withRedisTimeout(loose)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Second {
+		t.Errorf("deadline in %v, want REDIS_TIMEOUT's 1s", time.Until(deadline))
+	}
+}
+
+func TestCallerGaveUpIgnoresRedisTimeout(t *testing.T) {
+	c, _ := newTestClient(t)
+	c.cfg.RedisTimeout = time.Millisecond
+
+	ctx, cancel := c.withRedisTimeout(context.Background())
+	defer cancel()
+	<-ctx.Done()
+	if callerGaveUp(ctx) {
+		t.Error("callerGaveUp = true when REDIS_TIMEOUT ran out")
+	}
+}
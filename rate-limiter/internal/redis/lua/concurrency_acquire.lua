@@ -0,0 +1,47 @@
+-- Concurrency Limiter: acquire a lease
+-- Caps how many operations can be in flight per key at once. Each holder gets a lease
+-- in a sorted set scored by its expiry, so a client that crashes without releasing
+-- only holds its slot until the lease runs out
+-- KEYS[1]: concurrency key (e.g., "{ratelimit:user:123}:leases")
+-- ARGV[1]: limit (max leases held at once)
+-- ARGV[2]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- ARGV[3]: lease_ms (how long the lease lasts if it's never released)
+-- ARGV[4]: token (unique lease id, released with concurrency_release.lua)
+-- Returns: {acquired (1 or 0), remaining_slots, retry_after_ms}
+
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+if ARGV[2] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local lease_ms = tonumber(ARGV[3])
+local token = ARGV[4]
+
+if not limit or limit <= 0 or not now or not lease_ms or lease_ms <= 0 or not token or token == '' then
+    return redis.error_reply('invalid arguments: limit, now and lease must be positive and token non-empty')
+end
+
+-- Expired leases belong to holders that never released - free their slots
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now)
+
+local held = redis.call('ZCARD', key)
+if held >= limit then
+    -- The earliest a slot frees up without a release is when the oldest lease expires
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    local retry_after_ms = 0
+    if oldest[2] then
+        retry_after_ms = math.max(0, tonumber(oldest[2]) - now)
+    end
+    return {0, 0, retry_after_ms}
+end
+
+redis.call('ZADD', key, now + lease_ms, token)
+
+-- Keep the set around as long as its longest lease
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+redis.call('PEXPIRE', key, math.max(1, tonumber(newest[2]) - now))
+
+return {1, limit - held - 1, 0}
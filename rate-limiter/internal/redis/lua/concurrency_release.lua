@@ -0,0 +1,6 @@
+-- Concurrency Limiter: release a lease
+-- KEYS[1]: concurrency key
+-- ARGV[1]: token returned by concurrency_acquire.lua
+-- Returns: 1 if the lease was held, 0 if it was unknown or had already expired
+
+return redis.call('ZREM', KEYS[1], ARGV[1])
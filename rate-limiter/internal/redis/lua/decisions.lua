@@ -0,0 +1,31 @@
+-- Per-Key Decision Counts
+-- Not a script on its own: limiter/decisions.go appends it to a check script (an algorithm's,
+-- or one already wrapped with penalty.lua), whose body becomes decision_check(KEYS, ARGV), so
+-- the counts are updated in the same call that makes the decision
+-- KEYS: the check's own keys, followed by
+--   KEYS[#KEYS]: decision counts (e.g., "{ratelimit:user:123}:decisions" - same hash slot as
+--                KEYS[1]), a hash with fields allowed and denied
+-- ARGV: the check's own arguments, followed by
+--   ARGV[#ARGV - 1]: ttl_ms (how long the counts outlive the key's last counted check)
+--   ARGV[#ARGV]: dry_run ("1" leaves the counts alone)
+-- Returns: whatever the check returns
+
+local decisions_key = KEYS[#KEYS]
+local decisions_ttl_ms = tonumber(ARGV[#ARGV - 1])
+local decisions_dry_run = ARGV[#ARGV] == '1'
+
+if not decisions_ttl_ms or decisions_ttl_ms <= 0 then
+    return redis.error_reply('invalid arguments: decision counts ttl must be positive')
+end
+
+-- The check sees only its own keys and arguments, so one that reads from the end of
+-- them (penalty.lua) finds what it expects
+local result = decision_check({unpack(KEYS, 1, #KEYS - 1)}, {unpack(ARGV, 1, #ARGV - 2)})
+if result.err or decisions_dry_run then
+    return result
+end
+
+redis.call('HINCRBY', decisions_key, result[1] == 1 and 'allowed' or 'denied', 1)
+redis.call('PEXPIRE', decisions_key, decisions_ttl_ms)
+
+return result
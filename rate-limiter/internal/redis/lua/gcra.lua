@@ -0,0 +1,73 @@
+-- GCRA (Generic Cell Rate Algorithm) Rate Limiter
+-- Stores a single value per key: the theoretical arrival time (TAT) of the next request
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:user:123")
+-- ARGV[1]: capacity (burst size)
+-- ARGV[2]: rate (requests per second - the emission rate)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- ARGV[4]: cost (units this request consumes, defaults to 1)
+-- ARGV[5]: dry_run ("1" computes the decision without writing any state)
+-- ARGV[6]: ttl_jitter (fraction, e.g. 0.07, added to the key TTL so keys created together don't expire together)
+-- Returns: {allowed (1 or 0), remaining_cells, retry_after_ms, reset_ms}
+--   reset_ms: unix ms when the next cell frees up (now if the key is fully replenished)
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local cost = tonumber(ARGV[4]) or 1
+local dry_run = ARGV[5] == '1'
+local ttl_jitter = tonumber(ARGV[6]) or 0
+
+-- Reject bad input with an error reply rather than corrupting state
+if not capacity or capacity <= 0 or not rate or rate <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, rate and now must be positive numbers')
+end
+if cost <= 0 or cost > capacity then
+    return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+end
+
+-- A cell frees up every emission interval as time catches up with the TAT
+-- remaining goes up by one once now + burst_tolerance - tat reaches the next interval
+local function gcra_reset_ms(tat, now, remaining, emission_interval, burst_tolerance)
+    if tat <= now then
+        return now
+    end
+    return math.max(now, math.ceil(tat - burst_tolerance + (remaining + 1) * emission_interval))
+end
+
+-- One request is "emitted" every interval; up to capacity of them may arrive early
+local emission_interval = 1000 / rate
+local burst_tolerance = emission_interval * capacity
+
+-- A TAT in the past means the key has been idle - nothing is owed
+local tat = tonumber(redis.call('GET', key))
+if tat == nil or tat < now then
+    tat = now
+end
+
+local new_tat = tat + emission_interval * cost
+local allow_at = new_tat - burst_tolerance
+
+-- Too early: the request would push TAT past the burst allowance
+-- remaining still reports what a cheaper request could use
+if now < allow_at then
+    local remaining = math.max(0, math.floor((now + burst_tolerance - tat) / emission_interval))
+    return {0, remaining, math.ceil(allow_at - now), gcra_reset_ms(tat, now, remaining, emission_interval, burst_tolerance)}
+end
+
+-- The key expires once its TAT is reached, i.e. once it's fully replenished (plus jitter -
+-- a TAT in the past reads the same as a missing key)
+-- Dry runs only report the decision
+if not dry_run then
+    redis.call('SET', key, new_tat, 'PX', math.max(1, math.ceil((new_tat - now) * (1 + ttl_jitter))))
+end
+
+-- Cells still available before the next rejection
+local remaining = math.floor((now - allow_at) / emission_interval)
+
+return {1, remaining, 0, gcra_reset_ms(new_tat, now, remaining, emission_interval, burst_tolerance)}
@@ -0,0 +1,35 @@
+-- GCRA Peek (read-only)
+-- Same TAT math as gcra.lua, but never advances the TAT
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:user:123")
+-- ARGV[1]: capacity (burst size)
+-- ARGV[2]: rate (requests per second - the emission rate)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- Returns: {remaining_cells, reset_after_ms}
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+
+if not capacity or capacity <= 0 or not rate or rate <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, rate and now must be positive numbers')
+end
+
+local emission_interval = 1000 / rate
+local burst_tolerance = emission_interval * capacity
+
+-- Unknown or idle key - the full burst is available
+local tat = tonumber(redis.call('GET', key))
+if tat == nil or tat < now then
+    return {capacity, 0}
+end
+
+-- Cells that fit before TAT would exceed the burst allowance
+local remaining = math.floor((now + burst_tolerance - tat) / emission_interval)
+
+return {math.max(0, remaining), math.ceil(tat - now)}
@@ -0,0 +1,88 @@
+-- Leaky Bucket Rate Limiter (as a meter)
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:downstream:billing")
+-- ARGV[1]: capacity (max queue depth)
+-- ARGV[2]: leak_rate (units drained per second)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- ARGV[4]: cost (units this request consumes, defaults to 1)
+-- ARGV[5]: dry_run ("1" computes the decision without writing any state)
+-- ARGV[6]: ttl_jitter (fraction, e.g. 0.07, added to the key TTL so keys created together don't expire together)
+-- Returns: {allowed (1 or 0), remaining_queue_slots, retry_after_ms, reset_ms}
+--   reset_ms: unix ms when the next whole slot has drained (now if the queue is empty)
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local leak_rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local cost = tonumber(ARGV[4]) or 1
+local dry_run = ARGV[5] == '1'
+local ttl_jitter = tonumber(ARGV[6]) or 0
+
+-- Reject bad input with an error reply rather than corrupting state
+if not capacity or capacity <= 0 or not leak_rate or leak_rate <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, leak_rate and now must be positive numbers')
+end
+if cost <= 0 or cost > capacity then
+    return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+end
+
+-- Get current queue state
+local bucket = redis.call('HMGET', key, 'level', 'last_leak')
+local level = tonumber(bucket[1])
+local last_leak = tonumber(bucket[2])
+
+-- First request for this key - start with an empty queue
+if level == nil then
+    level = 0
+    last_leak = now
+end
+
+-- Drain the queue based on elapsed time
+-- Same clamping as token bucket: skew can't add, and past time-to-drain there's nothing left
+local elapsed_seconds = (now - last_leak) / 1000.0
+local time_to_drain = capacity / leak_rate
+if elapsed_seconds < 0 then
+    elapsed_seconds = 0
+elseif elapsed_seconds > time_to_drain then
+    elapsed_seconds = time_to_drain
+end
+level = math.max(0, level - elapsed_seconds * leak_rate)
+last_leak = now
+
+-- Admit the request only if there's room in the queue
+-- Unlike token bucket there's no stored burst: output is smoothed to leak_rate
+local allowed = 0
+if level + cost <= capacity then
+    level = level + cost
+    allowed = 1
+end
+
+-- capacity stored alongside so offline tooling (snapshots) can compute usage
+-- Dry runs only report the decision
+if not dry_run then
+    redis.call('HMSET', key, 'level', level, 'last_leak', last_leak, 'capacity', capacity)
+
+    -- Expire once the queue would have fully drained twice over, capped (~68 years) at
+    -- what EXPIRE takes
+    local ttl = math.min(math.ceil(capacity / leak_rate * 2 * (1 + ttl_jitter)), 2147483647)
+    redis.call('EXPIRE', key, ttl)
+end
+
+-- When blocked, report how long until the queue has drained enough to fit the cost
+local retry_after_ms = 0
+if allowed == 0 then
+    retry_after_ms = math.ceil((level + cost - capacity) / leak_rate * 1000)
+end
+
+local remaining = math.floor(capacity - level)
+
+local reset_ms = now
+if level > 0 then
+    reset_ms = now + math.ceil((level - (capacity - remaining - 1)) / leak_rate * 1000)
+end
+
+return {allowed, remaining, retry_after_ms, reset_ms}
@@ -0,0 +1,38 @@
+-- Leaky Bucket Peek (read-only)
+-- Same drain math as leaky_bucket.lua, but never adds to the queue or writes state
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:downstream:billing")
+-- ARGV[1]: capacity (max queue depth)
+-- ARGV[2]: leak_rate (units drained per second)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- Returns: {remaining_queue_slots, reset_after_ms}
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local leak_rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+
+if not capacity or capacity <= 0 or not leak_rate or leak_rate <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, leak_rate and now must be positive numbers')
+end
+
+local bucket = redis.call('HMGET', key, 'level', 'last_leak')
+local level = tonumber(bucket[1])
+local last_leak = tonumber(bucket[2])
+
+-- Unknown key - the queue would start empty
+if level == nil then
+    return {capacity, 0}
+end
+
+local elapsed_seconds = math.max(0, (now - last_leak) / 1000.0)
+level = math.max(0, level - elapsed_seconds * leak_rate)
+
+-- Time until the queue has fully drained
+local reset_after_ms = math.ceil(level / leak_rate * 1000)
+
+return {math.floor(capacity - level), reset_after_ms}
@@ -0,0 +1,10 @@
+// Package lua holds the rate limiting scripts, compiled into the binary
+package lua
+
+import "embed"
+
+// Scripts is every .lua file in this directory
+// It's the source of truth - LUA_DIR can shadow individual files at runtime
+//
+//go:embed *.lua
+var Scripts embed.FS
@@ -0,0 +1,37 @@
+package lua
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedScriptsMatchFiles(t *testing.T) {
+	files, err := filepath.Glob("*.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := fs.Glob(Scripts, "*.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 || len(files) != len(embedded) {
+		t.Fatalf("%d scripts on disk, %d embedded", len(files), len(embedded))
+	}
+
+	for _, name := range files {
+		onDisk, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		built, err := Scripts.ReadFile(name)
+		if err != nil {
+			t.Errorf("%s is not embedded: %v", name, err)
+			continue
+		}
+		if string(built) != string(onDisk) {
+			t.Errorf("embedded %s differs from the file on disk", name)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+-- Multi-limit Check (AND semantics)
+-- Every limit is evaluated before anything is written, and state is only updated
+-- when all of them allow - a rejection never leaves tokens consumed on the others
+-- KEYS[2i-1]: key for limit i (keys must be distinct)
+-- KEYS[2i]: its counter companion (e.g., "{ratelimit:ip:1.2.3.4}:counter" - same hash slot),
+--   only used by sliding_window but passed for every limit
+-- ARGV[(i-1)*5+1 .. (i-1)*5+5]: algorithm, capacity, param, now, cost for limit i
+--   param: refill_rate (token_bucket, gcra), window_ms (sliding_window, sliding_window_counter),
+--          leak_rate (leaky_bucket), reset_ms - the end of the period (quota)
+--   now: current time in milliseconds, empty to use Redis TIME
+-- ARGV[n*5+1]: ttl_jitter (fraction added to every key's TTL, shared by all limits), n being #KEYS/2
+-- Returns: {allowed (1 or 0), allowed_1, remaining_1, retry_after_ms_1, reset_ms_1, allowed_2, ...}
+-- remaining and reset_ms describe the state after this request when all limits allow,
+-- otherwise the state now (reset_ms: unix ms when remaining next goes up by one)
+
+local n = #KEYS / 2
+local ttl_jitter = tonumber(ARGV[n * 5 + 1]) or 0
+
+-- Bucket TTLs grow with capacity / rate; capped (~68 years) at what EXPIRE takes
+local max_ttl_seconds = 2147483647
+
+-- Each evaluator mirrors its algorithm's script and returns allowed, remaining,
+-- retry_after_ms, a function giving reset_ms (with or without this request counted)
+-- and a function that applies the update
+
+-- How long until a sliding window counter's remaining goes up by one - same as
+-- sliding_window_counter.lua
+local function swc_reset_after(capacity, remaining, prev, curr, elapsed, window_ms)
+    local weighted = prev * (1 - elapsed) + curr
+    if weighted <= 0 then
+        return 0
+    end
+    local needed = weighted - (capacity - remaining - 1)
+    local decays_now = prev * (1 - elapsed)
+    if prev > 0 and needed <= decays_now then
+        return math.ceil(needed / prev * window_ms)
+    end
+    if curr <= 0 then
+        return math.ceil((1 - elapsed) * window_ms)
+    end
+    return math.ceil((1 - elapsed) * window_ms + (needed - decays_now) / curr * window_ms)
+end
+
+local function token_bucket(key, capacity, refill_rate, now, cost)
+    local bucket = redis.call('HMGET', key, 'tokens', 'last_refill')
+    local tokens = tonumber(bucket[1])
+    local last_refill = tonumber(bucket[2])
+    if tokens == nil then
+        tokens = capacity
+        last_refill = now
+    end
+
+    local elapsed_seconds = (now - last_refill) / 1000.0
+    local time_to_fill = capacity / refill_rate
+    if elapsed_seconds < 0 then
+        elapsed_seconds = 0
+    elseif elapsed_seconds > time_to_fill then
+        elapsed_seconds = time_to_fill
+    end
+    tokens = math.min(capacity, tokens + elapsed_seconds * refill_rate)
+
+    local function reset_ms(consumed)
+        local left = tokens
+        if consumed then
+            left = tokens - cost
+        end
+        if left >= capacity then
+            return now
+        end
+        return now + math.ceil((math.floor(left) + 1 - left) / refill_rate * 1000)
+    end
+
+    if tokens < cost then
+        return 0, math.floor(tokens), math.ceil((cost - tokens) / refill_rate * 1000), reset_ms, nil
+    end
+
+    return 1, math.floor(tokens - cost), 0, reset_ms, function()
+        redis.call('HMSET', key, 'tokens', tokens - cost, 'last_refill', now, 'capacity', capacity)
+        redis.call('EXPIRE', key, math.min(math.ceil(capacity / refill_rate * 2 * (1 + ttl_jitter)), max_ttl_seconds))
+    end
+end
+
+local function sliding_window(key, capacity, window, now, cost, counter_key)
+    -- Trimming expired entries is safe either way, the single-limit script always does it
+    redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+    local current_count = redis.call('ZCARD', key)
+
+    -- This request's own entries would be the newest, so only an empty window changes
+    local function reset_ms(consumed)
+        local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+        if oldest[2] then
+            return math.max(now, tonumber(oldest[2]) + window)
+        end
+        if consumed then
+            return now + window
+        end
+        return now
+    end
+
+    if current_count + cost > capacity then
+        local retry_after_ms = 0
+        local nth = current_count + cost - capacity - 1
+        local entry = redis.call('ZRANGE', key, nth, nth, 'WITHSCORES')
+        if entry[2] then
+            retry_after_ms = math.max(0, tonumber(entry[2]) + window - now)
+        end
+        return 0, 0, retry_after_ms, reset_ms, nil
+    end
+
+    return 1, capacity - (current_count + cost), 0, reset_ms, function()
+        local last = redis.call('INCRBY', counter_key, cost)
+        for id = last - cost + 1, last do
+            redis.call('ZADD', key, now, now .. ':' .. id)
+        end
+        local ttl = math.ceil((window + 10000) * (1 + ttl_jitter))
+        redis.call('PEXPIRE', key, ttl)
+        redis.call('PEXPIRE', counter_key, ttl)
+    end
+end
+
+local function sliding_window_counter(key, capacity, window_ms, now, cost)
+    local current_window = math.floor(now / window_ms)
+    local window_start = current_window * window_ms
+
+    local state = redis.call('HMGET', key, 'window', 'curr', 'prev')
+    local stored_window = tonumber(state[1])
+    local curr = tonumber(state[2]) or 0
+    local prev = tonumber(state[3]) or 0
+    if stored_window ~= current_window then
+        if stored_window == current_window - 1 then
+            prev = curr
+        else
+            prev = 0
+        end
+        curr = 0
+    end
+
+    local elapsed = (now - window_start) / window_ms
+    local weighted = prev * (1 - elapsed) + curr
+
+    local function reset_ms(consumed)
+        local counted = curr
+        if consumed then
+            counted = curr + cost
+        end
+        local remaining = math.max(0, math.floor(capacity - prev * (1 - elapsed) - counted))
+        return now + swc_reset_after(capacity, remaining, prev, counted, elapsed, window_ms)
+    end
+
+    if weighted + cost > capacity then
+        local retry_after_ms
+        if curr + cost <= capacity then
+            retry_after_ms = math.ceil((1 - (capacity - curr - cost) / prev - elapsed) * window_ms)
+        else
+            retry_after_ms = math.ceil((window_start + window_ms - now) + (1 - (capacity - cost) / curr) * window_ms)
+        end
+        return 0, math.max(0, math.floor(capacity - weighted)), retry_after_ms, reset_ms, nil
+    end
+
+    return 1, math.max(0, math.floor(capacity - weighted - cost)), 0, reset_ms, function()
+        redis.call('HMSET', key, 'window', current_window, 'curr', curr + cost, 'prev', prev, 'capacity', capacity)
+        redis.call('PEXPIRE', key, math.ceil(window_ms * 2 * (1 + ttl_jitter)))
+    end
+end
+
+local function leaky_bucket(key, capacity, leak_rate, now, cost)
+    local bucket = redis.call('HMGET', key, 'level', 'last_leak')
+    local level = tonumber(bucket[1])
+    local last_leak = tonumber(bucket[2])
+    if level == nil then
+        level = 0
+        last_leak = now
+    end
+
+    local elapsed_seconds = (now - last_leak) / 1000.0
+    local time_to_drain = capacity / leak_rate
+    if elapsed_seconds < 0 then
+        elapsed_seconds = 0
+    elseif elapsed_seconds > time_to_drain then
+        elapsed_seconds = time_to_drain
+    end
+    level = math.max(0, level - elapsed_seconds * leak_rate)
+
+    local function reset_ms(consumed)
+        local queued = level
+        if consumed then
+            queued = level + cost
+        end
+        if queued <= 0 then
+            return now
+        end
+        local remaining = math.floor(capacity - queued)
+        return now + math.ceil((queued - (capacity - remaining - 1)) / leak_rate * 1000)
+    end
+
+    if level + cost > capacity then
+        return 0, math.floor(capacity - level), math.ceil((level + cost - capacity) / leak_rate * 1000), reset_ms, nil
+    end
+
+    return 1, math.floor(capacity - level - cost), 0, reset_ms, function()
+        redis.call('HMSET', key, 'level', level + cost, 'last_leak', now, 'capacity', capacity)
+        redis.call('EXPIRE', key, math.min(math.ceil(capacity / leak_rate * 2 * (1 + ttl_jitter)), max_ttl_seconds))
+    end
+end
+
+local function gcra(key, capacity, rate, now, cost)
+    local emission_interval = 1000 / rate
+    local burst_tolerance = emission_interval * capacity
+
+    local tat = tonumber(redis.call('GET', key))
+    if tat == nil or tat < now then
+        tat = now
+    end
+
+    local new_tat = tat + emission_interval * cost
+    local allow_at = new_tat - burst_tolerance
+
+    local function reset_ms(consumed)
+        local t = tat
+        if consumed then
+            t = new_tat
+        end
+        if t <= now then
+            return now
+        end
+        local remaining = math.max(0, math.floor((now + burst_tolerance - t) / emission_interval))
+        return math.max(now, math.ceil(t - burst_tolerance + (remaining + 1) * emission_interval))
+    end
+
+    if now < allow_at then
+        return 0, math.max(0, math.floor((now + burst_tolerance - tat) / emission_interval)), math.ceil(allow_at - now), reset_ms, nil
+    end
+
+    return 1, math.floor((now - allow_at) / emission_interval), 0, reset_ms, function()
+        redis.call('SET', key, new_tat, 'PX', math.max(1, math.ceil((new_tat - now) * (1 + ttl_jitter))))
+    end
+end
+
+-- param is the period's end; the key already names the period (see quota.lua)
+local function quota(key, capacity, reset_at, now, cost)
+    local used = tonumber(redis.call('GET', key)) or 0
+
+    local function reset_ms(consumed)
+        if used > 0 or consumed then
+            return reset_at
+        end
+        return now
+    end
+
+    if used + cost > capacity then
+        return 0, math.max(0, capacity - used), math.max(0, reset_at - now), reset_ms, nil
+    end
+
+    return 1, capacity - used - cost, 0, reset_ms, function()
+        redis.call('INCRBY', key, cost)
+        redis.call('PEXPIREAT', key, reset_at)
+    end
+end
+
+local evaluators = {
+    token_bucket = token_bucket,
+    sliding_window = sliding_window,
+    leaky_bucket = leaky_bucket,
+    gcra = gcra,
+    sliding_window_counter = sliding_window_counter,
+    quota = quota,
+}
+
+if n == 0 or #KEYS % 2 ~= 0 or #ARGV ~= n * 5 + 1 then
+    return redis.error_reply('invalid arguments: expected 2 keys and 5 arguments per limit plus ttl_jitter')
+end
+
+local results = {1}
+local updates = {}
+local resets = {}
+local costs = {}
+
+for i = 1, n do
+    local key = KEYS[2 * i - 1]
+    local base = (i - 1) * 5
+    local evaluate = evaluators[ARGV[base + 1]]
+    local capacity = tonumber(ARGV[base + 2])
+    local param = tonumber(ARGV[base + 3])
+    local now = tonumber(ARGV[base + 4])
+    if ARGV[base + 4] == '' then
+        local time = redis.call('TIME')
+        now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+    end
+    local cost = tonumber(ARGV[base + 5]) or 1
+
+    if not evaluate then
+        return redis.error_reply('invalid arguments: unknown algorithm ' .. tostring(ARGV[base + 1]))
+    end
+    if not capacity or capacity <= 0 or not param or param <= 0 or not now then
+        return redis.error_reply('invalid arguments: capacity, rate/window and now must be positive numbers')
+    end
+    if cost <= 0 or cost > capacity then
+        return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+    end
+
+    local allowed, remaining, retry_after_ms, reset_ms, apply = evaluate(key, capacity, param, now, cost, KEYS[2 * i])
+    if allowed == 0 then
+        results[1] = 0
+    end
+    table.insert(results, allowed)
+    table.insert(results, remaining)
+    table.insert(results, retry_after_ms)
+    table.insert(results, 0) -- reset_ms, filled in once the overall decision is known
+    updates[i] = apply
+    resets[i] = reset_ms
+    costs[i] = cost
+end
+
+-- Every limit's reset is read before any update, so a key's own write can't skew it
+for i = 1, n do
+    results[(i - 1) * 4 + 5] = resets[i](results[1] == 1)
+end
+
+-- All or nothing: only consume once every limit has agreed
+if results[1] == 1 then
+    for _, apply in ipairs(updates) do
+        apply()
+    end
+else
+    -- Nothing was consumed, so limits that would have allowed keep their cost
+    for i = 1, n do
+        if results[(i - 1) * 4 + 2] == 1 then
+            results[(i - 1) * 4 + 3] = results[(i - 1) * 4 + 3] + costs[i]
+        end
+    end
+end
+
+return results
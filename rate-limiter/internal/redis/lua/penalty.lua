@@ -0,0 +1,59 @@
+-- Repeat-Offender Penalty
+-- Not a script on its own: limiter/penalty.go appends it to an algorithm's script, whose
+-- body becomes algorithm_check(), so the penalty and the limit are decided in one call
+-- KEYS: the algorithm's own keys, followed by
+--   KEYS[#KEYS]: penalty state (e.g., "{ratelimit:user:123}:penalty" - same hash slot as KEYS[1])
+-- ARGV: the algorithm's own arguments, unchanged, followed by
+--   ARGV[#ARGV - 3]: penalty_base_ms (length of the first penalty - each consecutive block doubles it)
+--   ARGV[#ARGV - 2]: penalty_max_ms (cap on one penalty, and how long a key must stay quiet
+--                    after a penalty before its strikes are forgotten)
+--   ARGV[#ARGV - 1]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+--   ARGV[#ARGV]: dry_run ("1" reports the decision without recording a strike)
+-- Returns: the algorithm's {allowed, remaining, retry_after_ms, reset_ms} plus penalty_until_ms
+--   (unix ms the active penalty ends, 0 when there is none), then the algorithm's precise
+--   remaining if it returned one
+
+local n = #ARGV
+local penalty_base_ms = tonumber(ARGV[n - 3])
+local penalty_max_ms = tonumber(ARGV[n - 2])
+local penalty_now = tonumber(ARGV[n - 1])
+if ARGV[n - 1] == '' then
+    local time = redis.call('TIME')
+    penalty_now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local penalty_dry_run = ARGV[n] == '1'
+
+if not penalty_base_ms or penalty_base_ms <= 0 or not penalty_max_ms or penalty_max_ms < penalty_base_ms or not penalty_now then
+    return redis.error_reply('invalid arguments: penalty base must be positive and no more than the max')
+end
+
+local penalty_key = KEYS[#KEYS]
+local state = redis.call('HMGET', penalty_key, 'until', 'strikes')
+local penalty_until = tonumber(state[1]) or 0
+local strikes = tonumber(state[2]) or 0
+
+-- Serving a penalty: rejected outright, whatever the limit itself would say
+if penalty_until > penalty_now then
+    return {0, 0, penalty_until - penalty_now, penalty_until, penalty_until}
+end
+
+local result = algorithm_check()
+if result.err then
+    return result
+end
+
+if result[1] == 1 then
+    return {1, result[2], result[3], result[4] or 0, 0, result[6]}
+end
+
+-- Blocked by the limit: one more strike, and the penalty doubles
+strikes = strikes + 1
+local penalty_ms = math.min(penalty_max_ms, penalty_base_ms * 2 ^ (strikes - 1))
+penalty_until = penalty_now + penalty_ms
+
+if not penalty_dry_run then
+    redis.call('HMSET', penalty_key, 'until', penalty_until, 'strikes', strikes)
+    redis.call('PEXPIRE', penalty_key, math.ceil(penalty_ms + penalty_max_ms))
+end
+
+return {0, result[2], math.max(result[3], penalty_ms), math.max(result[4] or 0, penalty_until), penalty_until, result[6]}
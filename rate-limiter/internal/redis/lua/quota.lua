@@ -0,0 +1,41 @@
+-- Calendar Quota (e.g. 10000 requests a day, resetting at midnight)
+-- KEYS[1]: counter for the current period - the limit's key with the period appended
+--          (e.g., "ratelimit:user:123:2026-10-15"), so each period starts from zero
+-- ARGV[1]: capacity (requests allowed per period)
+-- ARGV[2]: reset_ms (unix ms when the period ends - computed by the caller in QUOTA_TIMEZONE)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds)
+-- ARGV[4]: cost (units this request consumes, defaults to 1)
+-- ARGV[5]: dry_run ("1" computes the decision without writing any state)
+-- Returns: {allowed (1 or 0), remaining, retry_after_ms, reset_ms}
+--   reset_ms: the end of the period once anything has been used (now for an untouched quota)
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local reset_at = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4]) or 1
+local dry_run = ARGV[5] == '1'
+
+if not capacity or capacity <= 0 or not now or not reset_at or reset_at <= now then
+    return redis.error_reply('invalid arguments: capacity must be positive and reset_ms after now')
+end
+if cost <= 0 or cost > capacity then
+    return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+end
+
+local used = tonumber(redis.call('GET', key)) or 0
+
+-- Blocked until the period rolls over - nothing refills before then
+if used + cost > capacity then
+    return {0, math.max(0, capacity - used), reset_at - now, reset_at}
+end
+
+if not dry_run then
+    used = redis.call('INCRBY', key, cost)
+    -- Expire exactly at the boundary; the next period uses a new key anyway
+    redis.call('PEXPIREAT', key, reset_at)
+else
+    used = used + cost
+end
+
+return {1, capacity - used, 0, reset_at}
@@ -0,0 +1,22 @@
+-- Calendar Quota Peek (read-only)
+-- KEYS[1]: counter for the current period (same key quota.lua uses)
+-- ARGV[1]: capacity (requests allowed per period)
+-- ARGV[2]: reset_ms (unix ms when the period ends)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds)
+-- Returns: {remaining, reset_after_ms}
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local reset_at = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+if not capacity or capacity <= 0 or not now or not reset_at or reset_at <= now then
+    return redis.error_reply('invalid arguments: capacity must be positive and reset_ms after now')
+end
+
+local used = tonumber(redis.call('GET', key)) or 0
+if used <= 0 then
+    return {capacity, 0}
+end
+
+return {math.max(0, capacity - used), reset_at - now}
@@ -1,14 +1,39 @@
 -- Sliding Window Log Rate Limiter
 -- KEYS[1]: rate limiter key (e.g., "ratelimit:ip:1.2.3.4")
+-- KEYS[2]: member counter (e.g., "{ratelimit:ip:1.2.3.4}:counter" - same hash slot as KEYS[1])
 -- ARGV[1]: capacity (max requests in window)
--- ARGV[2]: window_seconds (time window in seconds)
--- ARGV[3]: current_time (current timestamp in seconds)
--- Returns: {allowed (1 or 0), remaining_capacity}
+-- ARGV[2]: window_ms (time window in milliseconds)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- ARGV[4]: cost (units this request consumes, defaults to 1)
+-- ARGV[5]: dry_run ("1" computes the decision without writing any state)
+-- ARGV[6]: ttl_jitter (fraction, e.g. 0.07, added to the key TTL so keys created together don't expire together)
+-- Returns: {allowed (1 or 0), remaining_capacity, retry_after_ms, reset_ms}
+--   reset_ms: unix ms when the oldest entry slides out of the window (now if it's empty)
 
 local key = KEYS[1]
+local counter_key = KEYS[2]
 local capacity = tonumber(ARGV[1])
 local window = tonumber(ARGV[2])
 local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local cost = tonumber(ARGV[4]) or 1
+local dry_run = ARGV[5] == '1'
+local ttl_jitter = tonumber(ARGV[6]) or 0
+
+-- Reject bad input with an error reply rather than corrupting state
+if not capacity or capacity <= 0 or not window or window <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, window and now must be positive numbers')
+end
+if cost <= 0 or cost > capacity then
+    return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+end
+if not counter_key then
+    return redis.error_reply('invalid arguments: expected the counter key as KEYS[2]')
+end
 
 -- Calculate the start of the sliding window
 local window_start = now - window
@@ -20,23 +45,48 @@ redis.call('ZREMRANGEBYSCORE', key, 0, window_start)
 -- Count current requests in the window
 local current_count = redis.call('ZCARD', key)
 
+-- Blocked requests aren't recorded, so remaining is simply 0 for them
 local allowed = 0
-local remaining = capacity - current_count
+local remaining = 0
 
 -- Check if we're under the limit
-if current_count < capacity then
-    -- Add current request with timestamp as score and unique ID as member
-    -- Using timestamp + random to avoid collision (sorted sets need unique members)
-    local member = now .. ':' .. redis.call('INCR', key .. ':counter')
-    redis.call('ZADD', key, now, member)
+if current_count + cost <= capacity then
+    -- Add one entry per unit of cost, timestamp as score and a unique ID as member
+    -- The counter keeps members unique (sorted sets need unique members)
+    -- Dry runs only report the decision
+    if not dry_run then
+        local last = redis.call('INCRBY', counter_key, cost)
+        for id = last - cost + 1, last do
+            redis.call('ZADD', key, now, now .. ':' .. id)
+        end
+    end
     allowed = 1
-    remaining = remaining - 1
+    -- Slots left after counting this request
+    remaining = capacity - (current_count + cost)
 end
 
 -- Set expiry to cleanup old keys
--- Adding some buffer to window to ensure we don't lose data prematurely
-redis.call('EXPIRE', key, window + 10)
-redis.call('EXPIRE', key .. ':counter', window + 10)
+-- Adding some buffer (10s) to window to ensure we don't lose data prematurely
+local ttl = math.ceil((window + 10000) * (1 + ttl_jitter))
+redis.call('PEXPIRE', key, ttl)
+redis.call('PEXPIRE', counter_key, ttl)
+
+-- When blocked, enough capacity frees up once the entry that would make room for
+-- the cost slides out of the window (the oldest one when cost is 1)
+local retry_after_ms = 0
+if allowed == 0 then
+    local nth = current_count + cost - capacity - 1
+    local entry = redis.call('ZRANGE', key, nth, nth, 'WITHSCORES')
+    if entry[2] then
+        retry_after_ms = math.max(0, tonumber(entry[2]) + window - now)
+    end
+end
+
+local reset_ms = now
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+    reset_ms = math.max(now, tonumber(oldest[2]) + window)
+end
 
-return {allowed, math.max(0, remaining)}
+return {allowed, remaining, retry_after_ms, reset_ms}
 
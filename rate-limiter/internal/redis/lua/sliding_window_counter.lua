@@ -0,0 +1,113 @@
+-- Sliding Window Counter Rate Limiter (approximate)
+-- Keeps two fixed-window counters (current and previous) and weights the previous
+-- one by how much of it still overlaps the sliding window - O(1) memory per key
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:user:123")
+-- ARGV[1]: capacity (max requests in window)
+-- ARGV[2]: window_ms (time window in milliseconds)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- ARGV[4]: cost (units this request consumes, defaults to 1)
+-- ARGV[5]: dry_run ("1" computes the decision without writing any state)
+-- ARGV[6]: ttl_jitter (fraction, e.g. 0.07, added to the key TTL so keys created together don't expire together)
+-- Returns: {allowed (1 or 0), remaining_capacity, retry_after_ms, reset_ms}
+--   reset_ms: unix ms when remaining next goes up by one (now if nothing is counted)
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local window_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local cost = tonumber(ARGV[4]) or 1
+local dry_run = ARGV[5] == '1'
+local ttl_jitter = tonumber(ARGV[6]) or 0
+
+-- Reject bad input with an error reply rather than corrupting state
+if not capacity or capacity <= 0 or not window_ms or window_ms <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, window and now must be positive numbers')
+end
+if cost <= 0 or cost > capacity then
+    return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+end
+
+-- How long until remaining goes up by one: the previous window's weight decays
+-- until this window ends, then the current count becomes previous and decays in turn
+local function swc_reset_after(capacity, remaining, prev, curr, elapsed, window_ms)
+    local weighted = prev * (1 - elapsed) + curr
+    if weighted <= 0 then
+        return 0
+    end
+    local needed = weighted - (capacity - remaining - 1)
+    local decays_now = prev * (1 - elapsed)
+    if prev > 0 and needed <= decays_now then
+        return math.ceil(needed / prev * window_ms)
+    end
+    if curr <= 0 then
+        return math.ceil((1 - elapsed) * window_ms)
+    end
+    return math.ceil((1 - elapsed) * window_ms + (needed - decays_now) / curr * window_ms)
+end
+
+local current_window = math.floor(now / window_ms)
+
+local state = redis.call('HMGET', key, 'window', 'curr', 'prev')
+local stored_window = tonumber(state[1])
+local curr = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+
+-- Roll the counters forward: the old current becomes previous if it's the window
+-- just before this one, otherwise both are stale
+if stored_window ~= current_window then
+    if stored_window == current_window - 1 then
+        prev = curr
+    else
+        prev = 0
+    end
+    curr = 0
+end
+
+-- How far we are into the current window (0..1) - the previous window's count
+-- is assumed to be spread evenly, so (1 - elapsed) of it still falls inside
+local window_start = current_window * window_ms
+local elapsed = (now - window_start) / window_ms
+local weighted = prev * (1 - elapsed) + curr
+
+local allowed = 0
+local remaining = math.max(0, math.floor(capacity - weighted))
+
+if weighted + cost <= capacity then
+    allowed = 1
+    remaining = math.max(0, math.floor(capacity - weighted - cost))
+
+    if not dry_run then
+        curr = curr + cost
+        -- capacity stored alongside so offline tooling (snapshots) can compute usage
+        redis.call('HMSET', key, 'window', current_window, 'curr', curr, 'prev', prev, 'capacity', capacity)
+        -- Once two windows have passed both counters are stale anyway
+        redis.call('PEXPIRE', key, math.ceil(window_ms * 2 * (1 + ttl_jitter)))
+    end
+end
+
+-- When blocked, wait for the previous window's weight to decay enough - or, if the
+-- current window alone is too full, for it to become the previous one and decay
+local retry_after_ms = 0
+if allowed == 0 then
+    if curr + cost <= capacity then
+        local needed = 1 - (capacity - curr - cost) / prev
+        retry_after_ms = math.ceil((needed - elapsed) * window_ms)
+    else
+        local needed = 1 - (capacity - cost) / curr
+        retry_after_ms = math.ceil((window_start + window_ms - now) + needed * window_ms)
+    end
+end
+
+-- Dry runs count the cost too, to match the remaining they report
+local counted = curr
+if allowed == 1 and dry_run then
+    counted = curr + cost
+end
+local reset_ms = now + swc_reset_after(capacity, remaining, prev, counted, elapsed, window_ms)
+
+return {allowed, remaining, retry_after_ms, reset_ms}
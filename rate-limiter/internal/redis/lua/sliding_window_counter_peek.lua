@@ -0,0 +1,51 @@
+-- Sliding Window Counter Peek (read-only)
+-- Same interpolation as sliding_window_counter.lua, but never counts a request
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:user:123")
+-- ARGV[1]: capacity (max requests in window)
+-- ARGV[2]: window_ms (time window in milliseconds)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- Returns: {remaining_capacity, reset_after_ms}
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local window_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+
+if not capacity or capacity <= 0 or not window_ms or window_ms <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, window and now must be positive numbers')
+end
+
+local current_window = math.floor(now / window_ms)
+local window_start = current_window * window_ms
+
+local state = redis.call('HMGET', key, 'window', 'curr', 'prev')
+local stored_window = tonumber(state[1])
+local curr = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+
+if stored_window ~= current_window then
+    if stored_window == current_window - 1 then
+        prev = curr
+    else
+        prev = 0
+    end
+    curr = 0
+end
+
+local elapsed = (now - window_start) / window_ms
+local weighted = prev * (1 - elapsed) + curr
+
+-- The previous window stops counting when this one ends; the current one a window later
+local reset_after_ms = 0
+if curr > 0 then
+    reset_after_ms = window_start + 2 * window_ms - now
+elseif prev > 0 then
+    reset_after_ms = window_start + window_ms - now
+end
+
+return {math.max(0, math.floor(capacity - weighted)), reset_after_ms}
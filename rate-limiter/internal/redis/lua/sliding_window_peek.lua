@@ -0,0 +1,33 @@
+-- Sliding Window Log Peek
+-- Trims expired entries like sliding_window.lua, but never records a request
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:ip:1.2.3.4")
+-- ARGV[1]: capacity (max requests in window)
+-- ARGV[2]: window_ms (time window in milliseconds)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- Returns: {remaining_capacity, reset_after_ms}
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+
+if not capacity or capacity <= 0 or not window or window <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, window and now must be positive numbers')
+end
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local current_count = redis.call('ZCARD', key)
+
+-- The window is fully clear once the newest entry slides out
+local reset_after_ms = 0
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+if newest[2] then
+    reset_after_ms = math.max(0, tonumber(newest[2]) + window - now)
+end
+
+return {math.max(0, capacity - current_count), reset_after_ms}
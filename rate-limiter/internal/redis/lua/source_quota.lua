@@ -0,0 +1,40 @@
+-- Per-Source Unique Key Quota
+-- Caps how many distinct rate limit keys one source (API key/IP) can create per window
+-- KEYS[1]: source key set (e.g., "source_keys:1.2.3.4")
+-- ARGV[1]: max_keys (distinct keys allowed per source per window)
+-- ARGV[2]: window_seconds (how long the source's key set is remembered)
+-- ARGV[3]: rate limiter key the source is about to check - an argument rather than a key,
+--          since in cluster mode it generally lives on another node
+-- Returns: 1 if the check may proceed, 0 if it would create one key too many
+--   (limiter/source_quota.go still admits a 0 when the key already exists)
+
+local source_set = KEYS[1]
+local key = ARGV[3]
+local max_keys = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+if not max_keys or max_keys <= 0 or not window or window <= 0 then
+    return redis.error_reply('invalid arguments: max_keys and window must be positive numbers')
+end
+if not key or key == '' then
+    return redis.error_reply('invalid arguments: expected the rate limiter key as ARGV[3]')
+end
+
+-- Keys this source already created always keep working
+if redis.call('SISMEMBER', source_set, key) == 1 then
+    return 1
+end
+
+-- New key: only allowed while the source is under its cap
+if redis.call('SCARD', source_set) >= max_keys then
+    return 0
+end
+
+redis.call('SADD', source_set, key)
+
+-- Window starts with the source's first key, it isn't extended by later ones
+if redis.call('TTL', source_set) < 0 then
+    redis.call('EXPIRE', source_set, window)
+end
+
+return 1
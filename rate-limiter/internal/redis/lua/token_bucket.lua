@@ -1,14 +1,67 @@
 -- Token Bucket Rate Limiter
 -- KEYS[1]: rate limiter key (e.g., "ratelimit:user:123")
+-- KEYS[2]: dedup key for request_id (e.g., "{ratelimit:user:123}:req:abc" - same hash slot as
+--          KEYS[1]), only needed when a request_id is given
 -- ARGV[1]: capacity (max tokens)
 -- ARGV[2]: refill_rate (tokens per second)
--- ARGV[3]: current_time_ms (current timestamp in milliseconds)
--- Returns: {allowed (1 or 0), remaining_tokens}
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- ARGV[4]: cost (units this request consumes, defaults to 1)
+-- ARGV[5]: dry_run ("1" computes the decision without writing any state)
+-- ARGV[6]: request_id (optional - a retry with the same id gets the first decision back
+--          instead of consuming again)
+-- ARGV[7]: dedup_ttl_ms (how long request ids are remembered)
+-- ARGV[8]: ttl_jitter (fraction, e.g. 0.07, added to the key TTL so keys created together don't expire together)
+-- ARGV[9]: precise ("1" also returns the unfloored token count)
+-- Returns: {allowed (1 or 0), remaining_tokens, retry_after_ms, reset_ms}
+--   reset_ms: unix ms when the next whole token is available (now if the bucket is full)
+--   With precise: {..., reset_ms, 0, tokens} - tokens as a string, since Redis truncates
+--   numbers in replies (the 0 is where a penalty puts penalty_until_ms)
 
 local key = KEYS[1]
 local capacity = tonumber(ARGV[1])
 local refill_rate = tonumber(ARGV[2])
 local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+local cost = tonumber(ARGV[4]) or 1
+local dry_run = ARGV[5] == '1'
+local request_id = ARGV[6] or ''
+local dedup_ttl_ms = tonumber(ARGV[7]) or 0
+local ttl_jitter = tonumber(ARGV[8]) or 0
+local precise = ARGV[9] == '1'
+
+-- Reject bad input with an error reply rather than corrupting state
+if not capacity or capacity <= 0 or not refill_rate or refill_rate <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, refill_rate and now must be positive numbers')
+end
+if cost <= 0 or cost > capacity then
+    return redis.error_reply('invalid arguments: cost must be positive and no more than capacity')
+end
+
+-- A replayed request id returns the decision recorded the first time
+-- Stored as "allowed:remaining:retry_after_ms:reset_ms" in a companion key that expires by itself
+local dedup_key = nil
+if request_id ~= '' and dedup_ttl_ms > 0 and not dry_run then
+    dedup_key = KEYS[2]
+    if not dedup_key then
+        return redis.error_reply('invalid arguments: expected the dedup key as KEYS[2] with a request_id')
+    end
+    local previous = redis.call('GET', dedup_key)
+    if previous then
+        local a, r, t, z = string.match(previous, '^(%d+):(%d+):(%d+):(%d+)$')
+        if a then
+            return {tonumber(a), tonumber(r), tonumber(t), tonumber(z)}
+        end
+        -- Recorded before reset_ms was kept - the first decision still stands
+        a, r, t = string.match(previous, '^(%d+):(%d+):(%d+)$')
+        if a then
+            return {tonumber(a), tonumber(r), tonumber(t), now}
+        end
+    end
+end
 
 -- Get current bucket state
 local bucket = redis.call('HMGET', key, 'tokens', 'last_refill')
@@ -24,6 +77,15 @@ end
 -- Calculate tokens to add based on elapsed time
 -- Using milliseconds for precision, dividing by 1000 to get seconds
 local elapsed_seconds = (now - last_refill) / 1000.0
+
+-- Clamp elapsed time: negative means clock skew, and anything past the time to
+-- fill from empty adds nothing, so capping it keeps elapsed * rate from overflowing
+local time_to_fill = capacity / refill_rate
+if elapsed_seconds < 0 then
+    elapsed_seconds = 0
+elseif elapsed_seconds > time_to_fill then
+    elapsed_seconds = time_to_fill
+end
 local tokens_to_add = elapsed_seconds * refill_rate
 
 -- Add tokens but don't exceed capacity
@@ -32,19 +94,49 @@ last_refill = now
 
 -- Check if we can allow this request
 local allowed = 0
-if tokens >= 1 then
-    tokens = tokens - 1
+if tokens >= cost then
+    tokens = tokens - cost
     allowed = 1
 end
 
--- Persist the updated state
+-- Persist the updated state (dry runs only report the decision)
 -- Using HMSET for atomic update of multiple fields
-redis.call('HMSET', key, 'tokens', tokens, 'last_refill', last_refill)
+-- capacity is stored alongside so offline tooling (snapshots) can compute usage
+if not dry_run then
+    redis.call('HMSET', key, 'tokens', tokens, 'last_refill', last_refill, 'capacity', capacity)
+
+    -- Set expiry to cleanup old keys (2x the time to fill bucket from empty, plus jitter)
+    -- This prevents memory leaks from inactive keys. A huge capacity over a tiny rate would
+    -- give a TTL EXPIRE can't take, so it's capped at ~68 years
+    local ttl = math.min(math.ceil(capacity / refill_rate * 2 * (1 + ttl_jitter)), 2147483647)
+    redis.call('EXPIRE', key, ttl)
+end
 
--- Set expiry to cleanup old keys (2x the time to fill bucket from empty)
--- This prevents memory leaks from inactive keys
-local ttl = math.ceil(capacity / refill_rate * 2)
-redis.call('EXPIRE', key, ttl)
+-- Waits are capped at 2^53 ms (~285,000 years): past that they'd lose precision, and
+-- Redis can't turn them into integer replies
+local max_wait_ms = 9007199254740992
+
+-- When blocked, report how long until enough tokens have refilled to cover the cost
+local retry_after_ms = 0
+if allowed == 0 then
+    retry_after_ms = math.min(math.ceil((cost - tokens) / refill_rate * 1000), max_wait_ms)
+end
+
+local remaining = math.floor(tokens)
+
+-- Fractional tokens don't count, so the next one is whole once it's fully refilled
+local reset_ms = now
+if tokens < capacity then
+    reset_ms = now + math.min(math.ceil((remaining + 1 - tokens) / refill_rate * 1000), max_wait_ms)
+end
+
+if dedup_key then
+    redis.call('SET', dedup_key, string.format('%d:%d:%d:%d', allowed, remaining, retry_after_ms, reset_ms), 'PX', dedup_ttl_ms)
+end
+
+if precise then
+    return {allowed, remaining, retry_after_ms, reset_ms, 0, tostring(tokens)}
+end
 
-return {allowed, math.floor(tokens)}
+return {allowed, remaining, retry_after_ms, reset_ms}
 
@@ -0,0 +1,38 @@
+-- Token Bucket Peek (read-only)
+-- Same refill math as token_bucket.lua, but never takes a token or writes state
+-- KEYS[1]: rate limiter key (e.g., "ratelimit:user:123")
+-- ARGV[1]: capacity (max tokens)
+-- ARGV[2]: refill_rate (tokens per second)
+-- ARGV[3]: current_time_ms (current timestamp in milliseconds, empty to use Redis TIME)
+-- Returns: {remaining_tokens, reset_after_ms}
+
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local refill_rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+if ARGV[3] == '' then
+    -- No time given (CLOCK_SOURCE=redis): Redis's own clock, so every instance agrees
+    local time = redis.call('TIME')
+    now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+end
+
+if not capacity or capacity <= 0 or not refill_rate or refill_rate <= 0 or not now then
+    return redis.error_reply('invalid arguments: capacity, refill_rate and now must be positive numbers')
+end
+
+local bucket = redis.call('HMGET', key, 'tokens', 'last_refill')
+local tokens = tonumber(bucket[1])
+local last_refill = tonumber(bucket[2])
+
+-- Unknown key - the bucket would start full
+if tokens == nil then
+    return {capacity, 0}
+end
+
+local elapsed_seconds = math.max(0, (now - last_refill) / 1000.0)
+tokens = math.min(capacity, tokens + elapsed_seconds * refill_rate)
+
+-- Time until the bucket is full again
+local reset_after_ms = math.ceil((capacity - tokens) / refill_rate * 1000)
+
+return {math.floor(tokens), reset_after_ms}
@@ -0,0 +1,157 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyProxy forwards connections to a Redis server, except that while drops is
+// positive each command it sees closes the connection instead, as a network blip would
+type flakyProxy struct {
+	ln     net.Listener
+	target string
+	drops  atomic.Int32
+}
+
+func newFlakyProxy(t *testing.T, target string) *flakyProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &flakyProxy{ln: ln, target: target}
+	t.Cleanup(func() { ln.Close() })
+	go p.serve()
+	return p
+}
+
+func (p *flakyProxy) serve() {
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		go func() {
+			io.Copy(client, server)
+			client.Close()
+		}()
+		go p.forward(client, server)
+	}
+}
+
+// forward copies commands to the server, dropping the connection on one if asked to
+func (p *flakyProxy) forward(client, server net.Conn) {
+	defer client.Close()
+	defer server.Close()
+	buf := make([]byte, 4096)
+	for {
+		n, err := client.Read(buf)
+		if err != nil {
+			return
+		}
+		if p.drops.Load() > 0 && p.drops.Add(-1) >= 0 {
+			return
+		}
+		if _, err := server.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// newFlakyClient connects a Client to miniredis through a flakyProxy
+func newFlakyClient(t *testing.T, backoff time.Duration) (*Client, *flakyProxy) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	proxy := newFlakyProxy(t, mr.Addr())
+	cfg := config.Load()
+	cfg.RedisAddr = proxy.ln.Addr().String()
+	cfg.RedisTimeout = time.Second
+	cfg.RedisRetryBackoff = backoff
+	c, err := NewClient(cfg, metrics.New(prometheus.NewRegistry(), nil, nil))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, proxy
+}
+
+func TestEvalLuaRetriesADroppedConnection(t *testing.T) {
+	c, proxy := newFlakyClient(t, 10*time.Millisecond)
+
+	proxy.drops.Store(1)
+	result, err := c.EvalLua(context.Background(), "return 'ok'", []string{"user:1"})
+	if err != nil {
+		t.Fatalf("EvalLua after one dropped connection: %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("result = %v, want ok", result)
+	}
+	if got := testutil.ToFloat64(c.metrics.RedisRetries); got != 1 {
+		t.Errorf("redis_retries_total = %v, want 1", got)
+	}
+}
+
+func TestEvalLuaFailsOpenWithoutRetry(t *testing.T) {
+	c, proxy := newFlakyClient(t, 0)
+
+	proxy.drops.Store(1)
+	_, err := c.EvalLua(context.Background(), "return 'ok'", []string{"user:1"})
+	var failOpen *FailOpenError
+	if !errors.As(err, &failOpen) {
+		t.Fatalf("err = %v, want a FailOpenError with REDIS_RETRY_BACKOFF off", err)
+	}
+	if got := testutil.ToFloat64(c.metrics.RedisRetries); got != 0 {
+		t.Errorf("redis_retries_total = %v, want 0", got)
+	}
+}
+
+func TestRetryWaitOnlyForRetriableErrors(t *testing.T) {
+	c, _ := newTestClient(t)
+	c.cfg.RedisRetryBackoff = 10 * time.Millisecond
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	if !c.retryWait(context.Background(), io.EOF) {
+		t.Error("no retry after a dropped connection")
+	}
+	if c.retryWait(context.Background(), replyError("ERR user_script:1: boom")) {
+		t.Error("retried an error reply")
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.retryWait(cancelled, reset) {
+		t.Error("retried for a cancelled caller")
+	}
+
+	// Backoff plus another attempt won't fit in 5ms
+	tight, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if c.retryWait(tight, reset) {
+		t.Error("retried past the deadline")
+	}
+	if waited := time.Since(start); waited > 5*time.Millisecond {
+		t.Errorf("waited %v before giving up, want no wait", waited)
+	}
+
+	c.cfg.RedisRetryBackoff = 0
+	if c.retryWait(context.Background(), io.EOF) {
+		t.Error("retried with REDIS_RETRY_BACKOFF off")
+	}
+}
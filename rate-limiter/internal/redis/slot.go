@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrCrossSlot means a script's keys hash to different cluster slots, so no single node
+// holds them all. Keys that must be checked together need a shared hash tag, e.g.
+// "{user:123}:minute" and "{user:123}:day"
+var ErrCrossSlot = errors.New("keys hash to different redis cluster slots")
+
+// clusterSlots is the number of hash slots a Redis Cluster splits keys across
+const clusterSlots = 16384
+
+// slotKey returns the part of key Redis Cluster hashes: the text between the first '{'
+// and the next '}' when that isn't empty, otherwise the whole key
+func slotKey(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+// keySlot is the cluster slot Redis assigns key to (CRC16 of its hash tag, mod 16384)
+func keySlot(key string) int {
+	return int(crc16(slotKey(key))) % clusterSlots
+}
+
+// sameSlot reports whether every key hashes to the same cluster slot
+func sameSlot(keys []string) bool {
+	for i := 1; i < len(keys); i++ {
+		if keySlot(keys[i]) != keySlot(keys[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// crc16 is CRC-16/XMODEM, the checksum Redis Cluster uses for key slots
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+)
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		// Check value of CRC-16/XMODEM, and the example from the Redis Cluster spec
+		{"123456789", 0x31c3},
+		{"foo", 12182},
+		{"{foo}:counter", 12182},
+		{"rl:{foo}", 12182},
+	}
+	for _, tt := range tests {
+		if got := keySlot(tt.key); got != tt.want {
+			t.Errorf("keySlot(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCrossSlot(t *testing.T) {
+	cluster := &Client{cfg: &config.Config{RedisClusterAddrs: []string{"127.0.0.1:7000"}}}
+	single := &Client{cfg: &config.Config{}}
+
+	sameTag := []string{"rl:{user:1}:sec", "rl:{user:1}:min"}
+	companion := []string{"rl:user:1", "{rl:user:1}:counter"}
+	spread := []string{"rl:user:1", "rl:user:2"}
+
+	if cluster.crossSlot(sameTag) || cluster.crossSlot(companion) {
+		t.Error("keys sharing a hash tag were reported as cross-slot")
+	}
+	if !cluster.crossSlot(spread) {
+		t.Errorf("%v reported as one slot, want cross-slot", spread)
+	}
+	if single.crossSlot(spread) {
+		t.Error("cross-slot check applied outside cluster mode")
+	}
+}
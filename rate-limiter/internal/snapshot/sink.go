@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+)
+
+// Sink receives completed snapshots
+type Sink interface {
+	Write(ctx context.Context, snap *Snapshot) error
+}
+
+func newSink(cfg *config.Config, redis *redisclient.Client) (Sink, error) {
+	switch cfg.SnapshotSink {
+	case "log":
+		return logSink{}, nil
+	case "file":
+		if cfg.SnapshotFile == "" {
+			return nil, fmt.Errorf("SNAPSHOT_FILE is required for the file sink")
+		}
+		return fileSink{path: cfg.SnapshotFile}, nil
+	case "redis":
+		return redisSink{redis: redis, key: cfg.SnapshotRedisKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown snapshot sink %q (supported: log, file, redis)", cfg.SnapshotSink)
+	}
+}
+
+// logSink writes one JSON line to the service log
+type logSink struct{}
+
+func (logSink) Write(_ context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	logging.Printf("snapshot: %s", data)
+	return nil
+}
+
+// fileSink appends JSON lines so an analytics job can tail or batch-load the file
+type fileSink struct {
+	path string
+}
+
+func (s fileSink) Write(_ context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// redisSink overwrites a single key with the latest snapshot
+type redisSink struct {
+	redis *redisclient.Client
+	key   string
+}
+
+func (s redisSink) Write(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, s.key, data, 0)
+}
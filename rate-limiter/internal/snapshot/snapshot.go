@@ -0,0 +1,284 @@
+package snapshot
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/logging"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/redis/go-redis/v9"
+)
+
+// companionKeyPattern matches the keys scripts keep beside a limit (see the limiter's
+// companionKey): the sliding window counter, request_id replays, penalties, decision
+// counts and concurrency leases. They aren't limits of their own
+var companionKeyPattern = regexp.MustCompile(`^[^{]*\{[^}]+\}.*:(counter|decisions|penalty|leases|req:.+)$`)
+
+// quotaPeriodPattern matches a quota's per-period counter (key:2026-01-02 or key:2026-01)
+var quotaPeriodPattern = regexp.MustCompile(`:\d{4}-\d{2}(-\d{2})?$`)
+
+// Snapshot is a point-in-time aggregate of rate limiter state for offline analytics
+// Values are approximate: sliding window sets may still hold entries that haven't been
+// trimmed yet, token bucket usage ignores refill since the key was last touched, and
+// sliding window counters only count the window they were last written in
+type Snapshot struct {
+	Timestamp     time.Time  `json:"timestamp"`
+	ActiveKeys    int64      `json:"active_keys"`
+	CapacityInUse int64      `json:"capacity_in_use"`
+	TopKeys       []KeyUsage `json:"top_keys"`
+}
+
+// KeyUsage describes how much of its limit a single key is consuming
+type KeyUsage struct {
+	Key       string `json:"key"`
+	Algorithm string `json:"algorithm"`
+	Used      int64  `json:"used"`
+}
+
+// Collector periodically scans Redis and writes snapshots to a sink
+type Collector struct {
+	redis     *redisclient.Client
+	keyPrefix string
+	sink      Sink
+	interval  time.Duration
+	topN      int
+	scanCount int64
+}
+
+// NewCollector builds a collector from config, picking the sink named by SNAPSHOT_SINK
+func NewCollector(redis *redisclient.Client, cfg *config.Config) (*Collector, error) {
+	sink, err := newSink(cfg, redis)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Collector{
+		redis:     redis,
+		keyPrefix: cfg.RedisKeyPrefix,
+		sink:      sink,
+		interval:  cfg.SnapshotInterval,
+		topN:      cfg.SnapshotTopN,
+		scanCount: cfg.SnapshotScanCount,
+	}, nil
+}
+
+// Run snapshots on every tick until ctx is cancelled
+// Failures are logged and retried next tick - analytics shouldn't affect serving
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snap, err := c.Collect(ctx)
+			if err != nil {
+				logging.Printf("snapshot collection failed: %v", err)
+				continue
+			}
+			if err := c.sink.Write(ctx, snap); err != nil {
+				logging.Printf("snapshot write failed: %v", err)
+			}
+		}
+	}
+}
+
+// Collect walks the keys under REDIS_KEY_PREFIX with SCAN and aggregates usage
+// Each SCAN page is classified with two pipelined round trips (TYPE, then usage)
+func (c *Collector) Collect(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{Timestamp: time.Now().UTC()}
+	top := &usageHeap{}
+
+	match := "*"
+	if c.keyPrefix != "" {
+		match = escapeGlob(c.keyPrefix+":") + "*"
+	}
+	err := c.redis.ScanAll(ctx, match, c.scanCount, func(keys []string) error {
+		usages, err := c.usageFor(ctx, limitKeys(keys))
+		if err != nil {
+			return err
+		}
+
+		for _, u := range usages {
+			snap.ActiveKeys++
+			snap.CapacityInUse += u.Used
+			pushTopN(top, u, c.topN)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+
+	// Heap pops smallest first, so fill the slice from the back for descending order
+	snap.TopKeys = make([]KeyUsage, top.Len())
+	for i := len(snap.TopKeys) - 1; i >= 0; i-- {
+		snap.TopKeys[i] = heap.Pop(top).(KeyUsage)
+	}
+
+	return snap, nil
+}
+
+// limitKeys drops the companion keys from a SCAN page
+func limitKeys(keys []string) []string {
+	kept := keys[:0]
+	for _, key := range keys {
+		if !companionKeyPattern.MatchString(key) {
+			kept = append(kept, key)
+		}
+	}
+	return kept
+}
+
+// usageFor classifies keys by Redis type and stored fields, and reads their usage
+// zset = sliding window (entries in window)
+// hash = token bucket (capacity - tokens), leaky bucket (queue level) or sliding window
+// counter (requests in its current window), told apart by which fields are present
+// string = quota (units used this period) when named after a period, otherwise GCRA
+// Anything else (e.g. source key sets) isn't a limiter key
+func (c *Collector) usageFor(ctx context.Context, keys []string) ([]KeyUsage, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	typeCmds := make([]*redis.StatusCmd, len(keys))
+	_, err := c.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			typeCmds[i] = pipe.Type(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("type lookup failed: %w", err)
+	}
+
+	zcards := make(map[string]*redis.IntCmd)
+	buckets := make(map[string]*redis.SliceCmd)
+	strs := make(map[string]*redis.StringCmd)
+	_, err = c.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			switch typeCmds[i].Val() {
+			case "zset":
+				zcards[key] = pipe.ZCard(ctx, key)
+			case "hash":
+				buckets[key] = pipe.HMGet(ctx, key, "tokens", "capacity", "level", "window", "curr")
+			case "string":
+				strs[key] = pipe.Get(ctx, key)
+			}
+		}
+		return nil
+	})
+	// Keys can expire between the two pipelines - redis.Nil there is expected
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("usage lookup failed: %w", err)
+	}
+
+	usages := make([]KeyUsage, 0, len(zcards)+len(buckets)+len(strs))
+	for key, cmd := range zcards {
+		usages = append(usages, KeyUsage{Key: key, Algorithm: "sliding_window", Used: cmd.Val()})
+	}
+	for key, cmd := range buckets {
+		vals := cmd.Val()
+		if len(vals) != 5 {
+			continue
+		}
+		switch {
+		case vals[0] != nil:
+			usages = append(usages, KeyUsage{Key: key, Algorithm: "token_bucket", Used: bucketUsed(vals[0], vals[1])})
+		case vals[2] != nil:
+			usages = append(usages, KeyUsage{Key: key, Algorithm: "leaky_bucket", Used: queueDepth(vals[2])})
+		case vals[3] != nil && vals[4] != nil:
+			usages = append(usages, KeyUsage{Key: key, Algorithm: "sliding_window_counter", Used: queueDepth(vals[4])})
+		}
+	}
+	for key, cmd := range strs {
+		value, err := strconv.ParseFloat(cmd.Val(), 64)
+		if err != nil {
+			continue
+		}
+		if quotaPeriodPattern.MatchString(key) {
+			usages = append(usages, KeyUsage{Key: key, Algorithm: "quota", Used: int64(value)})
+			continue
+		}
+		// A GCRA key only holds its theoretical arrival time - without the rate there's
+		// no telling how much of the burst is used, so it counts as active with 0 used
+		usages = append(usages, KeyUsage{Key: key, Algorithm: "gcra"})
+	}
+
+	return usages, nil
+}
+
+// bucketUsed converts the stored token/capacity strings into tokens consumed
+// Buckets written before capacity was stored report 0 used but still count as active
+func bucketUsed(tokensVal, capacityVal interface{}) int64 {
+	tokensStr, _ := tokensVal.(string)
+	capacityStr, _ := capacityVal.(string)
+
+	tokens, err1 := strconv.ParseFloat(tokensStr, 64)
+	capacity, err2 := strconv.ParseFloat(capacityStr, 64)
+	if err1 != nil || err2 != nil || capacity <= tokens {
+		return 0
+	}
+	return int64(capacity - tokens)
+}
+
+// queueDepth converts a leaky bucket's stored level (or a sliding window counter's
+// count) into whole units
+func queueDepth(levelVal interface{}) int64 {
+	levelStr, _ := levelVal.(string)
+	level, err := strconv.ParseFloat(levelStr, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(level)
+}
+
+// escapeGlob escapes SCAN MATCH wildcards so a prefix matches literally
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// usageHeap is a min-heap on Used so we can keep only the top N in bounded memory
+type usageHeap []KeyUsage
+
+func (h usageHeap) Len() int            { return len(h) }
+func (h usageHeap) Less(i, j int) bool  { return h[i].Used < h[j].Used }
+func (h usageHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *usageHeap) Push(x interface{}) { *h = append(*h, x.(KeyUsage)) }
+func (h *usageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+func pushTopN(h *usageHeap, u KeyUsage, n int) {
+	if n <= 0 {
+		return
+	}
+	if h.Len() < n {
+		heap.Push(h, u)
+		return
+	}
+	if (*h)[0].Used < u.Used {
+		(*h)[0] = u
+		heap.Fix(h, 0)
+	}
+}
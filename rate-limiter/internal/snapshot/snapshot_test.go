@@ -0,0 +1,187 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/piyushpatra/rate-limiter/internal/config"
+	"github.com/piyushpatra/rate-limiter/internal/metrics"
+	redisclient "github.com/piyushpatra/rate-limiter/internal/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newTestCollector starts miniredis and builds a Collector from the default config,
+// after setup (which may be nil) has adjusted it
+func newTestCollector(t *testing.T, setup func(cfg *config.Config)) (*Collector, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cfg := config.Load()
+	cfg.RedisAddr = mr.Addr()
+	cfg.RedisKeyPrefix = ""
+	cfg.RedisTimeout = time.Second // the 2ms default is too tight for a busy test machine
+	cfg.SnapshotScanCount = 2      // several SCAN pages even for a handful of keys
+	if setup != nil {
+		setup(cfg)
+	}
+
+	client, err := redisclient.NewClient(cfg, metrics.New(prometheus.NewRegistry(), nil, nil))
+	if err != nil {
+		t.Fatalf("redis client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	c, err := NewCollector(client, cfg)
+	if err != nil {
+		t.Fatalf("NewCollector: %v", err)
+	}
+	return c, mr
+}
+
+// seed writes limiter state as the algorithms' scripts leave it
+func seed(t *testing.T, mr *miniredis.Miniredis) {
+	t.Helper()
+	mr.HSet("user:bucket", "tokens", "4", "capacity", "10")   // 6 used
+	mr.HSet("user:full", "tokens", "10", "capacity", "10")    // 0 used
+	mr.HSet("user:leaky", "level", "3.5", "last_leak", "100") // 3 queued
+	for _, member := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		if _, err := mr.ZAdd("user:window", 1, member); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mr.Set("{user:window}:counter", "8") // not a limiter key
+}
+
+func TestCollectRanksBusiestKeys(t *testing.T) {
+	c, mr := newTestCollector(t, func(cfg *config.Config) {
+		cfg.SnapshotTopN = 2
+	})
+	seed(t, mr)
+
+	snap, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if snap.ActiveKeys != 4 {
+		t.Errorf("ActiveKeys = %d, want 4", snap.ActiveKeys)
+	}
+	if snap.CapacityInUse != 17 {
+		t.Errorf("CapacityInUse = %d, want 17", snap.CapacityInUse)
+	}
+	want := []KeyUsage{
+		{Key: "user:window", Algorithm: "sliding_window", Used: 8},
+		{Key: "user:bucket", Algorithm: "token_bucket", Used: 6},
+	}
+	if !reflect.DeepEqual(snap.TopKeys, want) {
+		t.Errorf("TopKeys = %+v, want %+v", snap.TopKeys, want)
+	}
+}
+
+func TestCollectClassifiesOnlyPrefixedLimitKeys(t *testing.T) {
+	c, mr := newTestCollector(t, func(cfg *config.Config) {
+		cfg.RedisKeyPrefix = "rl"
+	})
+	mr.HSet("rl:bucket", "tokens", "7", "capacity", "10")                                // 3 used
+	mr.HSet("rl:counter", "window", "29459", "curr", "5", "prev", "2", "capacity", "10") // 5 this window
+	mr.Set("rl:gcra", "1767225601500")                                                   // GCRA TAT
+	mr.Set("rl:quota:2026-01-01", "9")                                                   // 9 used today
+	mr.Set("rl:monthly:2026-01", "4")                                                    // 4 used this month
+	if _, err := mr.ZAdd("rl:window", 1, "1:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Companions, bookkeeping and other apps' keys are none of the snapshot's business
+	if _, err := mr.ZAdd("rl:{user:1}:sec:leases", 2, "9f1c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mr.ZAdd("{rl:user:2}:leases", 2, "9f1d"); err != nil {
+		t.Fatal(err)
+	}
+	mr.Set("rl:{user:1}:sec:counter", "12")
+	mr.Set("rl:{user:1}:sec:req:3f2a9c", "1:4:0:0")
+	mr.HSet("rl:{user:1}:sec:decisions", "allowed", "40")
+	mr.HSet("rl:adaptive", "factor", "0.5")
+	mr.SetAdd("rl:source_keys:api-key-1", "rl:bucket")
+	mr.HSet("other-app:bucket", "tokens", "0", "capacity", "100")
+	if _, err := mr.ZAdd("other-app:window", 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	got := make(map[string]KeyUsage, len(snap.TopKeys))
+	for _, u := range snap.TopKeys {
+		got[u.Key] = u
+	}
+	want := map[string]KeyUsage{
+		"rl:bucket":           {Key: "rl:bucket", Algorithm: "token_bucket", Used: 3},
+		"rl:counter":          {Key: "rl:counter", Algorithm: "sliding_window_counter", Used: 5},
+		"rl:gcra":             {Key: "rl:gcra", Algorithm: "gcra", Used: 0},
+		"rl:quota:2026-01-01": {Key: "rl:quota:2026-01-01", Algorithm: "quota", Used: 9},
+		"rl:monthly:2026-01":  {Key: "rl:monthly:2026-01", Algorithm: "quota", Used: 4},
+		"rl:window":           {Key: "rl:window", Algorithm: "sliding_window", Used: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TopKeys = %+v, want %+v", got, want)
+	}
+	if snap.ActiveKeys != 6 || snap.CapacityInUse != 22 {
+		t.Errorf("ActiveKeys %d CapacityInUse %d, want 6 and 22", snap.ActiveKeys, snap.CapacityInUse)
+	}
+}
+
+func TestFileSinkAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.jsonl")
+	c, mr := newTestCollector(t, func(cfg *config.Config) {
+		cfg.SnapshotSink = "file"
+		cfg.SnapshotFile = path
+	})
+	seed(t, mr)
+
+	for i := 0; i < 2; i++ {
+		snap, err := c.Collect(context.Background())
+		if err != nil {
+			t.Fatalf("Collect: %v", err)
+		}
+		if err := c.sink.Write(context.Background(), snap); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2", len(lines))
+	}
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(lines[1]), &snap); err != nil {
+		t.Fatalf("decoding %q: %v", lines[1], err)
+	}
+	if len(snap.TopKeys) == 0 || snap.TopKeys[0].Key != "user:window" {
+		t.Errorf("TopKeys = %+v, want user:window first", snap.TopKeys)
+	}
+}
+
+func TestNewCollectorRejectsUnknownSink(t *testing.T) {
+	cfg := config.Load()
+	cfg.SnapshotSink = "kafka"
+	if _, err := NewCollector(nil, cfg); err == nil {
+		t.Error("NewCollector accepted an unknown sink")
+	}
+
+	cfg.SnapshotSink = "file"
+	if _, err := NewCollector(nil, cfg); err == nil {
+		t.Error("NewCollector accepted the file sink without SNAPSHOT_FILE")
+	}
+}
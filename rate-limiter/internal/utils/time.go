@@ -1,6 +1,49 @@
 package utils
 
-import "time"
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is where the limiters get "now" from
+// Swapping it lets tests step time forward instead of sleeping, and lets every instance
+// share Redis's idea of the time rather than its own
+type Clock interface {
+	NowMillis() int64
+}
+
+// SystemClock is this machine's clock
+type SystemClock struct{}
+
+func (SystemClock) NowMillis() int64 {
+	return NowMillis()
+}
+
+// ManualClock only moves when told to - for tests that need refills without sleeping
+type ManualClock struct {
+	ms atomic.Int64
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	c := &ManualClock{}
+	c.ms.Store(start.UnixMilli())
+	return c
+}
+
+func (c *ManualClock) NowMillis() int64 {
+	return c.ms.Load()
+}
+
+// Set moves the clock to t
+func (c *ManualClock) Set(t time.Time) {
+	c.ms.Store(t.UnixMilli())
+}
+
+// Advance moves the clock forward by d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.ms.Add(d.Milliseconds())
+}
 
 // NowMillis returns current Unix timestamp in milliseconds
 // Using milliseconds instead of seconds for better precision in token bucket refills
@@ -8,9 +51,25 @@ func NowMillis() int64 {
 	return time.Now().UnixMilli()
 }
 
-// NowSeconds returns current Unix timestamp in seconds
-// Used for sliding window where second-level precision is enough
-func NowSeconds() int64 {
-	return time.Now().Unix()
+
+type nowOverrideKey struct{}
+
+// WithNowMillis pins "now" for everything downstream of ctx
+// Only used for client-supplied timestamps in testing mode - never set in production
+func WithNowMillis(ctx context.Context, ms int64) context.Context {
+	return context.WithValue(ctx, nowOverrideKey{}, ms)
 }
 
+// PinnedNowMillis returns the timestamp pinned by WithNowMillis, if any
+func PinnedNowMillis(ctx context.Context) (int64, bool) {
+	ms, ok := ctx.Value(nowOverrideKey{}).(int64)
+	return ms, ok
+}
+
+// NowMillisCtx returns the pinned timestamp from ctx if present, otherwise clock's time
+func NowMillisCtx(ctx context.Context, clock Clock) int64 {
+	if ms, ok := PinnedNowMillis(ctx); ok {
+		return ms
+	}
+	return clock.NowMillis()
+}